@@ -0,0 +1,187 @@
+/*
+Package admin contains the admin HTTP server of a service. It is meant to listen on a local or otherwise protected address
+separate from the public API and exposes net/http/pprof, goroutine dumps, the build and resource information,
+the redacted configuration and the levels of the loggers.
+Every request has to be authenticated with a bearer token or a client certificate.
+*/
+package admin
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/logging"
+)
+
+// DefaultAddr is the default address of the admin server, so it is only reachable from the host or pod.
+const DefaultAddr string = "127.0.0.1"
+
+// DefaultPort is the default port of the admin server.
+const DefaultPort int = 6060
+
+// adminReadHeaderTimeout limits how long the admin listener waits for request headers.
+const adminReadHeaderTimeout = 10 * time.Second
+
+// errNoAuth is returned by NewServer if neither a token nor a client CA is configured.
+var errNoAuth = errors.New("admin server requires a token or a client CA")
+
+// Config is a struct that represents the configuration of the admin server.
+type Config struct {
+	// Addr is the address to listen on. If empty, DefaultAddr is used.
+	Addr string
+	// Port is the port to listen on. If zero, DefaultPort is used.
+	Port int
+	// Token is the bearer token the requests have to be authenticated with.
+	Token string
+	// TokenFile is a file holding the token, e.g. a mounted secret. It is used if Token is empty.
+	TokenFile string
+	// CertFile and KeyFile are the server certificate and key. If set, the server is served with TLS.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the CA the client certificates have to be signed by. If set, the requests are authenticated with client certificates (mTLS).
+	ClientCAFile string
+}
+
+// withDefaults returns the config with the defaults applied to the unset fields.
+func (c Config) withDefaults() Config {
+	if c.Addr == "" {
+		c.Addr = DefaultAddr
+	}
+	if c.Port == 0 {
+		c.Port = DefaultPort
+	}
+	return c
+}
+
+// Sources is a struct that holds the state of the service exposed by the admin server. All fields are optional.
+type Sources struct {
+	// Resource is the OpenTelemetry resource of the service.
+	Resource *sdkresource.Resource
+	// Config is the effective config of the service. It is redacted with config.Redact before it is served.
+	Config any
+	// Levels are the levels of the loggers of the service.
+	Levels *logging.Levels
+}
+
+/*
+NewServer returns the admin server. The server is not started, call ListenAndServe or, if Config.CertFile is set, ListenAndServeTLS("", "") on it.
+
+The following endpoints are served:
+  - /debug/pprof/: The net/http/pprof profiles.
+  - /debug/goroutines: A dump of the stacks of all goroutines.
+  - /info: The build information and the resource attributes as JSON.
+  - /config: The redacted config as JSON.
+  - /loglevels: GET returns the levels of the loggers, PUT /loglevels/{name}?level=debug sets a level.
+
+Parameters:
+  - config: The configuration of the admin server.
+  - sources: The state of the service exposed by the server.
+
+Returns:
+  - *http.Server: The created server.
+  - error: An error if neither a token nor a client CA is configured or the token, certificate or CA could not be read.
+
+Example usage:
+
+	srv, err := admin.NewServer(admin.Config{TokenFile: "/run/secrets/admin-token"}, admin.Sources{Config: cfg, Levels: levels})
+	if err != nil {
+		return err
+	}
+	go srv.ListenAndServe()
+*/
+func NewServer(config Config, sources Sources) (*http.Server, error) {
+	config = config.withDefaults()
+	token, err := readToken(config)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" && config.ClientCAFile == "" {
+		return nil, errNoAuth
+	}
+
+	srv := &http.Server{
+		Addr:              net.JoinHostPort(config.Addr, strconv.Itoa(config.Port)),
+		Handler:           authenticate(token, newMux(sources)),
+		ReadHeaderTimeout: adminReadHeaderTimeout,
+	}
+	if config.CertFile != "" || config.KeyFile != "" || config.ClientCAFile != "" {
+		tlsConfig, err := newTLSConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+	return srv, nil
+}
+
+// readToken returns the token of config, reading it from the token file if set.
+func readToken(config Config) (string, error) {
+	if config.Token != "" || config.TokenFile == "" {
+		return config.Token, nil
+	}
+	b, err := os.ReadFile(config.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("reading admin token: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// newTLSConfig returns the TLS config of the server, requiring client certificates signed by the client CA if set.
+func newTLSConfig(config Config) (*tls.Config, error) {
+	switch {
+	case config.CertFile == "" && config.KeyFile == "":
+		return nil, errors.New("admin server with a client CA requires a certificate and key")
+	case config.CertFile == "" || config.KeyFile == "":
+		return nil, errors.New("admin certificate and key must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading admin certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if config.ClientCAFile != "" {
+		pem, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading admin client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates in admin client CA")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// authenticate wraps next so requests without a verified client certificate have to present the bearer token.
+func authenticate(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the TLS handshake already verified the certificate against the client CA
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,273 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/logging"
+)
+
+const testToken string = "admin-token"
+
+// testCert is a certificate and its key.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		template.ExtKeyUsage = nil
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// write writes the certificate and key as PEM files to dir and returns their paths.
+func (c *testCert) write(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, c.cert.Subject.CommonName+".crt")
+	keyFile := filepath.Join(dir, c.cert.Subject.CommonName+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// tlsCertificate returns the certificate for a tls.Config.
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// serve serves srv with httptest and returns the URL of the server.
+func serve(t *testing.T, srv *http.Server) string {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(srv.Handler)
+	if srv.TLSConfig != nil {
+		ts.TLS = srv.TLSConfig
+		ts.StartTLS()
+	} else {
+		ts.Start()
+	}
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+// request sends a request with method to path of handler, authenticated with the token.
+func request(handler http.Handler, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewServerValidatesConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, 0)
+	caFile, _ := ca.write(t, dir)
+	certFile, keyFile := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth).write(t, dir)
+
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{name: "no auth", config: Config{}, want: errNoAuth.Error()},
+		{name: "missing token file", config: Config{TokenFile: filepath.Join(dir, "missing")}, want: "reading admin token"},
+		{name: "client CA without certificate", config: Config{ClientCAFile: caFile}, want: "requires a certificate and key"},
+		{name: "certificate without key", config: Config{Token: testToken, CertFile: certFile}, want: "must be set together"},
+		{name: "key without certificate", config: Config{Token: testToken, KeyFile: keyFile}, want: "must be set together"},
+		{name: "key of other certificate", config: Config{Token: testToken, CertFile: caFile, KeyFile: keyFile}, want: "loading admin certificate"},
+		{name: "invalid client CA", config: Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, want: "no certificates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewServer(tt.config, Sources{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte(testToken+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewServer(Config{TokenFile: tokenFile}, Sources{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Addr != "127.0.0.1:6060" {
+		t.Errorf("got address %q", srv.Addr)
+	}
+	for name, header := range map[string]string{
+		"missing token": "",
+		"wrong token":   "Bearer wrong",
+		"basic auth":    "Basic " + testToken,
+	} {
+		req := httptest.NewRequest(http.MethodGet, InfoPath, nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: got %d", name, rec.Code)
+		}
+	}
+	if rec := request(srv.Handler, http.MethodGet, InfoPath); rec.Code != http.StatusOK {
+		t.Errorf("got %d with the token", rec.Code)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, 0)
+	caFile, _ := ca.write(t, dir)
+	certFile, keyFile := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth).write(t, dir)
+	srv, err := NewServer(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, Sources{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, srv)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	resp, err := client(newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth).tlsCertificate()).Get(url + InfoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got %d with a client certificate", resp.StatusCode)
+	}
+
+	// certificates of other CAs and requests without a certificate fail the handshake
+	other := newTestCert(t, "other", nil, 0)
+	for name, c := range map[string]*http.Client{
+		"no certificate":    client(),
+		"other certificate": client(newTestCert(t, "client", other, x509.ExtKeyUsageClientAuth).tlsCertificate()),
+	} {
+		if resp, err := c.Get(url + InfoPath); err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: got %d", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	type database struct {
+		Host     string `mapstructure:"host"`
+		Password string `mapstructure:"password"`
+	}
+	srv, err := NewServer(Config{Token: testToken}, Sources{Config: &struct {
+		Database database `mapstructure:"database"`
+	}{Database: database{Host: "db", Password: "hunter2"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := request(srv.Handler, http.MethodGet, ConfigPath)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d", rec.Code)
+	}
+	var got map[string]map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if db := got["database"]; db["host"] != "db" || db["password"] != "REDACTED" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestLogLevelsEndpoint(t *testing.T) {
+	levels := logging.NewLevels()
+	levels.Var("stdout", "info")
+	srv, err := NewServer(Config{Token: testToken}, Sources{Levels: levels})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{method: http.MethodGet, path: LogLevelsPath, status: http.StatusOK},
+		{method: http.MethodPut, path: LogLevelsPath + "/stdout?level=debug", status: http.StatusOK},
+		{method: http.MethodPut, path: LogLevelsPath + "/stdout?level=trace", status: http.StatusBadRequest},
+		{method: http.MethodPut, path: LogLevelsPath + "/otel?level=debug", status: http.StatusBadRequest},
+		{method: http.MethodPut, path: LogLevelsPath + "?level=debug", status: http.StatusBadRequest},
+		{method: http.MethodDelete, path: LogLevelsPath + "/stdout", status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec := request(srv.Handler, tt.method, tt.path); rec.Code != tt.status {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+	}
+	if got := levels.Get()["stdout"]; got != "DEBUG" {
+		t.Errorf("got level %s, want DEBUG", got)
+	}
+
+	srv, err = NewServer(Config{Token: testToken}, Sources{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := request(srv.Handler, http.MethodGet, LogLevelsPath); rec.Code != http.StatusNotFound {
+		t.Errorf("got %d without levels", rec.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+	"strings"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/buildinfo"
+	"github.com/SaimonWoidig/cc-microsvcs/common/config"
+	"github.com/SaimonWoidig/cc-microsvcs/common/logging"
+)
+
+// The paths of the admin endpoints.
+const (
+	PprofPath      string = "/debug/pprof/"
+	GoroutinesPath string = "/debug/goroutines"
+	InfoPath       string = "/info"
+	ConfigPath     string = "/config"
+	LogLevelsPath  string = "/loglevels"
+)
+
+// info is the response of InfoPath.
+type info struct {
+	Build    buildinfo.Info    `json:"build"`
+	Resource map[string]string `json:"resource,omitempty"`
+}
+
+// newMux returns the handler of the admin endpoints.
+func newMux(sources Sources) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PprofPath, pprof.Index)
+	mux.HandleFunc(PprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(PprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(PprofPath+"trace", pprof.Trace)
+	mux.HandleFunc(GoroutinesPath, handleGoroutines)
+	mux.HandleFunc(InfoPath, func(w http.ResponseWriter, r *http.Request) {
+		resp := info{Build: buildinfo.Get()}
+		if sources.Resource != nil {
+			resp.Resource = make(map[string]string, sources.Resource.Len())
+			for _, kv := range sources.Resource.Attributes() {
+				resp.Resource[string(kv.Key)] = kv.Value.Emit()
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	mux.HandleFunc(ConfigPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, config.Redact(sources.Config))
+	})
+	levels := &levelsHandler{levels: sources.Levels}
+	mux.Handle(LogLevelsPath, levels)
+	mux.Handle(LogLevelsPath+"/", levels)
+	return mux
+}
+
+// handleGoroutines writes the stacks of all goroutines in the format of an unrecovered panic.
+func handleGoroutines(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// levelsHandler serves the levels of the loggers.
+type levelsHandler struct {
+	levels *logging.Levels
+}
+
+// ServeHTTP returns the levels on GET and sets the level named by the last path segment to the level query parameter on PUT.
+func (h *levelsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.levels == nil {
+		http.Error(w, "log levels are not available", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.levels.Get())
+	case http.MethodPut, http.MethodPost:
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, LogLevelsPath), "/")
+		if name == "" {
+			http.Error(w, "missing logger name", http.StatusBadRequest)
+			return
+		}
+		if err := h.levels.Set(name, r.URL.Query().Get("level")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.levels.Get())
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// writeJSON writes v as indented JSON with status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,91 @@
+/*
+Package buildinfo contains the version, VCS revision and build time of the running binary.
+The values are read from the linker flags if set, otherwise from the build information embedded by the Go toolchain.
+
+Example usage:
+
+	go build -ldflags "-X github.com/SaimonWoidig/cc-microsvcs/common/buildinfo.version=1.2.3 -X github.com/SaimonWoidig/cc-microsvcs/common/buildinfo.buildTime=2024-02-01T12:00:00Z" ./service.auth
+*/
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DevVersion is the version of binaries built without a version, e.g. with go run.
+const DevVersion string = "dev"
+
+// The values set with the -X linker flag. They take precedence over the embedded build information.
+var (
+	version   string
+	revision  string
+	buildTime string
+)
+
+// Info is a struct that represents the build metadata of the running binary.
+type Info struct {
+	// Version is the version of the binary, e.g. "1.2.3", or DevVersion if unknown.
+	Version string `json:"version"`
+	// Revision is the VCS revision the binary was built from. Empty if unknown.
+	Revision string `json:"revision,omitempty"`
+	// Modified reports whether the working tree had uncommitted changes when the binary was built.
+	Modified bool `json:"modified,omitempty"`
+	// BuildTime is the time the binary was built, or of the VCS revision if the build time was not set. Nil if unknown.
+	BuildTime *time.Time `json:"buildTime,omitempty"`
+	// GoVersion is the version of the Go toolchain the binary was built with.
+	GoVersion string `json:"goVersion"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build metadata of the running binary. The metadata is read once and cached.
+func Get() Info {
+	once.Do(func() {
+		info = read()
+	})
+	return info
+}
+
+// read collects the build metadata from the linker flags and the embedded build information.
+func read() Info {
+	i := Info{
+		Version:   DevVersion,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if v := bi.Main.Version; v != "" && v != "(devel)" {
+			i.Version = v
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				i.Revision = s.Value
+			case "vcs.time":
+				if t, err := time.Parse(time.RFC3339, s.Value); err == nil {
+					i.BuildTime = &t
+				}
+			case "vcs.modified":
+				i.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if version != "" {
+		i.Version = version
+	}
+	if revision != "" {
+		i.Revision = revision
+	}
+	if buildTime != "" {
+		if t, err := time.Parse(time.RFC3339, buildTime); err == nil {
+			i.BuildTime = &t
+		}
+	}
+	return i
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Redacted replaces the values of sensitive config fields in the output of Redact.
+const Redacted string = "REDACTED"
+
+// sensitiveNames are the lowercased substrings of field names whose values are redacted.
+var sensitiveNames = []string{"password", "secret", "token", "privatekey", "apikey", "credential", "passphrase"}
+
+/*
+Redact returns a copy of the config v safe to expose, e.g. on the admin server.
+Structs are converted to maps keyed by their mapstructure names. The values of fields tagged `redact:"true"`
+or whose name contains a sensitive word like "password", "secret" or "token" are replaced with Redacted.
+
+Parameters:
+  - v: The config, usually a pointer to the config struct of a service.
+
+Returns:
+  - any: The redacted copy of v built from maps, slices and the original scalar values.
+
+Example usage:
+
+	json.NewEncoder(w).Encode(config.Redact(cfg))
+*/
+func Redact(v any) any {
+	return redactValue(reflect.ValueOf(v))
+}
+
+// redactValue returns the redacted copy of v.
+func redactValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Struct:
+		t := v.Type()
+		m := make(map[string]any, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := fieldName(f)
+			if sensitive(f) && !v.Field(i).IsZero() {
+				m[name] = Redacted
+				continue
+			}
+			m[name] = redactValue(v.Field(i))
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		s := make([]any, v.Len())
+		for i := range s {
+			s[i] = redactValue(v.Index(i))
+		}
+		return s
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if sensitiveName(key) {
+				m[key] = Redacted
+				continue
+			}
+			m[key] = redactValue(iter.Value())
+		}
+		return m
+	default:
+		return v.Interface()
+	}
+}
+
+// fieldName returns the mapstructure name of f, or its Go name if it has none.
+func fieldName(f reflect.StructField) string {
+	if tag, ok := f.Tag.Lookup("mapstructure"); ok {
+		if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// sensitive reports whether the value of f has to be redacted.
+func sensitive(f reflect.StructField) bool {
+	if f.Tag.Get("redact") == "true" {
+		return true
+	}
+	return sensitiveName(f.Name)
+}
+
+// sensitiveName reports whether name contains a sensitive word. Names of paths, e.g. "TokenFile", are not sensitive.
+func sensitiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, suffix := range []string{"file", "path", "dir"} {
+		if strings.HasSuffix(lower, suffix) {
+			return false
+		}
+	}
+	for _, s := range sensitiveNames {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	type tls struct {
+		CertFile string `mapstructure:"certFile"`
+		KeyFile  string `mapstructure:"keyFile"`
+	}
+	type server struct {
+		Port      int    `mapstructure:"port"`
+		Token     string `mapstructure:"token"`
+		TokenFile string `mapstructure:"tokenFile"`
+		TLS       *tls   `mapstructure:"tls"`
+	}
+	type config struct {
+		Server     server            `mapstructure:"server"`
+		DSN        string            `mapstructure:"dsn" redact:"true"`
+		APIKey     string            `mapstructure:"apiKey"`
+		Secret     string            `mapstructure:"secret"`
+		Headers    map[string]string `mapstructure:"headers"`
+		Hosts      []string          `mapstructure:"hosts"`
+		Disabled   *server           `mapstructure:"disabled"`
+		NoTag      bool
+		unexported string
+	}
+	cfg := &config{
+		Server:     server{Port: 8080, Token: "t0ken", TokenFile: "/run/secrets/token", TLS: &tls{CertFile: "tls.crt", KeyFile: "tls.key"}},
+		DSN:        "postgres://user:pass@db",
+		APIKey:     "key",
+		Headers:    map[string]string{"Authorization-Token": "abc", "X-Tenant": "acme"},
+		Hosts:      []string{"a", "b"},
+		NoTag:      true,
+		unexported: "hidden",
+	}
+	want := map[string]any{
+		"server": map[string]any{
+			"port":      8080,
+			"token":     Redacted,
+			"tokenFile": "/run/secrets/token",
+			"tls":       map[string]any{"certFile": "tls.crt", "keyFile": "tls.key"},
+		},
+		"dsn":    Redacted,
+		"apiKey": Redacted,
+		// empty sensitive values are kept, so it is visible they are unset
+		"secret":   "",
+		"headers":  map[string]any{"Authorization-Token": Redacted, "X-Tenant": "acme"},
+		"hosts":    []any{"a", "b"},
+		"disabled": nil,
+		"NoTag":    true,
+	}
+	if got := Redact(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	// the config is not modified
+	if cfg.Server.Token != "t0ken" || cfg.Headers["Authorization-Token"] != "abc" {
+		t.Errorf("config modified: %+v", cfg)
+	}
+}
+
+func TestSensitiveName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"Password":       true,
+		"clientSecret":   true,
+		"PrivateKey":     true,
+		"privateKeyFile": false,
+		"TokenPath":      false,
+		"SecretDir":      false,
+		"Username":       false,
+	} {
+		if got := sensitiveName(name); got != want {
+			t.Errorf("%s: got %t, want %t", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cachedChecker runs a Checker with a timeout and reuses its result for a TTL.
+type cachedChecker struct {
+	checker Checker
+	ttl     time.Duration
+	timeout time.Duration
+
+	// mu is held while the check runs, so concurrent probes wait for a single run instead of starting their own.
+	mu     sync.Mutex
+	result Result
+	valid  bool
+}
+
+// newCachedChecker wraps c.
+func newCachedChecker(c Checker, ttl time.Duration, timeout time.Duration) *cachedChecker {
+	return &cachedChecker{checker: c, ttl: ttl, timeout: timeout}
+}
+
+// check returns the cached result if it is younger than the TTL, otherwise it runs the check.
+func (c *cachedChecker) check(ctx context.Context) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.valid && time.Since(c.result.CheckedAt) < c.ttl {
+		return c.result
+	}
+
+	// the result is shared by the following probes, so the check must not be canceled with the probe which ran it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+	begin := time.Now()
+	err := c.checker.Check(ctx)
+	c.result = Result{
+		Name:      c.checker.Name(),
+		Status:    StatusOK,
+		Duration:  time.Since(begin).String(),
+		CheckedAt: begin,
+	}
+	if err != nil {
+		c.result.Status = StatusFail
+		c.result.Error = err.Error()
+	}
+	c.valid = true
+	return c.result
+}
@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+)
+
+/*
+NewTCPCheck returns a Checker which passes if a TCP connection to addr can be established, e.g. to check the OTLP collector is reachable.
+
+Parameters:
+  - name: The name of the check, e.g. "otlp".
+  - addr: The address to connect to, either "host:port" or a URL like "http://otlp:4318/v1/traces".
+
+Returns:
+  - Checker: The created check.
+
+Example usage:
+
+	h.AddReadinessCheck(health.NewTCPCheck("otlp", cfg.Telemetry.Tracing.OTLPEndpoint))
+*/
+func NewTCPCheck(name string, addr string) Checker {
+	return NewCheck(name, func(ctx context.Context) error {
+		hostPort, err := dialAddr(addr)
+		if err != nil {
+			return err
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", hostPort)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// dialAddr returns the host:port of addr, which is either a host:port or a URL.
+func dialAddr(addr string) (string, error) {
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		// not a URL, e.g. "otlp:4318"
+		return addr, nil
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	switch u.Scheme {
+	case "https":
+		return net.JoinHostPort(u.Hostname(), "443"), nil
+	case "http":
+		return net.JoinHostPort(u.Hostname(), "80"), nil
+	default:
+		return "", errors.New("no port in address " + addr)
+	}
+}
+
+// Pinger is the interface implemented by connections which can be pinged, e.g. *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// NewPingCheck returns a Checker which passes if p can be pinged, e.g. to check the database is reachable.
+func NewPingCheck(name string, p Pinger) Checker {
+	return NewCheck(name, p.PingContext)
+}
+
+// SigningKeySource is the interface implemented by the providers of the active token signing key.
+type SigningKeySource interface {
+	// ActiveKeyID returns the ID of the key new tokens are signed with, or an error if there is none.
+	ActiveKeyID(ctx context.Context) (string, error)
+}
+
+// errNoSigningKey is the error of the signing key check if the source returned an empty key ID.
+var errNoSigningKey = errors.New("no active signing key")
+
+// NewSigningKeyCheck returns a Checker which passes if keys has an active signing key, so the service can issue tokens.
+func NewSigningKeyCheck(name string, keys SigningKeySource) Checker {
+	return NewCheck(name, func(ctx context.Context) error {
+		kid, err := keys.ActiveKeyID(ctx)
+		if err != nil {
+			return err
+		}
+		if kid == "" {
+			return errNoSigningKey
+		}
+		return nil
+	})
+}
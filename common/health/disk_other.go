@@ -0,0 +1,15 @@
+//go:build !unix
+
+package health
+
+import (
+	"context"
+	"errors"
+)
+
+// NewDiskSpaceCheck returns a Checker which always fails, as the available disk space is only checked on Unix systems.
+func NewDiskSpaceCheck(name string, path string, minFreeBytes uint64) Checker {
+	return NewCheck(name, func(_ context.Context) error {
+		return errors.New("disk space check is not supported on this platform")
+	})
+}
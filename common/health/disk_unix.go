@@ -0,0 +1,35 @@
+//go:build unix
+
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+NewDiskSpaceCheck returns a Checker which passes if the filesystem of path has at least minFreeBytes available, e.g. for the telemetry queue or database.
+
+Parameters:
+  - name: The name of the check, e.g. "disk".
+  - path: A path on the checked filesystem.
+  - minFreeBytes: The minimum number of bytes available to unprivileged users.
+
+Returns:
+  - Checker: The created check.
+*/
+func NewDiskSpaceCheck(name string, path string, minFreeBytes uint64) Checker {
+	return NewCheck(name, func(_ context.Context) error {
+		var st unix.Statfs_t
+		if err := unix.Statfs(path, &st); err != nil {
+			return err
+		}
+		free := uint64(st.Bavail) * uint64(st.Bsize)
+		if free < minFreeBytes {
+			return fmt.Errorf("%d bytes available on %s, need %d", free, path, minFreeBytes)
+		}
+		return nil
+	})
+}
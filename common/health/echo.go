@@ -0,0 +1,60 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// The paths the probes are served on.
+const (
+	LivezPath   string = "/livez"
+	ReadyzPath  string = "/readyz"
+	HealthzPath string = "/healthz"
+)
+
+// verboseParam is the query parameter requesting the results of the individual checks, e.g. /readyz?verbose=true.
+const verboseParam string = "verbose"
+
+// Router is the interface implemented by *echo.Echo and *echo.Group.
+type Router interface {
+	GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
+}
+
+/*
+RegisterRoutes serves the probes of h on LivezPath, ReadyzPath and HealthzPath of r.
+The probes respond with 200 if all checks pass and with 503 otherwise. The body is a JSON Report,
+containing the results of the individual checks only if the verbose query parameter is set, e.g. /readyz?verbose=true.
+
+Parameters:
+  - r: The Echo instance or group the probes are registered on.
+  - h: The checks the probes run.
+
+Example usage:
+
+	h := health.New(health.Config{})
+	h.AddReadinessCheck(health.NewPingCheck("database", db))
+	health.RegisterRoutes(e, h)
+*/
+func RegisterRoutes(r Router, h *Health) {
+	r.GET(LivezPath, handler(h.Live))
+	r.GET(ReadyzPath, handler(h.Ready))
+	r.GET(HealthzPath, handler(h.Health))
+}
+
+// handler returns the echo.HandlerFunc responding with the report of probe.
+func handler(probe func(ctx context.Context) Report) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		report := probe(c.Request().Context())
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		if verbose, _ := strconv.ParseBool(c.QueryParam(verboseParam)); !verbose {
+			report.Checks = nil
+		}
+		return c.JSON(code, report)
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Package health contains the liveness and readiness probes of a service.
+Checks implement the Checker interface and are registered as liveness, readiness or informational checks of a Health.
+Their results are cached, so frequent probes do not overload the checked dependencies.
+*/
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is the default duration the result of a check is reused for.
+	DefaultCacheTTL = 5 * time.Second
+	// DefaultTimeout is the default duration a check may run for before it fails.
+	DefaultTimeout = 2 * time.Second
+)
+
+// Status is the result of a check or a set of checks.
+type Status string
+
+const (
+	// StatusOK is the status of a passing check.
+	StatusOK Status = "ok"
+	// StatusFail is the status of a failing check.
+	StatusFail Status = "fail"
+)
+
+// errNotReady is the error of the readiness result while the service is starting or shutting down.
+var errNotReady = errors.New("service is starting or shutting down")
+
+// Checker is the interface implemented by the health checks.
+type Checker interface {
+	// Name returns the name of the check, e.g. "database".
+	Name() string
+	// Check returns an error if the checked dependency is unhealthy. It has to return once ctx is done.
+	Check(ctx context.Context) error
+}
+
+// checkerFunc is a Checker calling a function.
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewCheck returns a Checker named name calling fn.
+func NewCheck(name string, fn func(ctx context.Context) error) Checker {
+	return &checkerFunc{name: name, fn: fn}
+}
+
+// Name implements Checker.
+func (c *checkerFunc) Name() string {
+	return c.name
+}
+
+// Check implements Checker.
+func (c *checkerFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// Result is a struct that represents the result of a single check.
+type Result struct {
+	// Name is the name of the check.
+	Name string `json:"name"`
+	// Status is the status of the check.
+	Status Status `json:"status"`
+	// Error is the error of a failing check.
+	Error string `json:"error,omitempty"`
+	// Duration is the duration of the check.
+	Duration string `json:"duration"`
+	// CheckedAt is the time the check ran. It is older than the request if the result was cached.
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Report is a struct that represents the results of a set of checks.
+type Report struct {
+	// Status is StatusOK if all checks passed.
+	Status Status `json:"status"`
+	// Checks are the results of the individual checks.
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Config is a struct that represents the configuration of a Health.
+type Config struct {
+	// CacheTTL is the duration the result of a check is reused for. If zero, DefaultCacheTTL is used.
+	CacheTTL time.Duration
+	// Timeout is the duration a check may run for before it fails. If zero, DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// withDefaults returns the config with the defaults applied to the unset fields.
+func (c Config) withDefaults() Config {
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = DefaultCacheTTL
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	return c
+}
+
+// Health holds the liveness and readiness checks of a service.
+type Health struct {
+	config Config
+	ready  atomic.Bool
+
+	mu        sync.RWMutex
+	liveness  []*cachedChecker
+	readiness []*cachedChecker
+	checks    []*cachedChecker
+}
+
+// New creates a Health without checks. It is not ready until SetReady(true) is called.
+func New(config Config) *Health {
+	return &Health{config: config.withDefaults()}
+}
+
+// AddLivenessCheck registers c as a liveness check. A failing liveness check means the service has to be restarted.
+func (h *Health) AddLivenessCheck(c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, newCachedChecker(c, h.config.CacheTTL, h.config.Timeout))
+}
+
+// AddReadinessCheck registers c as a readiness check. A failing readiness check means the service must not receive traffic.
+func (h *Health) AddReadinessCheck(c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, newCachedChecker(c, h.config.CacheTTL, h.config.Timeout))
+}
+
+// AddCheck registers c as a check only reported by Health, e.g. for dependencies the service keeps serving without, like the OTLP collector.
+func (h *Health) AddCheck(c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, newCachedChecker(c, h.config.CacheTTL, h.config.Timeout))
+}
+
+// SetReady sets whether the service has started and is not shutting down. The service is only ready if it is set and all readiness checks pass.
+func (h *Health) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Live runs the liveness checks.
+func (h *Health) Live(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.liveness
+	h.mu.RUnlock()
+	return run(ctx, checks)
+}
+
+// Ready runs the readiness checks. The report fails without running the checks if the service is not set ready.
+func (h *Health) Ready(ctx context.Context) Report {
+	if !h.ready.Load() {
+		return Report{Status: StatusFail, Checks: []Result{{
+			Name:      "ready",
+			Status:    StatusFail,
+			Error:     errNotReady.Error(),
+			Duration:  "0s",
+			CheckedAt: time.Now(),
+		}}}
+	}
+	h.mu.RLock()
+	checks := h.readiness
+	h.mu.RUnlock()
+	return run(ctx, checks)
+}
+
+// Health runs the liveness, readiness and the checks added with AddCheck.
+func (h *Health) Health(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+	report := Report{Status: StatusOK}
+	for _, r := range []Report{h.Live(ctx), h.Ready(ctx), run(ctx, checks)} {
+		report.Checks = append(report.Checks, r.Checks...)
+		if r.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// run runs checks concurrently and returns their results in registration order.
+func run(ctx context.Context, checks []*cachedChecker) Report {
+	report := Report{Status: StatusOK, Checks: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *cachedChecker) {
+			defer wg.Done()
+			report.Checks[i] = c.check(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+	for _, r := range report.Checks {
+		if r.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
@@ -0,0 +1,177 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// countingCheck returns a Checker named name returning the error stored in err and counting its runs in runs.
+func countingCheck(name string, err *atomic.Pointer[error], runs *atomic.Int32) Checker {
+	return NewCheck(name, func(context.Context) error {
+		runs.Add(1)
+		if e := err.Load(); e != nil {
+			return *e
+		}
+		return nil
+	})
+}
+
+func TestCachedChecker(t *testing.T) {
+	var err atomic.Pointer[error]
+	var runs atomic.Int32
+	c := newCachedChecker(countingCheck("db", &err, &runs), 50*time.Millisecond, time.Second)
+
+	if r := c.check(context.Background()); r.Status != StatusOK || r.Name != "db" {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	failed := errors.New("connection refused")
+	err.Store(&failed)
+	// the cached result is reused within the TTL
+	if r := c.check(context.Background()); r.Status != StatusOK || runs.Load() != 1 {
+		t.Fatalf("cached result not reused: %+v after %d runs", r, runs.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	r := c.check(context.Background())
+	if r.Status != StatusFail || r.Error != failed.Error() || runs.Load() != 2 {
+		t.Errorf("expired result reused: %+v after %d runs", r, runs.Load())
+	}
+}
+
+func TestCachedCheckerConcurrentProbes(t *testing.T) {
+	var err atomic.Pointer[error]
+	var runs atomic.Int32
+	slow := NewCheck("slow", func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return countingCheck("slow", &err, &runs).Check(ctx)
+	})
+	c := newCachedChecker(slow, time.Minute, time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.check(context.Background())
+		}()
+	}
+	wg.Wait()
+	if got := runs.Load(); got != 1 {
+		t.Errorf("concurrent probes ran the check %d times, want 1", got)
+	}
+}
+
+func TestCachedCheckerTimeout(t *testing.T) {
+	blocking := NewCheck("blocking", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c := newCachedChecker(blocking, time.Minute, 10*time.Millisecond)
+
+	// the check is bounded by the timeout, not by the context of the probe
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	begin := time.Now()
+	r := c.check(ctx)
+	if r.Status != StatusFail || r.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("got %+v, want a timed out check", r)
+	}
+	if elapsed := time.Since(begin); elapsed < 10*time.Millisecond {
+		t.Errorf("check canceled with the probe after %s", elapsed)
+	}
+}
+
+// probe requests path from e and returns the status code and report.
+func probe(t *testing.T, e *echo.Echo, path string) (int, Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("%s: %v: %s", path, err, rec.Body)
+	}
+	return rec.Code, report
+}
+
+func TestReadinessDuringShutdown(t *testing.T) {
+	h := New(Config{})
+	h.AddReadinessCheck(NewCheck("db", func(context.Context) error { return nil }))
+	e := echo.New()
+	RegisterRoutes(e, h)
+
+	if code, _ := probe(t, e, ReadyzPath); code != http.StatusServiceUnavailable {
+		t.Errorf("starting service: got %d, want 503", code)
+	}
+	h.SetReady(true)
+	if code, report := probe(t, e, ReadyzPath); code != http.StatusOK || report.Status != StatusOK {
+		t.Errorf("started service: got %d %+v, want 200", code, report)
+	}
+
+	// shutting down flips the readiness without waiting for the cached checks
+	h.SetReady(false)
+	code, report := probe(t, e, ReadyzPath+"?verbose=true")
+	if code != http.StatusServiceUnavailable || report.Status != StatusFail {
+		t.Errorf("shutting down service: got %d %+v, want 503", code, report)
+	}
+	if len(report.Checks) != 1 || report.Checks[0].Name != "ready" || report.Checks[0].Error != errNotReady.Error() {
+		t.Errorf("unexpected checks %+v", report.Checks)
+	}
+	// the service stays alive while shutting down
+	if code, _ := probe(t, e, LivezPath); code != http.StatusOK {
+		t.Errorf("livez: got %d, want 200", code)
+	}
+}
+
+func TestRoutesVerbose(t *testing.T) {
+	failed := errors.New("collector unreachable")
+	h := New(Config{})
+	h.AddLivenessCheck(NewCheck("deadlock", func(context.Context) error { return nil }))
+	h.AddReadinessCheck(NewCheck("db", func(context.Context) error { return nil }))
+	h.AddCheck(NewCheck("otlp", func(context.Context) error { return failed }))
+	h.SetReady(true)
+	e := echo.New()
+	RegisterRoutes(e, h)
+
+	for _, tc := range []struct {
+		path   string
+		code   int
+		checks []string
+	}{
+		{LivezPath, http.StatusOK, []string{"deadlock"}},
+		{ReadyzPath, http.StatusOK, []string{"db"}},
+		// the informational checks are only reported by healthz
+		{HealthzPath, http.StatusServiceUnavailable, []string{"deadlock", "db", "otlp"}},
+	} {
+		code, report := probe(t, e, tc.path)
+		if code != tc.code {
+			t.Errorf("%s: got %d, want %d", tc.path, code, tc.code)
+		}
+		if report.Checks != nil {
+			t.Errorf("%s: checks reported without verbose", tc.path)
+		}
+
+		_, report = probe(t, e, tc.path+"?verbose=true")
+		if len(report.Checks) != len(tc.checks) {
+			t.Fatalf("%s?verbose: got checks %+v, want %v", tc.path, report.Checks, tc.checks)
+		}
+		for i, name := range tc.checks {
+			if report.Checks[i].Name != name {
+				t.Errorf("%s?verbose: got check %q at %d, want %q", tc.path, report.Checks[i].Name, i, name)
+			}
+		}
+	}
+
+	_, report := probe(t, e, HealthzPath+"?verbose=1")
+	if otlp := report.Checks[2]; otlp.Status != StatusFail || otlp.Error != failed.Error() {
+		t.Errorf("unexpected result %+v", otlp)
+	}
+}
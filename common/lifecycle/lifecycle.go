@@ -0,0 +1,263 @@
+/*
+Package lifecycle contains the startup and shutdown orchestration of the components of a service.
+Components register hooks with the names of the components they depend on. The hooks are started in dependency order
+and stopped in the reverse order, every phase is timed, logged and recorded as a span.
+*/
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer the spans of the phases are created with.
+const TracerName string = "github.com/SaimonWoidig/cc-microsvcs/common/lifecycle"
+
+// componentKey is the attribute key holding the name of a component.
+const componentKey attribute.Key = "lifecycle.component"
+
+// ErrAlreadyStarted is returned by Start if the lifecycle was already started.
+var ErrAlreadyStarted = errors.New("lifecycle already started")
+
+// Hook is a struct that represents a component of a service.
+type Hook struct {
+	// Name is the unique name of the component, e.g. "telemetry.tracing".
+	Name string
+	// DependsOn are the names of the components which have to be started before and stopped after the component.
+	DependsOn []string
+	// Start starts the component. It is optional.
+	Start func(ctx context.Context) error
+	// Stop stops the component. It is optional and only called if the component was started.
+	Stop func(ctx context.Context) error
+	// StartTimeout bounds Start. If zero, Start is only bounded by the context passed to Lifecycle.Start.
+	StartTimeout time.Duration
+	// StopTimeout bounds Stop. If zero, Stop is only bounded by the context passed to Lifecycle.Stop.
+	StopTimeout time.Duration
+}
+
+// Lifecycle holds the hooks of the components of a service.
+type Lifecycle struct {
+	mu      sync.Mutex
+	logger  *slog.Logger
+	hooks   []Hook
+	names   map[string]struct{}
+	started []Hook
+	running bool
+}
+
+// New creates an empty Lifecycle logging with logger. If logger is nil, nothing is logged until SetLogger is called.
+func New(logger *slog.Logger) *Lifecycle {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return &Lifecycle{
+		logger: logger,
+		names:  make(map[string]struct{}),
+	}
+}
+
+// SetLogger replaces the logger of l, e.g. once the logger component has started.
+func (l *Lifecycle) SetLogger(logger *slog.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger = logger
+}
+
+// Append registers h. It returns an error if h has no name, a component with the same name is registered or l was already started.
+func (l *Lifecycle) Append(h Hook) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h.Name == "" {
+		return errors.New("lifecycle hook without a name")
+	}
+	if _, ok := l.names[h.Name]; ok {
+		return fmt.Errorf("lifecycle hook %q already registered", h.Name)
+	}
+	if l.running {
+		return fmt.Errorf("lifecycle hook %q registered after start: %w", h.Name, ErrAlreadyStarted)
+	}
+	l.names[h.Name] = struct{}{}
+	l.hooks = append(l.hooks, h)
+	return nil
+}
+
+/*
+Start starts the registered components in dependency order. Components without dependencies between them start in registration order.
+If a component fails to start, the already started components are stopped in reverse order and the error is returned.
+
+Parameters:
+  - ctx: The context of the startup. It is passed to the Start hooks.
+
+Returns:
+  - error: An error if a dependency is unknown or cyclic, or the error of the failed component joined with the errors of stopping the started ones.
+
+Example usage:
+
+	lc := lifecycle.New(logger)
+	_ = lc.Append(lifecycle.Hook{Name: "db", Start: db.Open, Stop: db.Close})
+	_ = lc.Append(lifecycle.Hook{Name: "http", DependsOn: []string{"db"}, Start: srv.Start, Stop: srv.Shutdown, StopTimeout: 10 * time.Second})
+	if err := lc.Start(ctx); err != nil {
+		return err
+	}
+	defer lc.Stop(context.Background())
+*/
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	if l.running {
+		l.mu.Unlock()
+		return ErrAlreadyStarted
+	}
+	ordered, err := order(l.hooks)
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	l.running = true
+	l.mu.Unlock()
+
+	ctx, span := otel.Tracer(TracerName).Start(ctx, "lifecycle.start")
+	defer span.End()
+	begin := time.Now()
+	for _, h := range ordered {
+		if err := l.run(ctx, "start", h, h.Start, h.StartTimeout); err != nil {
+			err = fmt.Errorf("starting %s: %w", h.Name, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			// the startup context may be done already, the started components still have to be stopped
+			return errors.Join(err, l.Stop(context.WithoutCancel(ctx)))
+		}
+		l.mu.Lock()
+		l.started = append(l.started, h)
+		l.mu.Unlock()
+	}
+	l.log().Info("lifecycle started", "components", len(ordered), "duration", time.Since(begin).String())
+	return nil
+}
+
+/*
+Stop stops the started components in the reverse order they were started in. All components are stopped even if stopping one of them fails.
+
+Parameters:
+  - ctx: The context of the shutdown. It is passed to the Stop hooks.
+
+Returns:
+  - error: The errors of all components which failed to stop joined with errors.Join, or nil.
+*/
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	started := l.started
+	l.started = nil
+	l.mu.Unlock()
+
+	ctx, span := otel.Tracer(TracerName).Start(ctx, "lifecycle.stop")
+	defer span.End()
+	begin := time.Now()
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		h := started[i]
+		if err := l.run(ctx, "stop", h, h.Stop, h.StopTimeout); err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", h.Name, err))
+		}
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	l.log().Info("lifecycle stopped", "components", len(started), "duration", time.Since(begin).String())
+	return err
+}
+
+// run runs the phase fn of h with timeout in a span, logging its duration and result.
+func (l *Lifecycle) run(ctx context.Context, phase string, h Hook, fn func(context.Context) error, timeout time.Duration) error {
+	if fn == nil {
+		return nil
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx, span := otel.Tracer(TracerName).Start(ctx, "lifecycle."+phase+" "+h.Name, trace.WithAttributes(componentKey.String(h.Name)))
+	defer span.End()
+
+	begin := time.Now()
+	err := fn(ctx)
+	duration := time.Since(begin)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		l.log().Error("component "+phase+" failed", "component", h.Name, "duration", duration.String(), "error", err.Error())
+		return err
+	}
+	l.log().Info("component "+phase+" done", "component", h.Name, "duration", duration.String())
+	return nil
+}
+
+// log returns the current logger of l.
+func (l *Lifecycle) log() *slog.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.logger
+}
+
+// order returns hooks sorted so every hook comes after its dependencies, keeping the registration order otherwise.
+func order(hooks []Hook) ([]Hook, error) {
+	byName := make(map[string]Hook, len(hooks))
+	for _, h := range hooks {
+		byName[h.Name] = h
+	}
+	for _, h := range hooks {
+		for _, dep := range h.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("lifecycle hook %q depends on unknown hook %q", h.Name, dep)
+			}
+		}
+	}
+
+	ordered := make([]Hook, 0, len(hooks))
+	done := make(map[string]bool, len(hooks))
+	for len(ordered) < len(hooks) {
+		progressed := false
+		for _, h := range hooks {
+			if done[h.Name] || !dependenciesDone(h, done) {
+				continue
+			}
+			ordered = append(ordered, h)
+			done[h.Name] = true
+			progressed = true
+			// restart from the first hook to keep the registration order
+			break
+		}
+		if !progressed {
+			var cyclic []string
+			for _, h := range hooks {
+				if !done[h.Name] {
+					cyclic = append(cyclic, h.Name)
+				}
+			}
+			return nil, fmt.Errorf("lifecycle hooks with cyclic dependencies: %v", cyclic)
+		}
+	}
+	return ordered, nil
+}
+
+// dependenciesDone reports whether all dependencies of h are in done.
+func dependenciesDone(h Hook, done map[string]bool) bool {
+	for _, dep := range h.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the phases of the hooks it creates.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+// hook returns a Hook named name depending on deps, recording its start and stop.
+func (r *recorder) hook(name string, deps ...string) Hook {
+	return Hook{
+		Name:      name,
+		DependsOn: deps,
+		Start:     r.record("start " + name),
+		Stop:      r.record("stop " + name),
+	}
+}
+
+// record returns a hook function recording event.
+func (r *recorder) record(event string) func(context.Context) error {
+	return func(context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, event)
+		return nil
+	}
+}
+
+// assertEvents fails the test if r did not record exactly want in order.
+func (r *recorder) assertEvents(t *testing.T, want ...string) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("got events %q, want %q", r.events, want)
+	}
+}
+
+// newLifecycle returns a Lifecycle with hooks registered.
+func newLifecycle(t *testing.T, hooks ...Hook) *Lifecycle {
+	t.Helper()
+	l := New(nil)
+	for _, h := range hooks {
+		if err := l.Append(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	r := &recorder{}
+	l := newLifecycle(t,
+		r.hook("http", "db", "cache"),
+		r.hook("db", "logger"),
+		r.hook("cache"),
+		r.hook("logger"),
+	)
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the first hook whose dependencies started comes next, so cache starts before logger
+	r.assertEvents(t,
+		"start cache", "start logger", "start db", "start http",
+		"stop http", "stop db", "stop logger", "stop cache",
+	)
+}
+
+func TestLifecycleInvalidDependencies(t *testing.T) {
+	r := &recorder{}
+	for name, hooks := range map[string][]Hook{
+		"cyclic":  {r.hook("a", "c"), r.hook("b", "a"), r.hook("c", "b"), r.hook("d")},
+		"self":    {r.hook("a", "a")},
+		"unknown": {r.hook("a", "missing")},
+	} {
+		l := newLifecycle(t, hooks...)
+		if err := l.Start(context.Background()); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+	// no hook is started if the dependencies are invalid
+	r.assertEvents(t)
+
+	l := newLifecycle(t, r.hook("a", "b"), r.hook("b", "a"))
+	err := l.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "cyclic") || !strings.Contains(err.Error(), "[a b]") {
+		t.Errorf("got %v, want the cyclic hooks", err)
+	}
+}
+
+func TestLifecycleAppend(t *testing.T) {
+	l := newLifecycle(t, Hook{Name: "a"})
+	if err := l.Append(Hook{}); err == nil {
+		t.Error("hook without a name registered")
+	}
+	if err := l.Append(Hook{Name: "a"}); err == nil {
+		t.Error("duplicate hook registered")
+	}
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(Hook{Name: "b"}); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("got %v, want ErrAlreadyStarted", err)
+	}
+	if err := l.Start(context.Background()); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("got %v, want ErrAlreadyStarted", err)
+	}
+}
+
+func TestLifecycleRollback(t *testing.T) {
+	r := &recorder{}
+	errStart := errors.New("start failed")
+	errStop := errors.New("stop failed")
+	failing := r.hook("http", "db")
+	failing.Start = func(context.Context) error { return errStart }
+	db := r.hook("db", "logger")
+	db.Stop = func(context.Context) error { return errStop }
+	l := newLifecycle(t, r.hook("logger"), db, failing, r.hook("worker", "http"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := l.Start(ctx)
+	if !errors.Is(err, errStart) || !errors.Is(err, errStop) {
+		t.Fatalf("got %v, want the start error joined with the stop error", err)
+	}
+	// the failed and the following components are not started, the started ones are stopped in reverse order
+	r.assertEvents(t, "start logger", "start db", "stop logger")
+
+	if err := l.Stop(context.Background()); err != nil {
+		t.Errorf("stopped components stopped again: %v", err)
+	}
+	r.assertEvents(t, "start logger", "start db", "stop logger")
+}
+
+func TestLifecycleTimeouts(t *testing.T) {
+	// waitDone blocks until the context of the hook is done and returns its error.
+	waitDone := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+	var deadline time.Time
+	l := newLifecycle(t,
+		Hook{
+			Name: "unbounded",
+			Start: func(ctx context.Context) error {
+				if d, ok := ctx.Deadline(); ok {
+					deadline = d
+				}
+				return nil
+			},
+			Stop:        waitDone,
+			StopTimeout: 10 * time.Millisecond,
+		},
+		Hook{Name: "slow", Start: waitDone, StartTimeout: 10 * time.Millisecond},
+	)
+
+	begin := time.Now()
+	err := l.Start(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the start timeout and the stop timeout", err)
+	}
+	if !strings.Contains(err.Error(), "starting slow") || !strings.Contains(err.Error(), "stopping unbounded") {
+		t.Errorf("got %v, want the start timeout and the stop timeout", err)
+	}
+	if elapsed := time.Since(begin); elapsed >= time.Second {
+		t.Errorf("timeouts not applied, took %s", elapsed)
+	}
+	if !deadline.IsZero() {
+		t.Error("hook without a timeout got a deadline")
+	}
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Levels holds the named levels of the loggers of a service, so they can be changed at runtime, e.g. by the admin server.
+type Levels struct {
+	mu   sync.RWMutex
+	vars map[string]*slog.LevelVar
+}
+
+// NewLevels creates an empty Levels.
+func NewLevels() *Levels {
+	return &Levels{vars: make(map[string]*slog.LevelVar)}
+}
+
+// Var returns the level named name, creating it with logLevel if it does not exist. The returned level is passed to NewSlogLoggerWithLeveler.
+func (l *Levels) Var(name string, logLevel string) *slog.LevelVar {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.vars[name]; ok {
+		return v
+	}
+	v := new(slog.LevelVar)
+	v.Set(LogLevelStringToSlogLevel(logLevel))
+	l.vars[name] = v
+	return v
+}
+
+// Get returns the current levels by name, e.g. {"stdout": "INFO"}.
+func (l *Levels) Get() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	levels := make(map[string]string, len(l.vars))
+	for name, v := range l.vars {
+		levels[name] = v.Level().String()
+	}
+	return levels
+}
+
+// Names returns the sorted names of the levels.
+func (l *Levels) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	names := make([]string, 0, len(l.vars))
+	for name := range l.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set sets the level named name to logLevel, one of "debug", "info", "warn" or "error". It returns an error if the name or level is unknown.
+func (l *Levels) Set(name string, logLevel string) error {
+	level, err := ParseLogLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	l.mu.RLock()
+	v, ok := l.vars[name]
+	l.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown logger %q", name)
+	}
+	v.Set(level)
+	return nil
+}
+
+// ParseLogLevel parses logLevel case-insensitively. Unlike LogLevelStringToSlogLevel, it returns an error for unknown levels.
+func ParseLogLevel(logLevel string) (slog.Level, error) {
+	switch strings.ToLower(logLevel) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", logLevel)
+	}
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	levels := NewLevels()
+	stdout := levels.Var("stdout", "info")
+	if levels.Var("stdout", "debug") != stdout || stdout.Level() != slog.LevelInfo {
+		t.Error("existing level replaced")
+	}
+	levels.Var("otel", "warn")
+	if got := levels.Names(); !reflect.DeepEqual(got, []string{"otel", "stdout"}) {
+		t.Errorf("got names %v", got)
+	}
+
+	if err := levels.Set("stdout", "DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Level() != slog.LevelDebug {
+		t.Errorf("got level %s, want DEBUG", stdout.Level())
+	}
+	if err := levels.Set("stdout", "trace"); err == nil {
+		t.Error("unknown level accepted")
+	}
+	if err := levels.Set("audit", "debug"); err == nil {
+		t.Error("unknown logger accepted")
+	}
+	if got := levels.Get(); !reflect.DeepEqual(got, map[string]string{"stdout": "DEBUG", "otel": "WARN"}) {
+		t.Errorf("got levels %v", got)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for logLevel, want := range map[string]slog.Level{"debug": slog.LevelDebug, "Info": slog.LevelInfo, "WARN": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLogLevel(logLevel); err != nil || got != want {
+			t.Errorf("%s: got %s, %v", logLevel, got, err)
+		}
+	}
+	if _, err := ParseLogLevel("fatal"); err == nil {
+		t.Error("unknown level accepted")
+	}
+}
@@ -23,19 +23,23 @@ func LogLevelStringToSlogLevel(logLevel string) slog.Level {
 }
 
 func NewSlogLogger(logLevel string, pretty bool) *slog.Logger {
-	l := LogLevelStringToSlogLevel(logLevel)
+	return NewSlogLoggerWithLeveler(LogLevelStringToSlogLevel(logLevel), pretty)
+}
+
+// NewSlogLoggerWithLeveler creates a logger whose level is read from logLevel on every record, e.g. a *slog.LevelVar of Levels.
+func NewSlogLoggerWithLeveler(logLevel slog.Leveler, pretty bool) *slog.Logger {
 	if !pretty {
-		return NewJSONSlogLogger(l)
+		return NewJSONSlogLogger(logLevel)
 	}
-	return NewTextSlogLogger(l)
+	return NewTextSlogLogger(logLevel)
 }
-func NewJSONSlogLogger(logLevel slog.Level) *slog.Logger {
+func NewJSONSlogLogger(logLevel slog.Leveler) *slog.Logger {
 	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		AddSource: true,
 		Level:     logLevel,
 	}))
 }
-func NewTextSlogLogger(logLevel slog.Level) *slog.Logger {
+func NewTextSlogLogger(logLevel slog.Leveler) *slog.Logger {
 	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		AddSource: true,
 		Level:     logLevel,
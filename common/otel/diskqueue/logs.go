@@ -0,0 +1,74 @@
+package diskqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/agoda-com/opentelemetry-logs-go/exporters/otlp/otlplogs"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// logsClient is an otlplogs.Client queueing the log records it failed to upload.
+type logsClient struct {
+	otlplogs.Client
+	queue    *Queue
+	replayer *replayer
+}
+
+/*
+NewLogsClient wraps client so log records which could not be uploaded are written to queue and replayed once client uploads successfully again.
+The returned client is used to create the exporter with otlplogs.WithClient.
+
+Parameters:
+  - client: The OTLP logs client uploading the log records, e.g. created with otlplogshttp.NewClient.
+  - queue: The queue the failed uploads are written to.
+
+Returns:
+  - otlplogs.Client: The wrapping client.
+*/
+func NewLogsClient(client otlplogs.Client, queue *Queue) otlplogs.Client {
+	return &logsClient{Client: client, queue: queue}
+}
+
+// Start starts client and the replay of queued log records.
+func (c *logsClient) Start(ctx context.Context) error {
+	if err := c.Client.Start(ctx); err != nil {
+		return err
+	}
+	c.replayer = c.queue.startReplay(c.replay)
+	return nil
+}
+
+// Stop stops the replay of queued log records and client. Log records left in the queue are replayed by the next process.
+func (c *logsClient) Stop(ctx context.Context) error {
+	return errors.Join(c.replayer.stop(ctx), c.Client.Stop(ctx))
+}
+
+// UploadLogs uploads log records, writing them to the queue if the upload fails. The error of a queued upload wraps ErrQueued.
+func (c *logsClient) UploadLogs(ctx context.Context, protoLogs []*logspb.ResourceLogs) error {
+	err := c.Client.UploadLogs(ctx, protoLogs)
+	if err == nil {
+		c.queue.notify()
+		return nil
+	}
+	data, merr := proto.Marshal(&collogspb.ExportLogsServiceRequest{ResourceLogs: protoLogs})
+	if merr != nil {
+		return errors.Join(err, merr)
+	}
+	if qerr := c.queue.Push(ctx, data); qerr != nil {
+		return errors.Join(err, qerr)
+	}
+	return fmt.Errorf("%w: %w", ErrQueued, err)
+}
+
+// replay uploads a queued export.
+func (c *logsClient) replay(ctx context.Context, data []byte) error {
+	req := new(collogspb.ExportLogsServiceRequest)
+	if err := proto.Unmarshal(data, req); err != nil {
+		return errCorrupt{err: err}
+	}
+	return c.Client.UploadLogs(ctx, req.ResourceLogs)
+}
@@ -0,0 +1,94 @@
+package diskqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// metricExporter is a sdkmetric.Exporter queueing the metrics it failed to export.
+type metricExporter struct {
+	sdkmetric.Exporter
+	queue *Queue
+
+	mu       sync.Mutex
+	replayer *replayer
+	stopped  bool
+}
+
+/*
+NewMetricExporter wraps exporter so metrics which could not be exported are written to queue and replayed once exporter exports successfully again.
+The metrics exporter does not expose its OTLP client, so queued metrics are converted back to metric data and replayed through exporter, using its endpoint, TLS, headers and compression.
+The replay starts with the first export, so an exporter which is never used does not leave a replay running.
+
+Parameters:
+  - exporter: The exporter exporting the metrics, e.g. created with NewOTLPMetricExporter.
+  - queue: The queue the failed exports are written to.
+
+Returns:
+  - sdkmetric.Exporter: The wrapping exporter.
+*/
+func NewMetricExporter(exporter sdkmetric.Exporter, queue *Queue) sdkmetric.Exporter {
+	return &metricExporter{Exporter: exporter, queue: queue}
+}
+
+// Export exports rm, writing it to the queue if the export fails. The error of a queued export wraps ErrQueued.
+func (e *metricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.start()
+	err := e.Exporter.Export(ctx, rm)
+	if err == nil {
+		e.queue.notify()
+		return nil
+	}
+	data, merr := proto.Marshal(&colmetricpb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{resourceMetrics(rm)}})
+	if merr != nil {
+		return errors.Join(err, merr)
+	}
+	if qerr := e.queue.Push(ctx, data); qerr != nil {
+		return errors.Join(err, qerr)
+	}
+	return fmt.Errorf("%w: %w", ErrQueued, err)
+}
+
+// Shutdown stops the replay of queued metrics and shuts down the wrapped exporter. Metrics left in the queue are replayed by the next process.
+func (e *metricExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.stopped = true
+	r := e.replayer
+	e.mu.Unlock()
+	return errors.Join(r.stop(ctx), e.Exporter.Shutdown(ctx))
+}
+
+// start starts the replay of queued metrics unless it runs already or the exporter is shut down.
+func (e *metricExporter) start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.replayer == nil && !e.stopped {
+		e.replayer = e.queue.startReplay(e.replay)
+	}
+}
+
+// replay exports a queued export through the wrapped exporter.
+func (e *metricExporter) replay(ctx context.Context, data []byte) error {
+	req := new(colmetricpb.ExportMetricsServiceRequest)
+	if err := proto.Unmarshal(data, req); err != nil {
+		return errCorrupt{err: err}
+	}
+	for _, prm := range req.GetResourceMetrics() {
+		rm, err := metricData(prm)
+		if err != nil {
+			return errCorrupt{err: err}
+		}
+		if err := e.Exporter.Export(ctx, rm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
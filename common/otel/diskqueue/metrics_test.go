@@ -0,0 +1,114 @@
+package diskqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/oteltest"
+)
+
+// fakeExporter records the exported metrics, failing with err if it is set.
+type fakeExporter struct {
+	sdkmetric.Exporter
+	mu       sync.Mutex
+	err      error
+	exported []*metricdata.ResourceMetrics
+}
+
+func (e *fakeExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	e.exported = append(e.exported, rm)
+	return nil
+}
+
+func (e *fakeExporter) setErr(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err = err
+}
+
+// wait waits until n metrics were exported and returns them.
+func (e *fakeExporter) wait(t *testing.T, n int) []*metricdata.ResourceMetrics {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		e.mu.Lock()
+		exported := e.exported
+		e.mu.Unlock()
+		if len(exported) >= n {
+			return exported
+		}
+	}
+	t.Fatalf("%d metrics not exported", n)
+	return nil
+}
+
+func (e *fakeExporter) Shutdown(context.Context) error { return nil }
+
+// collect records metrics of every aggregation and returns them.
+func collect(t *testing.T, tel *oteltest.Telemetry) metricdata.ResourceMetrics {
+	t.Helper()
+	ctx := context.Background()
+	meter := tel.MeterProvider.Meter("test")
+	attrs := metric.WithAttributes(attribute.String("route", "/v1/token"), attribute.StringSlice("scopes", []string{"openid", "profile"}))
+	counter, _ := meter.Int64Counter("requests")
+	counter.Add(ctx, 3, attrs)
+	duration, _ := meter.Float64Histogram("duration", metric.WithUnit("s"))
+	duration.Record(ctx, 0.25, attrs)
+	duration.Record(ctx, 1.5, attrs)
+	_, _ = meter.Float64ObservableGauge("temperature", metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+		o.Observe(21.5, metric.WithAttributes(attribute.Bool("indoor", true)))
+		return nil
+	}))
+	rm, err := tel.Collect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rm
+}
+
+func TestMetricExporterReplay(t *testing.T) {
+	ctx := context.Background()
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(ctx)
+	rm := collect(t, tel)
+
+	exporter := &fakeExporter{err: errors.New("connection refused")}
+	q := newTestQueue(t, oteltest.New(nil), "metrics", "")
+	e := NewMetricExporter(exporter, q)
+	defer e.Shutdown(ctx)
+	if err := e.Export(ctx, &rm); !errors.Is(err, ErrQueued) || q.Len() != 1 {
+		t.Fatalf("got %v with %d queued, want %v", err, q.Len(), ErrQueued)
+	}
+
+	// a successful export wakes the replay, which exports the queued metrics through the wrapped exporter
+	exporter.setErr(nil)
+	next := collect(t, tel)
+	if err := e.Export(ctx, &next); err != nil {
+		t.Fatal(err)
+	}
+	exported := exporter.wait(t, 2)
+	if got, want := resourceMetrics(exported[1]), resourceMetrics(&rm); !proto.Equal(got, want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+}
+
+func TestMetricDataRejectsUnknownAggregations(t *testing.T) {
+	rm := &metricspb.ResourceMetrics{ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{{Name: "summary", Data: &metricspb.Metric_Summary{}}}}}}
+	if _, err := metricData(rm); err == nil {
+		t.Error("summary accepted")
+	}
+}
@@ -0,0 +1,484 @@
+/*
+Package diskqueue contains an on-disk write-ahead queue for telemetry which could not be exported.
+
+The OTLP exporters retry for a limited time and then drop the data. The exporters wrapped by this package
+persist failed exports as serialized OTLP requests instead and replay them, oldest first, once the collector is reachable again.
+*/
+package diskqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// DefaultMaxBytes is the default maximum size of a queue on disk. It is set to 64 MiB.
+	DefaultMaxBytes int64 = 64 * 1024 * 1024
+	// DefaultMaxItems is the default maximum number of exports held by a queue. It is set to 10000.
+	DefaultMaxItems int = 10000
+	// DefaultRetryInterval is the default interval the replay of queued exports is attempted in. It is set to 10 seconds.
+	DefaultRetryInterval time.Duration = 10 * time.Second
+
+	// MeterName is the name of the meter the queue metrics are created with.
+	MeterName string = "github.com/SaimonWoidig/cc-microsvcs/common/otel/diskqueue"
+)
+
+const (
+	// itemExt is the file extension of a queued export.
+	itemExt string = ".wal"
+	// tmpExt is the file extension of a queued export which is still being written.
+	tmpExt string = ".tmp"
+
+	// signalKey is the attribute key holding the signal a queue belongs to.
+	signalKey attribute.Key = "signal"
+	// queueKey is the attribute key holding the name of a queue.
+	queueKey attribute.Key = "queue"
+	// reasonKey is the attribute key holding the reason a queued export was dropped.
+	reasonKey attribute.Key = "reason"
+
+	// reasonEvicted is recorded when an export is evicted to make room for a newer one.
+	reasonEvicted string = "evicted"
+	// reasonTooLarge is recorded when an export is larger than the queue itself.
+	reasonTooLarge string = "too_large"
+	// reasonCorrupt is recorded when a queued export cannot be read or decoded.
+	reasonCorrupt string = "corrupt"
+	// reasonRejected is recorded when the collector rejects a replayed export permanently, e.g. with 400 Bad Request.
+	reasonRejected string = "rejected"
+)
+
+var (
+	// ErrQueued wraps the error of a failed export which was written to the queue. The export is replayed later, so it is not lost.
+	ErrQueued = errors.New("export failed and was queued to disk")
+	// errTooLarge is returned when an export does not fit into an empty queue.
+	errTooLarge = errors.New("export larger than queue size limit")
+
+	// statusPattern matches the HTTP status in the errors of the OTLP/HTTP clients, which do not expose the status code otherwise.
+	statusPattern = regexp.MustCompile(`failed to send (?:metrics )?to \S+: (\d{3}) `)
+)
+
+// Config is a struct that represents the configuration of a Queue.
+type Config struct {
+	// Dir is the directory the queued exports are stored in. It is created if it does not exist.
+	Dir string
+	// Name is the name of the queue, recorded as the queue attribute of the queue metrics.
+	// It tells apart the queues of the same signal, e.g. one per log pipeline. Defaults to the name of the signal.
+	Name string
+	// MaxBytes is the maximum total size of the queued exports. Defaults to DefaultMaxBytes.
+	MaxBytes int64
+	// MaxItems is the maximum number of queued exports. Defaults to DefaultMaxItems.
+	MaxItems int
+	// RetryInterval is the interval the replay of queued exports is attempted in. Defaults to DefaultRetryInterval.
+	RetryInterval time.Duration
+	// MeterProvider is used to create the queue metrics. Defaults to otel.GetMeterProvider().
+	MeterProvider metric.MeterProvider
+}
+
+// withDefaults returns a copy of config with defaults applied to all unset fields.
+func (config Config) withDefaults() Config {
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultMaxBytes
+	}
+	if config.MaxItems <= 0 {
+		config.MaxItems = DefaultMaxItems
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = DefaultRetryInterval
+	}
+	if config.MeterProvider == nil {
+		config.MeterProvider = otel.GetMeterProvider()
+	}
+	return config
+}
+
+// item is a queued export stored in its own file.
+type item struct {
+	seq  uint64
+	size int64
+}
+
+// Queue is an on-disk FIFO queue of serialized exports. Every export is stored in its own file, named by its sequence number,
+// so the queue survives restarts and the oldest exports can be evicted cheaply once a size limit is reached.
+type Queue struct {
+	dir           string
+	maxBytes      int64
+	maxItems      int
+	retryInterval time.Duration
+
+	mu      sync.Mutex
+	items   []item
+	size    int64
+	nextSeq uint64
+
+	// wake triggers a replay attempt, e.g. after an export succeeded again.
+	wake chan struct{}
+
+	// attrs are the signal and queue attributes recorded on all queue metrics.
+	attrs    metric.MeasurementOption
+	enqueued metric.Int64Counter
+	replayed metric.Int64Counter
+	dropped  metric.Int64Counter
+}
+
+/*
+New creates a Queue for the signal named signal, e.g. "traces", storing its exports in config.Dir.
+Exports queued by a previous process are picked up, so they are replayed after a restart.
+
+Parameters:
+  - signal: The name of the signal, recorded as the signal attribute of the queue metrics.
+  - config: The configuration of the queue. config.Name is recorded as the queue attribute of the queue metrics.
+
+Returns:
+  - *Queue: The created queue.
+  - error: An error if the directory could not be read or the metrics could not be created.
+
+The following metrics are recorded:
+  - otel.diskqueue.items: The number of queued exports.
+  - otel.diskqueue.size: The total size of the queued exports in bytes.
+  - otel.diskqueue.enqueued: The number of exports which failed and were queued.
+  - otel.diskqueue.replayed: The number of queued exports which were replayed successfully.
+  - otel.diskqueue.dropped: The number of queued exports which were dropped, by reason.
+*/
+func New(signal string, config Config) (*Queue, error) {
+	config = config.withDefaults()
+	if config.Name == "" {
+		config.Name = signal
+	}
+	if config.Dir == "" {
+		return nil, errors.New("queue directory must be set")
+	}
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		dir:           config.Dir,
+		maxBytes:      config.MaxBytes,
+		maxItems:      config.MaxItems,
+		retryInterval: config.RetryInterval,
+		wake:          make(chan struct{}, 1),
+		attrs:         metric.WithAttributes(signalKey.String(signal), queueKey.String(config.Name)),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.initMetrics(config.MeterProvider.Meter(MeterName)); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// initMetrics creates the queue metrics with meter.
+func (q *Queue) initMetrics(meter metric.Meter) error {
+	var err error
+	if q.enqueued, err = meter.Int64Counter(
+		"otel.diskqueue.enqueued",
+		metric.WithDescription("Number of failed exports written to the disk queue."),
+		metric.WithUnit("{export}"),
+	); err != nil {
+		return err
+	}
+	if q.replayed, err = meter.Int64Counter(
+		"otel.diskqueue.replayed",
+		metric.WithDescription("Number of queued exports replayed successfully."),
+		metric.WithUnit("{export}"),
+	); err != nil {
+		return err
+	}
+	if q.dropped, err = meter.Int64Counter(
+		"otel.diskqueue.dropped",
+		metric.WithDescription("Number of queued exports dropped from the disk queue."),
+		metric.WithUnit("{export}"),
+	); err != nil {
+		return err
+	}
+
+	items, err := meter.Int64ObservableGauge(
+		"otel.diskqueue.items",
+		metric.WithDescription("Number of exports in the disk queue."),
+		metric.WithUnit("{export}"),
+	)
+	if err != nil {
+		return err
+	}
+	size, err := meter.Int64ObservableGauge(
+		"otel.diskqueue.size",
+		metric.WithDescription("Size of the exports in the disk queue."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		n, bytes := q.stats()
+		o.ObserveInt64(items, int64(n), q.attrs)
+		o.ObserveInt64(size, bytes, q.attrs)
+		return nil
+	}, items, size)
+	return err
+}
+
+// load reads the exports left in the queue directory, removing unfinished writes.
+func (q *Queue) load() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, tmpExt) {
+			_ = os.Remove(filepath.Join(q.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, itemExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, itemExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		q.items = append(q.items, item{seq: seq, size: info.Size()})
+		q.size += info.Size()
+	}
+	sort.Slice(q.items, func(i, j int) bool { return q.items[i].seq < q.items[j].seq })
+	if len(q.items) > 0 {
+		q.nextSeq = q.items[len(q.items)-1].seq + 1
+	}
+	return nil
+}
+
+// path returns the path of the file holding the export with sequence number seq.
+func (q *Queue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, itemExt))
+}
+
+// stats returns the number and total size of the queued exports.
+func (q *Queue) stats() (int, int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items), q.size
+}
+
+// Len returns the number of queued exports.
+func (q *Queue) Len() int {
+	n, _ := q.stats()
+	return n
+}
+
+/*
+Push appends data to the queue. The oldest exports are evicted until data fits into the size and item limits.
+The data is written to a temporary file and renamed, so a crash never leaves a partially written export behind.
+
+Parameters:
+  - ctx: The context the dropped metric is recorded with.
+  - data: The serialized export.
+
+Returns:
+  - error: An error if data is larger than the queue or could not be written.
+*/
+func (q *Queue) Push(ctx context.Context, data []byte) error {
+	size := int64(len(data))
+	if size > q.maxBytes {
+		q.dropped.Add(ctx, 1, q.attrs, metric.WithAttributes(reasonKey.String(reasonTooLarge)))
+		return errTooLarge
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.items) > 0 && (len(q.items) >= q.maxItems || q.size+size > q.maxBytes) {
+		oldest := q.items[0]
+		if err := os.Remove(q.path(oldest.seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		q.items = q.items[1:]
+		q.size -= oldest.size
+		q.dropped.Add(ctx, 1, q.attrs, metric.WithAttributes(reasonKey.String(reasonEvicted)))
+	}
+
+	seq := q.nextSeq
+	path := q.path(seq)
+	if err := writeFile(path, data); err != nil {
+		return err
+	}
+	q.nextSeq++
+	q.items = append(q.items, item{seq: seq, size: size})
+	q.size += size
+	q.enqueued.Add(ctx, 1, q.attrs)
+	return nil
+}
+
+// writeFile writes data to a temporary file next to path, syncs it and renames it to path.
+func writeFile(path string, data []byte) error {
+	tmp := path + tmpExt
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// peek returns the oldest queued export. ok is false if the queue is empty.
+func (q *Queue) peek() (seq uint64, data []byte, ok bool, err error) {
+	q.mu.Lock()
+	if len(q.items) == 0 {
+		q.mu.Unlock()
+		return 0, nil, false, nil
+	}
+	seq = q.items[0].seq
+	q.mu.Unlock()
+
+	data, err = os.ReadFile(q.path(seq))
+	return seq, data, true, err
+}
+
+// remove removes the export with sequence number seq if it is still queued.
+func (q *Queue) remove(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, it := range q.items {
+		if it.seq != seq {
+			continue
+		}
+		q.items = append(q.items[:i], q.items[i+1:]...)
+		q.size -= it.size
+		if err := os.Remove(q.path(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return nil
+}
+
+// notify triggers a replay attempt without waiting for the retry interval.
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// errCorrupt marks exports which could not be decoded. They are dropped instead of being retried forever.
+type errCorrupt struct {
+	err error
+}
+
+func (e errCorrupt) Error() string { return fmt.Sprintf("corrupt queued export: %v", e.err) }
+func (e errCorrupt) Unwrap() error { return e.err }
+
+// rejected reports whether err is a permanent rejection of an export by the collector, which a retry cannot fix.
+// Client errors are permanent, except for timeouts and rate limiting.
+func rejected(err error) bool {
+	m := statusPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return false
+	}
+	status, _ := strconv.Atoi(m[1])
+	return status >= 400 && status <= 499 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+// drain replays the queued exports oldest first with upload, stopping at the first upload which failed transiently.
+// Corrupt exports and exports rejected by the collector are dropped.
+func (q *Queue) drain(ctx context.Context, upload func(context.Context, []byte) error) {
+	for ctx.Err() == nil {
+		seq, data, ok, err := q.peek()
+		if !ok {
+			return
+		}
+		if err == nil {
+			err = upload(ctx, data)
+		} else {
+			err = errCorrupt{err: err}
+		}
+		var corrupt errCorrupt
+		switch {
+		case err == nil:
+			q.replayed.Add(ctx, 1, q.attrs)
+		case errors.As(err, &corrupt):
+			otel.Handle(err)
+			q.dropped.Add(ctx, 1, q.attrs, metric.WithAttributes(reasonKey.String(reasonCorrupt)))
+		case rejected(err):
+			otel.Handle(fmt.Errorf("dropping queued export rejected by the collector: %w", err))
+			q.dropped.Add(ctx, 1, q.attrs, metric.WithAttributes(reasonKey.String(reasonRejected)))
+		default:
+			// the collector is still unreachable, retry later
+			return
+		}
+		if err := q.remove(seq); err != nil {
+			otel.Handle(err)
+			return
+		}
+	}
+}
+
+// replayer runs the replay loop of a Queue until it is stopped.
+type replayer struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startReplay starts replaying the queued exports with upload every retry interval and whenever the queue is notified.
+func (q *Queue) startReplay(upload func(context.Context, []byte) error) *replayer {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replayer{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(q.retryInterval)
+		defer ticker.Stop()
+		for {
+			q.drain(ctx, upload)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-q.wake:
+			}
+		}
+	}()
+	return r
+}
+
+// stop stops the replay loop and waits for it to return or ctx to be done.
+func (r *replayer) stop(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
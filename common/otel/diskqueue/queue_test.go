@@ -0,0 +1,230 @@
+package diskqueue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/oteltest"
+)
+
+// newTestQueue creates a queue of signal named name in a temporary directory, recording to tel.
+func newTestQueue(t *testing.T, tel *oteltest.Telemetry, signal, name string) *Queue {
+	t.Helper()
+	q, err := New(signal, Config{
+		Dir:           filepath.Join(t.TempDir(), signal, name),
+		Name:          name,
+		MeterProvider: tel.MeterProvider,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestQueueMetricsPerName(t *testing.T) {
+	ctx := context.Background()
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(ctx)
+
+	audit := newTestQueue(t, tel, "logs", "audit")
+	app := newTestQueue(t, tel, "logs", "app")
+	traces := newTestQueue(t, tel, "traces", "")
+
+	for _, push := range []struct {
+		q *Queue
+		n int
+	}{{audit, 1}, {app, 2}, {traces, 3}} {
+		for i := 0; i < push.n; i++ {
+			if err := push.q.Push(ctx, []byte("export")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// series are the expected values by queue name, a queue without a name is named after its signal
+	series := map[string]struct {
+		signal string
+		value  int64
+	}{
+		"audit":  {"logs", 1},
+		"app":    {"logs", 2},
+		"traces": {"traces", 3},
+	}
+	for _, name := range []string{"otel.diskqueue.items", "otel.diskqueue.enqueued"} {
+		m, ok, err := tel.Metric(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatalf("instrument %q did not record data", name)
+		}
+		points := oteltest.GaugeDataPoints[int64](m)
+		if points == nil {
+			points = oteltest.SumDataPoints[int64](m)
+		}
+		if len(points) != len(series) {
+			t.Fatalf("%s: got %d series, want %d", name, len(points), len(series))
+		}
+		for _, p := range points {
+			attrs := p.Attributes.ToSlice()
+			queue, _ := oteltest.AttributeValue(attrs, queueKey)
+			want, ok := series[queue.AsString()]
+			if !ok {
+				t.Fatalf("%s: unexpected series %v", name, attrs)
+			}
+			oteltest.AssertAttributes(t, attrs, attribute.String("signal", want.signal))
+			if p.Value != want.value {
+				t.Errorf("%s{queue=%q}: got %d, want %d", name, queue.AsString(), p.Value, want.value)
+			}
+		}
+	}
+}
+
+// dropped returns the number of exports q dropped for reason.
+func dropped(t *testing.T, tel *oteltest.Telemetry, reason string) int64 {
+	t.Helper()
+	m, ok, err := tel.Metric(context.Background(), "otel.diskqueue.dropped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		return 0
+	}
+	for _, p := range oteltest.SumDataPoints[int64](m) {
+		if v, _ := p.Attributes.Value(reasonKey); v.AsString() == reason {
+			return p.Value
+		}
+	}
+	return 0
+}
+
+// uploader records the uploaded exports, failing with the errors in errs first.
+type uploader struct {
+	errs     []error
+	uploaded []string
+}
+
+func (u *uploader) upload(_ context.Context, data []byte) error {
+	if len(u.errs) > 0 {
+		err := u.errs[0]
+		u.errs = u.errs[1:]
+		return err
+	}
+	u.uploaded = append(u.uploaded, string(data))
+	return nil
+}
+
+func TestQueueReplay(t *testing.T) {
+	ctx := context.Background()
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(ctx)
+	dir := filepath.Join(t.TempDir(), "traces")
+	q, err := New("traces", Config{Dir: dir, MeterProvider: tel.MeterProvider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"first", "second", "third"} {
+		if err := q.Push(ctx, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the replay stops at a transient failure and keeps the export
+	u := &uploader{errs: []error{errors.New("connection refused")}}
+	q.drain(ctx, u.upload)
+	if len(u.uploaded) != 0 || q.Len() != 3 {
+		t.Fatalf("uploaded %v, %d left", u.uploaded, q.Len())
+	}
+
+	// the queue is replayed by the next process, oldest first
+	q, err = New("traces", Config{Dir: dir, MeterProvider: tel.MeterProvider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.drain(ctx, u.upload)
+	if strings.Join(u.uploaded, " ") != "first second third" || q.Len() != 0 {
+		t.Errorf("uploaded %v, %d left", u.uploaded, q.Len())
+	}
+}
+
+func TestQueueEviction(t *testing.T) {
+	ctx := context.Background()
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(ctx)
+	q, err := New("traces", Config{Dir: t.TempDir(), MaxItems: 2, MaxBytes: 10, MeterProvider: tel.MeterProvider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"a", "b", "c", "dddddddddd"} {
+		if err := q.Push(ctx, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Push(ctx, []byte("too large!!")); !errors.Is(err, errTooLarge) {
+		t.Errorf("got %v, want %v", err, errTooLarge)
+	}
+
+	// "a" is evicted by the item limit, "b" by the item limit and "c" by the size limit
+	u := &uploader{}
+	q.drain(ctx, u.upload)
+	if strings.Join(u.uploaded, " ") != "dddddddddd" {
+		t.Errorf("uploaded %v", u.uploaded)
+	}
+	if n := dropped(t, tel, reasonEvicted); n != 3 {
+		t.Errorf("evicted %d, want 3", n)
+	}
+	if n := dropped(t, tel, reasonTooLarge); n != 1 {
+		t.Errorf("dropped %d too large, want 1", n)
+	}
+}
+
+func TestQueueDropsRejectedExports(t *testing.T) {
+	ctx := context.Background()
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(ctx)
+	q := newTestQueue(t, tel, "metrics", "")
+	for _, data := range []string{"invalid", "unavailable", "valid"} {
+		if err := q.Push(ctx, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := &uploader{errs: []error{
+		errors.New("failed to upload metrics: failed to send metrics to http://localhost:4318/v1/metrics: 400 Bad Request"),
+		errors.New("failed to upload metrics: failed to send metrics to http://localhost:4318/v1/metrics: 500 Internal Server Error"),
+	}}
+	q.drain(ctx, u.upload)
+	if n := dropped(t, tel, reasonRejected); n != 1 || q.Len() != 2 {
+		t.Fatalf("rejected %d, %d left", n, q.Len())
+	}
+	q.drain(ctx, u.upload)
+	if strings.Join(u.uploaded, " ") != "unavailable valid" || q.Len() != 0 {
+		t.Errorf("uploaded %v, %d left", u.uploaded, q.Len())
+	}
+}
+
+func TestRejected(t *testing.T) {
+	tests := []struct {
+		err  string
+		want bool
+	}{
+		{err: "failed to send to http://localhost:4318/v1/traces: 400 Bad Request", want: true},
+		{err: "failed to send to http://localhost:4318/v1/logs: 413 Request Entity Too Large\nbody", want: true},
+		{err: "failed to upload metrics: failed to send metrics to http://localhost:4318/v1/metrics: 401 Unauthorized", want: true},
+		{err: "failed to send to http://localhost:4318/v1/traces: 408 Request Timeout"},
+		{err: "failed to send to http://localhost:4318/v1/traces: 429 Too Many Requests"},
+		{err: "failed to send to http://localhost:4318/v1/traces: 500 Internal Server Error"},
+		{err: "max retry time elapsed: retry-able request failure"},
+		{err: `Post "http://localhost:4318/v1/traces": dial tcp: connection refused`},
+	}
+	for _, tt := range tests {
+		if got := rejected(errors.New(tt.err)); got != tt.want {
+			t.Errorf("%q: got %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package diskqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// traceClient is an otlptrace.Client queueing the spans it failed to upload.
+type traceClient struct {
+	otlptrace.Client
+	queue    *Queue
+	replayer *replayer
+}
+
+/*
+NewTraceClient wraps client so spans which could not be uploaded are written to queue and replayed once client uploads successfully again.
+The returned client is used to create the exporter with otlptrace.New.
+
+Parameters:
+  - client: The OTLP trace client uploading the spans, e.g. created with otlptracehttp.NewClient.
+  - queue: The queue the failed uploads are written to.
+
+Returns:
+  - otlptrace.Client: The wrapping client.
+
+Example usage:
+
+	queue, err := diskqueue.New("traces", diskqueue.Config{Dir: "/var/lib/auth/telemetry/traces"})
+	if err != nil {
+		return err
+	}
+	exporter, err := otlptrace.New(ctx, diskqueue.NewTraceClient(otlptracehttp.NewClient(), queue))
+*/
+func NewTraceClient(client otlptrace.Client, queue *Queue) otlptrace.Client {
+	return &traceClient{Client: client, queue: queue}
+}
+
+// Start starts client and the replay of queued spans.
+func (c *traceClient) Start(ctx context.Context) error {
+	if err := c.Client.Start(ctx); err != nil {
+		return err
+	}
+	c.replayer = c.queue.startReplay(c.replay)
+	return nil
+}
+
+// Stop stops the replay of queued spans and client. Spans left in the queue are replayed by the next process.
+func (c *traceClient) Stop(ctx context.Context) error {
+	return errors.Join(c.replayer.stop(ctx), c.Client.Stop(ctx))
+}
+
+// UploadTraces uploads spans, writing them to the queue if the upload fails. The error of a queued upload wraps ErrQueued.
+func (c *traceClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	err := c.Client.UploadTraces(ctx, spans)
+	if err == nil {
+		c.queue.notify()
+		return nil
+	}
+	data, merr := proto.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: spans})
+	if merr != nil {
+		return errors.Join(err, merr)
+	}
+	if qerr := c.queue.Push(ctx, data); qerr != nil {
+		return errors.Join(err, qerr)
+	}
+	return fmt.Errorf("%w: %w", ErrQueued, err)
+}
+
+// replay uploads a queued export.
+func (c *traceClient) replay(ctx context.Context, data []byte) error {
+	req := new(coltracepb.ExportTraceServiceRequest)
+	if err := proto.Unmarshal(data, req); err != nil {
+		return errCorrupt{err: err}
+	}
+	return c.Client.UploadTraces(ctx, req.ResourceSpans)
+}
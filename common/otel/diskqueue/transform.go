@@ -0,0 +1,526 @@
+package diskqueue
+
+/*
+Contains the conversion of SDK metric data to OTLP protobuf messages and back.
+The conversion to OTLP is rewritten from the internal transform package of go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp, which cannot be imported.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// resourceMetrics converts rm to its OTLP representation. Aggregations unknown to OTLP are skipped.
+func resourceMetrics(rm *metricdata.ResourceMetrics) *metricspb.ResourceMetrics {
+	out := &metricspb.ResourceMetrics{
+		Resource:     resourcePB(rm.Resource),
+		ScopeMetrics: make([]*metricspb.ScopeMetrics, 0, len(rm.ScopeMetrics)),
+	}
+	if rm.Resource != nil {
+		out.SchemaUrl = rm.Resource.SchemaURL()
+	}
+	for _, sm := range rm.ScopeMetrics {
+		out.ScopeMetrics = append(out.ScopeMetrics, scopeMetrics(sm))
+	}
+	return out
+}
+
+// resourcePB converts res to its OTLP representation.
+func resourcePB(res *resource.Resource) *resourcepb.Resource {
+	if res == nil {
+		return nil
+	}
+	return &resourcepb.Resource{Attributes: keyValues(res.Attributes())}
+}
+
+// scopeMetrics converts sm to its OTLP representation.
+func scopeMetrics(sm metricdata.ScopeMetrics) *metricspb.ScopeMetrics {
+	out := &metricspb.ScopeMetrics{
+		Scope:     scope(sm.Scope),
+		Metrics:   make([]*metricspb.Metric, 0, len(sm.Metrics)),
+		SchemaUrl: sm.Scope.SchemaURL,
+	}
+	for _, m := range sm.Metrics {
+		if pm := metricPB(m); pm != nil {
+			out.Metrics = append(out.Metrics, pm)
+		}
+	}
+	return out
+}
+
+// scope converts s to its OTLP representation.
+func scope(s instrumentation.Scope) *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{Name: s.Name, Version: s.Version}
+}
+
+// metricPB converts m to its OTLP representation. It returns nil for unknown aggregations.
+func metricPB(m metricdata.Metrics) *metricspb.Metric {
+	out := &metricspb.Metric{Name: m.Name, Description: m.Description, Unit: m.Unit}
+	switch a := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		out.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: dataPoints(a.DataPoints)}}
+	case metricdata.Gauge[float64]:
+		out.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: dataPoints(a.DataPoints)}}
+	case metricdata.Sum[int64]:
+		out.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints:             dataPoints(a.DataPoints),
+			AggregationTemporality: temporality(a.Temporality),
+			IsMonotonic:            a.IsMonotonic,
+		}}
+	case metricdata.Sum[float64]:
+		out.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints:             dataPoints(a.DataPoints),
+			AggregationTemporality: temporality(a.Temporality),
+			IsMonotonic:            a.IsMonotonic,
+		}}
+	case metricdata.Histogram[int64]:
+		out.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			DataPoints:             histogramDataPoints(a.DataPoints),
+			AggregationTemporality: temporality(a.Temporality),
+		}}
+	case metricdata.Histogram[float64]:
+		out.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			DataPoints:             histogramDataPoints(a.DataPoints),
+			AggregationTemporality: temporality(a.Temporality),
+		}}
+	case metricdata.ExponentialHistogram[int64]:
+		out.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+			DataPoints:             exponentialHistogramDataPoints(a.DataPoints),
+			AggregationTemporality: temporality(a.Temporality),
+		}}
+	case metricdata.ExponentialHistogram[float64]:
+		out.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+			DataPoints:             exponentialHistogramDataPoints(a.DataPoints),
+			AggregationTemporality: temporality(a.Temporality),
+		}}
+	default:
+		return nil
+	}
+	return out
+}
+
+// temporality converts t to its OTLP representation.
+func temporality(t metricdata.Temporality) metricspb.AggregationTemporality {
+	switch t {
+	case metricdata.DeltaTemporality:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	case metricdata.CumulativeTemporality:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	default:
+		return metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED
+	}
+}
+
+// dataPoints converts the data points of a gauge or sum to their OTLP representation.
+func dataPoints[N int64 | float64](dps []metricdata.DataPoint[N]) []*metricspb.NumberDataPoint {
+	out := make([]*metricspb.NumberDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		pdp := &metricspb.NumberDataPoint{
+			Attributes:        keyValues(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeUnixNano(dp.StartTime),
+			TimeUnixNano:      timeUnixNano(dp.Time),
+			Exemplars:         exemplars(dp.Exemplars),
+		}
+		switch v := any(dp.Value).(type) {
+		case int64:
+			pdp.Value = &metricspb.NumberDataPoint_AsInt{AsInt: v}
+		case float64:
+			pdp.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: v}
+		}
+		out = append(out, pdp)
+	}
+	return out
+}
+
+// histogramDataPoints converts the data points of a histogram to their OTLP representation.
+func histogramDataPoints[N int64 | float64](dps []metricdata.HistogramDataPoint[N]) []*metricspb.HistogramDataPoint {
+	out := make([]*metricspb.HistogramDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		sum := float64(dp.Sum)
+		pdp := &metricspb.HistogramDataPoint{
+			Attributes:        keyValues(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeUnixNano(dp.StartTime),
+			TimeUnixNano:      timeUnixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			BucketCounts:      dp.BucketCounts,
+			ExplicitBounds:    dp.Bounds,
+			Exemplars:         exemplars(dp.Exemplars),
+		}
+		pdp.Min = extrema(dp.Min)
+		pdp.Max = extrema(dp.Max)
+		out = append(out, pdp)
+	}
+	return out
+}
+
+// exponentialHistogramDataPoints converts the data points of an exponential histogram to their OTLP representation.
+func exponentialHistogramDataPoints[N int64 | float64](dps []metricdata.ExponentialHistogramDataPoint[N]) []*metricspb.ExponentialHistogramDataPoint {
+	out := make([]*metricspb.ExponentialHistogramDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		sum := float64(dp.Sum)
+		pdp := &metricspb.ExponentialHistogramDataPoint{
+			Attributes:        keyValues(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: timeUnixNano(dp.StartTime),
+			TimeUnixNano:      timeUnixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			Scale:             dp.Scale,
+			ZeroCount:         dp.ZeroCount,
+			ZeroThreshold:     dp.ZeroThreshold,
+			Positive:          &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: dp.PositiveBucket.Offset, BucketCounts: dp.PositiveBucket.Counts},
+			Negative:          &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: dp.NegativeBucket.Offset, BucketCounts: dp.NegativeBucket.Counts},
+			Exemplars:         exemplars(dp.Exemplars),
+		}
+		pdp.Min = extrema(dp.Min)
+		pdp.Max = extrema(dp.Max)
+		out = append(out, pdp)
+	}
+	return out
+}
+
+// extrema returns a pointer to the value of e, or nil if e is not set.
+func extrema[N int64 | float64](e metricdata.Extrema[N]) *float64 {
+	v, ok := e.Value()
+	if !ok {
+		return nil
+	}
+	f := float64(v)
+	return &f
+}
+
+// exemplars converts exemplars to their OTLP representation.
+func exemplars[N int64 | float64](exemplars []metricdata.Exemplar[N]) []*metricspb.Exemplar {
+	if len(exemplars) == 0 {
+		return nil
+	}
+	out := make([]*metricspb.Exemplar, 0, len(exemplars))
+	for _, e := range exemplars {
+		pe := &metricspb.Exemplar{
+			FilteredAttributes: keyValues(e.FilteredAttributes),
+			TimeUnixNano:       timeUnixNano(e.Time),
+			SpanId:             e.SpanID,
+			TraceId:            e.TraceID,
+		}
+		switch v := any(e.Value).(type) {
+		case int64:
+			pe.Value = &metricspb.Exemplar_AsInt{AsInt: v}
+		case float64:
+			pe.Value = &metricspb.Exemplar_AsDouble{AsDouble: v}
+		}
+		out = append(out, pe)
+	}
+	return out
+}
+
+// timeUnixNano returns t as nanoseconds since the Unix epoch, or 0 if t is zero.
+func timeUnixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// keyValues converts attrs to their OTLP representation.
+func keyValues(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: anyValue(kv.Value)})
+	}
+	return out
+}
+
+// anyValue converts v to its OTLP representation.
+func anyValue(v attribute.Value) *commonpb.AnyValue {
+	out := new(commonpb.AnyValue)
+	switch v.Type() {
+	case attribute.BOOL:
+		out.Value = &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}
+	case attribute.INT64:
+		out.Value = &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}
+	case attribute.FLOAT64:
+		out.Value = &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}
+	case attribute.STRING:
+		out.Value = &commonpb.AnyValue_StringValue{StringValue: v.AsString()}
+	case attribute.BOOLSLICE:
+		out.Value = arrayValue(v.AsBoolSlice(), func(b bool) *commonpb.AnyValue {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: b}}
+		})
+	case attribute.INT64SLICE:
+		out.Value = arrayValue(v.AsInt64Slice(), func(i int64) *commonpb.AnyValue {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: i}}
+		})
+	case attribute.FLOAT64SLICE:
+		out.Value = arrayValue(v.AsFloat64Slice(), func(f float64) *commonpb.AnyValue {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: f}}
+		})
+	case attribute.STRINGSLICE:
+		out.Value = arrayValue(v.AsStringSlice(), func(s string) *commonpb.AnyValue {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+		})
+	default:
+		out.Value = &commonpb.AnyValue_StringValue{StringValue: "INVALID"}
+	}
+	return out
+}
+
+// arrayValue converts a slice attribute value to its OTLP representation using convert for each element.
+func arrayValue[T any](values []T, convert func(T) *commonpb.AnyValue) *commonpb.AnyValue_ArrayValue {
+	out := &commonpb.ArrayValue{Values: make([]*commonpb.AnyValue, 0, len(values))}
+	for _, v := range values {
+		out.Values = append(out.Values, convert(v))
+	}
+	return &commonpb.AnyValue_ArrayValue{ArrayValue: out}
+}
+
+// metricData converts rm from its OTLP representation, so a queued export is replayed through the metrics exporter.
+// The sum, minimum and maximum of histograms are stored as doubles by OTLP, so histograms are converted to float64 histograms.
+func metricData(rm *metricspb.ResourceMetrics) (*metricdata.ResourceMetrics, error) {
+	out := &metricdata.ResourceMetrics{
+		Resource:     resource.NewWithAttributes(rm.GetSchemaUrl(), attributes(rm.GetResource().GetAttributes())...),
+		ScopeMetrics: make([]metricdata.ScopeMetrics, 0, len(rm.GetScopeMetrics())),
+	}
+	for _, sm := range rm.GetScopeMetrics() {
+		scope := metricdata.ScopeMetrics{
+			Scope:   instrumentation.Scope{Name: sm.GetScope().GetName(), Version: sm.GetScope().GetVersion(), SchemaURL: sm.GetSchemaUrl()},
+			Metrics: make([]metricdata.Metrics, 0, len(sm.GetMetrics())),
+		}
+		for _, m := range sm.GetMetrics() {
+			data, err := aggregation(m)
+			if err != nil {
+				return nil, err
+			}
+			scope.Metrics = append(scope.Metrics, metricdata.Metrics{Name: m.GetName(), Description: m.GetDescription(), Unit: m.GetUnit(), Data: data})
+		}
+		out.ScopeMetrics = append(out.ScopeMetrics, scope)
+	}
+	return out, nil
+}
+
+// aggregation converts the data of m from its OTLP representation.
+func aggregation(m *metricspb.Metric) (metricdata.Aggregation, error) {
+	switch d := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		if intPoints(d.Gauge.GetDataPoints()) {
+			return metricdata.Gauge[int64]{DataPoints: numberDataPoints[int64](d.Gauge.GetDataPoints())}, nil
+		}
+		return metricdata.Gauge[float64]{DataPoints: numberDataPoints[float64](d.Gauge.GetDataPoints())}, nil
+	case *metricspb.Metric_Sum:
+		if intPoints(d.Sum.GetDataPoints()) {
+			return metricdata.Sum[int64]{
+				DataPoints:  numberDataPoints[int64](d.Sum.GetDataPoints()),
+				Temporality: temporalityOf(d.Sum.GetAggregationTemporality()),
+				IsMonotonic: d.Sum.GetIsMonotonic(),
+			}, nil
+		}
+		return metricdata.Sum[float64]{
+			DataPoints:  numberDataPoints[float64](d.Sum.GetDataPoints()),
+			Temporality: temporalityOf(d.Sum.GetAggregationTemporality()),
+			IsMonotonic: d.Sum.GetIsMonotonic(),
+		}, nil
+	case *metricspb.Metric_Histogram:
+		return metricdata.Histogram[float64]{
+			DataPoints:  histogramDataPointsOf(d.Histogram.GetDataPoints()),
+			Temporality: temporalityOf(d.Histogram.GetAggregationTemporality()),
+		}, nil
+	case *metricspb.Metric_ExponentialHistogram:
+		return metricdata.ExponentialHistogram[float64]{
+			DataPoints:  exponentialHistogramDataPointsOf(d.ExponentialHistogram.GetDataPoints()),
+			Temporality: temporalityOf(d.ExponentialHistogram.GetAggregationTemporality()),
+		}, nil
+	default:
+		return nil, fmt.Errorf("metric %q has an unsupported aggregation %T", m.GetName(), d)
+	}
+}
+
+// temporalityOf converts t from its OTLP representation.
+func temporalityOf(t metricspb.AggregationTemporality) metricdata.Temporality {
+	switch t {
+	case metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA:
+		return metricdata.DeltaTemporality
+	case metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.Temporality(0)
+	}
+}
+
+// intPoints reports whether the data points of a gauge or sum hold integers.
+func intPoints(dps []*metricspb.NumberDataPoint) bool {
+	for _, dp := range dps {
+		if _, ok := dp.GetValue().(*metricspb.NumberDataPoint_AsInt); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// numberValue is an OTLP data point or exemplar holding an integer or a double.
+type numberValue interface {
+	GetAsInt() int64
+	GetAsDouble() float64
+}
+
+// number returns the value of v as N.
+func number[N int64 | float64](v numberValue) N {
+	var n N
+	if _, ok := any(n).(int64); ok {
+		return N(v.GetAsInt())
+	}
+	return N(v.GetAsDouble())
+}
+
+// numberDataPoints converts the data points of a gauge or sum from their OTLP representation.
+func numberDataPoints[N int64 | float64](dps []*metricspb.NumberDataPoint) []metricdata.DataPoint[N] {
+	out := make([]metricdata.DataPoint[N], 0, len(dps))
+	for _, dp := range dps {
+		out = append(out, metricdata.DataPoint[N]{
+			Attributes: attribute.NewSet(attributes(dp.GetAttributes())...),
+			StartTime:  timeOf(dp.GetStartTimeUnixNano()),
+			Time:       timeOf(dp.GetTimeUnixNano()),
+			Value:      number[N](dp),
+			Exemplars:  exemplarsOf[N](dp.GetExemplars()),
+		})
+	}
+	return out
+}
+
+// histogramDataPointsOf converts the data points of a histogram from their OTLP representation.
+func histogramDataPointsOf(dps []*metricspb.HistogramDataPoint) []metricdata.HistogramDataPoint[float64] {
+	out := make([]metricdata.HistogramDataPoint[float64], 0, len(dps))
+	for _, dp := range dps {
+		out = append(out, metricdata.HistogramDataPoint[float64]{
+			Attributes:   attribute.NewSet(attributes(dp.GetAttributes())...),
+			StartTime:    timeOf(dp.GetStartTimeUnixNano()),
+			Time:         timeOf(dp.GetTimeUnixNano()),
+			Count:        dp.GetCount(),
+			Sum:          dp.GetSum(),
+			Bounds:       dp.GetExplicitBounds(),
+			BucketCounts: dp.GetBucketCounts(),
+			Min:          extremaOf(dp.Min),
+			Max:          extremaOf(dp.Max),
+			Exemplars:    exemplarsOf[float64](dp.GetExemplars()),
+		})
+	}
+	return out
+}
+
+// exponentialHistogramDataPointsOf converts the data points of an exponential histogram from their OTLP representation.
+func exponentialHistogramDataPointsOf(dps []*metricspb.ExponentialHistogramDataPoint) []metricdata.ExponentialHistogramDataPoint[float64] {
+	out := make([]metricdata.ExponentialHistogramDataPoint[float64], 0, len(dps))
+	for _, dp := range dps {
+		out = append(out, metricdata.ExponentialHistogramDataPoint[float64]{
+			Attributes:     attribute.NewSet(attributes(dp.GetAttributes())...),
+			StartTime:      timeOf(dp.GetStartTimeUnixNano()),
+			Time:           timeOf(dp.GetTimeUnixNano()),
+			Count:          dp.GetCount(),
+			Sum:            dp.GetSum(),
+			Scale:          dp.GetScale(),
+			ZeroCount:      dp.GetZeroCount(),
+			ZeroThreshold:  dp.GetZeroThreshold(),
+			PositiveBucket: metricdata.ExponentialBucket{Offset: dp.GetPositive().GetOffset(), Counts: dp.GetPositive().GetBucketCounts()},
+			NegativeBucket: metricdata.ExponentialBucket{Offset: dp.GetNegative().GetOffset(), Counts: dp.GetNegative().GetBucketCounts()},
+			Min:            extremaOf(dp.Min),
+			Max:            extremaOf(dp.Max),
+			Exemplars:      exemplarsOf[float64](dp.GetExemplars()),
+		})
+	}
+	return out
+}
+
+// extremaOf returns the extrema of v, which is unset if v is nil.
+func extremaOf(v *float64) metricdata.Extrema[float64] {
+	if v == nil {
+		return metricdata.Extrema[float64]{}
+	}
+	return metricdata.NewExtrema(*v)
+}
+
+// exemplarsOf converts exemplars from their OTLP representation.
+func exemplarsOf[N int64 | float64](exemplars []*metricspb.Exemplar) []metricdata.Exemplar[N] {
+	if len(exemplars) == 0 {
+		return nil
+	}
+	out := make([]metricdata.Exemplar[N], 0, len(exemplars))
+	for _, e := range exemplars {
+		out = append(out, metricdata.Exemplar[N]{
+			FilteredAttributes: attributes(e.GetFilteredAttributes()),
+			Time:               timeOf(e.GetTimeUnixNano()),
+			Value:              number[N](e),
+			SpanID:             e.GetSpanId(),
+			TraceID:            e.GetTraceId(),
+		})
+	}
+	return out
+}
+
+// timeOf returns the time of nanoseconds since the Unix epoch, or the zero time if ns is 0.
+func timeOf(ns uint64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ns))
+}
+
+// attributes converts kvs from their OTLP representation.
+func attributes(kvs []*commonpb.KeyValue) []attribute.KeyValue {
+	if len(kvs) == 0 {
+		return nil
+	}
+	out := make([]attribute.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		out = append(out, attribute.KeyValue{Key: attribute.Key(kv.GetKey()), Value: attributeValue(kv.GetValue())})
+	}
+	return out
+}
+
+// attributeValue converts v from its OTLP representation. Arrays take the type of their first element.
+func attributeValue(v *commonpb.AnyValue) attribute.Value {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_BoolValue:
+		return attribute.BoolValue(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return attribute.Int64Value(v.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		return attribute.Float64Value(v.DoubleValue)
+	case *commonpb.AnyValue_StringValue:
+		return attribute.StringValue(v.StringValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := v.ArrayValue.GetValues()
+		if len(values) == 0 {
+			return attribute.StringSliceValue(nil)
+		}
+		switch values[0].GetValue().(type) {
+		case *commonpb.AnyValue_BoolValue:
+			return attribute.BoolSliceValue(sliceOf(values, (*commonpb.AnyValue).GetBoolValue))
+		case *commonpb.AnyValue_IntValue:
+			return attribute.Int64SliceValue(sliceOf(values, (*commonpb.AnyValue).GetIntValue))
+		case *commonpb.AnyValue_DoubleValue:
+			return attribute.Float64SliceValue(sliceOf(values, (*commonpb.AnyValue).GetDoubleValue))
+		default:
+			return attribute.StringSliceValue(sliceOf(values, (*commonpb.AnyValue).GetStringValue))
+		}
+	default:
+		return attribute.StringValue("INVALID")
+	}
+}
+
+// sliceOf converts the elements of an OTLP array using convert.
+func sliceOf[T any](values []*commonpb.AnyValue, convert func(*commonpb.AnyValue) T) []T {
+	out := make([]T, 0, len(values))
+	for _, v := range values {
+		out = append(out, convert(v))
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package otel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// The sources the service instance ID can be derived from.
+const (
+	// InstanceIDSourceAuto uses the pod name if running in Kubernetes, the state directory if set and the hostname otherwise.
+	InstanceIDSourceAuto string = "auto"
+	// InstanceIDSourcePod derives the ID from the Kubernetes pod name.
+	InstanceIDSourcePod string = "pod"
+	// InstanceIDSourceHostname derives the ID from the hostname.
+	InstanceIDSourceHostname string = "hostname"
+	// InstanceIDSourceFile persists a random ID in the state directory.
+	InstanceIDSourceFile string = "file"
+	// InstanceIDSourceRandom generates a new random ID on every start.
+	InstanceIDSourceRandom string = "random"
+)
+
+// InstanceIDFile is the name of the file the instance ID is persisted in within the state directory.
+const InstanceIDFile string = "instance-id"
+
+// instanceIDNamespace is the namespace of the name-based instance IDs, so the same pod name always results in the same ID.
+var instanceIDNamespace = uuid.MustParse("4d63009a-8d0f-11ee-b9d1-0242ac120002")
+
+// InstanceIDConfig is a struct that represents the configuration of the service instance ID.
+type InstanceIDConfig struct {
+	// ServiceName is the name of the service. It is part of the name-based IDs, so services sharing a pod have different IDs.
+	ServiceName string
+	// Source is the source of the ID, one of the InstanceIDSource constants. If empty, InstanceIDSourceAuto is used.
+	Source string
+	// StateDir is the directory the ID is persisted in by InstanceIDSourceFile. It has to survive restarts, e.g. a persistent volume.
+	StateDir string
+}
+
+/*
+NewInstanceID returns the ID of the service instance used as the service.instance.id resource attribute.
+IDs derived from a pod name or hostname are UUIDv5 of the service name and that name, so they are stable across restarts of the same pod or host.
+IDs of InstanceIDSourceFile are random UUIDs persisted in the state directory on the first start and read on the following ones.
+
+Parameters:
+  - config: The configuration of the ID.
+
+Returns:
+  - string: The ID of the service instance.
+  - error: An error if the source is unknown, the pod name or hostname is not available or the persisted ID could not be read or written.
+
+Example usage:
+
+	iid, err := NewInstanceID(InstanceIDConfig{ServiceName: "auth-service", StateDir: "/var/lib/auth-service"})
+*/
+func NewInstanceID(config InstanceIDConfig) (string, error) {
+	switch config.Source {
+	case "", InstanceIDSourceAuto:
+		if podName := os.Getenv(EnvK8SPodName); podName != "" {
+			return nameBasedInstanceID(config.ServiceName, podName), nil
+		}
+		if config.StateDir != "" {
+			return persistedInstanceID(config.StateDir)
+		}
+		if hostname, err := os.Hostname(); err == nil && hostname != "" {
+			return nameBasedInstanceID(config.ServiceName, hostname), nil
+		}
+		return uuid.New().String(), nil
+	case InstanceIDSourcePod:
+		podName := os.Getenv(EnvK8SPodName)
+		if podName == "" {
+			return "", fmt.Errorf("instance ID source %q requires the %s environment variable", config.Source, EnvK8SPodName)
+		}
+		return nameBasedInstanceID(config.ServiceName, podName), nil
+	case InstanceIDSourceHostname:
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", err
+		}
+		return nameBasedInstanceID(config.ServiceName, hostname), nil
+	case InstanceIDSourceFile:
+		if config.StateDir == "" {
+			return "", fmt.Errorf("instance ID source %q requires a state directory", config.Source)
+		}
+		return persistedInstanceID(config.StateDir)
+	case InstanceIDSourceRandom:
+		return uuid.New().String(), nil
+	default:
+		return "", fmt.Errorf("unknown instance ID source %q", config.Source)
+	}
+}
+
+// nameBasedInstanceID returns the UUIDv5 of the service name and the pod name or hostname.
+func nameBasedInstanceID(serviceName string, name string) string {
+	return uuid.NewSHA1(instanceIDNamespace, []byte(serviceName+"/"+name)).String()
+}
+
+// persistedInstanceID returns the ID persisted in dir, generating and persisting a new one if there is none.
+func persistedInstanceID(dir string) (string, error) {
+	path := filepath.Join(dir, InstanceIDFile)
+	b, err := os.ReadFile(path)
+	if err == nil {
+		id, err := uuid.Parse(strings.TrimSpace(string(b)))
+		if err != nil {
+			return "", fmt.Errorf("invalid instance ID in %s: %w", path, err)
+		}
+		return id.String(), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	id := uuid.New().String()
+	// write to a temporary file first so a crash does not leave a partial ID behind
+	tmp, err := os.CreateTemp(dir, InstanceIDFile+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(id + "\n"); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return id, nil
+}
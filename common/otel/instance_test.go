@@ -0,0 +1,93 @@
+package otel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewInstanceIDNameBased(t *testing.T) {
+	t.Setenv(EnvK8SPodName, "auth-0")
+	first, err := NewInstanceID(InstanceIDConfig{ServiceName: "auth-service", Source: InstanceIDSourcePod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the auto source prefers the pod name over the state directory
+	second, err := NewInstanceID(InstanceIDConfig{ServiceName: "auth-service", StateDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("IDs of the same pod differ: %s and %s", first, second)
+	}
+	other, err := NewInstanceID(InstanceIDConfig{ServiceName: "other-service", Source: InstanceIDSourcePod})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Error("services sharing a pod have the same ID")
+	}
+	if _, err := uuid.Parse(first); err != nil {
+		t.Errorf("ID %q is not a UUID: %v", first, err)
+	}
+}
+
+func TestNewInstanceIDPersisted(t *testing.T) {
+	unsetenv(t, EnvK8SPodName)
+	dir := filepath.Join(t.TempDir(), "state")
+	first, err := NewInstanceID(InstanceIDConfig{ServiceName: "auth-service", Source: InstanceIDSourceFile, StateDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the auto source uses the state directory outside of Kubernetes
+	second, err := NewInstanceID(InstanceIDConfig{ServiceName: "auth-service", StateDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("persisted ID changed from %s to %s", first, second)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != InstanceIDFile {
+		t.Errorf("unexpected files %v in the state directory", entries)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, InstanceIDFile), []byte("invalid\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewInstanceID(InstanceIDConfig{Source: InstanceIDSourceFile, StateDir: dir}); err == nil {
+		t.Error("invalid persisted ID accepted")
+	}
+}
+
+func TestNewInstanceIDRandom(t *testing.T) {
+	first, err := NewInstanceID(InstanceIDConfig{Source: InstanceIDSourceRandom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewInstanceID(InstanceIDConfig{Source: InstanceIDSourceRandom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("random IDs are equal")
+	}
+}
+
+func TestNewInstanceIDErrors(t *testing.T) {
+	unsetenv(t, EnvK8SPodName)
+	for _, config := range []InstanceIDConfig{
+		{Source: InstanceIDSourcePod},
+		{Source: InstanceIDSourceFile},
+		{Source: "unknown"},
+	} {
+		if _, err := NewInstanceID(config); err == nil {
+			t.Errorf("source %q: got no error", config.Source)
+		}
+	}
+}
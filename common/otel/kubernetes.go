@@ -0,0 +1,99 @@
+package otel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// The environment variables the Kubernetes downward API is expected to expose the pod metadata in.
+const (
+	EnvK8SPodName       string = "K8S_POD_NAME"
+	EnvK8SPodUID        string = "K8S_POD_UID"
+	EnvK8SNamespaceName string = "K8S_NAMESPACE_NAME"
+	EnvK8SNodeName      string = "K8S_NODE_NAME"
+)
+
+const (
+	// DefaultPodInfoDir is the default mount path of the downward API volume with the "name", "namespace" and "uid" files.
+	DefaultPodInfoDir string = "/etc/podinfo"
+	// serviceAccountNamespaceFile is the file holding the namespace of the pod if a service account token is mounted.
+	serviceAccountNamespaceFile string = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// kubernetesDetector is a sdkresource.Detector detecting the pod, namespace and node from the Kubernetes downward API.
+type kubernetesDetector struct {
+	podInfoDir string
+}
+
+var _ sdkresource.Detector = (*kubernetesDetector)(nil)
+
+/*
+Detect returns the Kubernetes attributes of the pod the process runs in, or an empty resource outside of Kubernetes.
+The environment variables take precedence over the files of the downward API volume in podInfoDir.
+The namespace falls back to the namespace of the mounted service account and the pod name to the hostname, which Kubernetes sets to the pod name.
+*/
+func (d *kubernetesDetector) Detect(_ context.Context) (*sdkresource.Resource, error) {
+	_, inCluster := os.LookupEnv("KUBERNETES_SERVICE_HOST")
+
+	podName := firstNonEmpty(os.Getenv(EnvK8SPodName), d.readFile("name"))
+	podUID := firstNonEmpty(os.Getenv(EnvK8SPodUID), d.readFile("uid"))
+	namespace := firstNonEmpty(os.Getenv(EnvK8SNamespaceName), d.readFile("namespace"))
+	nodeName := os.Getenv(EnvK8SNodeName)
+	if !inCluster && podName == "" && namespace == "" {
+		return sdkresource.Empty(), nil
+	}
+	if namespace == "" {
+		namespace = readTrimmed(serviceAccountNamespaceFile)
+	}
+	if podName == "" {
+		podName, _ = os.Hostname()
+	}
+
+	var attrs []attribute.KeyValue
+	if podName != "" {
+		attrs = append(attrs, semconv.K8SPodName(podName))
+	}
+	if podUID != "" {
+		attrs = append(attrs, semconv.K8SPodUID(podUID))
+	}
+	if namespace != "" {
+		attrs = append(attrs, semconv.K8SNamespaceName(namespace))
+	}
+	if nodeName != "" {
+		attrs = append(attrs, semconv.K8SNodeName(nodeName))
+	}
+	return sdkresource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// readFile returns the trimmed content of the file name in the downward API volume, or an empty string if it cannot be read.
+func (d *kubernetesDetector) readFile(name string) string {
+	if d.podInfoDir == "" {
+		return ""
+	}
+	return readTrimmed(filepath.Join(d.podInfoDir, name))
+}
+
+// readTrimmed returns the trimmed content of the file at path, or an empty string if it cannot be read.
+func readTrimmed(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// firstNonEmpty returns the first of values which is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
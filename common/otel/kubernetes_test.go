@@ -0,0 +1,83 @@
+package otel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePodInfo writes the files of a downward API volume to a temporary directory and returns it.
+func writePodInfo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// unsetKubernetesEnv unsets the environment variables read by the kubernetesDetector for the test.
+func unsetKubernetesEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"KUBERNETES_SERVICE_HOST", EnvK8SPodName, EnvK8SPodUID, EnvK8SNamespaceName, EnvK8SNodeName} {
+		unsetenv(t, key)
+	}
+}
+
+func TestKubernetesDetectorOutsideKubernetes(t *testing.T) {
+	unsetKubernetesEnv(t)
+	res, err := (&kubernetesDetector{podInfoDir: t.TempDir()}).Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Len() != 0 {
+		t.Errorf("got attributes %v outside of Kubernetes", res.Attributes())
+	}
+}
+
+func TestKubernetesDetectorPodInfo(t *testing.T) {
+	unsetKubernetesEnv(t)
+	dir := writePodInfo(t, map[string]string{"name": "auth-0", "namespace": "auth", "uid": "uid-0"})
+	res, err := (&kubernetesDetector{podInfoDir: dir}).Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAttribute(t, res, "k8s.pod.name", "auth-0")
+	assertAttribute(t, res, "k8s.namespace.name", "auth")
+	assertAttribute(t, res, "k8s.pod.uid", "uid-0")
+	if _, ok := res.Set().Value("k8s.node.name"); ok {
+		t.Error("k8s.node.name set without the environment variable")
+	}
+}
+
+func TestKubernetesDetectorEnvPrecedence(t *testing.T) {
+	unsetKubernetesEnv(t)
+	t.Setenv(EnvK8SPodName, "auth-1")
+	t.Setenv(EnvK8SNodeName, "node-1")
+	dir := writePodInfo(t, map[string]string{"name": "auth-0", "namespace": "auth"})
+	res, err := (&kubernetesDetector{podInfoDir: dir}).Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAttribute(t, res, "k8s.pod.name", "auth-1")
+	assertAttribute(t, res, "k8s.namespace.name", "auth")
+	assertAttribute(t, res, "k8s.node.name", "node-1")
+}
+
+func TestKubernetesDetectorHostnameFallback(t *testing.T) {
+	unsetKubernetesEnv(t)
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		t.Skip("no hostname")
+	}
+	res, err := (&kubernetesDetector{}).Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Kubernetes sets the hostname to the pod name
+	assertAttribute(t, res, "k8s.pod.name", hostname)
+}
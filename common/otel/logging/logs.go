@@ -11,7 +11,13 @@ import (
 )
 
 func NewOTLPLogsExporter(ctx context.Context, otlpEndpoint string, exportTimeout time.Duration) (*otlplogs.Exporter, error) {
-	exporter, err := otlplogs.NewExporter(ctx, otlplogs.WithClient(otlplogshttp.NewClient(
+	exporter, err := otlplogs.NewExporter(ctx, otlplogs.WithClient(NewOTLPLogsClient(otlpEndpoint, exportTimeout)))
+	return exporter, err
+}
+
+// NewOTLPLogsClient creates the OTLP/HTTP client used by NewOTLPLogsExporter, e.g. to wrap it with a disk queue.
+func NewOTLPLogsClient(otlpEndpoint string, exportTimeout time.Duration) otlplogs.Client {
+	return otlplogshttp.NewClient(
 		otlplogshttp.WithRetry(otlplogshttp.RetryConfig{
 			Enabled:        true,
 			MaxElapsedTime: time.Minute,
@@ -19,8 +25,7 @@ func NewOTLPLogsExporter(ctx context.Context, otlpEndpoint string, exportTimeout
 		otlplogshttp.WithTimeout(exportTimeout),
 		otlplogshttp.WithInsecure(),
 		otlplogshttp.WithEndpoint(otlpEndpoint),
-	)))
-	return exporter, err
+	)
 }
 
 func NewLogProvider(res *sdkresource.Resource, exporter sdklogs.LogRecordExporter, batchTimeout time.Duration) (*sdklogs.LoggerProvider, error) {
@@ -0,0 +1,181 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/agoda-com/opentelemetry-logs-go/logs"
+	sdklogs "github.com/agoda-com/opentelemetry-logs-go/sdk/logs"
+	"go.opentelemetry.io/otel/attribute"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/logging"
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/selfobs"
+)
+
+// CategoryKey is the attribute key log records are routed by, e.g. logger.With("category", "audit").
+const CategoryKey attribute.Key = "category"
+
+// RouteConfig is a struct that represents the rules selecting the log records of a pipeline. A record has to match all set rules.
+type RouteConfig struct {
+	// MinLevel is the lowest level routed to the pipeline, one of "debug", "info", "warn" or "error". If empty, all levels are routed.
+	MinLevel string
+	// Categories are the values of CategoryKey routed to the pipeline. If empty, records with any or no category are routed.
+	Categories []string
+	// ExcludeCategories are the values of CategoryKey not routed to the pipeline, e.g. to keep audit logs out of the application pipeline.
+	ExcludeCategories []string
+}
+
+// matcher returns the function reporting whether a log record matches the rules of route.
+func (route RouteConfig) matcher() func(sdklogs.ReadableLogRecord) bool {
+	minSeverity := logs.UNSPECIFIED
+	if route.MinLevel != "" {
+		// slog levels map to OpenTelemetry severities with an offset of 9, see the otelslog handler
+		minSeverity = logs.SeverityNumber(int(logging.LogLevelStringToSlogLevel(route.MinLevel)) + 9)
+	}
+	include := stringSet(route.Categories)
+	exclude := stringSet(route.ExcludeCategories)
+
+	return func(r sdklogs.ReadableLogRecord) bool {
+		if minSeverity != logs.UNSPECIFIED {
+			if s := r.SeverityNumber(); s == nil || *s < minSeverity {
+				return false
+			}
+		}
+		if include == nil && exclude == nil {
+			return true
+		}
+		category, ok := recordCategory(r)
+		if include != nil {
+			if _, found := include[category]; !ok || !found {
+				return false
+			}
+		}
+		if _, found := exclude[category]; ok && found {
+			return false
+		}
+		return true
+	}
+}
+
+// stringSet returns values as a set, or nil if values is empty.
+func stringSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+// recordCategory returns the value of CategoryKey of r and whether it is set.
+func recordCategory(r sdklogs.ReadableLogRecord) (string, bool) {
+	attrs := r.Attributes()
+	if attrs == nil {
+		return "", false
+	}
+	for _, kv := range *attrs {
+		if kv.Key == CategoryKey {
+			return kv.Value.Emit(), true
+		}
+	}
+	return "", false
+}
+
+// routingProcessor is a sdklogs.LogRecordProcessor passing only the log records matching its route to the wrapped processor.
+type routingProcessor struct {
+	sdklogs.LogRecordProcessor
+	match func(sdklogs.ReadableLogRecord) bool
+}
+
+// NewRoutingProcessor wraps processor so it only processes the log records matching route.
+func NewRoutingProcessor(processor sdklogs.LogRecordProcessor, route RouteConfig) sdklogs.LogRecordProcessor {
+	return &routingProcessor{LogRecordProcessor: processor, match: route.matcher()}
+}
+
+// OnEmit passes r to the wrapped processor if it matches the route.
+func (p *routingProcessor) OnEmit(r sdklogs.ReadableLogRecord) {
+	if p.match(r) {
+		p.LogRecordProcessor.OnEmit(r)
+	}
+}
+
+// Shutdown shuts down the wrapped processor.
+func (p *routingProcessor) Shutdown(ctx context.Context) error {
+	return p.LogRecordProcessor.Shutdown(ctx)
+}
+
+// ForceFlush flushes the wrapped processor.
+func (p *routingProcessor) ForceFlush(ctx context.Context) error {
+	return p.LogRecordProcessor.ForceFlush(ctx)
+}
+
+// Pipeline is a struct that represents a named log pipeline with its own exporter, batching and routing rules.
+type Pipeline struct {
+	// Name is the name of the pipeline, e.g. "audit".
+	Name string
+	// Exporter is the exporter the routed log records are exported with.
+	Exporter sdklogs.LogRecordExporter
+	// BatchTimeout is the maximum delay before a batch is exported.
+	BatchTimeout time.Duration
+	// MaxQueueSize is the maximum number of log records buffered by the pipeline. If zero, the SDK default is used.
+	MaxQueueSize int
+	// MaxExportBatchSize is the maximum number of log records in a single export. If zero, the SDK default is used.
+	MaxExportBatchSize int
+	// Route selects the log records exported by the pipeline.
+	Route RouteConfig
+	// Tracker records the self-observability metrics of the pipeline if set. It has to be created with the queue size of the pipeline.
+	Tracker *selfobs.Tracker
+}
+
+// processor returns the batching processor of the pipeline wrapped in its routing rules.
+func (p Pipeline) processor() sdklogs.LogRecordProcessor {
+	exporter := p.Exporter
+	if p.Tracker != nil {
+		exporter = p.Tracker.LogExporter(exporter)
+	}
+	opts := []sdklogs.BatchLogRecordProcessorOption{sdklogs.WithBatchTimeout(p.BatchTimeout)}
+	if p.MaxQueueSize > 0 {
+		opts = append(opts, sdklogs.WithMaxQueueSize(p.MaxQueueSize))
+	}
+	if p.MaxExportBatchSize > 0 {
+		opts = append(opts, sdklogs.WithMaxExportBatchSize(p.MaxExportBatchSize))
+	}
+	processor := sdklogs.NewBatchLogRecordProcessor(exporter, opts...)
+	if p.Tracker != nil {
+		processor = p.Tracker.LogProcessor(processor)
+	}
+	return NewRoutingProcessor(processor, p.Route)
+}
+
+/*
+NewLogProviderWithPipelines creates a LoggerProvider exporting log records through multiple pipelines.
+Every log record is offered to every pipeline and exported by each pipeline whose route it matches, so e.g. audit logs can be
+exported to a different endpoint with different batching than application logs.
+
+Parameters:
+  - res: The resource describing the service.
+  - pipelines: The pipelines of the LoggerProvider.
+
+Returns:
+  - *sdklogs.LoggerProvider: The created LoggerProvider.
+  - error: Always nil, returned for symmetry with NewLogProvider.
+
+Example usage:
+
+	lp, err := NewLogProviderWithPipelines(res, []Pipeline{
+		{Name: "audit", Exporter: auditExporter, BatchTimeout: time.Second, Route: RouteConfig{Categories: []string{"audit"}}},
+		{Name: "app", Exporter: appExporter, BatchTimeout: 30 * time.Second, Route: RouteConfig{ExcludeCategories: []string{"audit"}}},
+	})
+
+Note: The level of the slog handler created by NewSlogOtelHandler is applied before the routing, so it has to be as low as the lowest MinLevel.
+*/
+func NewLogProviderWithPipelines(res *sdkresource.Resource, pipelines []Pipeline) (*sdklogs.LoggerProvider, error) {
+	opts := []sdklogs.LoggerProviderOption{sdklogs.WithResource(res)}
+	for _, p := range pipelines {
+		opts = append(opts, sdklogs.WithLogRecordProcessor(p.processor()))
+	}
+	return sdklogs.NewLoggerProvider(opts...), nil
+}
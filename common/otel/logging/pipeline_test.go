@@ -0,0 +1,116 @@
+package logging_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/agoda-com/opentelemetry-logs-go/logs"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/logging"
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/oteltest"
+)
+
+// emit logs the test records through pipelines and returns the bodies exported by every pipeline.
+func emit(t *testing.T, pipelines ...logging.Pipeline) [][]string {
+	t.Helper()
+	exporters := make([]*oteltest.InMemoryLogExporter, len(pipelines))
+	for i := range pipelines {
+		exporters[i] = oteltest.NewInMemoryLogExporter()
+		pipelines[i].Exporter = exporters[i]
+	}
+	lp, err := logging.NewLogProviderWithPipelines(sdkresource.Empty(), pipelines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lp.Shutdown(context.Background())
+
+	logger := slog.New(logging.NewSlogOtelHandler(lp, "debug"))
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+	logger.With(string(logging.CategoryKey), "audit").Info("audit")
+	logger.With(string(logging.CategoryKey), "access").Warn("access")
+	if err := lp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := make([][]string, len(exporters))
+	for i, exporter := range exporters {
+		for _, r := range exporter.GetLogRecords() {
+			bodies[i] = append(bodies[i], *r.Body)
+		}
+	}
+	return bodies
+}
+
+// assertBodies fails the test if got does not hold exactly want in order.
+func assertBodies(t *testing.T, name string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: got records %q, want %q", name, got, want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: got records %q, want %q", name, got, want)
+			return
+		}
+	}
+}
+
+func TestPipelinesRouteCategories(t *testing.T) {
+	bodies := emit(t,
+		logging.Pipeline{Name: "audit", Route: logging.RouteConfig{Categories: []string{"audit"}}},
+		logging.Pipeline{Name: "app", Route: logging.RouteConfig{ExcludeCategories: []string{"audit"}}},
+		logging.Pipeline{Name: "all"},
+	)
+	assertBodies(t, "audit", bodies[0], "audit")
+	// records without a category are not excluded
+	assertBodies(t, "app", bodies[1], "debug", "info", "warn", "error", "access")
+	assertBodies(t, "all", bodies[2], "debug", "info", "warn", "error", "audit", "access")
+}
+
+func TestPipelinesRouteLevels(t *testing.T) {
+	bodies := emit(t,
+		logging.Pipeline{Name: "warn", Route: logging.RouteConfig{MinLevel: "warn"}},
+		logging.Pipeline{Name: "info", Route: logging.RouteConfig{MinLevel: "info"}},
+		logging.Pipeline{Name: "access", Route: logging.RouteConfig{MinLevel: "warn", Categories: []string{"access", "audit"}}},
+	)
+	assertBodies(t, "warn", bodies[0], "warn", "error", "access")
+	assertBodies(t, "info", bodies[1], "info", "warn", "error", "audit", "access")
+	// a record has to match all rules
+	assertBodies(t, "access", bodies[2], "access")
+}
+
+func TestSeverityMapping(t *testing.T) {
+	exporter := oteltest.NewInMemoryLogExporter()
+	lp, err := logging.NewLogProviderWithPipelines(sdkresource.Empty(), []logging.Pipeline{{Name: "all", Exporter: exporter}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lp.Shutdown(context.Background())
+
+	// slog levels are offset by 9 from the OpenTelemetry severities, the routing relies on it
+	logger := slog.New(logging.NewSlogOtelHandler(lp, "debug"))
+	want := map[string]logs.SeverityNumber{"debug": logs.DEBUG, "info": logs.INFO, "warn": logs.WARN, "error": logs.ERROR}
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+	if err := lp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	records := exporter.GetLogRecords()
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for _, r := range records {
+		if r.SeverityNumber == nil || *r.SeverityNumber != want[*r.Body] {
+			t.Errorf("%s: got severity %v, want %d", *r.Body, r.SeverityNumber, want[*r.Body])
+		}
+	}
+}
@@ -14,6 +14,11 @@ func NewSlogOtelHandler(logProvider logs.LoggerProvider, logLevel string) *otels
 	return otelHandler
 }
 
+// NewSlogOtelHandlerWithLeveler creates the OpenTelemetry slog handler whose level is read from logLevel on every record, e.g. a *slog.LevelVar of logging.Levels.
+func NewSlogOtelHandlerWithLeveler(logProvider logs.LoggerProvider, logLevel slog.Leveler) *otelslog.OtelHandler {
+	return otelslog.NewOtelHandler(logProvider, &otelslog.HandlerOptions{Level: logLevel})
+}
+
 func NewSlogOtelCompositeLogger(slogHandler slog.Handler, otelHandler *otelslog.OtelHandler) *slog.Logger {
 	h := slogmulti.Fanout(slogHandler, otelHandler)
 	return slog.New(h)
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// DefaultUnmatchedRouteLabel is the default path label recorded for requests which did not match any route. It is set to "unmatched".
+	DefaultUnmatchedRouteLabel string = "unmatched"
+	// OtherHostLabel is the host label recorded for hosts which are not in the host allowlist. It is set to "other".
+	OtherHostLabel string = "other"
+	// OtherMethodLabel is the method label recorded for non-standard HTTP methods. It is set to "_OTHER" as in the OpenTelemetry HTTP semantic conventions.
+	OtherMethodLabel string = "_OTHER"
+	// DefaultMaxAttributeSets is the default maximum number of distinct attribute sets recorded per instrument. It is set to 2000.
+	DefaultMaxAttributeSets int = 2000
+)
+
+const (
+	// limitReasonKey is the attribute key describing which cardinality limit was applied.
+	limitReasonKey attribute.Key = "reason"
+	// limitInstrumentKey is the attribute key holding the name of the instrument an overflow occurred on.
+	limitInstrumentKey attribute.Key = "instrument"
+
+	// limitReasonUnmatchedRoute is recorded when the path of an unmatched request is collapsed to the unmatched route label.
+	limitReasonUnmatchedRoute string = "unmatched_route"
+	// limitReasonHost is recorded when a host outside of the allowlist is collapsed to OtherHostLabel.
+	limitReasonHost string = "host"
+	// limitReasonMethod is recorded when a non-standard method is collapsed to OtherMethodLabel.
+	limitReasonMethod string = "method"
+	// limitReasonOverflow is recorded when a measurement is moved to the overflow attribute set.
+	limitReasonOverflow string = "overflow"
+)
+
+// overflowAttributeSet is the attribute set measurements are recorded with once an instrument reached its attribute set limit.
+// The key follows the OpenTelemetry specification for cardinality limits.
+var overflowAttributeSet = attribute.NewSet(attribute.Bool("otel.metric.overflow", true))
+
+// knownMethods is the set of HTTP methods recorded as-is in the method label.
+var knownMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodConnect: {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+}
+
+// methodLabel returns the method label for method and whether the method is a standard HTTP method.
+func methodLabel(method string) (string, bool) {
+	if _, ok := knownMethods[method]; ok {
+		return method, true
+	}
+	return OtherMethodLabel, false
+}
+
+// hostAllowlist maps request hosts to host labels. Hosts are compared case-insensitively, with or without a port.
+type hostAllowlist map[string]struct{}
+
+// newHostAllowlist creates a hostAllowlist from hosts. It returns nil if hosts is empty, which disables host filtering.
+func newHostAllowlist(hosts []string) hostAllowlist {
+	if len(hosts) == 0 {
+		return nil
+	}
+	l := make(hostAllowlist, len(hosts))
+	for _, h := range hosts {
+		l[strings.ToLower(h)] = struct{}{}
+	}
+	return l
+}
+
+// label returns the host label for host and whether the host is allowed. Hosts are always allowed when filtering is disabled.
+func (l hostAllowlist) label(host string) (string, bool) {
+	if l == nil {
+		return host, true
+	}
+	host = strings.ToLower(host)
+	if _, ok := l[host]; ok {
+		return host, true
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		if _, ok := l[hostname]; ok {
+			return host, true
+		}
+	}
+	return OtherHostLabel, false
+}
+
+// attributeSetLimiter limits the number of distinct attribute sets recorded by a single instrument.
+type attributeSetLimiter struct {
+	mu      sync.Mutex
+	maxSets int
+	seen    map[attribute.Distinct]struct{}
+	limited metric.Int64Counter
+	// overflowAttrs are the attributes the limit counter is incremented with on overflow.
+	overflowAttrs metric.AddOption
+}
+
+// newAttributeSetLimiter creates an attributeSetLimiter for the instrument named instrument. A maxSets of zero or less disables the limit.
+func newAttributeSetLimiter(instrument string, maxSets int, limited metric.Int64Counter) *attributeSetLimiter {
+	return &attributeSetLimiter{
+		maxSets: maxSets,
+		seen:    make(map[attribute.Distinct]struct{}),
+		limited: limited,
+		overflowAttrs: metric.WithAttributes(
+			limitReasonKey.String(limitReasonOverflow),
+			limitInstrumentKey.String(instrument),
+		),
+	}
+}
+
+// attributes returns the measurement option recording attrs, or the overflow attribute set if the limit was reached and attrs were not seen before.
+func (l *attributeSetLimiter) attributes(ctx context.Context, attrs attribute.Set) metric.MeasurementOption {
+	if l.maxSets <= 0 || l.allow(attrs) {
+		return metric.WithAttributeSet(attrs)
+	}
+	l.limited.Add(ctx, 1, l.overflowAttrs)
+	return metric.WithAttributeSet(overflowAttributeSet)
+}
+
+// allow reports whether attrs may be recorded, remembering attrs if there is room left.
+func (l *attributeSetLimiter) allow(attrs attribute.Set) bool {
+	key := attrs.Equivalent()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[key]; ok {
+		return true
+	}
+	// one slot is reserved for the overflow attribute set itself
+	if len(l.seen) >= l.maxSets-1 {
+		return false
+	}
+	l.seen[key] = struct{}{}
+	return true
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Catalog is a struct that represents the documentation of the business instruments of a service.
+type Catalog struct {
+	// Service is the name of the service the instruments belong to.
+	Service string `json:"service"`
+	// Metrics are the declared instruments sorted by name.
+	Metrics []Definition `json:"metrics"`
+}
+
+// Catalog returns the catalog of the instruments declared in r for the service named service.
+func (r *Registry) Catalog(service string) Catalog {
+	return Catalog{
+		Service: service,
+		Metrics: r.Definitions(),
+	}
+}
+
+// WriteJSON writes the catalog as indented JSON to w.
+func (c Catalog) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+/*
+WriteMarkdown writes the catalog as a Markdown document to w.
+The document contains a heading with the service name and a table with a row per instrument.
+
+Parameters:
+  - w: The writer the document is written to.
+
+Returns:
+  - error: An error if writing to w failed.
+*/
+func (c Catalog) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s metrics\n\n", c.Service)
+	b.WriteString("| Name | Kind | Unit | Attributes | Description |\n")
+	b.WriteString("| ---- | ---- | ---- | ---------- | ----------- |\n")
+	for _, def := range c.Metrics {
+		keys := make([]string, len(def.AttributeKeys))
+		for i, k := range def.AttributeKeys {
+			keys[i] = fmt.Sprintf("`%s`", k)
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | `%s` | %s | %s |\n",
+			def.Name,
+			def.Kind,
+			def.Unit,
+			strings.Join(keys, ", "),
+			strings.ReplaceAll(def.Description, "|", `\|`),
+		)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
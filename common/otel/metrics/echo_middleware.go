@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"runtime/debug"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -55,6 +56,104 @@ type Config struct {
 	MeterName string
 	// InstrumentNamePrefix is the prefix added to the names of the created metrics.
 	InstrumentNamePrefix string
+	// UnmatchedRouteLabel is the path label recorded for requests which did not match any route. Defaults to DefaultUnmatchedRouteLabel.
+	UnmatchedRouteLabel string
+	// AllowedHosts is the list of hosts recorded as-is in the host label. Other hosts are recorded as OtherHostLabel. If empty, hosts are recorded unfiltered.
+	AllowedHosts []string
+	// MaxAttributeSets is the maximum number of distinct attribute sets recorded per instrument. Further attribute sets are recorded as a single overflow set.
+	// Defaults to DefaultMaxAttributeSets, a negative value disables the limit.
+	MaxAttributeSets int
+	// SemConv switches the middleware to the instruments and attributes of the OpenTelemetry HTTP semantic conventions, see newSemConvInstruments.
+	// InstrumentNamePrefix and UnmatchedRouteLabel are not applied to these instruments.
+	SemConv bool
+	// RequestDuration configures the request duration histogram. Defaults to secondsBucket, or to the advised boundaries in SemConv mode.
+	RequestDuration HistogramConfig
+	// RequestSize configures the request size histogram. Defaults to bytesBucket.
+	RequestSize HistogramConfig
+	// ResponseSize configures the response size histogram. Defaults to bytesBucket.
+	ResponseSize HistogramConfig
+	// RecoverPanics makes the middleware turn panics of the next handlers into errors with status 500 instead of letting them propagate after recording them. A recovered panic is returned with its stack as the internal error.
+	RecoverPanics bool
+	// ExcludeStreamingTime ends the request duration measurement at the first flush of the response, so the time spent streaming a response is not recorded.
+	ExcludeStreamingTime bool
+	// Exemplars records the request duration with the span of the request, so trace-based exemplars link the histogram to the trace.
+	// The span is looked up in the request context before and after the next handlers, so the tracing middleware may run before or after this middleware.
+	// Exemplars also have to be enabled on the MeterProvider, see SetExemplarFilter.
+	Exemplars bool
+}
+
+// withDefaults returns a copy of config with defaults applied to all unset fields.
+func (config Config) withDefaults() Config {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.MeterProvider == nil {
+		config.MeterProvider = otel.GetMeterProvider()
+	}
+	if config.UnmatchedRouteLabel == "" {
+		config.UnmatchedRouteLabel = DefaultUnmatchedRouteLabel
+	}
+	if config.MaxAttributeSets == 0 {
+		config.MaxAttributeSets = DefaultMaxAttributeSets
+	}
+	return config
+}
+
+// instrumentName returns name prefixed with config.InstrumentNamePrefix.
+func (config Config) instrumentName(name string) string {
+	if config.InstrumentNamePrefix == "" {
+		return name
+	}
+	return fmt.Sprintf("%v_%v", config.InstrumentNamePrefix, name)
+}
+
+// counterInstrumentNames holds the names and units of the counters of the applied cardinality limits and recovered panics.
+type counterInstrumentNames struct {
+	limited     string
+	limitedUnit string
+	panics      string
+	panicsUnit  string
+}
+
+// counterNames returns the names of the counter instruments for the configured mode.
+func (config Config) counterNames() counterInstrumentNames {
+	if config.SemConv {
+		return counterInstrumentNames{
+			limited:     semConvCardinalityLimitedName,
+			limitedUnit: unitMeasurement,
+			panics:      semConvPanicsRecoveredName,
+			panicsUnit:  unitPanic,
+		}
+	}
+	return counterInstrumentNames{
+		limited:     config.instrumentName("cardinality_limited_total"),
+		limitedUnit: unitDimensionless,
+		panics:      config.instrumentName("panics_recovered_total"),
+		panicsUnit:  unitDimensionless,
+	}
+}
+
+// histogramInstrumentNames holds the names of the histogram instruments created by the middleware.
+type histogramInstrumentNames struct {
+	reqDuration string
+	reqSize     string
+	resSize     string
+}
+
+// histogramNames returns the names of the histogram instruments for the configured mode.
+func (config Config) histogramNames() histogramInstrumentNames {
+	if config.SemConv {
+		return histogramInstrumentNames{
+			reqDuration: semConvRequestDurationName,
+			reqSize:     semConvRequestBodySizeName,
+			resSize:     semConvResponseBodySizeName,
+		}
+	}
+	return histogramInstrumentNames{
+		reqDuration: config.instrumentName("request_duration_seconds"),
+		reqSize:     config.instrumentName("request_size_bytes"),
+		resSize:     config.instrumentName("response_size_bytes"),
+	}
 }
 
 /*
@@ -68,6 +167,14 @@ The default configuration uses the following settings:
   - Skipper: The default Echo middleware skipper obtained from middleware.DefaultSkipper.
   - MeterName: The default meter name "echo_http_metrics".
   - InstrumentNamePrefix: The default instrument name prefix "echo".
+  - UnmatchedRouteLabel: The default unmatched route label "unmatched".
+  - AllowedHosts: No host filtering.
+  - MaxAttributeSets: The default limit of 2000 attribute sets per instrument.
+  - SemConv: Disabled, Prometheus-style instrument names are used.
+  - RequestDuration, RequestSize, ResponseSize: The default explicit bucket boundaries.
+  - RecoverPanics: Disabled, panics are recorded and propagate with their stack.
+  - ExcludeStreamingTime: Disabled, the full request duration is recorded.
+  - Exemplars: Disabled, the request duration is recorded with the context the middleware was called with.
 
 Returns:
   - echo.MiddlewareFunc: The middleware function that can be used with Echo's Use() method.
@@ -90,6 +197,8 @@ func New() (echo.MiddlewareFunc, error) {
 		Skipper:              middleware.DefaultSkipper,
 		MeterName:            DefaultMeterName,
 		InstrumentNamePrefix: DefaultInstrumentNamePrefix,
+		UnmatchedRouteLabel:  DefaultUnmatchedRouteLabel,
+		MaxAttributeSets:     DefaultMaxAttributeSets,
 	}
 	return NewWithConfig(config)
 }
@@ -110,6 +219,12 @@ The function then returns a middleware function that records the metrics for eac
 
 The middleware function measures the duration of each request, the count of requests, the size of the request, and the size of the response.
 It records these metrics using the configured meter provider and attribute set.
+To keep the number of series bounded, requests which did not match any route are recorded with the unmatched route label instead of their URL path,
+non-standard methods are recorded as OtherMethodLabel, hosts outside of AllowedHosts are recorded as OtherHostLabel and every instrument records at most MaxAttributeSets distinct attribute sets.
+Every time one of these limits is applied, the cardinality_limited_total counter, or http.server.cardinality_limited in SemConv mode, is incremented.
+If config.SemConv is set, the instruments of the OpenTelemetry HTTP semantic conventions are recorded instead, so dashboards built for them work unchanged.
+The number of requests being handled is recorded by an up-down counter and panics of the next handlers are counted by the panics_recovered_total counter, or http.server.panics_recovered in SemConv mode.
+If config.Exemplars is set, the request duration is recorded with the span of the request so the SDK can attach trace-based exemplars.
 The function also handles any errors that occur during the execution of the next handler and sets the appropriate HTTP status code.
 
 Example usage:
@@ -132,30 +247,211 @@ Example usage:
 Note: The NewWithConfig function assumes that the required dependencies, such as the Echo framework and OpenTelemetry meter provider, are already imported and configured.
 */
 func NewWithConfig(config Config) (echo.MiddlewareFunc, error) {
-	if config.Skipper == nil {
-		config.Skipper = middleware.DefaultSkipper
+	config = config.withDefaults()
+	hosts := newHostAllowlist(config.AllowedHosts)
+
+	meter := config.MeterProvider.Meter(config.MeterName)
+	counterNames := config.counterNames()
+
+	limited, err := meter.Int64Counter(
+		counterNames.limited,
+		metric.WithDescription("Number of measurements whose attributes were reduced by cardinality limits."),
+		metric.WithUnit(counterNames.limitedUnit),
+	)
+	if err != nil {
+		return nil, err
 	}
-	if config.MeterProvider == nil {
-		config.MeterProvider = otel.GetMeterProvider()
+
+	panics, err := meter.Int64Counter(
+		counterNames.panics,
+		metric.WithDescription("Number of panics recovered from HTTP request handlers."),
+		metric.WithUnit(counterNames.panicsUnit),
+	)
+	if err != nil {
+		return nil, err
 	}
-	if config.InstrumentNamePrefix != "" {
-		config.InstrumentNamePrefix += "_"
+
+	var inst *instruments
+	if config.SemConv {
+		inst, err = newSemConvInstruments(meter, config, limited)
+	} else {
+		inst, err = newInstruments(meter, config, limited)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	meter := config.MeterProvider.Meter(config.MeterName)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+
+			route := c.Path()
+			if route == "" {
+				limited.Add(ctx, 1, metric.WithAttributes(limitReasonKey.String(limitReasonUnmatchedRoute)))
+			}
+
+			method, known := methodLabel(c.Request().Method)
+			if !known {
+				limited.Add(ctx, 1, metric.WithAttributes(limitReasonKey.String(limitReasonMethod)))
+			}
+
+			host, allowed := hosts.label(c.Request().Host)
+			if !allowed {
+				limited.Add(ctx, 1, metric.WithAttributes(limitReasonKey.String(limitReasonHost)))
+			}
+
+			req := requestInfo{
+				method: method,
+				route:  route,
+				scheme: c.Scheme(),
+				host:   host,
+			}
+
+			activeAttrs := inst.activeReqsLimiter.attributes(ctx, inst.activeAttributes(req))
+			inst.activeReqs.Add(ctx, 1, activeAttrs)
+			defer inst.activeReqs.Add(ctx, -1, activeAttrs)
+
+			var flushes *flushRecorder
+			if config.ExcludeStreamingTime {
+				flushes = &flushRecorder{ResponseWriter: c.Response().Writer}
+				c.Response().Writer = flushes
+				defer func() { c.Response().Writer = flushes.ResponseWriter }()
+			}
+
+			start := time.Now()
+			// record records the measurements of the handled request with status.
+			record := func(status int) {
+				end := time.Now()
+				if flushes != nil && !flushes.firstFlush.IsZero() {
+					end = flushes.firstFlush
+				}
+				elapsed := float64(end.Sub(start)) / float64(time.Second)
+				req.status = status
+
+				attrs := inst.requestAttributes(req)
+
+				durationCtx := ctx
+				if config.Exemplars {
+					durationCtx = exemplarContext(ctx, c)
+				}
+				inst.reqDuration.Record(durationCtx, elapsed, inst.reqDurationLimiter.attributes(ctx, attrs))
+				if inst.reqCount != nil {
+					inst.reqCount.Add(ctx, 1, inst.reqCountLimiter.attributes(ctx, attrs))
+				}
+				inst.reqSize.Record(ctx, inst.requestSize(c.Request()), inst.reqSizeLimiter.attributes(ctx, attrs))
+				inst.resSize.Record(ctx, c.Response().Size, inst.resSizeLimiter.attributes(ctx, attrs))
+			}
+
+			if !config.RecoverPanics {
+				// a panic is recorded while it unwinds without recovering it, so it keeps its stack
+				returned := false
+				defer func() {
+					if !returned {
+						panics.Add(ctx, 1)
+						record(http.StatusInternalServerError)
+					}
+				}()
+				err := next(c)
+				returned = true
+				record(responseStatus(c, err))
+				return err
+			}
+
+			panicValue, stack, err := callNext(next, c)
+			if panicValue != nil {
+				panics.Add(ctx, 1)
+				record(http.StatusInternalServerError)
+				return echo.NewHTTPError(http.StatusInternalServerError).SetInternal(fmt.Errorf("recovered from panic: %v\n%s", panicValue, stack))
+			}
+			record(responseStatus(c, err))
+			return err
+		}
+	}, nil
+}
+
+// callNext calls next and recovers a panic raised by it. The recovered value is nil if next did not panic, otherwise stack is the stack of the panicking goroutine.
+func callNext(next echo.HandlerFunc, c echo.Context) (panicValue any, stack []byte, err error) {
+	defer func() {
+		if panicValue = recover(); panicValue != nil {
+			stack = debug.Stack()
+		}
+	}()
+	return nil, nil, next(c)
+}
+
+// responseStatus returns the status of the response to c, which the next handlers returned err for.
+func responseStatus(c echo.Context, err error) int {
+	status := c.Response().Status
+	if err != nil {
+		var httpError *echo.HTTPError
+		if errors.As(err, &httpError) {
+			status = httpError.Code
+		}
+		if status == 0 || status == http.StatusOK {
+			status = http.StatusInternalServerError
+		}
+	}
+	return status
+}
+
+// requestInfo holds the bounded request properties measurements are labelled with.
+type requestInfo struct {
+	// method is the request method, non-standard methods are replaced by OtherMethodLabel.
+	method string
+	// route is the matched route template. It is empty if the request did not match any route.
+	route string
+	// scheme is the request scheme, either "http" or "https".
+	scheme string
+	// host is the request host as filtered by the host allowlist.
+	host string
+	// status is the response status code. It is only set once the request was handled.
+	status int
+}
+
+// instruments holds the instruments recorded by the middleware together with the functions deriving their attributes.
+type instruments struct {
+	reqDuration metric.Float64Histogram
+	// reqCount is nil if requests are not counted separately from reqDuration.
+	reqCount   metric.Int64Counter
+	reqSize    metric.Int64Histogram
+	resSize    metric.Int64Histogram
+	activeReqs metric.Int64UpDownCounter
+
+	reqDurationLimiter *attributeSetLimiter
+	reqCountLimiter    *attributeSetLimiter
+	reqSizeLimiter     *attributeSetLimiter
+	resSizeLimiter     *attributeSetLimiter
+	activeReqsLimiter  *attributeSetLimiter
+
+	// requestAttributes returns the attributes of a handled request.
+	requestAttributes func(req requestInfo) attribute.Set
+	// activeAttributes returns the attributes of a request which is being handled.
+	activeAttributes func(req requestInfo) attribute.Set
+	// requestSize returns the request size recorded by reqSize.
+	requestSize func(r *http.Request) int64
+}
+
+// newInstruments creates the Prometheus-style instruments named after config.InstrumentNamePrefix.
+func newInstruments(meter metric.Meter, config Config, limited metric.Int64Counter) (*instruments, error) {
+	names := config.histogramNames()
 
 	reqDuration, err := meter.Float64Histogram(
-		fmt.Sprintf("%vrequest_duration_seconds", config.InstrumentNamePrefix),
+		names.reqDuration,
 		metric.WithDescription("The HTTP request latencies in seconds."),
 		metric.WithUnit(unitSecond),
-		metric.WithExplicitBucketBoundaries(secondsBucket...),
+		config.RequestDuration.option(secondsBucket),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	reqCountName := config.instrumentName("requests_total")
 	reqCount, err := meter.Int64Counter(
-		fmt.Sprintf("%vrequests_total", config.InstrumentNamePrefix),
+		reqCountName,
 		metric.WithDescription("Number of HTTP requests processed."),
 		metric.WithUnit(unitDimensionless),
 	)
@@ -164,65 +460,67 @@ func NewWithConfig(config Config) (echo.MiddlewareFunc, error) {
 	}
 
 	reqSize, err := meter.Int64Histogram(
-		fmt.Sprintf("%vrequest_size_bytes", config.InstrumentNamePrefix),
+		names.reqSize,
 		metric.WithDescription("The HTTP request sizes in bytes."),
 		metric.WithUnit(unitByte),
-		metric.WithExplicitBucketBoundaries(bytesBucket...),
+		config.RequestSize.option(bytesBucket),
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	resSize, err := meter.Int64Histogram(
-		fmt.Sprintf("%vresponse_size_bytes", config.InstrumentNamePrefix),
+		names.resSize,
 		metric.WithDescription("The HTTP response sizes in bytes."),
 		metric.WithUnit(unitByte),
-		metric.WithExplicitBucketBoundaries(bytesBucket...),
+		config.ResponseSize.option(bytesBucket),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			if config.Skipper(c) {
-				return next(c)
-			}
-
-			start := time.Now()
-			err := next(c)
-			elapsed := float64(time.Since(start)) / float64(time.Second)
-
-			status := c.Response().Status
-			if err != nil {
-				var httpError *echo.HTTPError
-				if errors.As(err, &httpError) {
-					status = httpError.Code
-				}
-				if status == 0 || status == http.StatusOK {
-					status = http.StatusInternalServerError
-				}
-			}
+	activeReqsName := config.instrumentName("requests_in_flight")
+	activeReqs, err := meter.Int64UpDownCounter(
+		activeReqsName,
+		metric.WithDescription("Number of HTTP requests currently being handled."),
+		metric.WithUnit(unitDimensionless),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-			path := c.Path()
+	return &instruments{
+		reqDuration:        reqDuration,
+		reqCount:           reqCount,
+		reqSize:            reqSize,
+		resSize:            resSize,
+		activeReqs:         activeReqs,
+		reqDurationLimiter: newAttributeSetLimiter(names.reqDuration, config.MaxAttributeSets, limited),
+		reqCountLimiter:    newAttributeSetLimiter(reqCountName, config.MaxAttributeSets, limited),
+		reqSizeLimiter:     newAttributeSetLimiter(names.reqSize, config.MaxAttributeSets, limited),
+		resSizeLimiter:     newAttributeSetLimiter(names.resSize, config.MaxAttributeSets, limited),
+		activeReqsLimiter:  newAttributeSetLimiter(activeReqsName, config.MaxAttributeSets, limited),
+		requestAttributes: func(req requestInfo) attribute.Set {
+			path := req.route
 			if path == "" {
-				path = c.Request().URL.Path
+				path = config.UnmatchedRouteLabel
 			}
-
-			attrs := attribute.NewSet(
-				attribute.Int("status", status),
-				attribute.String("method", c.Request().Method),
+			return attribute.NewSet(
+				attribute.Int("status", req.status),
+				attribute.String("method", req.method),
 				attribute.String("path", path),
-				attribute.String("host", c.Request().Host),
+				attribute.String("host", req.host),
 			)
-
-			reqDuration.Record(c.Request().Context(), elapsed, metric.WithAttributeSet(attrs))
-			reqCount.Add(c.Request().Context(), 1, metric.WithAttributeSet(attrs))
-			reqSize.Record(c.Request().Context(), int64(computeApproxReqSize(c.Request())), metric.WithAttributeSet(attrs))
-			resSize.Record(c.Request().Context(), c.Response().Size, metric.WithAttributeSet(attrs))
-
-			return err
-		}
+		},
+		activeAttributes: func(req requestInfo) attribute.Set {
+			return attribute.NewSet(
+				attribute.String("method", req.method),
+				attribute.String("host", req.host),
+			)
+		},
+		requestSize: func(r *http.Request) int64 {
+			return int64(computeApproxReqSize(r))
+		},
 	}, nil
 }
 
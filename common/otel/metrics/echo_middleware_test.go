@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/oteltest"
+)
+
+// newTestServer returns an Echo server with the middleware of config recording to tel.
+func newTestServer(t *testing.T, tel *oteltest.Telemetry, config Config) *echo.Echo {
+	t.Helper()
+	config.MeterProvider = tel.MeterProvider
+	mw, err := NewWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	e.Use(mw)
+	e.GET("/users/:id", func(c echo.Context) error {
+		return c.String(http.StatusOK, "user")
+	})
+	e.GET("/panic", func(c echo.Context) error {
+		panic("boom")
+	})
+	return e
+}
+
+// serve sends a request to e and returns the status code. The host of the request is example.com.
+func serve(e *echo.Echo, method, target string) int {
+	return serveHost(e, method, target, "example.com")
+}
+
+// serveHost sends a request with host to e and returns the status code.
+func serveHost(e *echo.Echo, method, target, host string) int {
+	req := httptest.NewRequest(method, target, nil)
+	req.Host = host
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// metric collects the instrument name from tel, failing the test if it did not record data.
+func collectMetric(t *testing.T, tel *oteltest.Telemetry, name string) metricdata.Metrics {
+	t.Helper()
+	m, ok, err := tel.Metric(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatalf("instrument %q did not record data", name)
+	}
+	return m
+}
+
+// sumOf returns the value of the data point of the sum m with the attributes want, or -1.
+func sumOf(m metricdata.Metrics, want ...attribute.KeyValue) int64 {
+	for _, p := range oteltest.SumDataPoints[int64](m) {
+		if oteltest.HasAttributes(p.Attributes.ToSlice(), want...) {
+			return p.Value
+		}
+	}
+	return -1
+}
+
+func TestMiddlewareRecordsRequests(t *testing.T) {
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(context.Background())
+	e := newTestServer(t, tel, Config{InstrumentNamePrefix: DefaultInstrumentNamePrefix})
+
+	for _, id := range []string{"1", "2"} {
+		if code := serve(e, http.MethodGet, "/users/"+id); code != http.StatusOK {
+			t.Fatalf("status %d, want 200", code)
+		}
+	}
+
+	requests := collectMetric(t, tel, "echo_requests_total")
+	want := []attribute.KeyValue{attribute.Int("status", 200), attribute.String("method", "GET"), attribute.String("path", "/users/:id")}
+	if got := sumOf(requests, want...); got != 2 {
+		t.Errorf("echo_requests_total = %d, want 2 recorded under the route", got)
+	}
+	duration := oteltest.HistogramDataPoints[float64](collectMetric(t, tel, "echo_request_duration_seconds"))
+	if len(duration) != 1 || duration[0].Count != 2 {
+		t.Errorf("unexpected duration data points %+v", duration)
+	}
+	inFlight := collectMetric(t, tel, "echo_requests_in_flight")
+	if got := sumOf(inFlight, attribute.String("method", "GET")); got != 0 {
+		t.Errorf("echo_requests_in_flight = %d after the requests, want 0", got)
+	}
+}
+
+func TestMiddlewareLimitsCardinality(t *testing.T) {
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(context.Background())
+	e := newTestServer(t, tel, Config{InstrumentNamePrefix: DefaultInstrumentNamePrefix, AllowedHosts: []string{"example.com"}})
+
+	serve(e, http.MethodGet, "/does/not/exist")
+	serveHost(e, "BREW", "/users/1", "attacker.example")
+
+	requests := collectMetric(t, tel, "echo_requests_total")
+	if got := sumOf(requests, attribute.String("path", DefaultUnmatchedRouteLabel), attribute.Int("status", 404)); got != 1 {
+		t.Errorf("unmatched request recorded %d times under the unmatched label, want 1", got)
+	}
+	if got := sumOf(requests, attribute.String("method", OtherMethodLabel), attribute.String("host", OtherHostLabel)); got != 1 {
+		t.Errorf("non-standard method recorded %d times as %s from %s, want 1", got, OtherMethodLabel, OtherHostLabel)
+	}
+	limited := collectMetric(t, tel, "echo_cardinality_limited_total")
+	for _, reason := range []string{limitReasonUnmatchedRoute, limitReasonMethod, limitReasonHost} {
+		if got := sumOf(limited, limitReasonKey.String(reason)); got < 1 {
+			t.Errorf("cardinality limit %q not counted", reason)
+		}
+	}
+}
+
+// One of the two attribute sets is reserved for the overflow set, so the second request overflows.
+func TestMiddlewareOverflowsAttributeSets(t *testing.T) {
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(context.Background())
+	e := newTestServer(t, tel, Config{InstrumentNamePrefix: DefaultInstrumentNamePrefix, MaxAttributeSets: 2})
+
+	serve(e, http.MethodGet, "/users/1")
+	serve(e, http.MethodGet, "/missing")
+
+	requests := collectMetric(t, tel, "echo_requests_total")
+	if got := sumOf(requests, attribute.Bool("otel.metric.overflow", true)); got != 1 {
+		t.Errorf("overflow attribute set recorded %d requests, want 1", got)
+	}
+	limited := collectMetric(t, tel, "echo_cardinality_limited_total")
+	if got := sumOf(limited, limitReasonKey.String(limitReasonOverflow), limitInstrumentKey.String("echo_requests_total")); got != 1 {
+		t.Errorf("overflow of echo_requests_total counted %d times, want 1", got)
+	}
+}
+
+func TestMiddlewareRecoversPanics(t *testing.T) {
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(context.Background())
+	e := newTestServer(t, tel, Config{InstrumentNamePrefix: DefaultInstrumentNamePrefix, RecoverPanics: true})
+
+	if code := serve(e, http.MethodGet, "/panic"); code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500", code)
+	}
+	if got := sumOf(collectMetric(t, tel, "echo_panics_recovered_total")); got != 1 {
+		t.Errorf("echo_panics_recovered_total = %d, want 1", got)
+	}
+	if got := sumOf(collectMetric(t, tel, "echo_requests_total"), attribute.Int("status", 500)); got != 1 {
+		t.Errorf("panicking request recorded %d times with status 500, want 1", got)
+	}
+}
+
+func TestMiddlewarePropagatesPanics(t *testing.T) {
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(context.Background())
+	e := newTestServer(t, tel, Config{InstrumentNamePrefix: DefaultInstrumentNamePrefix})
+
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Fatalf("recovered %v, want the panic of the handler", v)
+			}
+			// the panic was not recovered and raised again, so the stack still holds the handler
+			if stack := string(debug.Stack()); !strings.Contains(stack, "newTestServer") {
+				t.Errorf("stack of the panic lost the handler:\n%s", stack)
+			}
+		}()
+		serve(e, http.MethodGet, "/panic")
+	}()
+	if got := sumOf(collectMetric(t, tel, "echo_panics_recovered_total")); got != 1 {
+		t.Errorf("echo_panics_recovered_total = %d, want 1", got)
+	}
+	if got := sumOf(collectMetric(t, tel, "echo_requests_total"), attribute.Int("status", 500)); got != 1 {
+		t.Errorf("panicking request recorded %d times with status 500, want 1", got)
+	}
+}
+
+func TestMiddlewareSemConv(t *testing.T) {
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(context.Background())
+	e := newTestServer(t, tel, Config{InstrumentNamePrefix: DefaultInstrumentNamePrefix, SemConv: true, RecoverPanics: true})
+
+	serve(e, http.MethodGet, "/users/1")
+	serve(e, http.MethodGet, "/panic")
+	serve(e, http.MethodGet, "/missing")
+
+	duration := oteltest.HistogramDataPoints[float64](collectMetric(t, tel, semConvRequestDurationName))
+	if len(duration) != 3 {
+		t.Fatalf("got %d duration data points, want 3", len(duration))
+	}
+	var users metricdata.HistogramDataPoint[float64]
+	for _, p := range duration {
+		if oteltest.HasAttributes(p.Attributes.ToSlice(), attribute.String("http.route", "/users/:id")) {
+			users = p
+		}
+	}
+	oteltest.AssertAttributes(t, users.Attributes.ToSlice(),
+		attribute.String("http.request.method", "GET"),
+		attribute.String("http.route", "/users/:id"),
+		attribute.Int("http.response.status_code", 200),
+	)
+	if got := sumOf(collectMetric(t, tel, semConvPanicsRecoveredName)); got != 1 {
+		t.Errorf("%s = %d, want 1", semConvPanicsRecoveredName, got)
+	}
+	if got := sumOf(collectMetric(t, tel, semConvCardinalityLimitedName), limitReasonKey.String(limitReasonUnmatchedRoute)); got != 1 {
+		t.Errorf("%s{reason=unmatched_route} = %d, want 1", semConvCardinalityLimitedName, got)
+	}
+	rm, err := tel.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if !strings.HasPrefix(m.Name, "http.server.") {
+				t.Errorf("instrument %q recorded in semconv mode", m.Name)
+			}
+		}
+	}
+}
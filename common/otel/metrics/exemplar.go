@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExemplarFilterTraceBased records exemplars only for measurements made within a sampled span.
+	ExemplarFilterTraceBased string = "trace_based"
+	// ExemplarFilterAlwaysOn records exemplars for all measurements.
+	ExemplarFilterAlwaysOn string = "always_on"
+	// ExemplarFilterAlwaysOff records no exemplars.
+	ExemplarFilterAlwaysOff string = "always_off"
+)
+
+const (
+	// exemplarFeatureEnvKey is the environment variable enabling the experimental exemplar support of the metrics SDK.
+	exemplarFeatureEnvKey string = "OTEL_GO_X_EXEMPLAR"
+	// exemplarFilterEnvKey is the environment variable selecting the exemplar filter of the metrics SDK.
+	exemplarFilterEnvKey string = "OTEL_METRICS_EXEMPLAR_FILTER"
+)
+
+/*
+SetExemplarFilter enables exemplars on MeterProviders created afterwards and selects which measurements are sampled as exemplars.
+The metrics SDK only supports exemplars as an experimental feature configured through environment variables, so the setting applies to the whole process.
+An empty filter leaves the environment untouched.
+
+Parameters:
+  - filter: One of ExemplarFilterTraceBased, ExemplarFilterAlwaysOn or ExemplarFilterAlwaysOff.
+
+Returns:
+  - error: An error if the filter is unknown or the environment could not be set.
+
+Note: Exemplars are exported over OTLP. The Prometheus exporter does not export exemplars yet.
+*/
+func SetExemplarFilter(filter string) error {
+	switch filter {
+	case "":
+		return nil
+	case ExemplarFilterTraceBased, ExemplarFilterAlwaysOn, ExemplarFilterAlwaysOff:
+	default:
+		return fmt.Errorf("unknown exemplar filter %q", filter)
+	}
+	if err := os.Setenv(exemplarFeatureEnvKey, "true"); err != nil {
+		return err
+	}
+	return os.Setenv(exemplarFilterEnvKey, filter)
+}
+
+// exemplarContext returns the context the request duration is recorded with, so the exemplar carries the span of the request.
+// The span is usually started by a tracing middleware, which only stores it in the request context if it runs after this middleware.
+func exemplarContext(ctx context.Context, c echo.Context) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if sc := trace.SpanContextFromContext(c.Request().Context()); sc.IsValid() {
+		return trace.ContextWithSpanContext(ctx, sc)
+	}
+	return ctx
+}
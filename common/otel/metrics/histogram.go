@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	// DefaultExponentialMaxSize is the default maximum number of buckets of an exponential histogram. It is set to 160.
+	DefaultExponentialMaxSize int32 = 160
+	// DefaultExponentialMaxScale is the default maximum scale of an exponential histogram. It is set to 20.
+	DefaultExponentialMaxScale int32 = 20
+)
+
+// HistogramConfig is a struct that represents the aggregation of a single histogram instrument.
+type HistogramConfig struct {
+	// Boundaries are the explicit bucket boundaries of the histogram. If empty, the default boundaries of the instrument are used.
+	Boundaries []float64
+	// Exponential aggregates the histogram as a base2 exponential histogram instead of using explicit buckets.
+	// The aggregation can only be changed by the SDK, so the views returned by Views have to be registered on the MeterProvider.
+	Exponential bool
+	// MaxSize is the maximum number of buckets of an exponential histogram. Defaults to DefaultExponentialMaxSize.
+	MaxSize int32
+	// MaxScale is the maximum scale of an exponential histogram. Defaults to DefaultExponentialMaxScale.
+	MaxScale int32
+}
+
+// option returns the instrument option setting the explicit bucket boundaries, falling back to defaultBoundaries.
+func (h HistogramConfig) option(defaultBoundaries []float64) metric.HistogramOption {
+	if len(h.Boundaries) == 0 {
+		return metric.WithExplicitBucketBoundaries(defaultBoundaries...)
+	}
+	return metric.WithExplicitBucketBoundaries(h.Boundaries...)
+}
+
+// view returns the view switching the instrument named name to an exponential histogram. It returns nil if h is not exponential.
+func (h HistogramConfig) view(meterName string, name string) sdkmetric.View {
+	if !h.Exponential {
+		return nil
+	}
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{Name: name, Scope: instrumentation.Scope{Name: meterName}},
+		sdkmetric.Stream{Aggregation: h.exponentialAggregation()},
+	)
+}
+
+// exponentialAggregation returns the base2 exponential histogram aggregation of h, falling back to the default size and scale.
+func (h HistogramConfig) exponentialAggregation() sdkmetric.Aggregation {
+	maxSize, maxScale := h.MaxSize, h.MaxScale
+	if maxSize == 0 {
+		maxSize = DefaultExponentialMaxSize
+	}
+	if maxScale == 0 {
+		maxScale = DefaultExponentialMaxScale
+	}
+	return sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: maxSize, MaxScale: maxScale}
+}
+
+/*
+Views returns the SDK views required by the histogram settings of config.
+Exponential histograms cannot be requested through the metrics API, so these views have to be registered on the MeterProvider the middleware uses.
+
+Parameters:
+  - config: The same configuration the middleware is created with.
+
+Returns:
+  - []sdkmetric.View: The views to register, empty if no histogram is exponential.
+
+Example usage:
+
+	config := Config{
+		RequestDuration: HistogramConfig{Exponential: true},
+	}
+
+	mp, err := NewMeterProvider(res, exporter, exportInterval, memStatsInterval, sdkmetric.WithView(Views(config)...))
+*/
+func Views(config Config) []sdkmetric.View {
+	config = config.withDefaults()
+	names := config.histogramNames()
+
+	var views []sdkmetric.View
+	for _, v := range []sdkmetric.View{
+		config.RequestDuration.view(config.MeterName, names.reqDuration),
+		config.RequestSize.view(config.MeterName, names.reqSize),
+		config.ResponseSize.view(config.MeterName, names.resSize),
+	} {
+		if v != nil {
+			views = append(views, v)
+		}
+	}
+	return views
+}
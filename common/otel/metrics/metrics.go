@@ -33,23 +33,88 @@ func NewOTLPMetricExporter(ctx context.Context, otlpEndpoint string, exportTimeo
 	return exporter, err
 }
 
-func NewMeterProvider(res *sdkresource.Resource, exporter sdkmetric.Exporter, exportInterval time.Duration, memStatsInterval time.Duration) (*sdkmetric.MeterProvider, error) {
-	mp := sdkmetric.NewMeterProvider(
+// MeterProviderConfig is a struct that represents the settings of a MeterProvider.
+type MeterProviderConfig struct {
+	// ExportInterval is the interval the metrics are pushed to the exporter in.
+	ExportInterval time.Duration
+	// MemStatsInterval is the minimum interval the runtime memory statistics are read in.
+	MemStatsInterval time.Duration
+	// HostMetrics starts the host metrics collector on the MeterProvider.
+	HostMetrics bool
+	// RuntimeMetrics starts the Go runtime metrics collector on the MeterProvider.
+	RuntimeMetrics bool
+	// Views are the views registered on the MeterProvider, applied in order.
+	Views []ViewConfig
+	// ExemplarFilter enables exemplars and selects the measurements sampled as exemplars, see SetExemplarFilter. If empty, exemplars are left as configured by the environment.
+	ExemplarFilter string
+}
+
+// NewMeterProvider creates a MeterProvider pushing to exporter every exportInterval with the host and runtime collectors started. Additional options, such as a Prometheus exporter reader or views, are applied to the same MeterProvider.
+func NewMeterProvider(res *sdkresource.Resource, exporter sdkmetric.Exporter, exportInterval time.Duration, memStatsInterval time.Duration, opts ...sdkmetric.Option) (*sdkmetric.MeterProvider, error) {
+	return NewMeterProviderWithConfig(res, exporter, MeterProviderConfig{
+		ExportInterval:   exportInterval,
+		MemStatsInterval: memStatsInterval,
+		HostMetrics:      true,
+		RuntimeMetrics:   true,
+	}, opts...)
+}
+
+/*
+NewMeterProviderWithConfig creates a MeterProvider pushing to exporter as configured by config.
+The configured views are registered before the views in opts. The host and runtime collectors are only started if enabled in config.
+
+Parameters:
+  - res: The resource describing the service.
+  - exporter: The exporter the metrics are pushed to.
+  - config: The settings of the MeterProvider.
+  - opts: Additional options, such as a Prometheus exporter reader or views.
+
+Returns:
+  - *sdkmetric.MeterProvider: The created MeterProvider.
+  - error: An error if a view or the exemplar filter is invalid or a collector could not be started.
+
+Example usage:
+
+	mp, err := NewMeterProviderWithConfig(res, exporter, MeterProviderConfig{
+		ExportInterval: 15 * time.Second,
+		RuntimeMetrics: true,
+		Views: []ViewConfig{
+			{InstrumentName: "process.runtime.go.gc.*", Drop: true},
+		},
+	})
+*/
+func NewMeterProviderWithConfig(res *sdkresource.Resource, exporter sdkmetric.Exporter, config MeterProviderConfig, opts ...sdkmetric.Option) (*sdkmetric.MeterProvider, error) {
+	views, err := NewViews(config.Views)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := SetExemplarFilter(config.ExemplarFilter); err != nil {
+		return nil, err
+	}
+
+	opts = append([]sdkmetric.Option{
 		sdkmetric.WithResource(res),
 		sdkmetric.WithReader(
 			sdkmetric.NewPeriodicReader(
 				exporter,
-				sdkmetric.WithInterval(exportInterval),
+				sdkmetric.WithInterval(config.ExportInterval),
 			),
 		),
-	)
+		sdkmetric.WithView(views...),
+	}, opts...)
+	mp := sdkmetric.NewMeterProvider(opts...)
 
-	if err := runtime.Start(runtime.WithMinimumReadMemStatsInterval(memStatsInterval), runtime.WithMeterProvider(mp)); err != nil {
-		return nil, err
+	if config.RuntimeMetrics {
+		if err := runtime.Start(runtime.WithMinimumReadMemStatsInterval(config.MemStatsInterval), runtime.WithMeterProvider(mp)); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := host.Start(host.WithMeterProvider(mp)); err != nil {
-		return nil, err
+	if config.HostMetrics {
+		if err := host.Start(host.WithMeterProvider(mp)); err != nil {
+			return nil, err
+		}
 	}
 
 	return mp, nil
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+)
+
+// PrometheusMetricsPath is the path the Prometheus scrape endpoint is served on.
+const PrometheusMetricsPath string = "/metrics"
+
+// prometheusReadHeaderTimeout limits how long the scrape listener waits for request headers.
+const prometheusReadHeaderTimeout = 10 * time.Second
+
+/*
+NewPrometheusExporter creates a Prometheus exporter which can be passed to NewMeterProvider as an additional reader.
+Metrics are collected on every scrape and registered with the given registry. The resource of the MeterProvider is exposed as the target_info metric.
+
+Parameters:
+  - registry: The Prometheus registry the exporter registers itself with.
+*/
+func NewPrometheusExporter(registry *prometheus.Registry) (*otelprometheus.Exporter, error) {
+	exporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	return exporter, err
+}
+
+// NewPrometheusHandler returns a http.Handler serving the metrics gathered by registry in the Prometheus exposition format.
+func NewPrometheusHandler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+/*
+NewPrometheusServer returns a http.Server serving the metrics gathered by registry on PrometheusMetricsPath.
+The server is not started, call ListenAndServe on it.
+
+Parameters:
+  - addr: The address to listen on. An empty address listens on all interfaces.
+  - port: The port to listen on.
+  - registry: The Prometheus registry to expose.
+*/
+func NewPrometheusServer(addr string, port int, registry *prometheus.Registry) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(PrometheusMetricsPath, NewPrometheusHandler(registry))
+	return &http.Server{
+		Addr:              net.JoinHostPort(addr, strconv.Itoa(port)),
+		Handler:           mux,
+		ReadHeaderTimeout: prometheusReadHeaderTimeout,
+	}
+}
@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// InstrumentKind is the kind of an instrument declared in a Registry.
+type InstrumentKind string
+
+const (
+	// KindCounter is a monotonic int64 counter.
+	KindCounter InstrumentKind = "counter"
+	// KindUpDownCounter is a non-monotonic int64 counter.
+	KindUpDownCounter InstrumentKind = "upDownCounter"
+	// KindHistogram is a float64 histogram.
+	KindHistogram InstrumentKind = "histogram"
+)
+
+// instrumentNamePattern is the instrument name syntax of the OpenTelemetry specification.
+var instrumentNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.\-/]{0,254}$`)
+
+// errUnknownAttribute is reported to the OpenTelemetry error handler when a measurement carries an attribute key which was not declared.
+var errUnknownAttribute = errors.New("attribute key not declared")
+
+// Definition is a struct that declares a single business instrument.
+type Definition struct {
+	// Name is the name of the instrument.
+	Name string `json:"name"`
+	// Kind is the kind of the instrument. It is set by the Registry method the instrument is declared with.
+	Kind InstrumentKind `json:"kind"`
+	// Unit is the unit of the instrument in UCUM syntax, e.g. "1", "s" or "{login}".
+	Unit string `json:"unit"`
+	// Description is the human readable description of the instrument.
+	Description string `json:"description"`
+	// AttributeKeys are the only attribute keys the instrument may be recorded with.
+	AttributeKeys []attribute.Key `json:"attributeKeys"`
+	// Boundaries are the explicit bucket boundaries of a histogram. If empty, the SDK default boundaries are used.
+	Boundaries []float64 `json:"boundaries,omitempty"`
+}
+
+// validate checks the name and attribute keys of d.
+func (d Definition) validate() error {
+	if !instrumentNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("invalid instrument name %q", d.Name)
+	}
+	seen := make(map[attribute.Key]struct{}, len(d.AttributeKeys))
+	for _, k := range d.AttributeKeys {
+		if !k.Defined() {
+			return fmt.Errorf("instrument %q: empty attribute key", d.Name)
+		}
+		if _, ok := seen[k]; ok {
+			return fmt.Errorf("instrument %q: duplicate attribute key %q", d.Name, k)
+		}
+		seen[k] = struct{}{}
+	}
+	return nil
+}
+
+// attributeFilter returns the filter keeping only the declared attribute keys of d.
+func (d Definition) attributeFilter() attribute.Filter {
+	return attribute.NewAllowKeysFilter(d.AttributeKeys...)
+}
+
+/*
+Registry is a declarative registry of the business instruments of a service.
+A service declares every instrument once, with its name, unit, description and allowed attribute keys, and records through the returned typed recorders.
+The declarations double as the metric catalog of the service, see Catalog.
+
+Example usage:
+
+	registry := metrics.NewRegistry(mp.Meter("auth-service"))
+
+	logins, err := registry.Counter(metrics.Definition{
+		Name:          "auth.logins",
+		Unit:          "{login}",
+		Description:   "Number of login attempts.",
+		AttributeKeys: []attribute.Key{"outcome"},
+	})
+	if err != nil {
+		return err
+	}
+
+	logins.Add(ctx, 1, attribute.String("outcome", "success"))
+*/
+type Registry struct {
+	meter metric.Meter
+	mu    sync.Mutex
+	defs  map[string]Definition
+}
+
+// NewRegistry creates an empty Registry creating its instruments with meter.
+func NewRegistry(meter metric.Meter) *Registry {
+	return &Registry{
+		meter: meter,
+		defs:  make(map[string]Definition),
+	}
+}
+
+// declare validates def and remembers it, failing if an instrument with the same name was already declared.
+func (r *Registry) declare(kind InstrumentKind, def Definition) (Definition, error) {
+	def.Kind = kind
+	if err := def.validate(); err != nil {
+		return def, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.defs[def.Name]; ok {
+		return def, fmt.Errorf("instrument %q already declared", def.Name)
+	}
+	r.defs[def.Name] = def
+	return def, nil
+}
+
+// Counter declares a monotonic int64 counter and returns its recorder.
+func (r *Registry) Counter(def Definition) (*Counter, error) {
+	def, err := r.declare(KindCounter, def)
+	if err != nil {
+		return nil, err
+	}
+	counter, err := r.meter.Int64Counter(def.Name, metric.WithUnit(def.Unit), metric.WithDescription(def.Description))
+	if err != nil {
+		return nil, err
+	}
+	return &Counter{recorder: newRecorder(def), counter: counter}, nil
+}
+
+// UpDownCounter declares a non-monotonic int64 counter and returns its recorder.
+func (r *Registry) UpDownCounter(def Definition) (*UpDownCounter, error) {
+	def, err := r.declare(KindUpDownCounter, def)
+	if err != nil {
+		return nil, err
+	}
+	counter, err := r.meter.Int64UpDownCounter(def.Name, metric.WithUnit(def.Unit), metric.WithDescription(def.Description))
+	if err != nil {
+		return nil, err
+	}
+	return &UpDownCounter{recorder: newRecorder(def), counter: counter}, nil
+}
+
+// Histogram declares a float64 histogram and returns its recorder.
+func (r *Registry) Histogram(def Definition) (*Histogram, error) {
+	def, err := r.declare(KindHistogram, def)
+	if err != nil {
+		return nil, err
+	}
+	opts := []metric.Float64HistogramOption{metric.WithUnit(def.Unit), metric.WithDescription(def.Description)}
+	if len(def.Boundaries) > 0 {
+		opts = append(opts, metric.WithExplicitBucketBoundaries(def.Boundaries...))
+	}
+	histogram, err := r.meter.Float64Histogram(def.Name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Histogram{recorder: newRecorder(def), histogram: histogram}, nil
+}
+
+// Definitions returns the declared instruments sorted by name.
+func (r *Registry) Definitions() []Definition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defs := make([]Definition, 0, len(r.defs))
+	for _, def := range r.defs {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// recorder validates the attributes of measurements against the declared attribute keys.
+type recorder struct {
+	name   string
+	filter attribute.Filter
+}
+
+// newRecorder creates the recorder of the instrument declared by def.
+func newRecorder(def Definition) recorder {
+	return recorder{name: def.Name, filter: def.attributeFilter()}
+}
+
+// attributes returns the measurement option for attrs. Undeclared attributes are dropped and reported to the OpenTelemetry error handler.
+func (r recorder) attributes(attrs []attribute.KeyValue) metric.MeasurementOption {
+	set, dropped := attribute.NewSetWithFiltered(attrs, r.filter)
+	for _, kv := range dropped {
+		otel.Handle(fmt.Errorf("instrument %q: %w: %q", r.name, errUnknownAttribute, kv.Key))
+	}
+	return metric.WithAttributeSet(set)
+}
+
+// Counter records a monotonic int64 counter declared in a Registry.
+type Counter struct {
+	recorder
+	counter metric.Int64Counter
+}
+
+// Add adds incr to the counter. Attributes with undeclared keys are dropped.
+func (c *Counter) Add(ctx context.Context, incr int64, attrs ...attribute.KeyValue) {
+	c.counter.Add(ctx, incr, c.attributes(attrs))
+}
+
+// UpDownCounter records a non-monotonic int64 counter declared in a Registry.
+type UpDownCounter struct {
+	recorder
+	counter metric.Int64UpDownCounter
+}
+
+// Add adds incr, which may be negative, to the counter. Attributes with undeclared keys are dropped.
+func (c *UpDownCounter) Add(ctx context.Context, incr int64, attrs ...attribute.KeyValue) {
+	c.counter.Add(ctx, incr, c.attributes(attrs))
+}
+
+// Histogram records a float64 histogram declared in a Registry.
+type Histogram struct {
+	recorder
+	histogram metric.Float64Histogram
+}
+
+// Record records value in the histogram. Attributes with undeclared keys are dropped.
+func (h *Histogram) Record(ctx context.Context, value float64, attrs ...attribute.KeyValue) {
+	h.histogram.Record(ctx, value, h.attributes(attrs))
+}
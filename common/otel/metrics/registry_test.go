@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/oteltest"
+)
+
+func TestRegistryRecordsDeclaredAttributes(t *testing.T) {
+	ctx := context.Background()
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(ctx)
+	registry := NewRegistry(tel.MeterProvider.Meter("test"))
+
+	logins, err := registry.Counter(Definition{
+		Name:          "auth.logins",
+		Unit:          "{login}",
+		Description:   "Number of login attempts.",
+		AttributeKeys: []attribute.Key{"outcome"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logins.Add(ctx, 1, attribute.String("outcome", "success"), attribute.String("user.id", "42"))
+
+	m, ok, err := tel.Metric(ctx, "auth.logins")
+	if err != nil || !ok {
+		t.Fatalf("Metric(auth.logins) = %v, %v", ok, err)
+	}
+	points := oteltest.SumDataPoints[int64](m)
+	if len(points) != 1 || points[0].Value != 1 {
+		t.Fatalf("unexpected data points %+v", points)
+	}
+	attrs := points[0].Attributes.ToSlice()
+	oteltest.AssertAttributes(t, attrs, attribute.String("outcome", "success"))
+	if _, ok := oteltest.AttributeValue(attrs, "user.id"); ok {
+		t.Error("undeclared attribute user.id was recorded")
+	}
+	if m.Unit != "{login}" || m.Description != "Number of login attempts." {
+		t.Errorf("unit %q and description %q not applied", m.Unit, m.Description)
+	}
+}
+
+func TestRegistryHistogramBoundaries(t *testing.T) {
+	ctx := context.Background()
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(ctx)
+	registry := NewRegistry(tel.MeterProvider.Meter("test"))
+
+	h, err := registry.Histogram(Definition{Name: "auth.duration", Unit: "s", Boundaries: []float64{0.1, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Record(ctx, 0.5)
+
+	m, _, err := tel.Metric(ctx, "auth.duration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := oteltest.HistogramDataPoints[float64](m)
+	if len(points) != 1 || len(points[0].Bounds) != 2 || points[0].BucketCounts[1] != 1 {
+		t.Errorf("unexpected histogram data points %+v", points)
+	}
+}
+
+func TestRegistryRejectsInvalidDefinitions(t *testing.T) {
+	registry := NewRegistry(oteltest.New(nil).MeterProvider.Meter("test"))
+	if _, err := registry.Counter(Definition{Name: "auth.logins"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]Definition{
+		"duplicate name":          {Name: "auth.logins"},
+		"invalid name":            {Name: "1logins"},
+		"empty attribute key":     {Name: "auth.a", AttributeKeys: []attribute.Key{""}},
+		"duplicate attribute key": {Name: "auth.b", AttributeKeys: []attribute.Key{"a", "a"}},
+	}
+	for name, def := range tests {
+		if _, err := registry.UpDownCounter(def); err == nil {
+			t.Errorf("%s: declaration succeeded", name)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	registry := NewRegistry(oteltest.New(nil).MeterProvider.Meter("test"))
+	for _, name := range []string{"b.second", "a.first"} {
+		if _, err := registry.Counter(Definition{Name: name, Unit: "1", Description: "Desc of " + name + "."}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	catalog := registry.Catalog("test-service")
+
+	var buf bytes.Buffer
+	if err := catalog.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Catalog
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Service != "test-service" || len(decoded.Metrics) != 2 || decoded.Metrics[0].Name != "a.first" || decoded.Metrics[0].Kind != KindCounter {
+		t.Errorf("unexpected JSON catalog %+v", decoded)
+	}
+
+	buf.Reset()
+	if err := catalog.WriteMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	md := buf.String()
+	if !strings.HasPrefix(md, "# test-service metrics") || strings.Index(md, "a.first") > strings.Index(md, "b.second") {
+		t.Errorf("unexpected Markdown catalog:\n%s", md)
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const (
+	// semConvRequestDurationName is the name of the HTTP server request duration instrument of the OpenTelemetry semantic conventions.
+	semConvRequestDurationName string = "http.server.request.duration"
+	// semConvActiveRequestsName is the name of the HTTP server active requests instrument of the OpenTelemetry semantic conventions.
+	semConvActiveRequestsName string = "http.server.active_requests"
+	// semConvRequestBodySizeName is the name of the HTTP server request body size instrument of the OpenTelemetry semantic conventions.
+	semConvRequestBodySizeName string = "http.server.request.body.size"
+	// semConvResponseBodySizeName is the name of the HTTP server response body size instrument of the OpenTelemetry semantic conventions.
+	semConvResponseBodySizeName string = "http.server.response.body.size"
+	// semConvCardinalityLimitedName is the name of the counter of measurements reduced by cardinality limits in SemConv mode.
+	// It is not part of the semantic conventions, but follows their naming so all instruments of the middleware share the http.server namespace.
+	semConvCardinalityLimitedName string = "http.server.cardinality_limited"
+	// semConvPanicsRecoveredName is the name of the counter of recovered panics in SemConv mode, named like semConvCardinalityLimitedName.
+	semConvPanicsRecoveredName string = "http.server.panics_recovered"
+
+	// unitRequest represents the unit of measurement for a number of requests.
+	unitRequest string = "{request}"
+	// unitMeasurement represents the unit of measurement for a number of measurements.
+	unitMeasurement string = "{measurement}"
+	// unitPanic represents the unit of measurement for a number of panics.
+	unitPanic string = "{panic}"
+)
+
+// semConvSecondsBucket is the bucket boundaries advised by the OpenTelemetry semantic conventions for http.server.request.duration.
+var semConvSecondsBucket = []float64{.005, .01, .025, .05, .075, .1, .25, .5, .75, 1, 2.5, 5, 7.5, 10}
+
+/*
+newSemConvInstruments creates the stable HTTP server instruments of the OpenTelemetry semantic conventions.
+
+The following instruments are created:
+  - http.server.request.duration: A histogram of request durations in seconds.
+  - http.server.active_requests: An up-down counter of requests which are being handled.
+  - http.server.request.body.size: A histogram of request body sizes in bytes, as announced by the Content-Length header.
+  - http.server.response.body.size: A histogram of response body sizes in bytes.
+
+Handled requests are recorded with the http.request.method, http.route, http.response.status_code, url.scheme, server.address and server.port attributes.
+The http.route attribute is omitted for requests which did not match any route and error.type is set for server errors.
+*/
+func newSemConvInstruments(meter metric.Meter, config Config, limited metric.Int64Counter) (*instruments, error) {
+	reqDuration, err := meter.Float64Histogram(
+		semConvRequestDurationName,
+		metric.WithDescription("Duration of HTTP server requests."),
+		metric.WithUnit(unitSecond),
+		config.RequestDuration.option(semConvSecondsBucket),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	activeReqs, err := meter.Int64UpDownCounter(
+		semConvActiveRequestsName,
+		metric.WithDescription("Number of active HTTP server requests."),
+		metric.WithUnit(unitRequest),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reqSize, err := meter.Int64Histogram(
+		semConvRequestBodySizeName,
+		metric.WithDescription("Size of HTTP server request bodies."),
+		metric.WithUnit(unitByte),
+		config.RequestSize.option(bytesBucket),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resSize, err := meter.Int64Histogram(
+		semConvResponseBodySizeName,
+		metric.WithDescription("Size of HTTP server response bodies."),
+		metric.WithUnit(unitByte),
+		config.ResponseSize.option(bytesBucket),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instruments{
+		reqDuration:        reqDuration,
+		reqSize:            reqSize,
+		resSize:            resSize,
+		activeReqs:         activeReqs,
+		reqDurationLimiter: newAttributeSetLimiter(semConvRequestDurationName, config.MaxAttributeSets, limited),
+		reqSizeLimiter:     newAttributeSetLimiter(semConvRequestBodySizeName, config.MaxAttributeSets, limited),
+		resSizeLimiter:     newAttributeSetLimiter(semConvResponseBodySizeName, config.MaxAttributeSets, limited),
+		activeReqsLimiter:  newAttributeSetLimiter(semConvActiveRequestsName, config.MaxAttributeSets, limited),
+		requestAttributes:  semConvRequestAttributes,
+		activeAttributes: func(req requestInfo) attribute.Set {
+			return attribute.NewSet(semConvServerAttributes(req)...)
+		},
+		requestSize: func(r *http.Request) int64 {
+			if r.ContentLength < 0 {
+				return 0
+			}
+			return r.ContentLength
+		},
+	}, nil
+}
+
+// semConvRequestAttributes returns the semantic convention attributes of a handled request.
+func semConvRequestAttributes(req requestInfo) attribute.Set {
+	attrs := append(semConvServerAttributes(req), semconv.HTTPResponseStatusCode(req.status))
+	if req.route != "" {
+		attrs = append(attrs, semconv.HTTPRoute(req.route))
+	}
+	if req.status >= http.StatusInternalServerError {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(req.status)))
+	}
+	return attribute.NewSet(attrs...)
+}
+
+// semConvServerAttributes returns the semantic convention attributes known before a request is handled.
+func semConvServerAttributes(req requestInfo) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.method),
+		semconv.URLScheme(req.scheme),
+	}
+	host, port, err := net.SplitHostPort(req.host)
+	if err != nil {
+		return append(attrs, semconv.ServerAddress(req.host))
+	}
+	attrs = append(attrs, semconv.ServerAddress(host))
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.ServerPort(p))
+	}
+	return attrs
+}
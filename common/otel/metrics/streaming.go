@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+)
+
+// flushRecorder wraps a http.ResponseWriter and remembers when the response was flushed for the first time.
+// A flush marks the start of a streaming response, so the time spent streaming can be excluded from the request duration.
+type flushRecorder struct {
+	http.ResponseWriter
+	// firstFlush is the time of the first flush. It is zero if the response was never flushed.
+	firstFlush time.Time
+}
+
+// Flush implements http.Flusher.
+func (w *flushRecorder) Flush() {
+	if w.firstFlush.IsZero() {
+		w.firstFlush = time.Now()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker.
+func (w *flushRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for use by http.ResponseController.
+func (w *flushRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Interface guards.
+var (
+	_ http.Flusher  = (*flushRecorder)(nil)
+	_ http.Hijacker = (*flushRecorder)(nil)
+)
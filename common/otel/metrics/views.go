@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+const (
+	// AggregationDefault keeps the default aggregation of the instrument kind.
+	AggregationDefault string = ""
+	// AggregationDrop drops all measurements of the instrument.
+	AggregationDrop string = "drop"
+	// AggregationSum aggregates measurements as an arithmetic sum.
+	AggregationSum string = "sum"
+	// AggregationLastValue keeps only the last measurement.
+	AggregationLastValue string = "lastValue"
+	// AggregationExplicitBucketHistogram aggregates measurements into a histogram with ViewConfig.Boundaries.
+	AggregationExplicitBucketHistogram string = "explicitBucketHistogram"
+	// AggregationExponentialHistogram aggregates measurements into a base2 exponential histogram.
+	AggregationExponentialHistogram string = "exponentialHistogram"
+)
+
+var (
+	// errViewNoCriteria is returned for a view which matches neither by instrument name nor by meter name.
+	errViewNoCriteria = errors.New("view must match by instrument name or meter name")
+	// errViewWildcardRename is returned for a view which renames all instruments matched by a wildcard.
+	errViewWildcardRename = errors.New("view cannot rename instruments matched by a wildcard")
+	// errViewRenameWithoutName is returned for a view which renames all instruments of a meter.
+	errViewRenameWithoutName = errors.New("view must match by instrument name to rename an instrument")
+)
+
+// ViewConfig is a struct that represents an OpenTelemetry view loaded from configuration.
+type ViewConfig struct {
+	// InstrumentName matches instruments by name. The wildcards "*" and "?" are supported.
+	InstrumentName string `mapstructure:"instrumentName"`
+	// MeterName matches instruments by the name of the meter which created them.
+	MeterName string `mapstructure:"meterName"`
+	// Drop drops all measurements of the matched instruments. It takes precedence over Aggregation.
+	Drop bool `mapstructure:"drop"`
+	// Rename is the new name of the matched instrument. It requires an InstrumentName without wildcards.
+	Rename string `mapstructure:"rename"`
+	// Aggregation changes the aggregation of the matched instruments, one of the Aggregation constants.
+	Aggregation string `mapstructure:"aggregation"`
+	// Boundaries are the bucket boundaries of AggregationExplicitBucketHistogram.
+	Boundaries []float64 `mapstructure:"boundaries"`
+	// MaxSize is the maximum number of buckets of AggregationExponentialHistogram. Defaults to DefaultExponentialMaxSize.
+	MaxSize int32 `mapstructure:"maxSize"`
+	// MaxScale is the maximum scale of AggregationExponentialHistogram. Defaults to DefaultExponentialMaxScale.
+	MaxScale int32 `mapstructure:"maxScale"`
+	// AttributeKeys are the only attribute keys kept on the matched instruments. If empty, all attributes are kept.
+	AttributeKeys []string `mapstructure:"attributeKeys"`
+}
+
+/*
+NewView creates a sdkmetric.View from config.
+Unlike sdkmetric.NewView, which drops invalid views with a logged error, an invalid configuration is returned as an error.
+
+Parameters:
+  - config: The view configuration.
+
+Returns:
+  - sdkmetric.View: The view to register on the MeterProvider.
+  - error: An error if the configuration is invalid.
+
+Example:
+
+	view, err := metrics.NewView(metrics.ViewConfig{
+		InstrumentName: "process.runtime.go.gc.*",
+		Drop:           true,
+	})
+*/
+func NewView(config ViewConfig) (sdkmetric.View, error) {
+	if config.InstrumentName == "" && config.MeterName == "" {
+		return nil, errViewNoCriteria
+	}
+	if config.Rename != "" && config.InstrumentName == "" {
+		return nil, errViewRenameWithoutName
+	}
+	if config.Rename != "" && strings.ContainsAny(config.InstrumentName, "*?") {
+		return nil, errViewWildcardRename
+	}
+
+	aggregation, err := config.aggregation()
+	if err != nil {
+		return nil, err
+	}
+
+	mask := sdkmetric.Stream{
+		Name:        config.Rename,
+		Aggregation: aggregation,
+	}
+	if len(config.AttributeKeys) > 0 {
+		keys := make([]attribute.Key, len(config.AttributeKeys))
+		for i, k := range config.AttributeKeys {
+			keys[i] = attribute.Key(k)
+		}
+		mask.AttributeFilter = attribute.NewAllowKeysFilter(keys...)
+	}
+
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{
+			Name:  config.InstrumentName,
+			Scope: instrumentation.Scope{Name: config.MeterName},
+		},
+		mask,
+	), nil
+}
+
+// NewViews creates a sdkmetric.View for every config. It returns the first error encountered, annotated with the index of the invalid view.
+func NewViews(configs []ViewConfig) ([]sdkmetric.View, error) {
+	views := make([]sdkmetric.View, 0, len(configs))
+	for i, config := range configs {
+		view, err := NewView(config)
+		if err != nil {
+			return nil, fmt.Errorf("view %d: %w", i, err)
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// aggregation returns the sdkmetric.Aggregation selected by config, or nil to keep the default aggregation.
+func (config ViewConfig) aggregation() (sdkmetric.Aggregation, error) {
+	if config.Drop {
+		return sdkmetric.AggregationDrop{}, nil
+	}
+	switch config.Aggregation {
+	case AggregationDefault:
+		return nil, nil
+	case AggregationDrop:
+		return sdkmetric.AggregationDrop{}, nil
+	case AggregationSum:
+		return sdkmetric.AggregationSum{}, nil
+	case AggregationLastValue:
+		return sdkmetric.AggregationLastValue{}, nil
+	case AggregationExplicitBucketHistogram:
+		return sdkmetric.AggregationExplicitBucketHistogram{Boundaries: config.Boundaries}, nil
+	case AggregationExponentialHistogram:
+		h := HistogramConfig{Exponential: true, MaxSize: config.MaxSize, MaxScale: config.MaxScale}
+		return h.exponentialAggregation(), nil
+	default:
+		return nil, fmt.Errorf("unknown aggregation %q", config.Aggregation)
+	}
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/oteltest"
+)
+
+func TestNewViewRejectsInvalidConfigs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ViewConfig
+		want   error
+	}{
+		{name: "no criteria", config: ViewConfig{Drop: true}, want: errViewNoCriteria},
+		{name: "wildcard rename", config: ViewConfig{InstrumentName: "http.*", Rename: "requests"}, want: errViewWildcardRename},
+		{name: "single character wildcard rename", config: ViewConfig{InstrumentName: "http.?", Rename: "requests"}, want: errViewWildcardRename},
+		{name: "rename by meter", config: ViewConfig{MeterName: "echo", Rename: "requests"}, want: errViewRenameWithoutName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewView(tt.config); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := NewView(ViewConfig{InstrumentName: "http.server.duration", Aggregation: "median"}); err == nil {
+		t.Error("unknown aggregation accepted")
+	}
+}
+
+func TestNewViewStreams(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     ViewConfig
+		instrument sdkmetric.Instrument
+		match      bool
+		want       sdkmetric.Aggregation
+		wantName   string
+	}{
+		{
+			name:       "drop by wildcard",
+			config:     ViewConfig{InstrumentName: "process.runtime.go.gc.*", Aggregation: AggregationSum, Drop: true},
+			instrument: sdkmetric.Instrument{Name: "process.runtime.go.gc.count"},
+			match:      true,
+			want:       sdkmetric.AggregationDrop{},
+		},
+		{
+			name:       "rename",
+			config:     ViewConfig{InstrumentName: "http.server.duration", Rename: "http.server.request.duration"},
+			instrument: sdkmetric.Instrument{Name: "http.server.duration"},
+			match:      true,
+			wantName:   "http.server.request.duration",
+		},
+		{
+			name:       "by meter",
+			config:     ViewConfig{MeterName: "echo", Aggregation: AggregationExplicitBucketHistogram, Boundaries: []float64{0.1, 1}},
+			instrument: sdkmetric.Instrument{Name: "http.server.duration", Scope: instrumentation.Scope{Name: "echo"}},
+			match:      true,
+			want:       sdkmetric.AggregationExplicitBucketHistogram{Boundaries: []float64{0.1, 1}},
+		},
+		{
+			name:       "other meter",
+			config:     ViewConfig{MeterName: "echo", Drop: true},
+			instrument: sdkmetric.Instrument{Name: "http.server.duration", Scope: instrumentation.Scope{Name: "grpc"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view, err := NewView(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream, ok := view(tt.instrument)
+			if ok != tt.match {
+				t.Fatalf("matched %t, want %t", ok, tt.match)
+			}
+			if !ok {
+				return
+			}
+			if tt.wantName != "" && stream.Name != tt.wantName {
+				t.Errorf("got name %q, want %q", stream.Name, tt.wantName)
+			}
+			if !reflect.DeepEqual(stream.Aggregation, tt.want) {
+				t.Errorf("got aggregation %#v, want %#v", stream.Aggregation, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewViewsAppliedToMeterProvider(t *testing.T) {
+	ctx := context.Background()
+	views, err := NewViews([]ViewConfig{
+		{InstrumentName: "requests", Rename: "http.requests", AttributeKeys: []string{"route"}},
+		{InstrumentName: "debug.*", Drop: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(views...))
+	defer mp.Shutdown(ctx)
+	meter := mp.Meter("test")
+	requests, _ := meter.Int64Counter("requests")
+	requests.Add(ctx, 1, metric.WithAttributes(attribute.String("route", "/v1/token"), attribute.String("user.id", "42")))
+	debug, _ := meter.Int64Counter("debug.allocations")
+	debug.Add(ctx, 1)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := oteltest.FindMetric(rm, "debug.allocations"); ok {
+		t.Error("dropped instrument exported")
+	}
+	if _, ok := oteltest.FindMetric(rm, "requests"); ok {
+		t.Error("renamed instrument exported with its old name")
+	}
+	m, ok := oteltest.FindMetric(rm, "http.requests")
+	if !ok {
+		t.Fatal("renamed instrument not exported")
+	}
+	points := oteltest.SumDataPoints[int64](m)
+	if len(points) != 1 || points[0].Attributes.Len() != 1 {
+		t.Fatalf("got data points %+v, want one with the route only", points)
+	}
+	oteltest.AssertAttributes(t, points[0].Attributes.ToSlice(), attribute.String("route", "/v1/token"))
+}
+
+func TestNewViewsAnnotatesIndex(t *testing.T) {
+	_, err := NewViews([]ViewConfig{{InstrumentName: "requests", Drop: true}, {Drop: true}})
+	if !errors.Is(err, errViewNoCriteria) || err.Error() != "view 1: "+errViewNoCriteria.Error() {
+		t.Errorf("got %v", err)
+	}
+}
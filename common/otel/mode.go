@@ -0,0 +1,42 @@
+package otel
+
+import "fmt"
+
+// The modes of the telemetry of a service or a single signal.
+const (
+	// ModeOTLP exports the telemetry to an OpenTelemetry collector. It is the default mode.
+	ModeOTLP string = "otlp"
+	// ModeConsole prints the telemetry to stdout, e.g. for local development without a collector.
+	ModeConsole string = "console"
+	// ModeOff installs no-op providers, so no telemetry is recorded or exported.
+	ModeOff string = "off"
+)
+
+/*
+SignalMode returns the mode of a signal. The mode of the signal takes precedence over the mode of all signals.
+
+Parameters:
+  - mode: The mode of all signals, e.g. TelemetryConfig.Mode. If empty, ModeOTLP is used.
+  - signalMode: The mode of the signal, e.g. TracingConfig.Mode. If empty, mode is used.
+
+Returns:
+  - string: The mode of the signal, one of ModeOTLP, ModeConsole or ModeOff.
+  - error: An error if the resulting mode is unknown.
+
+Example usage:
+
+	mode, err := SignalMode(cfg.Telemetry.Mode, cfg.Telemetry.Tracing.Mode)
+*/
+func SignalMode(mode string, signalMode string) (string, error) {
+	if signalMode != "" {
+		mode = signalMode
+	}
+	switch mode {
+	case "":
+		return ModeOTLP, nil
+	case ModeOTLP, ModeConsole, ModeOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown telemetry mode %q", mode)
+	}
+}
@@ -0,0 +1,30 @@
+package otel
+
+import "testing"
+
+func TestSignalMode(t *testing.T) {
+	for _, tc := range []struct {
+		mode       string
+		signalMode string
+		want       string
+		ok         bool
+	}{
+		{"", "", ModeOTLP, true},
+		{ModeConsole, "", ModeConsole, true},
+		{ModeOff, "", ModeOff, true},
+		{"", ModeConsole, ModeConsole, true},
+		// the mode of the signal takes precedence over the mode of all signals
+		{ModeOff, ModeOTLP, ModeOTLP, true},
+		{ModeOTLP, ModeOff, ModeOff, true},
+		// an invalid global mode is ignored if the signal sets a mode
+		{"invalid", ModeConsole, ModeConsole, true},
+		{"invalid", "", "", false},
+		{ModeOTLP, "invalid", "", false},
+		{"", "OTLP", "", false},
+	} {
+		got, err := SignalMode(tc.mode, tc.signalMode)
+		if got != tc.want || tc.ok != (err == nil) {
+			t.Errorf("SignalMode(%q, %q) = %q, %v, want %q", tc.mode, tc.signalMode, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package oteltest
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// AttributeValue returns the value of the attribute with the given key. The boolean is false if the key is not present.
+func AttributeValue(attrs []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// HasAttributes reports whether attrs contains every key-value pair in want.
+func HasAttributes(attrs []attribute.KeyValue, want ...attribute.KeyValue) bool {
+	for _, w := range want {
+		v, ok := AttributeValue(attrs, w.Key)
+		if !ok || v != w.Value {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+AssertAttributes reports a test error for every key-value pair in want that is missing from attrs or has a different value.
+
+Example:
+
+	span, _ := tel.FindSpan("login")
+	oteltest.AssertAttributes(t, span.Attributes, attribute.String("user.id", "42"))
+*/
+func AssertAttributes(tb testing.TB, attrs []attribute.KeyValue, want ...attribute.KeyValue) {
+	tb.Helper()
+	for _, w := range want {
+		v, ok := AttributeValue(attrs, w.Key)
+		if !ok {
+			tb.Errorf("attribute %q not found", w.Key)
+			continue
+		}
+		if v != w.Value {
+			tb.Errorf("attribute %q = %v, want %v", w.Key, v.Emit(), w.Value.Emit())
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package oteltest
+
+import (
+	"context"
+	"sync"
+
+	sdklogs "github.com/agoda-com/opentelemetry-logs-go/sdk/logs"
+	"github.com/agoda-com/opentelemetry-logs-go/sdk/logs/logstest"
+)
+
+// InMemoryLogExporter is an implementation of sdklogs.LogRecordExporter that keeps all exported log records in memory.
+type InMemoryLogExporter struct {
+	mu      sync.Mutex
+	records logstest.LogRecordStubs
+}
+
+// Interface guard.
+var _ sdklogs.LogRecordExporter = (*InMemoryLogExporter)(nil)
+
+// NewInMemoryLogExporter creates a new InMemoryLogExporter and returns a pointer to it.
+func NewInMemoryLogExporter() *InMemoryLogExporter {
+	return new(InMemoryLogExporter)
+}
+
+// Export implements sdklogs.LogRecordExporter.
+func (e *InMemoryLogExporter) Export(_ context.Context, batch []sdklogs.ReadableLogRecord) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range batch {
+		e.records = append(e.records, logstest.LogRecordStubFromReadableLogRecord(r))
+	}
+	return nil
+}
+
+// Shutdown implements sdklogs.LogRecordExporter. Captured records are kept.
+func (e *InMemoryLogExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// Reset discards all captured log records.
+func (e *InMemoryLogExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.records = nil
+}
+
+// GetLogRecords returns a copy of all captured log records.
+func (e *InMemoryLogExporter) GetLogRecords() logstest.LogRecordStubs {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	records := make(logstest.LogRecordStubs, len(e.records))
+	copy(records, e.records)
+	return records
+}
+
+// LogRecords returns all log records emitted since the Telemetry was created or last reset.
+func (t *Telemetry) LogRecords() logstest.LogRecordStubs {
+	return t.LogExporter.GetLogRecords()
+}
+
+// FindLogRecords returns all captured log records whose body equals body.
+func (t *Telemetry) FindLogRecords(body string) logstest.LogRecordStubs {
+	var found logstest.LogRecordStubs
+	for _, r := range t.LogRecords() {
+		if r.Body != nil && *r.Body == body {
+			found = append(found, r)
+		}
+	}
+	return found
+}
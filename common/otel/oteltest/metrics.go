@@ -0,0 +1,68 @@
+package oteltest
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Collect collects the current state of all instruments from MetricReader.
+func (t *Telemetry) Collect(ctx context.Context) (metricdata.ResourceMetrics, error) {
+	var rm metricdata.ResourceMetrics
+	err := t.MetricReader.Collect(ctx, &rm)
+	return rm, err
+}
+
+// Metric collects all instruments and returns the one with the given name. The boolean is false if no such instrument recorded data.
+func (t *Telemetry) Metric(ctx context.Context, name string) (metricdata.Metrics, bool, error) {
+	rm, err := t.Collect(ctx)
+	if err != nil {
+		return metricdata.Metrics{}, false, err
+	}
+	m, ok := FindMetric(rm, name)
+	return m, ok, nil
+}
+
+// FindMetric returns the instrument with the given name from collected resource metrics. The boolean is false if no such instrument exists.
+func FindMetric(rm metricdata.ResourceMetrics, name string) (metricdata.Metrics, bool) {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+// SumDataPoints returns the data points of a counter or up-down counter. It returns nil if m is not a sum of N.
+func SumDataPoints[N int64 | float64](m metricdata.Metrics) []metricdata.DataPoint[N] {
+	if s, ok := m.Data.(metricdata.Sum[N]); ok {
+		return s.DataPoints
+	}
+	return nil
+}
+
+// GaugeDataPoints returns the data points of a gauge. It returns nil if m is not a gauge of N.
+func GaugeDataPoints[N int64 | float64](m metricdata.Metrics) []metricdata.DataPoint[N] {
+	if g, ok := m.Data.(metricdata.Gauge[N]); ok {
+		return g.DataPoints
+	}
+	return nil
+}
+
+// HistogramDataPoints returns the data points of an explicit bucket histogram. It returns nil if m is not a histogram of N.
+func HistogramDataPoints[N int64 | float64](m metricdata.Metrics) []metricdata.HistogramDataPoint[N] {
+	if h, ok := m.Data.(metricdata.Histogram[N]); ok {
+		return h.DataPoints
+	}
+	return nil
+}
+
+// ExponentialHistogramDataPoints returns the data points of an exponential histogram. It returns nil if m is not an exponential histogram of N.
+func ExponentialHistogramDataPoints[N int64 | float64](m metricdata.Metrics) []metricdata.ExponentialHistogramDataPoint[N] {
+	if h, ok := m.Data.(metricdata.ExponentialHistogram[N]); ok {
+		return h.DataPoints
+	}
+	return nil
+}
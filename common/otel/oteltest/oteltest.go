@@ -0,0 +1,106 @@
+/*
+Package oteltest provides in-memory OpenTelemetry providers for unit-testing service instrumentation.
+
+The providers built by New can be assigned directly to the TracerProvider, MeterProvider and LoggerProvider fields of a service container.
+Spans, metrics and log records are kept in memory and can be inspected with the helpers in this package.
+*/
+package oteltest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	sdklogs "github.com/agoda-com/opentelemetry-logs-go/sdk/logs"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	otellogging "github.com/SaimonWoidig/cc-microsvcs/common/otel/logging"
+)
+
+// Telemetry is a set of OpenTelemetry providers backed by in-memory exporters.
+type Telemetry struct {
+	// Resource is the resource shared by all providers.
+	Resource *sdkresource.Resource
+	// TracerProvider exports every ended span synchronously to SpanExporter.
+	TracerProvider *sdktrace.TracerProvider
+	// MeterProvider is read on demand through MetricReader.
+	MeterProvider *sdkmetric.MeterProvider
+	// LoggerProvider exports every emitted log record synchronously to LogExporter.
+	LoggerProvider *sdklogs.LoggerProvider
+
+	// SpanExporter holds all ended spans.
+	SpanExporter *tracetest.InMemoryExporter
+	// MetricReader collects the current state of all instruments.
+	MetricReader *sdkmetric.ManualReader
+	// LogExporter holds all emitted log records.
+	LogExporter *InMemoryLogExporter
+}
+
+/*
+New creates a new Telemetry with in-memory exporters.
+
+Parameters:
+  - res: The resource attached to all signals. If nil, an empty resource is used.
+
+Example:
+
+	tel := oteltest.New(nil)
+	defer tel.Shutdown(context.Background())
+
+	c := &service.Container{
+		TracerProvider: tel.TracerProvider,
+		MeterProvider:  tel.MeterProvider,
+		LoggerProvider: tel.LoggerProvider,
+	}
+*/
+func New(res *sdkresource.Resource) *Telemetry {
+	if res == nil {
+		res = sdkresource.Empty()
+	}
+
+	t := &Telemetry{
+		Resource:     res,
+		SpanExporter: tracetest.NewInMemoryExporter(),
+		MetricReader: sdkmetric.NewManualReader(),
+		LogExporter:  NewInMemoryLogExporter(),
+	}
+
+	t.TracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSyncer(t.SpanExporter),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	)
+	t.MeterProvider = sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(t.MetricReader),
+	)
+	t.LoggerProvider = sdklogs.NewLoggerProvider(
+		sdklogs.WithResource(res),
+		sdklogs.WithSyncer(t.LogExporter),
+	)
+
+	return t
+}
+
+// Logger returns a slog.Logger whose records are captured by LogExporter. Records below logLevel are discarded.
+func (t *Telemetry) Logger(logLevel string) *slog.Logger {
+	return slog.New(otellogging.NewSlogOtelHandler(t.LoggerProvider, logLevel))
+}
+
+// Reset discards all captured spans and log records. Metrics are cumulative and are not affected.
+func (t *Telemetry) Reset() {
+	t.SpanExporter.Reset()
+	t.LogExporter.Reset()
+}
+
+// Shutdown shuts down all providers and returns the joined errors.
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	return errors.Join(
+		t.TracerProvider.Shutdown(ctx),
+		t.MeterProvider.Shutdown(ctx),
+		t.LoggerProvider.Shutdown(ctx),
+	)
+}
@@ -0,0 +1,128 @@
+package oteltest
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestTelemetryCapturesSpans(t *testing.T) {
+	ctx := context.Background()
+	tel := New(nil)
+	defer tel.Shutdown(ctx)
+
+	_, span := tel.TracerProvider.Tracer("test").Start(ctx, "login")
+	span.SetAttributes(attribute.String("user.id", "42"))
+	span.End()
+	_, other := tel.TracerProvider.Tracer("test").Start(ctx, "logout")
+	other.End()
+
+	if n := len(tel.Spans()); n != 2 {
+		t.Fatalf("Spans() returned %d spans, want 2", n)
+	}
+	got, ok := tel.FindSpan("login")
+	if !ok {
+		t.Fatal("span login not found")
+	}
+	AssertAttributes(t, got.Attributes, attribute.String("user.id", "42"))
+	if _, ok := tel.FindSpan("missing"); ok {
+		t.Error("FindSpan found a span that was not started")
+	}
+
+	tel.Reset()
+	if n := len(tel.Spans()); n != 0 {
+		t.Errorf("Spans() returned %d spans after Reset, want 0", n)
+	}
+}
+
+func TestTelemetryCollectsMetrics(t *testing.T) {
+	ctx := context.Background()
+	tel := New(nil)
+	defer tel.Shutdown(ctx)
+
+	meter := tel.MeterProvider.Meter("test")
+	counter, err := meter.Int64Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	histogram, err := meter.Float64Histogram("duration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(ctx, 2, metricAttrs("ok"))
+	counter.Add(ctx, 1, metricAttrs("ok"))
+	counter.Add(ctx, 1, metricAttrs("error"))
+	histogram.Record(ctx, 0.5)
+
+	m, ok, err := tel.Metric(ctx, "requests")
+	if err != nil || !ok {
+		t.Fatalf("Metric(requests) = %v, %v", ok, err)
+	}
+	points := SumDataPoints[int64](m)
+	if len(points) != 2 {
+		t.Fatalf("got %d data points, want 2", len(points))
+	}
+	for _, p := range points {
+		v, _ := AttributeValue(p.Attributes.ToSlice(), "status")
+		want := map[string]int64{"ok": 3, "error": 1}[v.AsString()]
+		if p.Value != want {
+			t.Errorf("requests{status=%s} = %d, want %d", v.AsString(), p.Value, want)
+		}
+	}
+	if SumDataPoints[float64](m) != nil {
+		t.Error("SumDataPoints returned data points of another number type")
+	}
+
+	m, ok, err = tel.Metric(ctx, "duration")
+	if err != nil || !ok {
+		t.Fatalf("Metric(duration) = %v, %v", ok, err)
+	}
+	if h := HistogramDataPoints[float64](m); len(h) != 1 || h[0].Count != 1 || h[0].Sum != 0.5 {
+		t.Errorf("unexpected histogram data points %+v", h)
+	}
+	if _, ok, _ := tel.Metric(ctx, "missing"); ok {
+		t.Error("Metric found an instrument that was not created")
+	}
+}
+
+func TestTelemetryCapturesLogs(t *testing.T) {
+	ctx := context.Background()
+	tel := New(nil)
+	defer tel.Shutdown(ctx)
+
+	logger := tel.Logger("info")
+	logger.Debug("discarded")
+	logger.Info("user logged in", "user_id", "42")
+
+	records := tel.FindLogRecords("user logged in")
+	if len(records) != 1 {
+		t.Fatalf("found %d records, want 1", len(records))
+	}
+	if n := len(tel.FindLogRecords("discarded")); n != 0 {
+		t.Errorf("found %d records below the level, want 0", n)
+	}
+
+	tel.Reset()
+	if n := len(tel.LogRecords()); n != 0 {
+		t.Errorf("LogRecords() returned %d records after Reset, want 0", n)
+	}
+}
+
+func TestHasAttributes(t *testing.T) {
+	attrs := []attribute.KeyValue{attribute.String("a", "1"), attribute.Int("b", 2)}
+	if !HasAttributes(attrs, attribute.Int("b", 2), attribute.String("a", "1")) {
+		t.Error("HasAttributes = false for present attributes")
+	}
+	if HasAttributes(attrs, attribute.String("a", "2")) {
+		t.Error("HasAttributes = true for a different value")
+	}
+	if HasAttributes(attrs, attribute.String("c", "1")) {
+		t.Error("HasAttributes = true for a missing key")
+	}
+}
+
+func metricAttrs(status string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("status", status))
+}
@@ -0,0 +1,30 @@
+package oteltest
+
+import (
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Spans returns all spans ended since the Telemetry was created or last reset.
+func (t *Telemetry) Spans() tracetest.SpanStubs {
+	return t.SpanExporter.GetSpans()
+}
+
+// FindSpans returns all ended spans with the given name in the order they ended.
+func (t *Telemetry) FindSpans(name string) tracetest.SpanStubs {
+	var found tracetest.SpanStubs
+	for _, s := range t.Spans() {
+		if s.Name == name {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+// FindSpan returns the first ended span with the given name. The boolean is false if no such span exists.
+func (t *Telemetry) FindSpan(name string) (tracetest.SpanStub, bool) {
+	spans := t.FindSpans(name)
+	if len(spans) == 0 {
+		return tracetest.SpanStub{}, false
+	}
+	return spans[0], true
+}
@@ -2,34 +2,83 @@ package otel
 
 import (
 	"context"
+	"net/url"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/buildinfo"
+)
+
+// The attribute keys of the build metadata without a semantic convention.
+const (
+	// VCSRevisionKey is the attribute key holding the VCS revision the service was built from.
+	VCSRevisionKey attribute.Key = "vcs.revision"
+	// VCSModifiedKey is the attribute key reporting whether the service was built from a modified working tree.
+	VCSModifiedKey attribute.Key = "vcs.modified"
+	// BuildTimeKey is the attribute key holding the RFC 3339 build time of the service.
+	BuildTimeKey attribute.Key = "build.time"
 )
 
 type ResourceConfig struct {
-	Name       string
+	Name string
+	// Version is the version of the service. If empty, the version of buildinfo.Get is used.
 	Version    string
 	Addr       string
 	Port       int
 	InstanceID string
+	// Environment is the deployment environment of the service, e.g. "production". Not set if empty.
+	Environment string
+	// Attributes are additional resource attributes, e.g. {"service.namespace": "cc-microsvcs"}. They override the detected attributes and OTEL_RESOURCE_ATTRIBUTES.
+	Attributes map[string]string
+	// PodInfoDir is the mount path of the Kubernetes downward API volume. If empty, DefaultPodInfoDir is used.
+	PodInfoDir string
 }
 
+/*
+NewResource creates the resource describing the service.
+Besides the attributes of config, the resource contains the detected process, host, container and OS attributes,
+the build metadata of buildinfo.Get, the Kubernetes pod attributes detected from the downward API and the attributes of OTEL_RESOURCE_ATTRIBUTES.
+The attributes of OTEL_RESOURCE_ATTRIBUTES override the detected ones and are overridden by config.Attributes,
+which in turn are overridden by the attributes identifying the service, e.g. service.name.
+
+Parameters:
+  - ctx: The context of the detection.
+  - config: The attributes of the service.
+
+Returns:
+  - *sdkresource.Resource: The created resource.
+  - error: An error if the resource could not be detected or merged. The partially detected resource may still be returned, see sdkresource.New.
+
+Example usage:
+
+	res, err := NewResource(ctx, ResourceConfig{Name: "auth-service", InstanceID: iid, Environment: "production"})
+*/
 func NewResource(ctx context.Context, config ResourceConfig) (*sdkresource.Resource, error) {
+	bi := buildinfo.Get()
+	version := config.Version
+	if version == "" {
+		version = bi.Version
+	}
+	podInfoDir := config.PodInfoDir
+	if podInfoDir == "" {
+		podInfoDir = DefaultPodInfoDir
+	}
+
 	res, err := sdkresource.New(ctx,
-		sdkresource.WithFromEnv(),
 		sdkresource.WithProcess(),
 		sdkresource.WithHost(),
 		sdkresource.WithContainer(),
 		sdkresource.WithOS(),
+		sdkresource.WithDetectors(&kubernetesDetector{podInfoDir: podInfoDir}),
 		sdkresource.WithSchemaURL(semconv.SchemaURL),
-		sdkresource.WithAttributes(
-			semconv.ServiceName(config.Name),
-			semconv.ServiceVersion(config.Version),
-			semconv.ServiceInstanceID(config.InstanceID),
-			semconv.ServerAddress(config.Addr),
-			semconv.ServerPort(config.Port),
-		),
+		sdkresource.WithAttributes(buildAttributes(bi)...),
+		sdkresource.WithFromEnv(),
+		sdkresource.WithAttributes(extraAttributes(config.Attributes)...),
+		sdkresource.WithAttributes(serviceAttributes(config, version)...),
 	)
 	if err != nil {
 		return nil, err
@@ -37,3 +86,58 @@ func NewResource(ctx context.Context, config ResourceConfig) (*sdkresource.Resou
 
 	return sdkresource.Merge(sdkresource.Default(), res)
 }
+
+// serviceAttributes returns the attributes identifying the service.
+func serviceAttributes(config ResourceConfig, version string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(config.Name),
+		semconv.ServiceVersion(version),
+		semconv.ServiceInstanceID(config.InstanceID),
+	}
+	if host := serverHost(config.Addr); host != "" {
+		attrs = append(attrs, semconv.ServerAddress(host))
+	}
+	if config.Port > 0 {
+		attrs = append(attrs, semconv.ServerPort(config.Port))
+	}
+	if config.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(config.Environment))
+	}
+	return attrs
+}
+
+// buildAttributes returns the attributes of the build metadata which are known.
+func buildAttributes(bi buildinfo.Info) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if bi.Revision != "" {
+		attrs = append(attrs, VCSRevisionKey.String(bi.Revision), VCSModifiedKey.Bool(bi.Modified))
+	}
+	if bi.BuildTime != nil {
+		attrs = append(attrs, BuildTimeKey.String(bi.BuildTime.UTC().Format(time.RFC3339)))
+	}
+	return attrs
+}
+
+// extraAttributes returns attrs as string attributes.
+func extraAttributes(attrs map[string]string) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	return kvs
+}
+
+// serverHost returns the host of addr, stripping the scheme, port and path of URL-like addresses, e.g. "example.com" for "https://example.com:8080/auth".
+func serverHost(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "//" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,95 @@
+package otel
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/buildinfo"
+)
+
+// unsetenv unsets the environment variable key for the test, restoring it afterwards.
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+// assertAttribute fails the test if res does not have the string attribute key with want.
+func assertAttribute(t *testing.T, res *sdkresource.Resource, key attribute.Key, want string) {
+	t.Helper()
+	got, ok := res.Set().Value(key)
+	if !ok {
+		t.Errorf("attribute %s missing", key)
+		return
+	}
+	if got.Emit() != want {
+		t.Errorf("attribute %s = %q, want %q", key, got.Emit(), want)
+	}
+}
+
+func TestNewResource(t *testing.T) {
+	unsetenv(t, "KUBERNETES_SERVICE_HOST")
+	unsetenv(t, EnvK8SPodName)
+	unsetenv(t, EnvK8SNamespaceName)
+	t.Setenv("OTEL_SERVICE_NAME", "env-service")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "service.namespace=env,deployment.environment=env,env.only=env")
+
+	res, err := NewResource(context.Background(), ResourceConfig{
+		Name:        "auth-service",
+		Version:     "1.2.3",
+		Addr:        "https://example.com:8443/auth",
+		Port:        8080,
+		InstanceID:  "iid",
+		Environment: "production",
+		Attributes:  map[string]string{"service.namespace": "config"},
+		PodInfoDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the configured attributes override OTEL_RESOURCE_ATTRIBUTES
+	assertAttribute(t, res, "service.name", "auth-service")
+	assertAttribute(t, res, "service.version", "1.2.3")
+	assertAttribute(t, res, "service.instance.id", "iid")
+	assertAttribute(t, res, "service.namespace", "config")
+	assertAttribute(t, res, "deployment.environment", "production")
+	assertAttribute(t, res, "env.only", "env")
+	assertAttribute(t, res, "server.address", "example.com")
+	assertAttribute(t, res, "server.port", "8080")
+	if _, ok := res.Set().Value("k8s.pod.name"); ok {
+		t.Error("Kubernetes attributes detected outside of Kubernetes")
+	}
+}
+
+func TestNewResourceDefaultVersion(t *testing.T) {
+	res, err := NewResource(context.Background(), ResourceConfig{Name: "auth-service", PodInfoDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAttribute(t, res, "service.version", buildinfo.Get().Version)
+	if _, ok := res.Set().Value("server.address"); ok {
+		t.Error("server.address set without an address")
+	}
+}
+
+func TestServerHost(t *testing.T) {
+	for _, tc := range []struct {
+		addr string
+		want string
+	}{
+		{"", ""},
+		{"example.com", "example.com"},
+		{"example.com:8080", "example.com"},
+		{"https://example.com:8080/auth", "example.com"},
+		{"0.0.0.0", "0.0.0.0"},
+		{"[::1]:8080", "::1"},
+	} {
+		if got := serverHost(tc.addr); got != tc.want {
+			t.Errorf("serverHost(%q) = %q, want %q", tc.addr, got, tc.want)
+		}
+	}
+}
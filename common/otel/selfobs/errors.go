@@ -0,0 +1,65 @@
+package selfobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+const (
+	// CategoryTimeout is the category of exports which timed out.
+	CategoryTimeout string = "timeout"
+	// CategoryCanceled is the category of exports which were canceled, e.g. during shutdown.
+	CategoryCanceled string = "canceled"
+	// CategoryConnection is the category of exports which could not reach the endpoint.
+	CategoryConnection string = "connection"
+	// CategoryExport is the category of all other failed exports, e.g. rejected by the endpoint.
+	CategoryExport string = "export"
+)
+
+// ExportError is the error returned by the exporters wrapped by a Tracker. It carries the exporter the error occurred in,
+// so the error handler can report the endpoint and deduplicate errors per exporter.
+type ExportError struct {
+	// Exporter is the name of the exporter.
+	Exporter string
+	// Signal is the signal of the exporter.
+	Signal string
+	// Endpoint is the endpoint the exporter exports to.
+	Endpoint string
+	// Err is the error returned by the exporter.
+	Err error
+}
+
+// Error implements error.
+func (e *ExportError) Error() string {
+	return fmt.Sprintf("%s exporter %q failed: %v", e.Signal, e.Exporter, e.Err)
+}
+
+// Unwrap returns the error returned by the exporter.
+func (e *ExportError) Unwrap() error {
+	return e.Err
+}
+
+// Category returns the category of err, one of the Category constants.
+func Category(err error) string {
+	var netErr net.Error
+	var urlErr *url.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return CategoryTimeout
+	case errors.Is(err, context.Canceled):
+		return CategoryCanceled
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return CategoryTimeout
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.As(err, &opErr), errors.As(err, &dnsErr), errors.As(err, &urlErr):
+		return CategoryConnection
+	default:
+		return CategoryExport
+	}
+}
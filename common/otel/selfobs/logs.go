@@ -0,0 +1,50 @@
+package selfobs
+
+import (
+	"context"
+
+	sdklogs "github.com/agoda-com/opentelemetry-logs-go/sdk/logs"
+)
+
+// logExporter is a sdklogs.LogRecordExporter recording the result of its exports.
+type logExporter struct {
+	sdklogs.LogRecordExporter
+	tracker *Tracker
+}
+
+// LogExporter wraps exporter so its exports are recorded by t.
+func (t *Tracker) LogExporter(exporter sdklogs.LogRecordExporter) sdklogs.LogRecordExporter {
+	return &logExporter{LogRecordExporter: exporter, tracker: t}
+}
+
+// Export exports batch and records the result.
+func (e *logExporter) Export(ctx context.Context, batch []sdklogs.ReadableLogRecord) error {
+	return e.tracker.done(ctx, len(batch), e.LogRecordExporter.Export(ctx, batch))
+}
+
+// logProcessor is a sdklogs.LogRecordProcessor recording the log records queued in the wrapped batch processor.
+type logProcessor struct {
+	sdklogs.LogRecordProcessor
+	tracker *Tracker
+}
+
+// LogProcessor wraps the batch processor feeding the exporter of t, so its queue size and drops are recorded.
+func (t *Tracker) LogProcessor(processor sdklogs.LogRecordProcessor) sdklogs.LogRecordProcessor {
+	return &logProcessor{LogRecordProcessor: processor, tracker: t}
+}
+
+// OnEmit records r and passes it to the wrapped processor.
+func (p *logProcessor) OnEmit(r sdklogs.ReadableLogRecord) {
+	p.tracker.enqueue(context.Background())
+	p.LogRecordProcessor.OnEmit(r)
+}
+
+// Shutdown shuts down the wrapped processor.
+func (p *logProcessor) Shutdown(ctx context.Context) error {
+	return p.LogRecordProcessor.Shutdown(ctx)
+}
+
+// ForceFlush flushes the wrapped processor.
+func (p *logProcessor) ForceFlush(ctx context.Context) error {
+	return p.LogRecordProcessor.ForceFlush(ctx)
+}
@@ -0,0 +1,54 @@
+package selfobs
+
+import (
+	"context"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// metricExporter is a sdkmetric.Exporter recording the result of its exports.
+type metricExporter struct {
+	sdkmetric.Exporter
+	tracker *Tracker
+}
+
+// MetricExporter wraps exporter so its exports are recorded by t. Exports are counted in data points.
+func (t *Tracker) MetricExporter(exporter sdkmetric.Exporter) sdkmetric.Exporter {
+	return &metricExporter{Exporter: exporter, tracker: t}
+}
+
+// Export exports rm and records the result.
+func (e *metricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.tracker.done(ctx, dataPoints(rm), e.Exporter.Export(ctx, rm))
+}
+
+// dataPoints returns the number of data points in rm.
+func dataPoints(rm *metricdata.ResourceMetrics) int {
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch a := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				n += len(a.DataPoints)
+			case metricdata.Gauge[float64]:
+				n += len(a.DataPoints)
+			case metricdata.Sum[int64]:
+				n += len(a.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(a.DataPoints)
+			case metricdata.Histogram[int64]:
+				n += len(a.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(a.DataPoints)
+			case metricdata.ExponentialHistogram[int64]:
+				n += len(a.DataPoints)
+			case metricdata.ExponentialHistogram[float64]:
+				n += len(a.DataPoints)
+			case metricdata.Summary:
+				n += len(a.DataPoints)
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,207 @@
+/*
+Package selfobs contains the self-observability of the telemetry pipelines: counters of exported, failed and dropped items per exporter,
+the size of the batch processor queues, the time of the last successful export and the error type carrying the exporter of a failed export.
+*/
+package selfobs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// MeterName is the name of the meter the self-observability metrics are created with.
+const MeterName string = "github.com/SaimonWoidig/cc-microsvcs/common/otel/selfobs"
+
+const (
+	// SignalTraces is the signal of span exporters.
+	SignalTraces string = "traces"
+	// SignalMetrics is the signal of metric exporters.
+	SignalMetrics string = "metrics"
+	// SignalLogs is the signal of log record exporters.
+	SignalLogs string = "logs"
+)
+
+const (
+	// exporterKey is the attribute key holding the name of an exporter.
+	exporterKey attribute.Key = "exporter"
+	// signalKey is the attribute key holding the signal of an exporter.
+	signalKey attribute.Key = "signal"
+)
+
+// ExporterInfo is a struct that describes a tracked exporter.
+type ExporterInfo struct {
+	// Name is the name of the exporter, e.g. "otlp" or the name of a log pipeline.
+	Name string
+	// Signal is the signal the exporter exports, one of the Signal constants.
+	Signal string
+	// Endpoint is the endpoint the exporter exports to. It is included in export errors.
+	Endpoint string
+}
+
+// Stats holds the self-observability instruments shared by all tracked exporters.
+type Stats struct {
+	exported metric.Int64Counter
+	failed   metric.Int64Counter
+	dropped  metric.Int64Counter
+
+	mu       sync.Mutex
+	trackers []*Tracker
+}
+
+/*
+New creates the self-observability instruments with mp.
+
+Parameters:
+  - mp: The MeterProvider the instruments are created with, usually the global otel.GetMeterProvider() so the instruments can be created before the MeterProvider of the service.
+
+Returns:
+  - *Stats: The instruments, used to track exporters with Track.
+  - error: An error if an instrument could not be created.
+
+The following metrics are recorded for every tracked exporter, with the exporter and signal attributes:
+  - otel.exporter.exported: The number of spans, metric data points or log records exported successfully.
+  - otel.exporter.failed: The number of spans, metric data points or log records whose export failed.
+  - otel.exporter.dropped: The number of spans or log records dropped because the batch processor queue was full.
+    The batch processors do not report their drops, so they are estimated from the items passed to the processor and handed to the exporter.
+  - otel.exporter.queue.size: The number of spans or log records waiting in the batch processor, estimated the same way.
+  - otel.exporter.last_success: The Unix time of the last successful export in seconds.
+*/
+func New(mp metric.MeterProvider) (*Stats, error) {
+	meter := mp.Meter(MeterName)
+	s := new(Stats)
+
+	var err error
+	if s.exported, err = meter.Int64Counter(
+		"otel.exporter.exported",
+		metric.WithDescription("Number of items exported successfully."),
+		metric.WithUnit("{item}"),
+	); err != nil {
+		return nil, err
+	}
+	if s.failed, err = meter.Int64Counter(
+		"otel.exporter.failed",
+		metric.WithDescription("Number of items whose export failed."),
+		metric.WithUnit("{item}"),
+	); err != nil {
+		return nil, err
+	}
+	if s.dropped, err = meter.Int64Counter(
+		"otel.exporter.dropped",
+		metric.WithDescription("Number of items dropped because the batch processor queue was full."),
+		metric.WithUnit("{item}"),
+	); err != nil {
+		return nil, err
+	}
+
+	queueSize, err := meter.Int64ObservableGauge(
+		"otel.exporter.queue.size",
+		metric.WithDescription("Number of items waiting in the batch processor."),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	lastSuccess, err := meter.Float64ObservableGauge(
+		"otel.exporter.last_success",
+		metric.WithDescription("Unix time of the last successful export."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, t := range s.trackers {
+			if t.maxQueueSize > 0 {
+				o.ObserveInt64(queueSize, t.pending.Load(), t.attrs)
+			}
+			if last := t.lastSuccess.Load(); last > 0 {
+				o.ObserveFloat64(lastSuccess, float64(last)/float64(time.Second), t.attrs)
+			}
+		}
+		return nil
+	}, queueSize, lastSuccess)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+/*
+Track creates a Tracker for the exporter described by info.
+The exporter and, for spans and log records, the batch processor feeding it have to be wrapped with the methods of the Tracker.
+
+Parameters:
+  - info: The description of the exporter.
+  - maxQueueSize: The queue size of the batch processor, e.g. sdktrace.DefaultMaxQueueSize. Zero disables the queue tracking, e.g. for metric exporters.
+
+Returns:
+  - *Tracker: The tracker of the exporter.
+*/
+func (s *Stats) Track(info ExporterInfo, maxQueueSize int) *Tracker {
+	t := &Tracker{
+		stats:        s,
+		info:         info,
+		attrs:        metric.WithAttributes(exporterKey.String(info.Name), signalKey.String(info.Signal)),
+		maxQueueSize: int64(maxQueueSize),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trackers = append(s.trackers, t)
+	return t
+}
+
+// Tracker records the self-observability metrics of a single exporter and the batch processor feeding it.
+type Tracker struct {
+	stats *Stats
+	info  ExporterInfo
+	attrs metric.MeasurementOption
+
+	maxQueueSize int64
+	// pending is the number of items passed to the batch processor which were not handed to the exporter yet.
+	// It never exceeds maxQueueSize, so it recovers from drops it did not record once the exporter catches up.
+	pending atomic.Int64
+	// lastSuccess is the Unix time of the last successful export in nanoseconds.
+	lastSuccess atomic.Int64
+}
+
+// enqueue records an item passed to the batch processor. The batch processor drops items silently when its queue is full,
+// so an item arriving while maxQueueSize items are pending is recorded as dropped instead of pending.
+// The item is passed to the batch processor either way, it alone decides whether the item is dropped.
+func (t *Tracker) enqueue(ctx context.Context) {
+	if t.maxQueueSize <= 0 {
+		return
+	}
+	for {
+		n := t.pending.Load()
+		if n >= t.maxQueueSize {
+			t.stats.dropped.Add(ctx, 1, t.attrs)
+			return
+		}
+		if t.pending.CompareAndSwap(n, n+1) {
+			return
+		}
+	}
+}
+
+// done records the export of n items with the result err. It returns err wrapped in an ExportError.
+func (t *Tracker) done(ctx context.Context, n int, err error) error {
+	if t.maxQueueSize > 0 {
+		if t.pending.Add(int64(-n)) < 0 {
+			t.pending.Store(0)
+		}
+	}
+	if err != nil {
+		t.stats.failed.Add(ctx, int64(n), t.attrs)
+		return &ExportError{Exporter: t.info.Name, Signal: t.info.Signal, Endpoint: t.info.Endpoint, Err: err}
+	}
+	t.stats.exported.Add(ctx, int64(n), t.attrs)
+	t.lastSuccess.Store(time.Now().UnixNano())
+	return nil
+}
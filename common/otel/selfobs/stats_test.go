@@ -0,0 +1,180 @@
+package selfobs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	sdklogs "github.com/agoda-com/opentelemetry-logs-go/sdk/logs"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/oteltest"
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/selfobs"
+)
+
+// newTestStats creates Stats recording to a new oteltest.Telemetry.
+func newTestStats(t *testing.T) (*selfobs.Stats, *oteltest.Telemetry) {
+	t.Helper()
+	tel := oteltest.New(nil)
+	t.Cleanup(func() { _ = tel.Shutdown(context.Background()) })
+	stats, err := selfobs.New(tel.MeterProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats, tel
+}
+
+// value returns the value of the int64 sum or gauge name recorded for the exporter.
+func value(t *testing.T, tel *oteltest.Telemetry, name, exporter string) (int64, bool) {
+	t.Helper()
+	m, ok, err := tel.Metric(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		return 0, false
+	}
+	points := oteltest.SumDataPoints[int64](m)
+	if points == nil {
+		points = oteltest.GaugeDataPoints[int64](m)
+	}
+	for _, p := range points {
+		if oteltest.HasAttributes(p.Attributes.ToSlice(), attribute.String("exporter", exporter)) {
+			return p.Value, true
+		}
+	}
+	return 0, false
+}
+
+// logRecorder is a sdklogs.LogRecordProcessor counting the emitted log records.
+type logRecorder struct {
+	sdklogs.LogRecordProcessor
+	emitted int
+}
+
+func (r *logRecorder) OnEmit(sdklogs.ReadableLogRecord) { r.emitted++ }
+
+func TestProcessorsPassAllItems(t *testing.T) {
+	stats, tel := newTestStats(t)
+
+	// the queue of the span processor holds two spans, the third is recorded as dropped but still passed on
+	spans := tracetest.NewSpanRecorder()
+	tracker := stats.Track(selfobs.ExporterInfo{Name: "otlp", Signal: selfobs.SignalTraces}, 2)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tracker.SpanProcessor(spans)))
+	for i := 0; i < 3; i++ {
+		_, span := tp.Tracer("test").Start(context.Background(), fmt.Sprintf("span %d", i))
+		span.End()
+	}
+	if n := len(spans.Ended()); n != 3 {
+		t.Errorf("processor got %d spans, want 3", n)
+	}
+	if n, _ := value(t, tel, "otel.exporter.dropped", "otlp"); n != 1 {
+		t.Errorf("dropped %d, want 1", n)
+	}
+	if n, _ := value(t, tel, "otel.exporter.queue.size", "otlp"); n != 2 {
+		t.Errorf("queue size %d, want 2", n)
+	}
+
+	// exporting a batch frees the queue
+	exporter := tracker.SpanExporter(tracetest.NewInMemoryExporter())
+	if err := exporter.ExportSpans(context.Background(), spans.Ended()[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := value(t, tel, "otel.exporter.queue.size", "otlp"); n != 0 {
+		t.Errorf("queue size %d after the export, want 0", n)
+	}
+	if n, _ := value(t, tel, "otel.exporter.exported", "otlp"); n != 2 {
+		t.Errorf("exported %d, want 2", n)
+	}
+
+	logs := &logRecorder{}
+	processor := stats.Track(selfobs.ExporterInfo{Name: "audit", Signal: selfobs.SignalLogs}, 1).LogProcessor(logs)
+	processor.OnEmit(nil)
+	processor.OnEmit(nil)
+	if logs.emitted != 2 {
+		t.Errorf("processor got %d log records, want 2", logs.emitted)
+	}
+	if n, _ := value(t, tel, "otel.exporter.dropped", "audit"); n != 1 {
+		t.Errorf("dropped %d log records, want 1", n)
+	}
+}
+
+func TestProcessorIgnoresUnsampledSpans(t *testing.T) {
+	stats, tel := newTestStats(t)
+	tracker := stats.Track(selfobs.ExporterInfo{Name: "otlp", Signal: selfobs.SignalTraces}, 1)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()), sdktrace.WithSpanProcessor(tracker.SpanProcessor(tracetest.NewSpanRecorder())))
+	for i := 0; i < 3; i++ {
+		_, span := tp.Tracer("test").Start(context.Background(), "span")
+		span.End()
+	}
+	if n, ok := value(t, tel, "otel.exporter.dropped", "otlp"); ok {
+		t.Errorf("dropped %d unsampled spans", n)
+	}
+}
+
+// failingExporter is a sdkmetric.Exporter failing with err.
+type failingExporter struct {
+	sdkmetric.Exporter
+	err error
+}
+
+func (e failingExporter) Export(context.Context, *metricdata.ResourceMetrics) error { return e.err }
+
+func TestExporterRecordsResult(t *testing.T) {
+	stats, tel := newTestStats(t)
+	rm := &metricdata.ResourceMetrics{ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{
+		{Name: "a", Data: metricdata.Sum[int64]{DataPoints: make([]metricdata.DataPoint[int64], 2)}},
+		{Name: "b", Data: metricdata.Histogram[float64]{DataPoints: make([]metricdata.HistogramDataPoint[float64], 3)}},
+	}}}}
+
+	info := selfobs.ExporterInfo{Name: "otlp", Signal: selfobs.SignalMetrics, Endpoint: "collector:4318"}
+	refused := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	err := stats.Track(info, 0).MetricExporter(failingExporter{err: refused}).Export(context.Background(), rm)
+	var exportErr *selfobs.ExportError
+	if !errors.As(err, &exportErr) || exportErr.Endpoint != "collector:4318" || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("got %v, want an ExportError", err)
+	}
+	if n, _ := value(t, tel, "otel.exporter.failed", "otlp"); n != 5 {
+		t.Errorf("failed %d, want 5 data points", n)
+	}
+	if _, ok := value(t, tel, "otel.exporter.queue.size", "otlp"); ok {
+		t.Error("queue size recorded for a metric exporter")
+	}
+
+	if err := stats.Track(selfobs.ExporterInfo{Name: "prometheus", Signal: selfobs.SignalMetrics}, 0).MetricExporter(failingExporter{}).Export(context.Background(), rm); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := value(t, tel, "otel.exporter.exported", "prometheus"); n != 5 {
+		t.Errorf("exported %d, want 5 data points", n)
+	}
+	m, ok, err := tel.Metric(context.Background(), "otel.exporter.last_success")
+	if err != nil || !ok || len(oteltest.GaugeDataPoints[float64](m)) != 1 {
+		t.Errorf("last success not recorded for the successful exporter only: %v", m)
+	}
+}
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: context.DeadlineExceeded, want: selfobs.CategoryTimeout},
+		{err: fmt.Errorf("export: %w", context.Canceled), want: selfobs.CategoryCanceled},
+		{err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: selfobs.CategoryConnection},
+		{err: &net.DNSError{Name: "collector", IsNotFound: true}, want: selfobs.CategoryConnection},
+		{err: &net.DNSError{Name: "collector", IsTimeout: true}, want: selfobs.CategoryTimeout},
+		{err: errors.New("failed to send to http://collector:4318/v1/traces: 400 Bad Request"), want: selfobs.CategoryExport},
+	}
+	for _, tt := range tests {
+		if got := selfobs.Category(tt.err); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package selfobs
+
+import (
+	"context"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanExporter is a sdktrace.SpanExporter recording the result of its exports.
+type spanExporter struct {
+	sdktrace.SpanExporter
+	tracker *Tracker
+}
+
+// SpanExporter wraps exporter so its exports are recorded by t.
+func (t *Tracker) SpanExporter(exporter sdktrace.SpanExporter) sdktrace.SpanExporter {
+	return &spanExporter{SpanExporter: exporter, tracker: t}
+}
+
+// ExportSpans exports spans and records the result.
+func (e *spanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.tracker.done(ctx, len(spans), e.SpanExporter.ExportSpans(ctx, spans))
+}
+
+// spanProcessor is a sdktrace.SpanProcessor recording the spans queued in the wrapped batch processor.
+type spanProcessor struct {
+	sdktrace.SpanProcessor
+	tracker *Tracker
+}
+
+// SpanProcessor wraps the batch processor feeding the exporter of t, so its queue size and drops are recorded.
+func (t *Tracker) SpanProcessor(processor sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return &spanProcessor{SpanProcessor: processor, tracker: t}
+}
+
+// OnEnd records s and passes it to the wrapped processor. Spans which are not sampled are ignored by the batch processor and not recorded.
+func (p *spanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.tracker.enqueue(context.Background())
+	}
+	p.SpanProcessor.OnEnd(s)
+}
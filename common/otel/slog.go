@@ -1,9 +1,22 @@
 package otel
 
 import (
+	"errors"
 	"log/slog"
+	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/selfobs"
+)
+
+const (
+	// DefaultErrorLogInterval is the default interval repeated errors are logged in. It is set to 1 minute.
+	DefaultErrorLogInterval time.Duration = time.Minute
+
+	// maxErrorKeys is the maximum number of distinct errors the rate limiter remembers.
+	maxErrorKeys int = 1000
 )
 
 /*
@@ -11,6 +24,7 @@ OtelSlogErrorHandler is a struct representing an OpenTelemetry error handler tha
 
 Fields:
   - l: A pointer to a slog.Logger instance.
+  - limiter: The rate limiter deduplicating repeated errors, nil if every error is logged.
 
 Implemented Interfaces:
   - otel.ErrorHandler
@@ -20,13 +34,15 @@ Example Usage:
 	o := OtelSlogErrorHandler{}
 */
 type OtelSlogErrorHandler struct {
-	l *slog.Logger
+	l       *slog.Logger
+	limiter *errorLimiter
 }
 
 var _ otel.ErrorHandler = OtelSlogErrorHandler{}
 
 /*
 NewOtelSlogErrorHandler creates a new instance of OtelErrorSlogLogger.
+Repeated errors are logged at most once per DefaultErrorLogInterval, see NewOtelSlogErrorHandlerWithInterval.
 
 Parameters:
   - logger: A pointer to a slog.Logger instance.
@@ -40,14 +56,35 @@ Example:
 	otelLogger := otel.NewOtelSlogErrorHandler(logger)
 */
 func NewOtelSlogErrorHandler(logger *slog.Logger) OtelSlogErrorHandler {
-	return OtelSlogErrorHandler{
+	return NewOtelSlogErrorHandlerWithInterval(logger, DefaultErrorLogInterval)
+}
+
+/*
+NewOtelSlogErrorHandlerWithInterval creates a new instance of OtelErrorSlogLogger logging repeated errors at most once per interval.
+Errors of exporters tracked by selfobs are deduplicated by exporter and error category, other errors by their message.
+The number of suppressed errors is logged with the next logged occurrence.
+
+Parameters:
+  - logger: A pointer to a slog.Logger instance.
+  - interval: The interval repeated errors are logged in. Zero or less logs every error.
+
+Returns:
+  - OtelErrorSlogLogger: The newly created OtelErrorSlogLogger instance.
+*/
+func NewOtelSlogErrorHandlerWithInterval(logger *slog.Logger, interval time.Duration) OtelSlogErrorHandler {
+	h := OtelSlogErrorHandler{
 		l: logger,
 	}
+	if interval > 0 {
+		h.limiter = &errorLimiter{interval: interval, seen: make(map[string]*limitedError)}
+	}
+	return h
 }
 
 /*
 Handle implements otel.ErrorHandler. Logs the error using the slog logging package.
 It takes an error as a parameter and logs the error message using the slog.Logger instance.
+Export errors are logged with the exporter, signal, endpoint and error category. Repeated errors are rate limited.
 
 Parameters:
   - err: The error to be logged.
@@ -58,5 +95,77 @@ Example:
 	o.Handle(err)
 */
 func (o OtelSlogErrorHandler) Handle(err error) {
-	o.l.Error("opentelemetry error", "error", err.Error())
+	args := []any{"error", err.Error()}
+	key := err.Error()
+
+	var exportErr *selfobs.ExportError
+	if errors.As(err, &exportErr) {
+		category := selfobs.Category(exportErr.Err)
+		args = append(args,
+			"exporter", exportErr.Exporter,
+			"signal", exportErr.Signal,
+			"endpoint", exportErr.Endpoint,
+			"category", category,
+		)
+		key = exportErr.Signal + "/" + exportErr.Exporter + "/" + category
+	}
+
+	if o.limiter != nil {
+		ok, suppressed := o.limiter.allow(key, time.Now())
+		if !ok {
+			return
+		}
+		if suppressed > 0 {
+			args = append(args, "suppressed", suppressed)
+		}
+	}
+	o.l.Error("opentelemetry error", args...)
+}
+
+// limitedError is the rate limiting state of a single distinct error.
+type limitedError struct {
+	logged     time.Time
+	suppressed int
+}
+
+// errorLimiter deduplicates errors by key, allowing each key to be logged once per interval.
+type errorLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	seen     map[string]*limitedError
+}
+
+// allow reports whether the error with key may be logged at now, and how many occurrences were suppressed since it was logged last.
+func (l *errorLimiter) allow(key string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.seen[key]
+	if ok && now.Sub(e.logged) < l.interval {
+		e.suppressed++
+		return false, 0
+	}
+	if !ok {
+		if len(l.seen) >= maxErrorKeys {
+			l.evict(now)
+		}
+		e = new(limitedError)
+		l.seen[key] = e
+	}
+	suppressed := e.suppressed
+	e.logged = now
+	e.suppressed = 0
+	return true, suppressed
+}
+
+// evict forgets the errors which were not logged within the last interval, or all errors if none are that old.
+func (l *errorLimiter) evict(now time.Time) {
+	for key, e := range l.seen {
+		if now.Sub(e.logged) >= l.interval {
+			delete(l.seen, key)
+		}
+	}
+	if len(l.seen) >= maxErrorKeys {
+		l.seen = make(map[string]*limitedError)
+	}
 }
@@ -21,7 +21,13 @@ func NewStdoutTraceExporter() (*stdouttrace.Exporter, error) {
 }
 
 func NewOTLPTraceExporter(ctx context.Context, otlpEndpoint string, exportTimeout time.Duration) (*otlptrace.Exporter, error) {
-	exporter, err := otlptracehttp.New(ctx,
+	exporter, err := otlptrace.New(ctx, NewOTLPTraceClient(otlpEndpoint, exportTimeout))
+	return exporter, err
+}
+
+// NewOTLPTraceClient creates the OTLP/HTTP client used by NewOTLPTraceExporter, e.g. to wrap it with a disk queue.
+func NewOTLPTraceClient(otlpEndpoint string, exportTimeout time.Duration) otlptrace.Client {
+	return otlptracehttp.NewClient(
 		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
 			Enabled:        true,
 			MaxElapsedTime: time.Minute,
@@ -30,7 +36,6 @@ func NewOTLPTraceExporter(ctx context.Context, otlpEndpoint string, exportTimeou
 		otlptracehttp.WithInsecure(),
 		otlptracehttp.WithEndpoint(otlpEndpoint),
 	)
-	return exporter, err
 }
 
 func NewAlwaysSampleSampler() sdktrace.Sampler {
@@ -50,6 +55,16 @@ func NewTraceProvider(res *sdkresource.Resource, exporter sdktrace.SpanExporter,
 	return tp, nil
 }
 
+// NewTraceProviderWithProcessor creates a TracerProvider passing the ended spans to processor, e.g. a batch processor tracked by selfobs.
+func NewTraceProviderWithProcessor(res *sdkresource.Resource, processor sdktrace.SpanProcessor, sampler sdktrace.Sampler) (*sdktrace.TracerProvider, error) {
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sampler),
+	)
+	return tp, nil
+}
+
 func NewTextMapPropagator() propagation.TextMapPropagator {
 	return propagation.NewCompositeTextMapPropagator(
 		propagation.TraceContext{},
@@ -0,0 +1,52 @@
+// Command metrics-catalog writes the catalog of the business metrics of the auth service as Markdown or JSON.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/service"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+)
+
+func main() {
+	format := flag.String("format", "md", "output format, md or json")
+	out := flag.String("out", "", "output file, stdout if empty")
+	flag.Parse()
+
+	if err := run(*format, *out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(format string, out string) error {
+	m, err := telemetry.NewMetrics(noop.NewMeterProvider().Meter(service.AppName))
+	if err != nil {
+		return err
+	}
+	catalog := m.Registry.Catalog(service.AppName)
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case "md":
+		return catalog.WriteMarkdown(w)
+	case "json":
+		return catalog.WriteJSON(w)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
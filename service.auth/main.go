@@ -2,27 +2,44 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"syscall"
 
+	commonconfig "github.com/SaimonWoidig/cc-microsvcs/common/config"
 	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/service"
 )
 
 func main() {
-	c := service.NewContainer()
+	c, err := service.NewContainer(context.Background())
+	if err != nil {
+		// the logger may not be initialized yet
+		fmt.Fprintf(os.Stderr, "failed to start container: %s\n", err.Error())
+		os.Exit(1)
+	}
 	c.Logger.Info("container initialized")
 
-	c.Logger.Debug("dumping config", "config", c.Config)
+	c.Logger.Debug("dumping config", "config", commonconfig.Redact(c.Config))
 
-	interruptCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer cancel()
-	<-interruptCtx.Done()
-	c.Logger.Info("got interrupt, shutting down")
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	c.Logger.Info("got signal, shutting down", "signal", sig.String(), "timeout", c.ShutdownTimeout().String())
 
-	defer func() {
-		if err := c.Shutdown(); err != nil {
-			c.Logger.Error("error while shutting down container", "error", err.Error())
-			panic(err.Error())
-		}
+	// a second signal skips the graceful shutdown
+	go func() {
+		sig := <-signals
+		c.Logger.Warn("got second signal, forcing exit", "signal", sig.String())
+		os.Exit(1)
 	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout())
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		c.Logger.Error("error while shutting down container", "error", err.Error())
+		cancel()
+		os.Exit(1)
+	}
+	c.Logger.Info("container shut down")
 }
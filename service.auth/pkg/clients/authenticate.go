@@ -0,0 +1,160 @@
+package clients
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// Credentials is a struct that represents the client authentication of a request.
+type Credentials struct {
+	// ID is the client_id. It may be omitted with an assertion, which names the client in its iss claim.
+	ID     string
+	Secret string
+	// Basic is set if the ID and secret were sent in the Authorization header.
+	Basic bool
+	// Assertion is the client_assertion of the private_key_jwt method, and AssertionType its client_assertion_type.
+	Assertion     string
+	AssertionType string
+}
+
+// Authenticator authenticates the clients of a Registry. It is safe for concurrent use.
+type Authenticator struct {
+	registry Registry
+	// audiences are the accepted aud claims of the client assertions.
+	audiences []string
+
+	mu sync.Mutex
+	// seen are the expiries of the used assertions by client ID and jti, so they can not be replayed.
+	seen map[string]time.Time
+}
+
+/*
+NewAuthenticator creates the Authenticator of the clients of registry.
+
+Parameters:
+  - registry: The registry the clients are looked up in.
+  - audiences: The accepted aud claims of the client assertions, the issuer and the URL of the token endpoint.
+
+Returns:
+  - *Authenticator: The created authenticator.
+*/
+func NewAuthenticator(registry Registry, audiences []string) *Authenticator {
+	return &Authenticator{registry: registry, audiences: audiences, seen: make(map[string]time.Time)}
+}
+
+/*
+Authenticate returns the client authenticated by creds. Clients with a secret may use the client_secret_basic
+or client_secret_post method, private_key_jwt clients have to send an assertion and public clients only their ID.
+
+Parameters:
+  - ctx: The context of the request.
+  - creds: The credentials of the request.
+
+Returns:
+  - *Client: The authenticated client.
+  - error: ErrInvalidClient if the client does not exist, is disabled or the credentials are wrong, or an error of the registry.
+*/
+func (a *Authenticator) Authenticate(ctx context.Context, creds Credentials) (*Client, error) {
+	if creds.Assertion != "" {
+		return a.authenticateAssertion(ctx, creds)
+	}
+	c, err := a.get(ctx, creds.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch c.AuthMethod {
+	case AuthMethodNone:
+		if creds.Secret != "" || creds.Basic {
+			return nil, ErrInvalidClient
+		}
+		return c, nil
+	case AuthMethodSecretBasic, AuthMethodSecretPost:
+		if !secretMatches(c, creds.Secret, time.Now()) {
+			return nil, ErrInvalidClient
+		}
+		return c, nil
+	default:
+		return nil, ErrInvalidClient
+	}
+}
+
+// authenticateAssertion authenticates a private_key_jwt client, see RFC 7523 section 2.2.
+func (a *Authenticator) authenticateAssertion(ctx context.Context, creds Credentials) (*Client, error) {
+	if creds.AssertionType != tokens.ClientAssertionType || creds.Secret != "" || creds.Basic {
+		return nil, ErrInvalidClient
+	}
+	id := creds.ID
+	if id == "" {
+		id = tokens.AssertionIssuer(creds.Assertion)
+	}
+	c, err := a.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.AuthMethod != AuthMethodPrivateKeyJWT || c.JWKS == nil {
+		return nil, ErrInvalidClient
+	}
+	claims, err := tokens.VerifyClientAssertion(creds.Assertion, c.ID, *c.JWKS, a.audiences)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+	if !a.markSeen(c.ID+"\x00"+claims.JWTID, time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+// get returns the enabled client with id, or ErrInvalidClient.
+func (a *Authenticator) get(ctx context.Context, id string) (*Client, error) {
+	if id == "" {
+		return nil, ErrInvalidClient
+	}
+	c, err := a.registry.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Disabled {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+// markSeen records the assertion with key until expiresAt and reports whether it was not seen before.
+func (a *Authenticator) markSeen(key string, expiresAt time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	for k, exp := range a.seen {
+		if now.After(exp) {
+			delete(a.seen, k)
+		}
+	}
+	if _, ok := a.seen[key]; ok {
+		return false
+	}
+	a.seen[key] = expiresAt
+	return true
+}
+
+// secretMatches reports whether secret is an active secret of c. All secrets are compared, so the timing does not tell which one matched.
+func secretMatches(c *Client, secret string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	hash := HashSecret(secret)
+	match := 0
+	for i := range c.Secrets {
+		if c.Secrets[i].active(now) {
+			match |= subtle.ConstantTimeCompare(hash, c.Secrets[i].Hash)
+		}
+	}
+	return match == 1
+}
@@ -0,0 +1,151 @@
+package clients
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// testAudience is the accepted audience of the client assertions of the tests.
+const testAudience string = "https://auth.example.com"
+
+// signAssertion returns a client assertion of clientID with jti, signed with key and the key ID kid.
+func signAssertion(t *testing.T, key ed25519.PrivateKey, kid, clientID, jti string) string {
+	t.Helper()
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": tokens.AlgEdDSA, "typ": "JWT", "kid": kid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(tokens.AssertionClaims{
+		Issuer:    clientID,
+		Subject:   clientID,
+		Audience:  tokens.Audience{testAudience},
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		IssuedAt:  now.Unix(),
+		JWTID:     jti,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return input + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(input)))
+}
+
+// testClients are the clients of newTestAuthenticator.
+type testClients struct {
+	svc *Service
+	// secret is the secret of gateway.
+	secret string
+	// key is the key of automation, a private_key_jwt client.
+	key ed25519.PrivateKey
+}
+
+// newTestAuthenticator returns an Authenticator of the clients gateway with a secret, automation with a key,
+// spa without credentials and disabled.
+func newTestAuthenticator(t *testing.T) (*Authenticator, *testClients) {
+	t.Helper()
+	ctx := context.Background()
+	tc := &testClients{svc: newTestService()}
+	var err error
+	if _, tc.secret, err = tc.svc.Create(ctx, CreateInput{ID: "gateway", GrantTypes: []string{GrantTypeClientCredentials}}); err != nil {
+		t.Fatal(err)
+	}
+	var jwks *tokens.JWKSet
+	tc.key, jwks = newTestKey(t, "k1")
+	for _, input := range []CreateInput{
+		{ID: "automation", AuthMethod: AuthMethodPrivateKeyJWT, JWKS: jwks},
+		{ID: "spa", AuthMethod: AuthMethodNone},
+		{ID: "disabled", Secret: testSecret, Disabled: true},
+	} {
+		if _, _, err := tc.svc.Create(ctx, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewAuthenticator(tc.svc.Registry(), []string{testAudience}), tc
+}
+
+func TestAuthenticateSecret(t *testing.T) {
+	a, tc := newTestAuthenticator(t)
+	ctx := context.Background()
+
+	for _, basic := range []bool{true, false} {
+		c, err := a.Authenticate(ctx, Credentials{ID: "gateway", Secret: tc.secret, Basic: basic})
+		if err != nil || c.ID != "gateway" {
+			t.Errorf("basic %t: got %v, %v", basic, c, err)
+		}
+	}
+	if c, err := a.Authenticate(ctx, Credentials{ID: "spa"}); err != nil || !c.Public() {
+		t.Errorf("public client: got %v, %v", c, err)
+	}
+
+	for name, creds := range map[string]Credentials{
+		"wrong secret":       {ID: "gateway", Secret: "wrong"},
+		"no secret":          {ID: "gateway"},
+		"unknown client":     {ID: "unknown", Secret: tc.secret},
+		"no client":          {Secret: tc.secret},
+		"disabled client":    {ID: "disabled", Secret: testSecret},
+		"secret of public":   {ID: "spa", Secret: "x"},
+		"basic of public":    {ID: "spa", Basic: true},
+		"secret of key auth": {ID: "automation", Secret: tc.secret},
+	} {
+		if _, err := a.Authenticate(ctx, creds); !errors.Is(err, ErrInvalidClient) {
+			t.Errorf("%s: got %v, want ErrInvalidClient", name, err)
+		}
+	}
+
+	// a rotated secret is accepted in its grace period
+	if _, _, err := tc.svc.RotateSecret(ctx, "gateway", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate(ctx, Credentials{ID: "gateway", Secret: tc.secret}); err != nil {
+		t.Errorf("rotated secret in its grace period: %v", err)
+	}
+}
+
+func TestAuthenticateAssertion(t *testing.T) {
+	a, tc := newTestAuthenticator(t)
+	ctx := context.Background()
+	assertion := func(clientID, jti string) Credentials {
+		return Credentials{Assertion: signAssertion(t, tc.key, "k1", clientID, jti), AssertionType: tokens.ClientAssertionType}
+	}
+
+	creds := assertion("automation", "1")
+	c, err := a.Authenticate(ctx, creds)
+	if err != nil || c.ID != "automation" {
+		t.Fatalf("got %v, %v", c, err)
+	}
+	if _, err := a.Authenticate(ctx, creds); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("replayed assertion: got %v, want ErrInvalidClient", err)
+	}
+	// the client is named by the client_id or the iss claim
+	creds = assertion("automation", "2")
+	creds.ID = "automation"
+	if _, err := a.Authenticate(ctx, creds); err != nil {
+		t.Errorf("assertion with client_id: %v", err)
+	}
+
+	wrongType := assertion("automation", "3")
+	wrongType.AssertionType = "urn:example"
+	withSecret := assertion("automation", "4")
+	withSecret.Secret = tc.secret
+	otherID := assertion("automation", "5")
+	otherID.ID = "gateway"
+	for name, creds := range map[string]Credentials{
+		"wrong type":       wrongType,
+		"with a secret":    withSecret,
+		"other client_id":  otherID,
+		"secret client":    assertion("gateway", "6"),
+		"unregistered key": {Assertion: signAssertion(t, ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)), "k1", "automation", "7"), AssertionType: tokens.ClientAssertionType},
+	} {
+		if _, err := a.Authenticate(ctx, creds); !errors.Is(err, ErrInvalidClient) {
+			t.Errorf("%s: got %v, want ErrInvalidClient", name, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Package clients contains the OAuth2 clients of the auth service: the Client model, the Repository they are stored in,
+the Service implementing the client management and secret rotation, and the Authenticator of the clients
+at the endpoints of the authorization server, see RFC 6749 section 2.3 and RFC 7523.
+*/
+package clients
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+var (
+	// ErrNotFound is returned if a client does not exist.
+	ErrNotFound = errors.New("client not found")
+	// ErrAlreadyExists is returned if a client with the same ID already exists.
+	ErrAlreadyExists = errors.New("client already exists")
+	// ErrInvalidClient is returned if the authentication of a client failed, see RFC 6749 section 5.2.
+	ErrInvalidClient = errors.New("invalid client")
+)
+
+// The names of the Repository implementations.
+const (
+	StoreMemory string = "memory"
+	StoreSQLite string = "sqlite"
+)
+
+// The client authentication methods at the token endpoint, see RFC 7591 section 2.
+const (
+	AuthMethodSecretBasic   string = "client_secret_basic"
+	AuthMethodSecretPost    string = "client_secret_post"
+	AuthMethodPrivateKeyJWT string = "private_key_jwt"
+	// AuthMethodNone is the method of public clients, e.g. browser applications, which can not keep a secret.
+	AuthMethodNone string = "none"
+)
+
+// The grants of the token endpoint.
+const (
+	// GrantTypeClientCredentials is the grant of the machine clients authenticating as themselves, see RFC 6749 section 4.4.
+	GrantTypeClientCredentials string = "client_credentials"
+	// GrantTypeAuthorizationCode is the grant of the clients the users log in to with OpenID Connect, see RFC 6749 section 4.1.
+	GrantTypeAuthorizationCode string = "authorization_code"
+	// GrantTypeRefreshToken is the grant of the clients getting refresh tokens with the authorization code, see RFC 6749 section 6.
+	GrantTypeRefreshToken string = "refresh_token"
+)
+
+// Secret is a struct that represents a client secret. A client has several secrets while a rotated secret is in its grace period.
+type Secret struct {
+	ID string `json:"id"`
+	// Hash is the SHA-256 hash of the secret. It is never serialized.
+	Hash      []byte    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	// ExpiresAt is the end of the grace period of a rotated secret. Nil for the current secret.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// active reports whether the secret can be used at t.
+func (s *Secret) active(at time.Time) bool {
+	return s.ExpiresAt == nil || at.Before(*s.ExpiresAt)
+}
+
+// Client is a struct that represents an OAuth2 client.
+type Client struct {
+	// ID is the unique client_id.
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// AuthMethod is the authentication method of the client, one of the AuthMethod constants.
+	AuthMethod string `json:"tokenEndpointAuthMethod"`
+	// Secrets are the secrets of the client_secret_basic and client_secret_post methods.
+	Secrets []Secret `json:"secrets,omitempty"`
+	// JWKS are the public keys of the private_key_jwt method.
+	JWKS *tokens.JWKSet `json:"jwks,omitempty"`
+	// GrantTypes are the grants the client may use.
+	GrantTypes []string `json:"grantTypes"`
+	// Scopes are the scopes the client may request.
+	Scopes []string `json:"scopes"`
+	// Audiences are the audiences the client may request tokens for.
+	Audiences []string `json:"audiences"`
+	// RedirectURIs are the exact redirect URIs of the authorization code grant.
+	RedirectURIs []string `json:"redirectURIs,omitempty"`
+	// PostLogoutRedirectURIs are the exact URIs the users may be redirected to after logging out.
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectURIs,omitempty"`
+	// SkipConsent is set for first-party clients the users do not have to consent to.
+	SkipConsent bool `json:"skipConsent"`
+	// Disabled clients can not authenticate.
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Public reports whether the client can not authenticate, e.g. a browser application.
+func (c *Client) Public() bool {
+	return c.AuthMethod == AuthMethodNone
+}
+
+// HasGrantType reports whether the client may use grantType.
+func (c *Client) HasGrantType(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// HashSecret returns the hash of a client secret. The secrets are generated with 32 random bytes,
+// and chosen secrets need at least 32 bytes, so they have enough entropy to not need a slow, salted hash.
+func HashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// ListOptions is a struct that represents the page of clients returned by Repository.List.
+type ListOptions struct {
+	Offset int
+	// Limit is the maximum number of returned clients. If zero, all clients are returned.
+	Limit int
+}
+
+// Registry is an interface that represents the lookup of the clients.
+type Registry interface {
+	// Get returns the client with id, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Client, error)
+}
+
+// Repository is an interface that represents the storage of the clients.
+// Implementations return ErrNotFound for missing clients and ErrAlreadyExists for duplicate IDs.
+type Repository interface {
+	Registry
+	// Create stores a new client.
+	Create(ctx context.Context, client *Client) error
+	// List returns a page of the clients ordered by ID.
+	List(ctx context.Context, opts ListOptions) ([]*Client, error)
+	// Update replaces the stored client with the same ID.
+	Update(ctx context.Context, client *Client) error
+	// Delete removes the client with id.
+	Delete(ctx context.Context, id string) error
+}
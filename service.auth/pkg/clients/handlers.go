@@ -0,0 +1,177 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminPath is the path of the client management API relative to the versioned API group.
+const AdminPath string = "/admin/clients"
+
+// maxListLimit is the maximum page size of the list endpoint.
+const maxListLimit int = 1000
+
+// SecretResponse is a struct that represents a client with its new secret, which is only returned once.
+type SecretResponse struct {
+	*Client
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+// RotateSecretInput is a struct that represents the grace period of a secret rotation.
+type RotateSecretInput struct {
+	// GraceSeconds is the time the previous secrets stay valid. If nil, the default grace period is used.
+	GraceSeconds *int `json:"graceSeconds"`
+}
+
+// handlers serves the client management API.
+type handlers struct {
+	svc *Service
+}
+
+/*
+RegisterAdminRoutes registers the client management API of svc on g. The group has to be restricted to administrators.
+
+The following endpoints are registered:
+  - GET /: Lists the clients, paginated with the offset and limit query parameters.
+  - POST /: Creates a client from a CreateInput and returns a SecretResponse.
+  - GET /:id: Returns a client.
+  - PATCH /:id: Updates a client with an UpdateInput.
+  - DELETE /:id: Deletes a client.
+  - POST /:id/secrets: Rotates the secret with a RotateSecretInput and returns a SecretResponse.
+  - DELETE /:id/secrets/:secretID: Revokes a secret, e.g. a leaked secret in its grace period.
+
+Example usage:
+
+	clients.RegisterAdminRoutes(api.Group(clients.AdminPath, adminAuth), svc)
+*/
+func RegisterAdminRoutes(g *echo.Group, svc *Service) {
+	h := &handlers{svc: svc}
+	g.GET("", h.list)
+	g.POST("", h.create)
+	g.GET("/:id", h.get)
+	g.PATCH("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+	g.POST("/:id/secrets", h.rotateSecret)
+	g.DELETE("/:id/secrets/:secretID", h.revokeSecret)
+}
+
+func (h *handlers) list(c echo.Context) error {
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		return err
+	}
+	limit, err := queryInt(c, "limit", 100)
+	if err != nil {
+		return err
+	}
+	clients, err := h.svc.List(c.Request().Context(), ListOptions{Offset: offset, Limit: min(limit, maxListLimit)})
+	if err != nil {
+		return httpError(err)
+	}
+	if clients == nil {
+		clients = []*Client{}
+	}
+	return c.JSON(http.StatusOK, clients)
+}
+
+func (h *handlers) create(c echo.Context) error {
+	var input CreateInput
+	if err := c.Bind(&input); err != nil {
+		return err
+	}
+	client, secret, err := h.svc.Create(c.Request().Context(), input)
+	if err != nil {
+		return httpError(err)
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusCreated, SecretResponse{Client: client, ClientSecret: secret})
+}
+
+func (h *handlers) get(c echo.Context) error {
+	client, err := h.svc.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return httpError(err)
+	}
+	return c.JSON(http.StatusOK, client)
+}
+
+func (h *handlers) update(c echo.Context) error {
+	var input UpdateInput
+	if err := c.Bind(&input); err != nil {
+		return err
+	}
+	client, err := h.svc.Update(c.Request().Context(), c.Param("id"), input)
+	if err != nil {
+		return httpError(err)
+	}
+	return c.JSON(http.StatusOK, client)
+}
+
+func (h *handlers) delete(c echo.Context) error {
+	if err := h.svc.Delete(c.Request().Context(), c.Param("id")); err != nil {
+		return httpError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *handlers) rotateSecret(c echo.Context) error {
+	var input RotateSecretInput
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&input); err != nil {
+			return err
+		}
+	}
+	grace := time.Duration(-1)
+	if input.GraceSeconds != nil {
+		if *input.GraceSeconds < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid graceSeconds")
+		}
+		grace = time.Duration(*input.GraceSeconds) * time.Second
+	}
+	client, secret, err := h.svc.RotateSecret(c.Request().Context(), c.Param("id"), grace)
+	if err != nil {
+		return httpError(err)
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusOK, SecretResponse{Client: client, ClientSecret: secret})
+}
+
+func (h *handlers) revokeSecret(c echo.Context) error {
+	client, err := h.svc.RevokeSecret(c.Request().Context(), c.Param("id"), c.Param("secretID"))
+	if err != nil {
+		return httpError(err)
+	}
+	return c.JSON(http.StatusOK, client)
+}
+
+// queryInt returns the non-negative integer query parameter name, or def if it is not set.
+func queryInt(c echo.Context, name string, def int) (int, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid "+name)
+	}
+	return n, nil
+}
+
+// httpError maps the errors of the Service to HTTP errors. Other errors are returned unchanged and result in a 500.
+func httpError(err error) error {
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return echo.NewHTTPError(http.StatusBadRequest, validationErr.Error())
+	case errors.Is(err, ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrAlreadyExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return err
+	}
+}
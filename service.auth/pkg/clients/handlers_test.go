@@ -0,0 +1,78 @@
+package clients
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestAPI returns an Echo server with the client management API of svc under AdminPath.
+func newTestAPI(svc *Service) *echo.Echo {
+	e := echo.New()
+	RegisterAdminRoutes(e.Group(AdminPath), svc)
+	return e
+}
+
+// do sends a request with the JSON body to e and returns the recorded response.
+func do(e *echo.Echo, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminRoutes(t *testing.T) {
+	e := newTestAPI(newTestService())
+
+	rec := do(e, http.MethodPost, AdminPath, `{"id":"gateway","grantTypes":["client_credentials"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get(echo.HeaderCacheControl) != "no-store" {
+		t.Error("created secret cacheable")
+	}
+	var created SecretResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ClientSecret == "" || strings.Contains(rec.Body.String(), "hash") {
+		t.Errorf("unexpected create response %s", rec.Body)
+	}
+
+	for _, tc := range []struct {
+		name, method, target, body string
+		want                       int
+	}{
+		{"duplicate", http.MethodPost, AdminPath, `{"id":"gateway"}`, http.StatusConflict},
+		{"short secret", http.MethodPost, AdminPath, `{"id":"other","secret":"short"}`, http.StatusBadRequest},
+		{"get", http.MethodGet, AdminPath + "/gateway", "", http.StatusOK},
+		{"get missing", http.MethodGet, AdminPath + "/missing", "", http.StatusNotFound},
+		{"list", http.MethodGet, AdminPath + "?offset=0&limit=10", "", http.StatusOK},
+		{"invalid offset", http.MethodGet, AdminPath + "?offset=x", "", http.StatusBadRequest},
+		{"update", http.MethodPatch, AdminPath + "/gateway", `{"scopes":["orders:read"]}`, http.StatusOK},
+		{"invalid update", http.MethodPatch, AdminPath + "/gateway", `{"grantTypes":["password"]}`, http.StatusBadRequest},
+		{"rotate", http.MethodPost, AdminPath + "/gateway/secrets", `{"graceSeconds":60}`, http.StatusOK},
+		{"rotate with default grace", http.MethodPost, AdminPath + "/gateway/secrets", "", http.StatusOK},
+		{"negative grace", http.MethodPost, AdminPath + "/gateway/secrets", `{"graceSeconds":-1}`, http.StatusBadRequest},
+		{"revoke missing secret", http.MethodDelete, AdminPath + "/gateway/secrets/missing", "", http.StatusNotFound},
+		{"revoke secret", http.MethodDelete, AdminPath + "/gateway/secrets/" + created.Client.Secrets[0].ID, "", http.StatusOK},
+		{"delete", http.MethodDelete, AdminPath + "/gateway", "", http.StatusNoContent},
+		{"delete again", http.MethodDelete, AdminPath + "/gateway", "", http.StatusNotFound},
+	} {
+		if rec := do(e, tc.method, tc.target, tc.body); rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d: %s", tc.name, rec.Code, tc.want, rec.Body)
+		}
+	}
+
+	rec = do(e, http.MethodGet, AdminPath, "")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("list of no clients: got %d %s, want an empty array", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,113 @@
+package clients
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// MemoryRepository is an implementation of Repository keeping the clients in memory.
+// The clients managed with the admin API are lost on a restart, only the clients of the config are registered again.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+// interface guard
+var _ Repository = (*MemoryRepository)(nil)
+
+// NewMemoryRepository returns an empty MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{clients: make(map[string]*Client)}
+}
+
+// Create implements Repository.
+func (r *MemoryRepository) Create(_ context.Context, client *Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[client.ID]; ok {
+		return ErrAlreadyExists
+	}
+	r.clients[client.ID] = clone(client)
+	return nil
+}
+
+// Get implements Repository.
+func (r *MemoryRepository) Get(_ context.Context, id string) (*Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.clients[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(c), nil
+}
+
+// List implements Repository.
+func (r *MemoryRepository) List(_ context.Context, opts ListOptions) ([]*Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.clients))
+	for id := range r.clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	ids = page(ids, opts)
+	clients := make([]*Client, 0, len(ids))
+	for _, id := range ids {
+		clients = append(clients, clone(r.clients[id]))
+	}
+	return clients, nil
+}
+
+// Update implements Repository.
+func (r *MemoryRepository) Update(_ context.Context, client *Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[client.ID]; !ok {
+		return ErrNotFound
+	}
+	r.clients[client.ID] = clone(client)
+	return nil
+}
+
+// Delete implements Repository.
+func (r *MemoryRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+// page returns the items of s selected by opts.
+func page[T any](s []T, opts ListOptions) []T {
+	if opts.Offset >= len(s) {
+		return nil
+	}
+	s = s[max(opts.Offset, 0):]
+	if opts.Limit > 0 && opts.Limit < len(s) {
+		s = s[:opts.Limit]
+	}
+	return s
+}
+
+// clone returns a copy of c, so the stored clients can not be modified by the callers.
+func clone(c *Client) *Client {
+	cc := *c
+	cc.Secrets = slices.Clone(c.Secrets)
+	cc.GrantTypes = slices.Clone(c.GrantTypes)
+	cc.Scopes = slices.Clone(c.Scopes)
+	cc.Audiences = slices.Clone(c.Audiences)
+	cc.RedirectURIs = slices.Clone(c.RedirectURIs)
+	cc.PostLogoutRedirectURIs = slices.Clone(c.PostLogoutRedirectURIs)
+	if c.JWKS != nil {
+		cc.JWKS = &tokens.JWKSet{Keys: slices.Clone(c.JWKS.Keys)}
+	}
+	return &cc
+}
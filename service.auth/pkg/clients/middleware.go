@@ -0,0 +1,77 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+// contextKey is the key of the authenticated client in the echo.Context.
+const contextKey string = "oauth2.client"
+
+// errorResponse is the error response of a failed client authentication, see RFC 6749 section 5.2.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+/*
+Middleware returns the middleware authenticating the clients with a, see RFC 6749 section 2.3.
+The client_secret_basic, client_secret_post and private_key_jwt methods are accepted, public clients send their client_id in the form body.
+The authenticated client is returned by FromContext. Failed authentications are answered with 401 and the invalid_client error.
+
+Example usage:
+
+	g.POST("/introspect", introspect, clients.Middleware(authenticator))
+*/
+func Middleware(a *Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var creds Credentials
+			var basic bool
+			creds.ID, creds.Secret, basic = c.Request().BasicAuth()
+			if basic {
+				// the credentials are form-urlencoded before they are base64 encoded, see RFC 6749 section 2.3.1
+				var err error
+				if creds.ID, err = url.QueryUnescape(creds.ID); err != nil {
+					return unauthorized(c, basic)
+				}
+				if creds.Secret, err = url.QueryUnescape(creds.Secret); err != nil {
+					return unauthorized(c, basic)
+				}
+				creds.Basic = true
+			} else {
+				creds.ID, creds.Secret = c.FormValue("client_id"), c.FormValue("client_secret")
+			}
+			creds.Assertion, creds.AssertionType = c.FormValue("client_assertion"), c.FormValue("client_assertion_type")
+			client, err := a.Authenticate(c.Request().Context(), creds)
+			if errors.Is(err, ErrInvalidClient) {
+				return unauthorized(c, basic)
+			}
+			if err != nil {
+				return err
+			}
+			// a client_id in the body has to match the authenticated client, see RFC 7523 section 3
+			if id := c.FormValue("client_id"); id != "" && id != client.ID {
+				return unauthorized(c, basic)
+			}
+			c.Set(contextKey, client)
+			return next(c)
+		}
+	}
+}
+
+// FromContext returns the client authenticated by Middleware, or nil.
+func FromContext(c echo.Context) *Client {
+	client, _ := c.Get(contextKey).(*Client)
+	return client
+}
+
+// unauthorized writes the invalid_client error, with a Basic challenge if the client used the Authorization header.
+func unauthorized(c echo.Context, basic bool) error {
+	if basic {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="auth-service"`)
+	}
+	return c.JSON(http.StatusUnauthorized, errorResponse{Error: "invalid_client"})
+}
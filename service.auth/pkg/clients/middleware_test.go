@@ -0,0 +1,75 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// authenticated sends form to an endpoint behind the Middleware of a, with the Basic credentials if id is not empty.
+// It returns the recorded response, whose body is the ID of the authenticated client.
+func authenticated(a *Authenticator, id, secret string, form url.Values) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.POST("/token", func(c echo.Context) error {
+		return c.String(http.StatusOK, FromContext(c).ID)
+	}, Middleware(a))
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	if id != "" {
+		req.SetBasicAuth(url.QueryEscape(id), url.QueryEscape(secret))
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	a, tc := newTestAuthenticator(t)
+
+	for _, test := range []struct {
+		name       string
+		id, secret string
+		form       url.Values
+		want       string
+	}{
+		{"basic", "gateway", tc.secret, url.Values{}, "gateway"},
+		{"basic with client_id", "gateway", tc.secret, url.Values{"client_id": {"gateway"}}, "gateway"},
+		{"post", "", "", url.Values{"client_id": {"gateway"}, "client_secret": {tc.secret}}, "gateway"},
+		{"public", "", "", url.Values{"client_id": {"spa"}}, "spa"},
+		{"assertion", "", "", url.Values{
+			"client_assertion":      {signAssertion(t, tc.key, "k1", "automation", "1")},
+			"client_assertion_type": {tokens.ClientAssertionType},
+		}, "automation"},
+	} {
+		rec := authenticated(a, test.id, test.secret, test.form)
+		if rec.Code != http.StatusOK || rec.Body.String() != test.want {
+			t.Errorf("%s: got %d %s, want %s", test.name, rec.Code, rec.Body, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		name       string
+		id, secret string
+		form       url.Values
+		challenge  bool
+	}{
+		{"wrong basic secret", "gateway", "wrong", url.Values{}, true},
+		{"other client_id", "gateway", tc.secret, url.Values{"client_id": {"spa"}}, true},
+		{"wrong post secret", "", "", url.Values{"client_id": {"gateway"}, "client_secret": {"wrong"}}, false},
+		{"no credentials", "", "", url.Values{}, false},
+	} {
+		rec := authenticated(a, test.id, test.secret, test.form)
+		if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "invalid_client") {
+			t.Errorf("%s: got %d %s, want 401 invalid_client", test.name, rec.Code, rec.Body)
+		}
+		if challenge := rec.Header().Get(echo.HeaderWWWAuthenticate) != ""; challenge != test.challenge {
+			t.Errorf("%s: Basic challenge %t, want %t", test.name, challenge, test.challenge)
+		}
+	}
+}
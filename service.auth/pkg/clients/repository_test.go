@@ -0,0 +1,134 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRepository checks the contract of Repository on the empty repo.
+func testRepository(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	expiresAt := now.Add(time.Hour)
+	newClient := func(id string) *Client {
+		return &Client{
+			ID:           id,
+			AuthMethod:   AuthMethodSecretBasic,
+			Secrets:      []Secret{{ID: "s1", Hash: HashSecret("old"), CreatedAt: now, ExpiresAt: &expiresAt}, {ID: "s2", Hash: HashSecret("new"), CreatedAt: now}},
+			GrantTypes:   []string{GrantTypeClientCredentials},
+			Scopes:       []string{"orders:read"},
+			Audiences:    []string{"orders"},
+			RedirectURIs: []string{"https://" + id + ".example.com/callback"},
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+	}
+
+	for _, id := range []string{"carol", "alice", "bob"} {
+		if err := repo.Create(ctx, newClient(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Create(ctx, newClient("alice")); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Create duplicate id: got %v, want ErrAlreadyExists", err)
+	}
+
+	c, err := repo.Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AuthMethod != AuthMethodSecretBasic || !c.CreatedAt.Equal(now) || len(c.Scopes) != 1 || c.RedirectURIs[0] != "https://alice.example.com/callback" || c.JWKS != nil {
+		t.Errorf("Get returned %+v", c)
+	}
+	if len(c.Secrets) != 2 || c.Secrets[0].ExpiresAt == nil || !c.Secrets[0].ExpiresAt.Equal(expiresAt) || c.Secrets[1].ExpiresAt != nil {
+		t.Errorf("Get returned secrets %+v", c.Secrets)
+	}
+	if !secretMatches(c, "old", now) || !secretMatches(c, "new", now) {
+		t.Error("secret hashes not stored")
+	}
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing: got %v, want ErrNotFound", err)
+	}
+
+	list, err := repo.List(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(list); got != "alice,bob,carol" {
+		t.Errorf("List = %s, want alice,bob,carol", got)
+	}
+	list, err = repo.List(ctx, ListOptions{Offset: 1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(list); got != "bob" {
+		t.Errorf("List page = %s, want bob", got)
+	}
+
+	_, c.JWKS = newTestKey(t, "k1")
+	c.AuthMethod = AuthMethodPrivateKeyJWT
+	c.Secrets = nil
+	c.Disabled = true
+	if err := repo.Update(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AuthMethod != AuthMethodPrivateKeyJWT || !got.Disabled || len(got.Secrets) != 0 || got.JWKS == nil || got.JWKS.Keys[0] != c.JWKS.Keys[0] {
+		t.Errorf("after Update: %+v", got)
+	}
+	if err := repo.Update(ctx, newClient("missing")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update missing: got %v, want ErrNotFound", err)
+	}
+
+	if err := repo.Delete(ctx, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, "carol"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete twice: got %v, want ErrNotFound", err)
+	}
+	if err := repo.Create(ctx, newClient("carol")); err != nil {
+		t.Errorf("id of a deleted client not reusable: %v", err)
+	}
+}
+
+// ids returns the IDs of clients as a comma separated list.
+func ids(clients []*Client) string {
+	ids := make([]string, len(clients))
+	for i, c := range clients {
+		ids[i] = c.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, NewMemoryRepository())
+}
+
+func TestMemoryRepositoryReturnsCopies(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	c := &Client{ID: "gateway", Scopes: []string{"orders:read"}, Secrets: []Secret{{ID: "s1"}}}
+	if err := repo.Create(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	c.Scopes[0] = "orders:write"
+	got, err := repo.Get(ctx, "gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Scopes[0] = "orders:write"
+	got.Secrets[0].ID = "s2"
+	again, err := repo.Get(ctx, "gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Scopes[0] != "orders:read" || again.Secrets[0].ID != "s1" {
+		t.Errorf("stored client modified through a caller: %+v", again)
+	}
+}
@@ -0,0 +1,433 @@
+package clients
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// secretBytes is the length of the generated client secrets.
+const secretBytes = 32
+
+// minSecretLength is the minimum length of the secrets chosen by the callers, so they are as hard to guess as the generated ones.
+const minSecretLength = 32
+
+// clientIDPattern are the allowed client IDs, which are used in URLs and the Basic credentials.
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{1,128}$`)
+
+// supportedGrantTypes are the grants clients can be registered for.
+var supportedGrantTypes = []string{GrantTypeClientCredentials, GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+
+// ValidationError is returned if the fields of a created or updated client are invalid.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// CreateInput is a struct that represents the fields of a new client.
+type CreateInput struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// AuthMethod is the authentication method. If empty, client_secret_basic is used.
+	AuthMethod string         `json:"tokenEndpointAuthMethod"`
+	JWKS       *tokens.JWKSet `json:"jwks"`
+	GrantTypes []string       `json:"grantTypes"`
+	Scopes     []string       `json:"scopes"`
+	Audiences  []string       `json:"audiences"`
+	// RedirectURIs are required for the authorization_code grant.
+	RedirectURIs           []string `json:"redirectURIs"`
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectURIs"`
+	SkipConsent            bool     `json:"skipConsent"`
+	Disabled               bool     `json:"disabled"`
+	// Secret is the initial secret of a client authenticated with a secret, at least 32 bytes of random characters.
+	// If empty, a secret is generated.
+	Secret string `json:"secret"`
+}
+
+// UpdateInput is a struct that represents the changed fields of a client. Nil fields are left unchanged.
+// The secrets are changed by RotateSecret and RevokeSecret.
+type UpdateInput struct {
+	Name       *string        `json:"name"`
+	JWKS       *tokens.JWKSet `json:"jwks"`
+	GrantTypes *[]string      `json:"grantTypes"`
+	Scopes     *[]string      `json:"scopes"`
+	Audiences  *[]string      `json:"audiences"`
+	// RedirectURIs and PostLogoutRedirectURIs replace the registered URIs.
+	RedirectURIs           *[]string `json:"redirectURIs"`
+	PostLogoutRedirectURIs *[]string `json:"postLogoutRedirectURIs"`
+	SkipConsent            *bool     `json:"skipConsent"`
+	Disabled               *bool     `json:"disabled"`
+}
+
+// Service manages the clients and their secrets.
+type Service struct {
+	repo Repository
+	// defaultGrace is the grace period of rotated secrets if none is given.
+	defaultGrace time.Duration
+	logger       *slog.Logger
+}
+
+/*
+NewService creates the Service of the clients stored in repo.
+
+Parameters:
+  - repo: The repository the clients are stored in.
+  - defaultGrace: The time a rotated secret stays valid if RotateSecret is called without a grace period.
+  - logger: The logger of the secret rotations.
+
+Returns:
+  - *Service: The created service.
+
+Example usage:
+
+	svc := clients.NewService(clients.NewMemoryRepository(), 24*time.Hour, logger)
+*/
+func NewService(repo Repository, defaultGrace time.Duration, logger *slog.Logger) *Service {
+	return &Service{repo: repo, defaultGrace: defaultGrace, logger: logger}
+}
+
+// Registry returns the registry the clients are authenticated with.
+func (s *Service) Registry() Registry {
+	return s.repo
+}
+
+/*
+Create validates input and stores the new client.
+
+Parameters:
+  - ctx: The context of the request.
+  - input: The fields of the client.
+
+Returns:
+  - *Client: The created client.
+  - string: The secret of a client authenticated with a secret, which is returned only once. Empty for the other clients.
+  - error: A ValidationError, ErrAlreadyExists or an error of the repository.
+*/
+func (s *Service) Create(ctx context.Context, input CreateInput) (*Client, string, error) {
+	if !clientIDPattern.MatchString(input.ID) {
+		return nil, "", &ValidationError{Field: "id", Reason: "must be 1 to 128 characters of letters, digits and ._~-"}
+	}
+	if input.AuthMethod == "" {
+		input.AuthMethod = AuthMethodSecretBasic
+	}
+	now := time.Now().UTC()
+	c := &Client{
+		ID:          input.ID,
+		Name:        strings.TrimSpace(input.Name),
+		AuthMethod:  input.AuthMethod,
+		JWKS:        input.JWKS,
+		SkipConsent: input.SkipConsent,
+		Disabled:    input.Disabled,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := setLists(c, input.GrantTypes, input.Scopes, input.Audiences); err != nil {
+		return nil, "", err
+	}
+	if err := setRedirectURIs(c, input.RedirectURIs, input.PostLogoutRedirectURIs); err != nil {
+		return nil, "", err
+	}
+	if err := validate(c); err != nil {
+		return nil, "", err
+	}
+	var secret string
+	if usesSecret(c.AuthMethod) {
+		var err error
+		if secret, err = addSecret(c, input.Secret, now); err != nil {
+			return nil, "", err
+		}
+	} else if input.Secret != "" {
+		return nil, "", &ValidationError{Field: "secret", Reason: "only clients authenticated with a secret have one"}
+	}
+	if err := s.repo.Create(ctx, c); err != nil {
+		return nil, "", err
+	}
+	return c, secret, nil
+}
+
+// Get returns the client with id.
+func (s *Service) Get(ctx context.Context, id string) (*Client, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// List returns a page of the clients ordered by ID.
+func (s *Service) List(ctx context.Context, opts ListOptions) ([]*Client, error) {
+	return s.repo.List(ctx, opts)
+}
+
+// Update applies the set fields of input to the client with id.
+func (s *Service) Update(ctx context.Context, id string, input UpdateInput) (*Client, error) {
+	c, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		c.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.JWKS != nil {
+		c.JWKS = input.JWKS
+	}
+	grantTypes, scopes, audiences := c.GrantTypes, c.Scopes, c.Audiences
+	if input.GrantTypes != nil {
+		grantTypes = *input.GrantTypes
+	}
+	if input.Scopes != nil {
+		scopes = *input.Scopes
+	}
+	if input.Audiences != nil {
+		audiences = *input.Audiences
+	}
+	if err := setLists(c, grantTypes, scopes, audiences); err != nil {
+		return nil, err
+	}
+	redirectURIs, postLogoutRedirectURIs := c.RedirectURIs, c.PostLogoutRedirectURIs
+	if input.RedirectURIs != nil {
+		redirectURIs = *input.RedirectURIs
+	}
+	if input.PostLogoutRedirectURIs != nil {
+		postLogoutRedirectURIs = *input.PostLogoutRedirectURIs
+	}
+	if err := setRedirectURIs(c, redirectURIs, postLogoutRedirectURIs); err != nil {
+		return nil, err
+	}
+	if input.SkipConsent != nil {
+		c.SkipConsent = *input.SkipConsent
+	}
+	if input.Disabled != nil {
+		c.Disabled = *input.Disabled
+	}
+	if err := validate(c); err != nil {
+		return nil, err
+	}
+	c.UpdatedAt = time.Now().UTC()
+	if err := s.repo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Delete removes the client with id.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	return s.repo.Delete(ctx, id)
+}
+
+/*
+RotateSecret generates a new secret of the client with id. The previous secrets stay valid for the grace period,
+so the deployments of the client can be updated without downtime. Secrets whose grace period ended are removed.
+
+Parameters:
+  - ctx: The context of the request.
+  - id: The ID of the client.
+  - grace: The time the previous secrets stay valid. If negative, the default grace period is used. If zero, they are invalid immediately.
+
+Returns:
+  - *Client: The updated client.
+  - string: The new secret, which is returned only once.
+  - error: ErrNotFound, a ValidationError if the client is not authenticated with a secret, or an error of the repository.
+*/
+func (s *Service) RotateSecret(ctx context.Context, id string, grace time.Duration) (*Client, string, error) {
+	c, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if !usesSecret(c.AuthMethod) {
+		return nil, "", &ValidationError{Field: "tokenEndpointAuthMethod", Reason: "the client is not authenticated with a secret"}
+	}
+	if grace < 0 {
+		grace = s.defaultGrace
+	}
+	now := time.Now().UTC()
+	expiresAt := now.Add(grace)
+	secrets := c.Secrets[:0]
+	for _, secret := range c.Secrets {
+		if !secret.active(now) {
+			continue
+		}
+		if secret.ExpiresAt == nil || secret.ExpiresAt.After(expiresAt) {
+			secret.ExpiresAt = &expiresAt
+		}
+		secrets = append(secrets, secret)
+	}
+	c.Secrets = secrets
+	secret, err := addSecret(c, "", now)
+	if err != nil {
+		return nil, "", err
+	}
+	c.UpdatedAt = now
+	if err := s.repo.Update(ctx, c); err != nil {
+		return nil, "", err
+	}
+	s.logger.Info("client secret rotated", "client_id", c.ID, "grace", grace.String())
+	return c, secret, nil
+}
+
+// RevokeSecret removes the secret with secretID of the client with id, e.g. a leaked secret in its grace period.
+func (s *Service) RevokeSecret(ctx context.Context, id, secretID string) (*Client, error) {
+	c, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(c.Secrets, func(secret Secret) bool { return secret.ID == secretID })
+	if i < 0 {
+		return nil, fmt.Errorf("secret %q: %w", secretID, ErrNotFound)
+	}
+	c.Secrets = slices.Delete(c.Secrets, i, i+1)
+	c.UpdatedAt = time.Now().UTC()
+	if err := s.repo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	s.logger.Info("client secret revoked", "client_id", c.ID, "secret_id", secretID)
+	return c, nil
+}
+
+// setLists sets the normalized grant types, scopes and audiences of c.
+func setLists(c *Client, grantTypes, scopes, audiences []string) error {
+	var err error
+	if c.GrantTypes, err = normalize("grantTypes", grantTypes); err != nil {
+		return err
+	}
+	if c.Scopes, err = normalize("scopes", scopes); err != nil {
+		return err
+	}
+	if c.Audiences, err = normalize("audiences", audiences); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validate returns a ValidationError if the authentication method, keys or grant types of c are invalid.
+func validate(c *Client) error {
+	switch c.AuthMethod {
+	case AuthMethodSecretBasic, AuthMethodSecretPost, AuthMethodNone:
+		if c.JWKS != nil {
+			return &ValidationError{Field: "jwks", Reason: "only private_key_jwt clients have keys"}
+		}
+	case AuthMethodPrivateKeyJWT:
+		if c.JWKS == nil || len(c.JWKS.Keys) == 0 {
+			return &ValidationError{Field: "jwks", Reason: "private_key_jwt clients need at least one key"}
+		}
+		for _, jwk := range c.JWKS.Keys {
+			if _, err := jwk.PublicKey(); err != nil {
+				return &ValidationError{Field: "jwks", Reason: err.Error()}
+			}
+			if jwk.Kid == "" && len(c.JWKS.Keys) > 1 {
+				return &ValidationError{Field: "jwks", Reason: "keys need a kid if there are several"}
+			}
+		}
+	default:
+		return &ValidationError{Field: "tokenEndpointAuthMethod", Reason: fmt.Sprintf("unsupported method %q", c.AuthMethod)}
+	}
+	for _, grantType := range c.GrantTypes {
+		if !slices.Contains(supportedGrantTypes, grantType) {
+			return &ValidationError{Field: "grantTypes", Reason: fmt.Sprintf("unsupported grant type %q", grantType)}
+		}
+	}
+	// public clients can not prove their identity, so they can not get tokens for themselves, see RFC 6749 section 4.4
+	if c.Public() && c.HasGrantType(GrantTypeClientCredentials) {
+		return &ValidationError{Field: "grantTypes", Reason: "public clients can not use client_credentials"}
+	}
+	if c.HasGrantType(GrantTypeAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		return &ValidationError{Field: "redirectURIs", Reason: "the authorization_code grant needs at least one redirect URI"}
+	}
+	return nil
+}
+
+// setRedirectURIs sets the validated redirect URIs of c.
+func setRedirectURIs(c *Client, redirectURIs, postLogoutRedirectURIs []string) error {
+	var err error
+	if c.RedirectURIs, err = normalizeURIs("redirectURIs", redirectURIs); err != nil {
+		return err
+	}
+	if c.PostLogoutRedirectURIs, err = normalizeURIs("postLogoutRedirectURIs", postLogoutRedirectURIs); err != nil {
+		return err
+	}
+	return nil
+}
+
+// normalizeURIs returns the distinct URIs, or a ValidationError if a URI is not an absolute URL without a fragment,
+// or uses http for another host than the loopback interface, see RFC 8252 section 7.3.
+func normalizeURIs(field string, uris []string) ([]string, error) {
+	normalized, err := normalize(field, uris)
+	if err != nil {
+		return nil, err
+	}
+	for _, uri := range normalized {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+			return nil, &ValidationError{Field: field, Reason: fmt.Sprintf("%q is not an absolute URL without a fragment", uri)}
+		}
+		if u.Scheme == "http" && !loopback(u.Hostname()) {
+			return nil, &ValidationError{Field: field, Reason: fmt.Sprintf("%q has to use https", uri)}
+		}
+	}
+	return normalized, nil
+}
+
+// loopback reports whether host is the loopback interface.
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// usesSecret reports whether clients with method are authenticated with a secret.
+func usesSecret(method string) bool {
+	return method == AuthMethodSecretBasic || method == AuthMethodSecretPost
+}
+
+// addSecret adds secret to c, generating it if it is empty, and returns it.
+// A ValidationError is returned if secret is shorter than minSecretLength.
+func addSecret(c *Client, secret string, now time.Time) (string, error) {
+	if secret != "" && len(secret) < minSecretLength {
+		return "", &ValidationError{Field: "secret", Reason: fmt.Sprintf("must be at least %d bytes", minSecretLength)}
+	}
+	if secret == "" {
+		b := make([]byte, secretBytes)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("generating client secret: %w", err)
+		}
+		secret = base64.RawURLEncoding.EncodeToString(b)
+	}
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("generating client secret id: %w", err)
+	}
+	c.Secrets = append(c.Secrets, Secret{
+		ID:        base64.RawURLEncoding.EncodeToString(idBytes),
+		Hash:      HashSecret(secret),
+		CreatedAt: now,
+	})
+	return secret, nil
+}
+
+// normalize returns the trimmed distinct values, or a ValidationError if a value is empty or contains whitespace.
+func normalize(field string, values []string) ([]string, error) {
+	normalized := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.ContainsAny(v, " \t\r\n") {
+			return nil, &ValidationError{Field: field, Reason: fmt.Sprintf("invalid value %q", v)}
+		}
+		if !slices.Contains(normalized, v) {
+			normalized = append(normalized, v)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,194 @@
+package clients
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// testSecret is a caller-chosen secret of the minimum length.
+var testSecret = strings.Repeat("s", minSecretLength)
+
+// newTestKey returns an Ed25519 key of a private_key_jwt client and the JWKS of its public key with the key ID kid.
+func newTestKey(t *testing.T, kid string) (ed25519.PrivateKey, *tokens.JWKSet) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := tokens.NewJWK(pub, kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, &tokens.JWKSet{Keys: []tokens.JWK{jwk}}
+}
+
+// testJWKS returns the JWKS of a new key.
+func testJWKS(t *testing.T) *tokens.JWKSet {
+	t.Helper()
+	_, jwks := newTestKey(t, "")
+	return jwks
+}
+
+// newTestService returns a Service of an empty MemoryRepository with a grace period of an hour.
+func newTestService() *Service {
+	return NewService(NewMemoryRepository(), time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestServiceCreateValidates(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+
+	for name, input := range map[string]CreateInput{
+		"invalid id":           {ID: "a b"},
+		"short secret":         {ID: "gateway", Secret: testSecret[1:]},
+		"secret of public":     {ID: "spa", AuthMethod: AuthMethodNone, Secret: testSecret},
+		"keys of secret":       {ID: "gateway", JWKS: testJWKS(t)},
+		"private_key_jwt keys": {ID: "automation", AuthMethod: AuthMethodPrivateKeyJWT},
+		"unknown method":       {ID: "gateway", AuthMethod: "tls_client_auth"},
+		"unknown grant":        {ID: "gateway", GrantTypes: []string{"password"}},
+		"public credentials":   {ID: "spa", AuthMethod: AuthMethodNone, GrantTypes: []string{GrantTypeClientCredentials}},
+		"code without uris":    {ID: "spa", AuthMethod: AuthMethodNone, GrantTypes: []string{GrantTypeAuthorizationCode}},
+		"http redirect uri":    {ID: "spa", AuthMethod: AuthMethodNone, RedirectURIs: []string{"http://spa.example.com/callback"}},
+		"whitespace in scope":  {ID: "gateway", Scopes: []string{"orders read"}},
+	} {
+		var verr *ValidationError
+		if _, _, err := svc.Create(ctx, input); !errors.As(err, &verr) {
+			t.Errorf("%s: got %v, want a ValidationError", name, err)
+		}
+	}
+
+	c, secret, err := svc.Create(ctx, CreateInput{ID: "gateway", Scopes: []string{"b", " a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AuthMethod != AuthMethodSecretBasic || len(c.Secrets) != 1 || len(secret) < minSecretLength {
+		t.Errorf("unexpected client %+v with secret %q", c, secret)
+	}
+	if len(c.Scopes) != 2 || c.Scopes[0] != "b" || c.Scopes[1] != "a" {
+		t.Errorf("got scopes %v, want [b a]", c.Scopes)
+	}
+	if _, _, err := svc.Create(ctx, CreateInput{ID: "gateway"}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("duplicate id: got %v, want ErrAlreadyExists", err)
+	}
+
+	c, secret, err = svc.Create(ctx, CreateInput{ID: "chosen", Secret: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != testSecret || !secretMatches(c, testSecret, time.Now()) {
+		t.Error("chosen secret not used")
+	}
+	c, secret, err = svc.Create(ctx, CreateInput{
+		ID:           "spa",
+		AuthMethod:   AuthMethodNone,
+		GrantTypes:   []string{GrantTypeAuthorizationCode},
+		RedirectURIs: []string{"http://127.0.0.1:8080/callback"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Public() || secret != "" || len(c.Secrets) != 0 {
+		t.Errorf("unexpected public client %+v with secret %q", c, secret)
+	}
+}
+
+func TestServiceUpdate(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+	if _, _, err := svc.Create(ctx, CreateInput{ID: "gateway", GrantTypes: []string{GrantTypeClientCredentials}}); err != nil {
+		t.Fatal(err)
+	}
+
+	name, disabled := " Gateway ", true
+	scopes := []string{"orders:read"}
+	c, err := svc.Update(ctx, "gateway", UpdateInput{Name: &name, Scopes: &scopes, Disabled: &disabled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "Gateway" || !c.Disabled || len(c.Scopes) != 1 || len(c.GrantTypes) != 1 {
+		t.Errorf("unexpected client %+v", c)
+	}
+
+	grantTypes := []string{GrantTypeAuthorizationCode}
+	var verr *ValidationError
+	if _, err := svc.Update(ctx, "gateway", UpdateInput{GrantTypes: &grantTypes}); !errors.As(err, &verr) {
+		t.Errorf("code without redirect uris: got %v, want a ValidationError", err)
+	}
+	if _, err := svc.Update(ctx, "missing", UpdateInput{Name: &name}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing client: got %v, want ErrNotFound", err)
+	}
+
+	if err := svc.Delete(ctx, "gateway"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Get(ctx, "gateway"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted client: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestServiceRotateSecret(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+	_, first, err := svc.Create(ctx, CreateInput{ID: "gateway"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the previous secret stays valid for the grace period
+	c, second, err := svc.RotateSecret(ctx, "gateway", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if second == first || !secretMatches(c, first, now) || !secretMatches(c, second, now) {
+		t.Fatal("rotated secrets not both valid")
+	}
+	if secretMatches(c, first, now.Add(2*time.Hour)) || !secretMatches(c, second, now.Add(2*time.Hour)) {
+		t.Error("previous secret valid after the grace period")
+	}
+
+	// a rotation without a grace period invalidates the previous secrets immediately
+	c, third, err := svc.RotateSecret(ctx, "gateway", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secretMatches(c, first, time.Now()) || secretMatches(c, second, time.Now()) || !secretMatches(c, third, time.Now()) {
+		t.Error("previous secrets valid without a grace period")
+	}
+
+	// a leaked secret in its grace period is revoked
+	c, fourth, err := svc.RotateSecret(ctx, "gateway", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Secrets) != 2 {
+		t.Fatalf("invalid secrets not removed: %d secrets, want 2", len(c.Secrets))
+	}
+	c, err = svc.RevokeSecret(ctx, "gateway", c.Secrets[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secretMatches(c, third, time.Now()) || !secretMatches(c, fourth, time.Now()) {
+		t.Error("revoked secret still valid")
+	}
+	if _, err := svc.RevokeSecret(ctx, "gateway", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing secret: got %v, want ErrNotFound", err)
+	}
+
+	if _, _, err := svc.Create(ctx, CreateInput{ID: "spa", AuthMethod: AuthMethodNone}); err != nil {
+		t.Fatal(err)
+	}
+	var verr *ValidationError
+	if _, _, err := svc.RotateSecret(ctx, "spa", -1); !errors.As(err, &verr) {
+		t.Errorf("public client: got %v, want a ValidationError", err)
+	}
+}
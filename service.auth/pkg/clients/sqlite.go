@@ -0,0 +1,249 @@
+package clients
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLiteDriver is the name of the database/sql driver SQLite databases are opened with.
+// The driver is registered by building with the sqlite tag, see sqlite_driver.go.
+const SQLiteDriver string = "sqlite3"
+
+// sqliteSchema creates the clients table if it does not exist.
+// The lists are stored as JSON arrays, the secrets and keys as JSON documents.
+const sqliteSchema string = `
+CREATE TABLE IF NOT EXISTS clients (
+	id                        TEXT PRIMARY KEY,
+	name                      TEXT NOT NULL DEFAULT '',
+	auth_method               TEXT NOT NULL,
+	secrets                   TEXT NOT NULL DEFAULT '[]',
+	jwks                      TEXT NOT NULL DEFAULT 'null',
+	grant_types               TEXT NOT NULL DEFAULT '[]',
+	scopes                    TEXT NOT NULL DEFAULT '[]',
+	audiences                 TEXT NOT NULL DEFAULT '[]',
+	redirect_uris             TEXT NOT NULL DEFAULT '[]',
+	post_logout_redirect_uris TEXT NOT NULL DEFAULT '[]',
+	skip_consent              INTEGER NOT NULL DEFAULT 0,
+	disabled                  INTEGER NOT NULL DEFAULT 0,
+	created_at                INTEGER NOT NULL,
+	updated_at                INTEGER NOT NULL
+)`
+
+// sqliteColumns are the selected columns in the order scanned by scanClient.
+const sqliteColumns string = "id, name, auth_method, secrets, jwks, grant_types, scopes, audiences, " +
+	"redirect_uris, post_logout_redirect_uris, skip_consent, disabled, created_at, updated_at"
+
+// storedSecret is the stored form of a Secret. Secret does not serialize its hash, so it is not disclosed by the admin API.
+type storedSecret struct {
+	ID        string     `json:"id"`
+	Hash      []byte     `json:"hash"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// SQLiteRepository is an implementation of Repository storing the clients in a SQLite database, so the clients
+// managed with the admin API survive restarts. It is meant for small deployments.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+// interface guard
+var _ Repository = (*SQLiteRepository)(nil)
+
+/*
+NewSQLiteRepository opens the SQLite database at path and creates the clients table if it does not exist.
+
+Parameters:
+  - ctx: The context of the schema creation.
+  - path: The path of the database file, or a data source name understood by the driver.
+
+Returns:
+  - *SQLiteRepository: The created repository, closed with Close.
+  - error: An error if the database could not be opened or migrated, e.g. if the binary was built without the sqlite tag.
+
+Example usage:
+
+	repo, err := clients.NewSQLiteRepository(ctx, "/var/lib/auth-service/clients.db")
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+*/
+func NewSQLiteRepository(ctx context.Context, path string) (*SQLiteRepository, error) {
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, serializing the connections avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("creating clients table: %w", err)
+	}
+	return &SQLiteRepository{db: db}, nil
+}
+
+// Close closes the database.
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
+// PingContext checks the connection to the database. It implements health.Pinger.
+func (r *SQLiteRepository) PingContext(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Create implements Repository.
+func (r *SQLiteRepository) Create(ctx context.Context, client *Client) error {
+	values, err := clientValues(client)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
+		"INSERT INTO clients ("+sqliteColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		append([]any{client.ID}, values...)...,
+	)
+	return uniqueError(err)
+}
+
+// Get implements Repository.
+func (r *SQLiteRepository) Get(ctx context.Context, id string) (*Client, error) {
+	return scanClient(r.db.QueryRowContext(ctx, "SELECT "+sqliteColumns+" FROM clients WHERE id = ?", id))
+}
+
+// List implements Repository.
+func (r *SQLiteRepository) List(ctx context.Context, opts ListOptions) ([]*Client, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		// a negative limit means no limit in SQLite
+		limit = -1
+	}
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+sqliteColumns+" FROM clients ORDER BY id LIMIT ? OFFSET ?",
+		limit, max(opts.Offset, 0),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var clients []*Client
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
+
+// Update implements Repository.
+func (r *SQLiteRepository) Update(ctx context.Context, client *Client) error {
+	values, err := clientValues(client)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE clients SET name = ?, auth_method = ?, secrets = ?, jwks = ?, grant_types = ?, scopes = ?, audiences = ?, "+
+			"redirect_uris = ?, post_logout_redirect_uris = ?, skip_consent = ?, disabled = ?, created_at = ?, updated_at = ? WHERE id = ?",
+		append(values, client.ID)...,
+	)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// Delete implements Repository.
+func (r *SQLiteRepository) Delete(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM clients WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// clientValues returns the values of the columns of c after the id, in the order of sqliteColumns.
+func clientValues(c *Client) ([]any, error) {
+	secrets := make([]storedSecret, len(c.Secrets))
+	for i, s := range c.Secrets {
+		secrets[i] = storedSecret{ID: s.ID, Hash: s.Hash, CreatedAt: s.CreatedAt, ExpiresAt: s.ExpiresAt}
+	}
+	values := []any{c.Name, c.AuthMethod}
+	for _, v := range []any{secrets, c.JWKS, c.GrantTypes, c.Scopes, c.Audiences, c.RedirectURIs, c.PostLogoutRedirectURIs} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("encoding client %q: %w", c.ID, err)
+		}
+		values = append(values, string(b))
+	}
+	return append(values, c.SkipConsent, c.Disabled, c.CreatedAt.UnixNano(), c.UpdatedAt.UnixNano()), nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanClient scans a row of sqliteColumns into a client.
+func scanClient(s scanner) (*Client, error) {
+	var (
+		c                    Client
+		secrets, jwks        string
+		lists                [5]string
+		createdAt, updatedAt int64
+	)
+	err := s.Scan(&c.ID, &c.Name, &c.AuthMethod, &secrets, &jwks, &lists[0], &lists[1], &lists[2], &lists[3], &lists[4],
+		&c.SkipConsent, &c.Disabled, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored []storedSecret
+	if err := json.Unmarshal([]byte(secrets), &stored); err != nil {
+		return nil, fmt.Errorf("decoding secrets of client %q: %w", c.ID, err)
+	}
+	for _, s := range stored {
+		c.Secrets = append(c.Secrets, Secret{ID: s.ID, Hash: s.Hash, CreatedAt: s.CreatedAt, ExpiresAt: s.ExpiresAt})
+	}
+	// a client without keys is stored as null
+	if err := json.Unmarshal([]byte(jwks), &c.JWKS); err != nil {
+		return nil, fmt.Errorf("decoding jwks of client %q: %w", c.ID, err)
+	}
+	for i, list := range []*[]string{&c.GrantTypes, &c.Scopes, &c.Audiences, &c.RedirectURIs, &c.PostLogoutRedirectURIs} {
+		if err := json.Unmarshal([]byte(lists[i]), list); err != nil {
+			return nil, fmt.Errorf("decoding client %q: %w", c.ID, err)
+		}
+	}
+	c.CreatedAt = time.Unix(0, createdAt).UTC()
+	c.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return &c, nil
+}
+
+// affected returns ErrNotFound if res did not affect any row.
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// uniqueError returns ErrAlreadyExists if err is a violation of a unique constraint.
+// The SQLite drivers do not share an error type, but all report the message of SQLite.
+func uniqueError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrAlreadyExists
+	}
+	return err
+}
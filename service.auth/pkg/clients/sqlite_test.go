@@ -0,0 +1,39 @@
+//go:build sqlite
+
+package clients
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "clients.db")
+	repo, err := NewSQLiteRepository(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRepository(t, repo)
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the clients survive reopening the database
+	repo, err = NewSQLiteRepository(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if err := repo.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c, err := repo.Get(ctx, "alice")
+	if err != nil {
+		t.Fatalf("client lost after reopening: %v", err)
+	}
+	if c.JWKS == nil || !c.Disabled {
+		t.Errorf("client changed after reopening: %+v", c)
+	}
+}
@@ -1,41 +1,279 @@
 package config
 
+import otelmetrics "github.com/SaimonWoidig/cc-microsvcs/common/otel/metrics"
+
 type LoggingConfig struct {
 	LogLevel string `mapstructure:"logLevel"`
 	Pretty   bool   `mapstructure:"pretty"`
 }
 
 type TracingConfig struct {
+	Mode                 string  `mapstructure:"mode"`
 	OTLPEndpoint         string  `mapstructure:"otlpEndpoint"`
 	ExportTimeoutSeconds int     `mapstructure:"exportTimeoutSeconds"`
 	SamplingRatio        float64 `mapstructure:"samplingRatio"`
 }
+type PrometheusConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Addr    string `mapstructure:"addr"`
+	Port    int    `mapstructure:"port"`
+}
 type MetricsConfig struct {
-	OTLPEndpoint            string `mapstructure:"otlpEndpoint"`
-	ExportTimeoutSeconds    int    `mapstructure:"exportTimeoutSeconds"`
-	ExportIntervalSeconds   int    `mapstructure:"exportIntervalSeconds"`
-	MemStatsIntervalSeconds int    `mapstructure:"memStatsIntervalSeconds"`
+	Mode                    string                   `mapstructure:"mode"`
+	OTLPEndpoint            string                   `mapstructure:"otlpEndpoint"`
+	ExportTimeoutSeconds    int                      `mapstructure:"exportTimeoutSeconds"`
+	ExportIntervalSeconds   int                      `mapstructure:"exportIntervalSeconds"`
+	MemStatsIntervalSeconds int                      `mapstructure:"memStatsIntervalSeconds"`
+	Prometheus              PrometheusConfig         `mapstructure:"prometheus"`
+	HostMetrics             bool                     `mapstructure:"hostMetrics"`
+	RuntimeMetrics          bool                     `mapstructure:"runtimeMetrics"`
+	Views                   []otelmetrics.ViewConfig `mapstructure:"views"`
+	ExemplarFilter          string                   `mapstructure:"exemplarFilter"`
+}
+type LogPipelineConfig struct {
+	Name                 string   `mapstructure:"name"`
+	OTLPEndpoint         string   `mapstructure:"otlpEndpoint"`
+	ExportTimeoutSeconds int      `mapstructure:"exportTimeoutSeconds"`
+	BatchTimeoutSeconds  int      `mapstructure:"batchTimeoutSeconds"`
+	MaxQueueSize         int      `mapstructure:"maxQueueSize"`
+	MaxExportBatchSize   int      `mapstructure:"maxExportBatchSize"`
+	MinLevel             string   `mapstructure:"minLevel"`
+	Categories           []string `mapstructure:"categories"`
+	ExcludeCategories    []string `mapstructure:"excludeCategories"`
 }
 type LogsConfig struct {
-	OTLPEndpoint         string `mapstructure:"otlpEndpoint"`
-	ExportTimeoutSeconds int    `mapstructure:"exportTimeoutSeconds"`
-	BatchTimeoutSeconds  int    `mapstructure:"batchTimeoutSeconds"`
-	LogLevel             string `mapstructure:"logLevel"`
+	Mode                 string              `mapstructure:"mode"`
+	OTLPEndpoint         string              `mapstructure:"otlpEndpoint"`
+	ExportTimeoutSeconds int                 `mapstructure:"exportTimeoutSeconds"`
+	BatchTimeoutSeconds  int                 `mapstructure:"batchTimeoutSeconds"`
+	LogLevel             string              `mapstructure:"logLevel"`
+	Pipelines            []LogPipelineConfig `mapstructure:"pipelines"`
+}
+
+type QueueConfig struct {
+	Enabled              bool   `mapstructure:"enabled"`
+	Dir                  string `mapstructure:"dir"`
+	MaxSizeMiB           int    `mapstructure:"maxSizeMiB"`
+	MaxItems             int    `mapstructure:"maxItems"`
+	RetryIntervalSeconds int    `mapstructure:"retryIntervalSeconds"`
+}
+
+type InstanceConfig struct {
+	Source   string `mapstructure:"source"`
+	StateDir string `mapstructure:"stateDir"`
 }
 
 type TelemetryConfig struct {
-	Tracing TracingConfig `mapstructure:"tracing"`
-	Metrics MetricsConfig `mapstructure:"metrics"`
-	Logging LogsConfig    `mapstructure:"logs"`
+	// Mode is the mode of all signals, one of "otlp", "console" or "off". The mode of a signal takes precedence.
+	Mode        string         `mapstructure:"mode"`
+	Tracing     TracingConfig  `mapstructure:"tracing"`
+	Metrics     MetricsConfig  `mapstructure:"metrics"`
+	Logging     LogsConfig     `mapstructure:"logs"`
+	Queue       QueueConfig    `mapstructure:"queue"`
+	Instance    InstanceConfig `mapstructure:"instance"`
+	Environment string         `mapstructure:"environment"`
+	// ResourceAttributes are added to the resource of all signals. They are a list, as the config loader would split the dotted keys of a map.
+	ResourceAttributes []ResourceAttributeConfig `mapstructure:"resourceAttributes"`
+}
+
+type ResourceAttributeConfig struct {
+	Key   string `mapstructure:"key"`
+	Value string `mapstructure:"value"`
 }
 
 type ServerConfig struct {
 	Addr string `mapstructure:"addr"`
 	Port int    `mapstructure:"port"`
+	// ListenAddr is the address the HTTP server listens on. If empty, it listens on all interfaces.
+	ListenAddr string `mapstructure:"listenAddr"`
+	// ReadTimeoutSeconds, ReadHeaderTimeoutSeconds, WriteTimeoutSeconds and IdleTimeoutSeconds are the timeouts of the connections.
+	ReadTimeoutSeconds       int `mapstructure:"readTimeoutSeconds"`
+	ReadHeaderTimeoutSeconds int `mapstructure:"readHeaderTimeoutSeconds"`
+	WriteTimeoutSeconds      int `mapstructure:"writeTimeoutSeconds"`
+	IdleTimeoutSeconds       int `mapstructure:"idleTimeoutSeconds"`
+	// RequestTimeoutSeconds is the time after which the context of a request is cancelled.
+	RequestTimeoutSeconds int `mapstructure:"requestTimeoutSeconds"`
+	// BodyLimit is the maximum size of request bodies, e.g. "1M".
+	BodyLimit string `mapstructure:"bodyLimit"`
+	// DrainDelaySeconds is the time the server keeps serving after the readiness probe starts failing on shutdown,
+	// so load balancers stop sending requests before the listener is closed.
+	DrainDelaySeconds int       `mapstructure:"drainDelaySeconds"`
+	TLS               TLSConfig `mapstructure:"tls"`
+	// AdminAPI protects the administration endpoints of the API, e.g. the user management.
+	AdminAPI AdminAPIConfig `mapstructure:"adminAPI"`
+}
+
+type AdminAPIConfig struct {
+	// Token is the bearer token of the admin API. If neither Token nor TokenFile is set, the admin API is disabled.
+	Token     string `mapstructure:"token"`
+	TokenFile string `mapstructure:"tokenFile"`
+}
+
+type TLSConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"certFile"`
+	KeyFile  string `mapstructure:"keyFile"`
+	// ClientCAFile is the CA client certificates are verified against. Client certificates are optional.
+	ClientCAFile string `mapstructure:"clientCAFile"`
+	// MinVersion is the minimum TLS version, "1.2" or "1.3".
+	MinVersion string `mapstructure:"minVersion"`
+}
+
+type HealthConfig struct {
+	CacheTTLSeconds int `mapstructure:"cacheTTLSeconds"`
+	TimeoutSeconds  int `mapstructure:"timeoutSeconds"`
+	// MinFreeDiskMiB is the space which has to be available for the telemetry queue for the service to be ready.
+	MinFreeDiskMiB int `mapstructure:"minFreeDiskMiB"`
+}
+
+type ShutdownConfig struct {
+	TimeoutSeconds int `mapstructure:"timeoutSeconds"`
+}
+
+type AdminConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	Addr         string `mapstructure:"addr"`
+	Port         int    `mapstructure:"port"`
+	Token        string `mapstructure:"token"`
+	TokenFile    string `mapstructure:"tokenFile"`
+	CertFile     string `mapstructure:"certFile"`
+	KeyFile      string `mapstructure:"keyFile"`
+	ClientCAFile string `mapstructure:"clientCAFile"`
 }
 
 type Config struct {
 	Logging   LoggingConfig   `mapstructure:"logging"`
 	Telemetry TelemetryConfig `mapstructure:"telemetry"`
 	Server    ServerConfig    `mapstructure:"server"`
+	Shutdown  ShutdownConfig  `mapstructure:"shutdown"`
+	Health    HealthConfig    `mapstructure:"health"`
+	Admin     AdminConfig     `mapstructure:"admin"`
+	Users     UsersConfig     `mapstructure:"users"`
+	Tokens    TokensConfig    `mapstructure:"tokens"`
+	OAuth2    OAuth2Config    `mapstructure:"oauth2"`
+	OIDC      OIDCConfig      `mapstructure:"oidc"`
+}
+
+type OAuth2Config struct {
+	// Store is the repository of the clients, "memory" or "sqlite". The clients managed with the admin API
+	// are lost on a restart with the memory store.
+	Store  string       `mapstructure:"store"`
+	SQLite SQLiteConfig `mapstructure:"sqlite"`
+	// Clients are the clients registered at startup, replacing stored clients with the same ID.
+	// More clients can be registered with the admin API.
+	Clients []ClientConfig `mapstructure:"clients"`
+	// SecretGraceSeconds is the default time a rotated client secret stays valid.
+	SecretGraceSeconds int `mapstructure:"secretGraceSeconds"`
+}
+
+type ClientConfig struct {
+	ID   string `mapstructure:"id"`
+	Name string `mapstructure:"name"`
+	// AuthMethod is client_secret_basic, client_secret_post, private_key_jwt or none for public clients.
+	// If empty, it is none for clients without a secret and client_secret_basic otherwise.
+	AuthMethod string `mapstructure:"authMethod"`
+	// Secret is the client secret of at least 32 bytes, e.g. openssl rand -base64 32. SecretFile is read if Secret is empty.
+	Secret     string `mapstructure:"secret"`
+	SecretFile string `mapstructure:"secretFile"`
+	// JWKSFile is the JSON Web Key Set of the public keys of a private_key_jwt client.
+	JWKSFile   string   `mapstructure:"jwksFile"`
+	GrantTypes []string `mapstructure:"grantTypes"`
+	Scopes     []string `mapstructure:"scopes"`
+	Audiences  []string `mapstructure:"audiences"`
+	// RedirectURIs and PostLogoutRedirectURIs are the redirect URIs of the OpenID Connect login and logout.
+	RedirectURIs           []string `mapstructure:"redirectURIs"`
+	PostLogoutRedirectURIs []string `mapstructure:"postLogoutRedirectURIs"`
+	// SkipConsent is set for first-party clients the users do not have to consent to.
+	SkipConsent bool `mapstructure:"skipConsent"`
+}
+
+type TokensConfig struct {
+	// Issuer is the iss claim of the tokens. If empty, it is the https URL of server.addr.
+	Issuer      string              `mapstructure:"issuer"`
+	SigningKeys SigningKeysConfig   `mapstructure:"signingKeys"`
+	Access      AccessTokensConfig  `mapstructure:"access"`
+	Refresh     RefreshTokensConfig `mapstructure:"refresh"`
+	ID          IDTokensConfig      `mapstructure:"id"`
+	Revocations RevocationsConfig   `mapstructure:"revocations"`
+}
+
+type SigningKeysConfig struct {
+	// Algorithm is the JWS algorithm of the generated keys: EdDSA, ES256, ES384, ES512 or RS256.
+	Algorithm string `mapstructure:"algorithm"`
+	// File is the encrypted file the keys are stored in. If empty, the keys are kept in memory and lost on restart.
+	File string `mapstructure:"file"`
+	// Secret is the secret the key file is encrypted with. SecretFile is read if Secret is empty.
+	Secret     string `mapstructure:"secret"`
+	SecretFile string `mapstructure:"secretFile"`
+	// RotationIntervalSeconds is the time a key signs tokens before it is replaced.
+	RotationIntervalSeconds int `mapstructure:"rotationIntervalSeconds"`
+	// PublishAheadSeconds is the time a new key is published in the JWKS before it signs tokens. It has to exceed JWKSMaxAgeSeconds.
+	PublishAheadSeconds int `mapstructure:"publishAheadSeconds"`
+	// JWKSMaxAgeSeconds is the time the verifiers may cache the JWKS for.
+	JWKSMaxAgeSeconds int `mapstructure:"jwksMaxAgeSeconds"`
+}
+
+type AccessTokensConfig struct {
+	TTLSeconds int      `mapstructure:"ttlSeconds"`
+	Audience   []string `mapstructure:"audience"`
+	// LoginClientID is the client_id claim of the tokens of the password login. It must not be the ID of an OAuth2 client.
+	LoginClientID string `mapstructure:"loginClientID"`
+	// IncludeUsername and IncludeEmail add the preferred_username and email claims of the user.
+	IncludeUsername bool `mapstructure:"includeUsername"`
+	IncludeEmail    bool `mapstructure:"includeEmail"`
+	// Claims are static claims added to all access tokens. They must not be named like a claim set by the issuer, e.g. sub or roles.
+	Claims []ClaimConfig `mapstructure:"claims"`
+}
+
+type ClaimConfig struct {
+	Name  string `mapstructure:"name"`
+	Value string `mapstructure:"value"`
+}
+
+type RefreshTokensConfig struct {
+	// TTLSeconds is the lifetime of a refresh token. Each rotation issues a token with a new lifetime.
+	TTLSeconds int `mapstructure:"ttlSeconds"`
+	// FamilyTTLSeconds is the absolute lifetime of the tokens rotated from a login.
+	FamilyTTLSeconds int `mapstructure:"familyTTLSeconds"`
+}
+
+type RevocationsConfig struct {
+	// Store is the store of the revocation list, "memory" or "sqlite". With the memory store, the list is emptied on a restart.
+	// The list is not shared between the replicas with either store.
+	Store  string       `mapstructure:"store"`
+	SQLite SQLiteConfig `mapstructure:"sqlite"`
+}
+
+type IDTokensConfig struct {
+	// TTLSeconds is the lifetime of the OpenID Connect ID tokens.
+	TTLSeconds int `mapstructure:"ttlSeconds"`
+}
+
+type OIDCConfig struct {
+	// SessionTTLSeconds is the lifetime of a login session in the browser, after which the user has to log in again.
+	SessionTTLSeconds int `mapstructure:"sessionTTLSeconds"`
+	// CodeTTLSeconds is the time an authorization code can be exchanged in.
+	CodeTTLSeconds int `mapstructure:"codeTTLSeconds"`
+	// RequestTTLSeconds is the time the user has to log in and consent in.
+	RequestTTLSeconds int `mapstructure:"requestTTLSeconds"`
+}
+
+type UsersConfig struct {
+	// Store is the repository of the users, "memory" or "sqlite".
+	Store  string       `mapstructure:"store"`
+	SQLite SQLiteConfig `mapstructure:"sqlite"`
+	Argon2 Argon2Config `mapstructure:"argon2"`
+}
+
+type SQLiteConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+// Argon2Config holds the parameters of the password hashing. Changed parameters are applied to the stored hashes on the next login.
+type Argon2Config struct {
+	MemoryKiB   uint32 `mapstructure:"memoryKiB"`
+	Iterations  uint32 `mapstructure:"iterations"`
+	Parallelism uint8  `mapstructure:"parallelism"`
+	SaltLength  uint32 `mapstructure:"saltLength"`
+	KeyLength   uint32 `mapstructure:"keyLength"`
 }
@@ -0,0 +1,90 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JWKSPath is the path of the JSON Web Key Set, see RFC 8414 section 2.
+const JWKSPath string = "/.well-known/jwks.json"
+
+// DefaultJWKSMaxAge is the default time the verifiers may cache the JWKS for.
+const DefaultJWKSMaxAge time.Duration = 5 * time.Minute
+
+// jwksCache caches the encoded JWKS until the keys change.
+type jwksCache struct {
+	mu   sync.Mutex
+	body []byte
+	etag string
+}
+
+// invalidate drops the cached JWKS.
+func (c *jwksCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.body = nil
+	c.etag = ""
+}
+
+// get returns the cached JWKS and its ETag, encoding the JWKS of m if it is not cached.
+func (c *jwksCache) get(m *Manager) ([]byte, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.body != nil {
+		return c.body, c.etag, nil
+	}
+	set, err := m.JWKS()
+	if err != nil {
+		return nil, "", err
+	}
+	body, err := json.Marshal(set)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(body)
+	c.body = body
+	c.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	return c.body, c.etag, nil
+}
+
+/*
+JWKSHandler returns the handler serving the JWKS of m. The response may be cached by the verifiers for maxAge,
+so maxAge has to be shorter than the publish ahead time of the keys. Conditional requests with If-None-Match are answered with 304.
+
+Parameters:
+  - m: The key manager.
+  - maxAge: The max-age of the Cache-Control header. If zero, DefaultJWKSMaxAge is used.
+
+Returns:
+  - echo.HandlerFunc: The handler, registered on JWKSPath.
+
+Example usage:
+
+	e.GET(keys.JWKSPath, keys.JWKSHandler(m, 0))
+*/
+func JWKSHandler(m *Manager, maxAge time.Duration) echo.HandlerFunc {
+	if maxAge == 0 {
+		maxAge = DefaultJWKSMaxAge
+	}
+	cacheControl := "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	return func(c echo.Context) error {
+		body, etag, err := m.jwks.get(m)
+		if err != nil {
+			return err
+		}
+		h := c.Response().Header()
+		h.Set(echo.HeaderCacheControl, cacheControl)
+		h.Set("ETag", etag)
+		if match := c.Request().Header.Get("If-None-Match"); match == etag {
+			return c.NoContent(http.StatusNotModified)
+		}
+		return c.Blob(http.StatusOK, "application/jwk-set+json", body)
+	}
+}
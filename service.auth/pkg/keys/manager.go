@@ -0,0 +1,412 @@
+/*
+Package keys contains the signing key manager of the auth service. The manager generates the keys tokens are signed with,
+rotates them on a schedule and publishes them as a JSON Web Key Set, so other services can verify the tokens without calling the auth service.
+
+A key goes through the following states:
+  - published: The key is in the JWKS, but does not sign tokens yet, so verifiers can fetch it before the first token signed with it.
+  - active: The key signs the new tokens.
+  - retired: The key does not sign tokens anymore, but stays in the JWKS until all tokens it signed expired.
+*/
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/health"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// The defaults of the unset config fields.
+const (
+	DefaultAlgorithm        string        = tokens.AlgEdDSA
+	DefaultRotationInterval time.Duration = 30 * 24 * time.Hour
+	DefaultPublishAhead     time.Duration = 24 * time.Hour
+	DefaultMaxTokenTTL      time.Duration = time.Hour
+	DefaultCheckInterval    time.Duration = time.Minute
+)
+
+// errNoActiveKey is returned if no key is active, e.g. before Start.
+var errNoActiveKey = errors.New("no active signing key")
+
+// Config is a struct that represents the configuration of the key manager.
+type Config struct {
+	// Algorithm is the JWS algorithm of the generated keys, e.g. tokens.AlgES256. If empty, DefaultAlgorithm is used.
+	Algorithm string
+	// RotationInterval is the time a key signs tokens before it is replaced. If zero, DefaultRotationInterval is used.
+	RotationInterval time.Duration
+	// PublishAhead is the time the next key is published before it becomes active.
+	// It has to be longer than the time the verifiers cache the JWKS. If zero, DefaultPublishAhead is used.
+	PublishAhead time.Duration
+	// MaxTokenTTL is the longest lifetime of a signed token. Retired keys are published for this time. If zero, DefaultMaxTokenTTL is used.
+	MaxTokenTTL time.Duration
+	// CheckInterval is the interval the rotation schedule is checked in. If zero, DefaultCheckInterval is used.
+	CheckInterval time.Duration
+}
+
+// withDefaults returns the config with the defaults applied to the unset fields.
+func (c Config) withDefaults() Config {
+	if c.Algorithm == "" {
+		c.Algorithm = DefaultAlgorithm
+	}
+	if c.RotationInterval == 0 {
+		c.RotationInterval = DefaultRotationInterval
+	}
+	if c.PublishAhead == 0 {
+		c.PublishAhead = DefaultPublishAhead
+	}
+	if c.MaxTokenTTL == 0 {
+		c.MaxTokenTTL = DefaultMaxTokenTTL
+	}
+	if c.CheckInterval == 0 {
+		c.CheckInterval = DefaultCheckInterval
+	}
+	return c
+}
+
+// key is a decoded StoredKey.
+type key struct {
+	stored StoredKey
+	signer *tokens.Key
+}
+
+// Manager generates, rotates and publishes the signing keys. It is safe for concurrent use.
+type Manager struct {
+	config Config
+	store  Store
+	logger *slog.Logger
+
+	mu   sync.RWMutex
+	keys []*key
+	jwks *jwksCache
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// interface guards
+var (
+	_ tokens.KeySource        = (*Manager)(nil)
+	_ health.SigningKeySource = (*Manager)(nil)
+)
+
+/*
+NewManager creates the key manager of the keys in store. The keys are loaded and rotated by Start.
+
+Parameters:
+  - config: The configuration of the key generation and rotation.
+  - store: The store the keys are kept in.
+  - logger: The logger of the rotations.
+
+Returns:
+  - *Manager: The created manager.
+  - error: An error if the algorithm is not supported or the rotation interval is not longer than the publish ahead time.
+
+Example usage:
+
+	m, err := keys.NewManager(keys.Config{Algorithm: tokens.AlgES256}, store, logger)
+	if err != nil {
+		return err
+	}
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+	defer m.Stop(ctx)
+*/
+func NewManager(config Config, store Store, logger *slog.Logger) (*Manager, error) {
+	config = config.withDefaults()
+	switch config.Algorithm {
+	case tokens.AlgEdDSA, tokens.AlgES256, tokens.AlgES384, tokens.AlgES512, tokens.AlgRS256:
+	default:
+		return nil, fmt.Errorf("unsupported signing key algorithm %q", config.Algorithm)
+	}
+	if config.RotationInterval <= config.PublishAhead {
+		return nil, errors.New("signing key rotation interval has to be longer than the publish ahead time")
+	}
+	return &Manager{
+		config: config,
+		store:  store,
+		logger: logger,
+		jwks:   &jwksCache{},
+	}, nil
+}
+
+// Start loads the keys, rotates them if due and starts checking the rotation schedule in the background.
+func (m *Manager) Start(ctx context.Context) error {
+	stored, err := m.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make([]*key, 0, len(stored))
+	for _, s := range stored {
+		k, err := decodeKey(s)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	if err := m.Rotate(ctx, time.Now()); err != nil {
+		return err
+	}
+
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run()
+	return nil
+}
+
+// Stop stops checking the rotation schedule.
+func (m *Manager) Stop(ctx context.Context) error {
+	if m.stop == nil {
+		return nil
+	}
+	close(m.stop)
+	select {
+	case <-m.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run checks the rotation schedule until Stop is called.
+func (m *Manager) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			if err := m.Rotate(context.Background(), now); err != nil {
+				m.logger.Error("signing key rotation failed", "error", err.Error())
+			}
+		}
+	}
+}
+
+/*
+Rotate applies the rotation schedule at now and saves the keys if they changed:
+  - If no key is active, a key is generated and activated immediately.
+  - If the active key is replaced within PublishAhead, the next key is generated and published.
+  - If the activation time of the next key passed, it becomes active and the active key is retired.
+  - If a retired key is older than MaxTokenTTL, it is removed.
+
+Parameters:
+  - ctx: The context of the store.
+  - now: The time the schedule is applied at.
+
+Returns:
+  - error: An error if a key could not be generated or the keys could not be saved.
+*/
+func (m *Manager) Rotate(ctx context.Context, now time.Time) error {
+	changed, err := m.rotate(ctx, now)
+	if err != nil {
+		return err
+	}
+	// the cache is invalidated without holding m.mu, as the cache lock is held while the JWKS is read
+	if changed {
+		m.jwks.invalidate()
+	}
+	return nil
+}
+
+// rotate applies the rotation schedule at now and reports whether the keys changed.
+func (m *Manager) rotate(ctx context.Context, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// change copies, so the keys are unchanged if they can not be saved
+	keys := make([]*key, 0, len(m.keys))
+	for _, k := range m.keys {
+		c := *k
+		keys = append(keys, &c)
+	}
+	changed := false
+
+	// remove the retired keys of which all tokens expired
+	kept := make([]*key, 0, len(keys))
+	for _, k := range keys {
+		if k.stored.RetiredAt != nil && !now.Before(k.stored.RetiredAt.Add(m.config.MaxTokenTTL)) {
+			m.logger.Info("signing key removed", "kid", k.stored.ID)
+			changed = true
+			continue
+		}
+		kept = append(kept, k)
+	}
+	keys = kept
+
+	// activate the published key once due, retiring the active one
+	active, next := activeKey(keys, now), nextKey(keys, now)
+	for _, k := range keys {
+		if k != active && k.stored.RetiredAt == nil && !now.Before(k.stored.ActivatesAt) {
+			retiredAt := now
+			k.stored.RetiredAt = &retiredAt
+			m.logger.Info("signing key retired", "kid", k.stored.ID)
+			changed = true
+		}
+	}
+
+	if active == nil {
+		k, err := m.generate(now, now)
+		if err != nil {
+			return false, err
+		}
+		keys = append(keys, k)
+		active = k
+		m.logger.Info("signing key generated and activated", "kid", k.stored.ID, "alg", k.stored.Algorithm)
+		changed = true
+	} else if next == nil && !now.Before(active.stored.ActivatesAt.Add(m.config.RotationInterval-m.config.PublishAhead)) {
+		// if the rotation is overdue, e.g. after a downtime, the key is still published for PublishAhead before it signs tokens
+		activatesAt := active.stored.ActivatesAt.Add(m.config.RotationInterval)
+		if earliest := now.Add(m.config.PublishAhead); activatesAt.Before(earliest) {
+			activatesAt = earliest
+		}
+		k, err := m.generate(now, activatesAt)
+		if err != nil {
+			return false, err
+		}
+		keys = append(keys, k)
+		m.logger.Info("signing key published", "kid", k.stored.ID, "alg", k.stored.Algorithm, "activates_at", k.stored.ActivatesAt)
+		changed = true
+	}
+
+	if !changed {
+		return false, nil
+	}
+	stored := make([]StoredKey, 0, len(keys))
+	for _, k := range keys {
+		stored = append(stored, k.stored)
+	}
+	if err := m.store.Save(ctx, stored); err != nil {
+		return false, err
+	}
+	m.keys = keys
+	return true, nil
+}
+
+// generate returns a new key activated at activatesAt.
+func (m *Manager) generate(now, activatesAt time.Time) (*key, error) {
+	signer, err := tokens.GenerateKey(m.config.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	tk, err := tokens.NewKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	return &key{
+		stored: StoredKey{
+			ID:          tk.ID,
+			Algorithm:   tk.Algorithm,
+			PrivateKey:  der,
+			CreatedAt:   now,
+			ActivatesAt: activatesAt,
+		},
+		signer: tk,
+	}, nil
+}
+
+// decodeKey returns the key of a StoredKey.
+func decodeKey(s StoredKey) (*key, error) {
+	priv, err := x509.ParsePKCS8PrivateKey(s.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signing key %s: %w", s.ID, err)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s: unsupported key type %T", s.ID, priv)
+	}
+	tk, err := tokens.NewKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	// keep the stored ID, so tokens signed before a change of the ID derivation stay valid
+	tk.ID = s.ID
+	return &key{stored: s, signer: tk}, nil
+}
+
+// activeKey returns the not retired key with the latest activation time before now, or nil.
+func activeKey(keys []*key, now time.Time) *key {
+	var active *key
+	for _, k := range keys {
+		if k.stored.RetiredAt != nil || now.Before(k.stored.ActivatesAt) {
+			continue
+		}
+		if active == nil || k.stored.ActivatesAt.After(active.stored.ActivatesAt) {
+			active = k
+		}
+	}
+	return active
+}
+
+// nextKey returns the published key activated after now, or nil.
+func nextKey(keys []*key, now time.Time) *key {
+	for _, k := range keys {
+		if now.Before(k.stored.ActivatesAt) {
+			return k
+		}
+	}
+	return nil
+}
+
+// SigningKey implements tokens.KeySource.
+func (m *Manager) SigningKey(_ context.Context) (*tokens.Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	active := activeKey(m.keys, time.Now())
+	if active == nil {
+		return nil, errNoActiveKey
+	}
+	return active.signer, nil
+}
+
+// VerificationKey implements tokens.KeySource. Active and retired keys are returned, published keys did not sign tokens yet.
+func (m *Manager) VerificationKey(_ context.Context, kid string) (crypto.PublicKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	for _, k := range m.keys {
+		if k.stored.ID == kid && !now.Before(k.stored.ActivatesAt) {
+			return k.signer.Signer.Public(), nil
+		}
+	}
+	return nil, tokens.ErrUnknownKey
+}
+
+// ActiveKeyID implements health.SigningKeySource.
+func (m *Manager) ActiveKeyID(ctx context.Context) (string, error) {
+	k, err := m.SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	return k.ID, nil
+}
+
+// JWKS returns the public keys of all published, active and retired keys.
+func (m *Manager) JWKS() (tokens.JWKSet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	set := tokens.JWKSet{Keys: make([]tokens.JWK, 0, len(m.keys))}
+	for _, k := range m.keys {
+		jwk, err := tokens.NewJWK(k.signer.Signer.Public(), k.stored.ID)
+		if err != nil {
+			return tokens.JWKSet{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
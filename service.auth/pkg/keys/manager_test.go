@@ -0,0 +1,272 @@
+package keys
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// testConfig is a rotation schedule of a key every 10 hours, published an hour ahead and retired for 2 hours.
+var testConfig = Config{
+	Algorithm:        tokens.AlgEdDSA,
+	RotationInterval: 10 * time.Hour,
+	PublishAhead:     time.Hour,
+	MaxTokenTTL:      2 * time.Hour,
+}
+
+// newTestManager returns a Manager of the keys in store with testConfig.
+func newTestManager(t *testing.T, store Store) *Manager {
+	t.Helper()
+	m, err := NewManager(testConfig, store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// rotate applies the schedule of m at now, failing the test on errors.
+func rotate(t *testing.T, m *Manager, now time.Time) {
+	t.Helper()
+	if err := m.Rotate(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// keyIDs returns the IDs of the keys of m.
+func keyIDs(m *Manager) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ids := make([]string, len(m.keys))
+	for i, k := range m.keys {
+		ids[i] = k.stored.ID
+	}
+	return ids
+}
+
+// activeID returns the ID of the key of m active at now.
+func activeID(m *Manager, now time.Time) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if k := activeKey(m.keys, now); k != nil {
+		return k.stored.ID
+	}
+	return ""
+}
+
+func TestNewManagerValidates(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := NewManager(Config{Algorithm: "HS256"}, NewMemoryStore(), logger); err == nil {
+		t.Error("unsupported algorithm accepted")
+	}
+	if _, err := NewManager(Config{RotationInterval: time.Hour, PublishAhead: time.Hour}, NewMemoryStore(), logger); err == nil {
+		t.Error("rotation interval not longer than the publish ahead time accepted")
+	}
+}
+
+func TestManagerRotationSchedule(t *testing.T) {
+	m := newTestManager(t, NewMemoryStore())
+	start := time.Now()
+
+	// the first key is activated immediately
+	rotate(t, m, start)
+	first := activeID(m, start)
+	if first == "" || len(keyIDs(m)) != 1 {
+		t.Fatalf("keys %v, active %q after the first rotation", keyIDs(m), first)
+	}
+
+	// the next key is published PublishAhead before the rotation, but does not sign yet
+	rotate(t, m, start.Add(9*time.Hour))
+	if ids := keyIDs(m); len(ids) != 2 {
+		t.Fatalf("keys %v, want the next key published", ids)
+	}
+	if got := activeID(m, start.Add(9*time.Hour)); got != first {
+		t.Errorf("active key %q before the rotation, want %q", got, first)
+	}
+	next := keyIDs(m)[1]
+
+	// the next key becomes active, the first is retired but still published
+	rotate(t, m, start.Add(10*time.Hour))
+	if got := activeID(m, start.Add(10*time.Hour)); got != next {
+		t.Errorf("active key %q after the rotation, want %q", got, next)
+	}
+	if ids := keyIDs(m); len(ids) != 2 {
+		t.Errorf("keys %v, want the retired key kept", ids)
+	}
+
+	// the retired key is removed once all its tokens expired
+	rotate(t, m, start.Add(12*time.Hour))
+	if ids := keyIDs(m); len(ids) != 1 || ids[0] != next {
+		t.Errorf("keys %v, want only %q", ids, next)
+	}
+}
+
+func TestManagerOverdueRotationPublishesAhead(t *testing.T) {
+	m := newTestManager(t, NewMemoryStore())
+	start := time.Now()
+	rotate(t, m, start)
+	first := activeID(m, start)
+
+	// after a downtime past the rotation, the next key is still published before it signs tokens
+	late := start.Add(20 * time.Hour)
+	rotate(t, m, late)
+	if got := activeID(m, late); got != first {
+		t.Errorf("active key %q right after the downtime, want %q", got, first)
+	}
+	rotate(t, m, late.Add(time.Hour))
+	if got := activeID(m, late.Add(time.Hour)); got == first || got == "" {
+		t.Errorf("active key %q after PublishAhead, want the next key", got)
+	}
+}
+
+func TestManagerKeySource(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, NewMemoryStore())
+	if _, err := m.SigningKey(ctx); err == nil {
+		t.Error("signing key before the first rotation")
+	}
+	rotate(t, m, time.Now())
+	// publish the next key
+	rotate(t, m, time.Now().Add(9*time.Hour+time.Minute))
+
+	key, err := m.SigningKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := m.ActiveKeyID(ctx); err != nil || id != key.ID {
+		t.Errorf("ActiveKeyID = %q, %v, want %q", id, err, key.ID)
+	}
+	if _, err := m.VerificationKey(ctx, key.ID); err != nil {
+		t.Errorf("active key not usable for verification: %v", err)
+	}
+	next := keyIDs(m)[1]
+	if _, err := m.VerificationKey(ctx, next); err != tokens.ErrUnknownKey {
+		t.Errorf("published key usable for verification: %v", err)
+	}
+	set, err := m.JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 2 {
+		t.Errorf("JWKS has %d keys, want the active and the published key", len(set.Keys))
+	}
+}
+
+func TestManagerReloadsKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	m := newTestManager(t, store)
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	id := activeID(m, time.Now())
+
+	restarted := newTestManager(t, store)
+	if err := restarted.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Stop(ctx)
+	if got := activeID(restarted, time.Now()); got != id {
+		t.Errorf("active key %q after a restart, want %q", got, id)
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	m := newTestManager(t, NewMemoryStore())
+	start := time.Now()
+	rotate(t, m, start)
+	e := echo.New()
+	e.GET(JWKSPath, JWKSHandler(m, time.Minute))
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, JWKSPath, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("")
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderCacheControl) != "public, max-age=60" {
+		t.Fatalf("got %d, Cache-Control %q", rec.Code, rec.Header().Get(echo.HeaderCacheControl))
+	}
+	etag := rec.Header().Get("ETag")
+	if rec := get(etag); rec.Code != http.StatusNotModified {
+		t.Errorf("conditional request: got %d, want 304", rec.Code)
+	}
+
+	// a rotation changes the JWKS
+	rotate(t, m, start.Add(9*time.Hour))
+	rec = get(etag)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("JWKS not updated after a rotation: got %d, ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestJWKSConcurrentWithRotation(t *testing.T) {
+	m := newTestManager(t, NewMemoryStore())
+	start := time.Now()
+	rotate(t, m, start)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// every step publishes, activates or removes a key, invalidating the JWKS cache
+		for i := 1; i <= 2000; i++ {
+			if err := m.Rotate(context.Background(), start.Add(time.Duration(i)*5*time.Hour)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				if _, _, err := m.jwks.get(m); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock between the rotation and the JWKS cache")
+	}
+
+	// the cached JWKS matches the keys after the last rotation
+	body, _, err := m.jwks.get(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.jwks.invalidate()
+	fresh, _, err := m.jwks.get(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != string(fresh) {
+		t.Error("stale JWKS cached after concurrent rotations")
+	}
+}
@@ -0,0 +1,221 @@
+package keys
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The argon2id parameters the key encryption key is derived from the secret with.
+const (
+	kdfMemory      uint32 = 64 * 1024
+	kdfIterations  uint32 = 3
+	kdfParallelism uint8  = 2
+	kdfSaltLength         = 16
+)
+
+// storeVersion is the version of the format of the key file.
+const storeVersion = 1
+
+// errWrongSecret is returned if the key file could not be decrypted.
+var errWrongSecret = errors.New("decrypting signing keys failed, wrong secret or corrupted file")
+
+// StoredKey is a struct that represents a signing key and its rotation state.
+type StoredKey struct {
+	ID        string `json:"id"`
+	Algorithm string `json:"alg"`
+	// PrivateKey is the PKCS #8 DER encoded private key.
+	PrivateKey []byte    `json:"privateKey"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// ActivatesAt is the time the key starts to sign tokens. Before, it is only published.
+	ActivatesAt time.Time `json:"activatesAt"`
+	// RetiredAt is the time the key stopped to sign tokens. Retired keys are published until all tokens they signed expired.
+	RetiredAt *time.Time `json:"retiredAt,omitempty"`
+}
+
+// Store is an interface that represents the storage of the signing keys.
+type Store interface {
+	// Load returns the stored keys, or no keys if none were saved yet.
+	Load(ctx context.Context) ([]StoredKey, error)
+	// Save replaces the stored keys.
+	Save(ctx context.Context, keys []StoredKey) error
+}
+
+// MemoryStore is an implementation of Store keeping the keys in memory, so they are lost on restart.
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys []StoredKey
+}
+
+// interface guard
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(_ context.Context) ([]StoredKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.keys), nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, keys []StoredKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = slices.Clone(keys)
+	return nil
+}
+
+// FileStore is an implementation of Store keeping the keys in a file encrypted with AES-256-GCM.
+// The encryption key is derived from a secret with argon2id and a random salt stored in the file.
+type FileStore struct {
+	path   string
+	secret []byte
+}
+
+// interface guard
+var _ Store = (*FileStore)(nil)
+
+// encryptedFile is the content of the key file.
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+/*
+NewFileStore returns a FileStore keeping the keys in the file at path, encrypted with a key derived from secret.
+
+Parameters:
+  - path: The path of the key file. Its directory is created if it does not exist.
+  - secret: The secret the keys are encrypted with, e.g. read from a mounted secret.
+
+Returns:
+  - *FileStore: The created store.
+  - error: An error if the secret is empty.
+*/
+func NewFileStore(path string, secret []byte) (*FileStore, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("signing key store requires an encryption secret")
+	}
+	return &FileStore{path: path, secret: secret}, nil
+}
+
+// Load implements Store.
+func (s *FileStore) Load(_ context.Context) ([]StoredKey, error) {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f encryptedFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decoding signing key file: %w", err)
+	}
+	if f.Version != storeVersion {
+		return nil, fmt.Errorf("unsupported signing key file version %d", f.Version)
+	}
+	aead, err := s.aead(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return nil, errWrongSecret
+	}
+	var keys []StoredKey
+	if err := json.Unmarshal(plaintext, &keys); err != nil {
+		return nil, fmt.Errorf("decoding signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Save implements Store. The file is replaced atomically, so a crash does not leave a partial file behind.
+func (s *FileStore) Save(_ context.Context, keys []StoredKey) error {
+	plaintext, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	f := encryptedFile{Version: storeVersion, Salt: make([]byte, kdfSaltLength)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	aead, err := s.aead(f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, plaintext, nil)
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	// the keys are on disk before they replace the old file, so a crash does not leave an empty key file
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes the entries of dir to disk, so a renamed file survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// aead returns the AES-256-GCM cipher with the key derived from the secret and salt.
+func (s *FileStore) aead(salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey(s.secret, salt, kdfIterations, kdfMemory, kdfParallelism, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
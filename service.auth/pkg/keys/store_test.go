@@ -0,0 +1,64 @@
+package keys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys", "signing-keys.json")
+	store, err := NewFileStore(path, []byte("hunter2hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := store.Load(ctx); err != nil || len(keys) != 0 {
+		t.Fatalf("Load of a missing file = %v, %v, want no keys", keys, err)
+	}
+
+	retiredAt := time.Now().UTC().Truncate(time.Second)
+	want := []StoredKey{
+		{ID: "a", Algorithm: "EdDSA", PrivateKey: []byte("private-a"), CreatedAt: retiredAt, ActivatesAt: retiredAt, RetiredAt: &retiredAt},
+		{ID: "b", Algorithm: "EdDSA", PrivateKey: []byte("private-b"), CreatedAt: retiredAt, ActivatesAt: retiredAt},
+	}
+	if err := store.Save(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "private-a") || strings.Contains(string(raw), `"id"`) {
+		t.Error("key file is not encrypted")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	reopened, err := NewFileStore(path, []byte("hunter2hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reopened.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "a" || string(got[1].PrivateKey) != "private-b" || got[0].RetiredAt == nil || !got[0].RetiredAt.Equal(retiredAt) {
+		t.Errorf("Load = %+v", got)
+	}
+
+	wrong, err := NewFileStore(path, []byte("wrong secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Load(ctx); err != errWrongSecret {
+		t.Errorf("Load with a wrong secret: got %v, want errWrongSecret", err)
+	}
+	if _, err := NewFileStore(path, nil); err == nil {
+		t.Error("store without a secret accepted")
+	}
+}
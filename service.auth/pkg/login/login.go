@@ -0,0 +1,245 @@
+/*
+Package login contains the password login of the auth service. A login verifies the credentials of a user
+and returns a JWT access token and an opaque refresh token, which is rotated on every refresh.
+The tokens are issued to the login client, a client ID reserved for the password login. The registered OAuth2 clients
+get their tokens from the token endpoint instead, see package oidc.
+*/
+package login
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+// The paths of the login endpoints relative to the versioned API group.
+const (
+	LoginPath   string = "/login"
+	RefreshPath string = "/refresh"
+)
+
+// The grant types the issued tokens are recorded with, named after the OAuth2 grants.
+const (
+	GrantTypePassword     string = "password"
+	GrantTypeRefreshToken string = "refresh_token"
+)
+
+// The token types the issued tokens are recorded with.
+const (
+	TokenTypeAccess  string = "access"
+	TokenTypeRefresh string = "refresh"
+)
+
+// Request is a struct that represents the body of a login.
+type Request struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+}
+
+// RefreshRequest is a struct that represents the body of a refresh.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+// Response is a struct that represents the issued tokens, in the format of an OAuth2 token response.
+type Response struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Handler serves the login and refresh endpoints.
+type Handler struct {
+	users          *users.Service
+	clientRegistry clients.Registry
+	issuer         *tokens.Issuer
+	refreshTokens  *tokens.RefreshTokens
+	metrics        *telemetry.Metrics
+	logger         *slog.Logger
+	clientID       string
+}
+
+/*
+NewHandler creates the Handler of the login and refresh endpoints.
+
+Parameters:
+  - users: The users authenticated by the login.
+  - clientRegistry: The registered OAuth2 clients, whose refresh tokens are rejected.
+  - issuer: The issuer of the access tokens.
+  - refreshTokens: The refresh tokens, shared with the token endpoint.
+  - metrics: The metrics the logins and issued tokens are recorded with.
+  - logger: The logger of the failed logins and detected refresh token reuse.
+  - clientID: The login client, the client_id claim of the issued tokens. It must not be the ID of a registered client.
+
+Returns:
+  - *Handler: The created handler, registered with RegisterRoutes.
+*/
+func NewHandler(users *users.Service, clientRegistry clients.Registry, issuer *tokens.Issuer, refreshTokens *tokens.RefreshTokens, metrics *telemetry.Metrics, logger *slog.Logger, clientID string) *Handler {
+	return &Handler{
+		users:          users,
+		clientRegistry: clientRegistry,
+		issuer:         issuer,
+		refreshTokens:  refreshTokens,
+		metrics:        metrics,
+		logger:         logger,
+		clientID:       clientID,
+	}
+}
+
+/*
+RegisterRoutes registers the endpoints of h on g.
+
+The following endpoints are registered:
+  - POST /login: Verifies the username and password of a Request and returns a Response.
+  - POST /refresh: Rotates the refresh token of a RefreshRequest and returns a Response with new tokens.
+    Only refresh tokens of the login client are accepted, the tokens of the registered clients are refreshed at the token endpoint.
+
+Both accept JSON or form bodies. Failed logins and refreshes return 401.
+*/
+func (h *Handler) RegisterRoutes(g *echo.Group) {
+	g.POST(LoginPath, h.login)
+	g.POST(RefreshPath, h.refresh)
+}
+
+func (h *Handler) login(c echo.Context) error {
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	u, err := h.users.Authenticate(ctx, req.Username, req.Password)
+	if err != nil {
+		h.metrics.Logins.Add(ctx, 1, telemetry.OutcomeKey.String(telemetry.OutcomeFailure))
+		if errors.Is(err, users.ErrInvalidCredentials) {
+			h.logger.Info("login failed", "username", req.Username)
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		return err
+	}
+	h.metrics.Logins.Add(ctx, 1, telemetry.OutcomeKey.String(telemetry.OutcomeSuccess))
+	return h.issue(c, GrantTypePassword, u, tokens.RefreshGrant{
+		Subject:  u.ID,
+		ClientID: h.clientID,
+		AuthTime: time.Now(),
+	}, "")
+}
+
+func (h *Handler) refresh(c echo.Context) error {
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	current, err := h.refreshTokens.Lookup(ctx, req.RefreshToken)
+	if err != nil && !errors.Is(err, tokens.ErrInvalidToken) {
+		return err
+	}
+	// a reused token is not active, but it has to be rotated to revoke its family
+	if current != nil {
+		owned, err := h.ownsToken(ctx, current)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return echo.NewHTTPError(http.StatusUnauthorized, tokens.ErrInvalidToken.Error())
+		}
+	}
+	refreshToken, old, err := h.refreshTokens.Rotate(ctx, req.RefreshToken)
+	if errors.Is(err, tokens.ErrTokenReused) {
+		h.metrics.RefreshTokensReused.Add(ctx, 1)
+		h.logger.Warn("refresh token reused, token family revoked", "user_id", old.Subject, "client_id", old.ClientID, "family_id", old.FamilyID)
+		return echo.NewHTTPError(http.StatusUnauthorized, tokens.ErrInvalidToken.Error())
+	}
+	if errors.Is(err, tokens.ErrInvalidToken) {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	owned, err := h.ownsToken(ctx, old)
+	if err != nil || !owned {
+		// the token was rotated between the lookup and the rotation, e.g. at the token endpoint
+		if revokeErr := h.refreshTokens.Revoke(context.WithoutCancel(ctx), refreshToken); revokeErr != nil {
+			h.logger.Error("revoking refresh token of another client failed", "client_id", old.ClientID, "error", revokeErr.Error())
+		}
+		if err != nil {
+			return err
+		}
+		return echo.NewHTTPError(http.StatusUnauthorized, tokens.ErrInvalidToken.Error())
+	}
+	// the user may have been disabled or deleted since the login
+	u, err := h.users.Get(ctx, old.Subject)
+	if errors.Is(err, users.ErrNotFound) || (err == nil && u.Disabled) {
+		if err := h.refreshTokens.Revoke(context.WithoutCancel(ctx), refreshToken); err != nil {
+			h.logger.Error("revoking refresh token of disabled user failed", "user_id", old.Subject, "error", err.Error())
+		}
+		return echo.NewHTTPError(http.StatusUnauthorized, tokens.ErrInvalidToken.Error())
+	}
+	if err != nil {
+		return err
+	}
+	return h.issue(c, GrantTypeRefreshToken, u, tokens.RefreshGrant{
+		Subject:  old.Subject,
+		ClientID: old.ClientID,
+		AuthTime: old.AuthTime,
+	}, refreshToken)
+}
+
+// ownsToken reports whether t was issued by the password login: to the login client, if no registered client took its ID.
+// The tokens of the registered clients are bound to the client authentication of the token endpoint and must not be refreshed here.
+func (h *Handler) ownsToken(ctx context.Context, t *tokens.RefreshToken) (bool, error) {
+	if t.ClientID != h.clientID {
+		return false, nil
+	}
+	_, err := h.clientRegistry.Get(ctx, t.ClientID)
+	if errors.Is(err, clients.ErrNotFound) {
+		return true, nil
+	}
+	if err == nil {
+		h.logger.Error("login client is a registered client, refreshes are rejected", "client_id", t.ClientID)
+	}
+	return false, err
+}
+
+// issue writes the Response with a new access token for u and grant. If refreshToken is empty, a new token family is started.
+func (h *Handler) issue(c echo.Context, grantType string, u *users.User, grant tokens.RefreshGrant, refreshToken string) error {
+	ctx := c.Request().Context()
+	accessToken, claims, err := h.issuer.IssueAccessToken(ctx, tokens.AccessTokenRequest{
+		Subject:  u.ID,
+		ClientID: grant.ClientID,
+		AuthTime: grant.AuthTime,
+		Roles:    u.Roles,
+		Username: u.Username,
+		Email:    u.Email,
+	})
+	if err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		if refreshToken, err = h.refreshTokens.Issue(ctx, grant); err != nil {
+			return err
+		}
+	}
+	h.metrics.TokensIssued.Add(ctx, 1, telemetry.GrantTypeKey.String(grantType), telemetry.TokenTypeKey.String(TokenTypeAccess))
+	h.metrics.TokensIssued.Add(ctx, 1, telemetry.GrantTypeKey.String(grantType), telemetry.TokenTypeKey.String(TokenTypeRefresh))
+
+	// the tokens must not be cached, see RFC 6749 section 5.1
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+	return c.JSON(http.StatusOK, Response{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    claims.ExpiresAt - claims.IssuedAt,
+		RefreshToken: refreshToken,
+	})
+}
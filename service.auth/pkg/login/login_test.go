@@ -0,0 +1,212 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+// testClientID is the login client of the tests.
+const testClientID string = "login"
+
+// testEnv is a login handler with its dependencies.
+type testEnv struct {
+	e             *echo.Echo
+	users         *users.Service
+	clients       *clients.MemoryRepository
+	issuer        *tokens.Issuer
+	refreshTokens *tokens.RefreshTokens
+	user          *users.User
+}
+
+// newTestEnv returns a login handler with the user alice, whose password is "correct horse".
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	userService, err := users.NewService(users.NewMemoryRepository(), users.NewHasher(users.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := userService.Create(ctx, users.CreateInput{Username: "alice", Password: "correct horse", Roles: []string{"reader"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := tokens.GenerateKey(tokens.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := tokens.NewStaticKeySource(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := telemetry.NewMetrics(noop.NewMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &testEnv{
+		e:       echo.New(),
+		users:   userService,
+		clients: clients.NewMemoryRepository(),
+		issuer: tokens.NewIssuer(keys, tokens.AccessTokenConfig{
+			Issuer:   "https://auth.example.com",
+			Audience: []string{"cc-microsvcs"},
+			TTL:      5 * time.Minute,
+		}),
+		refreshTokens: tokens.NewRefreshTokens(tokens.NewMemoryRefreshStore(), tokens.RefreshTokenConfig{
+			TTL:       time.Hour,
+			FamilyTTL: 24 * time.Hour,
+		}),
+		user: u,
+	}
+	NewHandler(env.users, env.clients, env.issuer, env.refreshTokens, metrics, logger, testClientID).RegisterRoutes(env.e.Group(""))
+	return env
+}
+
+// post sends a form to path and returns the recorded response.
+func (env *testEnv) post(path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	env.e.ServeHTTP(rec, req)
+	return rec
+}
+
+// response decodes the Response of rec, failing the test if the request did not succeed.
+func (env *testEnv) response(t *testing.T, rec *httptest.ResponseRecorder) Response {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// login logs in alice and returns the issued tokens.
+func (env *testEnv) login(t *testing.T) Response {
+	t.Helper()
+	return env.response(t, env.post(LoginPath, url.Values{"username": {"alice"}, "password": {"correct horse"}}))
+}
+
+func TestLogin(t *testing.T) {
+	env := newTestEnv(t)
+	rec := env.post(LoginPath, url.Values{"username": {"alice"}, "password": {"correct horse"}})
+	if got := rec.Header().Get(echo.HeaderCacheControl); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	resp := env.response(t, rec)
+	if resp.TokenType != "Bearer" || resp.ExpiresIn != 300 || resp.RefreshToken == "" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	claims, err := env.issuer.VerifyAccessToken(context.Background(), resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != env.user.ID || claims.ClientID != testClientID || claims.Scope != "" || len(claims.Roles) != 1 {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	for name, form := range map[string]url.Values{
+		"wrong password": {"username": {"alice"}, "password": {"wrong horse"}},
+		"unknown user":   {"username": {"bob"}, "password": {"correct horse"}},
+	} {
+		if rec := env.post(LoginPath, form); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", name, rec.Code)
+		}
+	}
+}
+
+func TestLoginIgnoresRequestedClientAndScope(t *testing.T) {
+	env := newTestEnv(t)
+	resp := env.response(t, env.post(LoginPath, url.Values{
+		"username":  {"alice"},
+		"password":  {"correct horse"},
+		"client_id": {"gateway"},
+		"scope":     {"admin"},
+	}))
+	claims, err := env.issuer.VerifyAccessToken(context.Background(), resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ClientID != testClientID || claims.Scope != "" {
+		t.Errorf("login issued a token for client %q and scope %q", claims.ClientID, claims.Scope)
+	}
+}
+
+func TestRefreshRotates(t *testing.T) {
+	env := newTestEnv(t)
+	first := env.login(t)
+	second := env.response(t, env.post(RefreshPath, url.Values{"refresh_token": {first.RefreshToken}}))
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token not rotated")
+	}
+
+	// presenting the rotated token again revokes the family
+	if rec := env.post(RefreshPath, url.Values{"refresh_token": {first.RefreshToken}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("reused token: got %d, want 401", rec.Code)
+	}
+	if rec := env.post(RefreshPath, url.Values{"refresh_token": {second.RefreshToken}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("token of a revoked family: got %d, want 401", rec.Code)
+	}
+	if rec := env.post(RefreshPath, url.Values{"refresh_token": {"unknown"}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token: got %d, want 401", rec.Code)
+	}
+}
+
+func TestRefreshRejectsDisabledUser(t *testing.T) {
+	env := newTestEnv(t)
+	resp := env.login(t)
+	disabled := true
+	if _, err := env.users.Update(context.Background(), env.user.ID, users.UpdateInput{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := env.post(RefreshPath, url.Values{"refresh_token": {resp.RefreshToken}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want 401", rec.Code)
+	}
+}
+
+func TestRefreshRejectsTokensOfOtherClients(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	// a token issued to a registered client at the token endpoint
+	token, err := env.refreshTokens.Issue(ctx, tokens.RefreshGrant{Subject: env.user.ID, ClientID: "gateway", AuthTime: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := env.post(RefreshPath, url.Values{"refresh_token": {token}}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", rec.Code)
+	}
+	// the rejected token was not rotated, so its client can still use it
+	if _, err := env.refreshTokens.Lookup(ctx, token); err != nil {
+		t.Errorf("rejected token no longer active: %v", err)
+	}
+}
+
+func TestRefreshRejectsLoginClientRegisteredAsClient(t *testing.T) {
+	env := newTestEnv(t)
+	resp := env.login(t)
+	if err := env.clients.Create(context.Background(), &clients.Client{ID: testClientID, AuthMethod: clients.AuthMethodSecretBasic}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := env.post(RefreshPath, url.Values{"refresh_token": {resp.RefreshToken}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want 401", rec.Code)
+	}
+}
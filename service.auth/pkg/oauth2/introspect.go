@@ -0,0 +1,126 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/login"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// tokenTypeUnknown is the token type the introspections of inactive tokens are recorded with.
+const tokenTypeUnknown string = "unknown"
+
+// IntrospectionResponse is a struct that represents the state of a token, see RFC 7662 section 2.2.
+// Inactive tokens only have Active set, so no information about them is disclosed.
+type IntrospectionResponse struct {
+	Active    bool            `json:"active"`
+	Scope     string          `json:"scope,omitempty"`
+	ClientID  string          `json:"client_id,omitempty"`
+	Username  string          `json:"username,omitempty"`
+	TokenType string          `json:"token_type,omitempty"`
+	ExpiresAt int64           `json:"exp,omitempty"`
+	IssuedAt  int64           `json:"iat,omitempty"`
+	Subject   string          `json:"sub,omitempty"`
+	Audience  tokens.Audience `json:"aud,omitempty"`
+	Issuer    string          `json:"iss,omitempty"`
+	JWTID     string          `json:"jti,omitempty"`
+}
+
+func (h *Handler) introspect(c echo.Context) error {
+	start := time.Now()
+	token := c.FormValue("token")
+	if token == "" {
+		return WriteError(c, http.StatusBadRequest, ErrorInvalidRequest, "missing token")
+	}
+	ctx := c.Request().Context()
+	resp, tokenType, err := h.lookup(ctx, token, c.FormValue("token_type_hint"))
+	if err != nil {
+		return err
+	}
+	result := telemetry.ResultMiss
+	if resp.Active {
+		result = telemetry.ResultHit
+	}
+	h.metrics.Introspections.Add(ctx, 1, telemetry.ResultKey.String(result), telemetry.TokenTypeKey.String(tokenType))
+	h.metrics.IntrospectionDuration.Record(ctx, time.Since(start).Seconds(), telemetry.ResultKey.String(result))
+	noStore(c)
+	return c.JSON(http.StatusOK, resp)
+}
+
+// lookup returns the introspection of token and its token type. The token type of hint is tried first.
+func (h *Handler) lookup(ctx context.Context, token, hint string) (IntrospectionResponse, string, error) {
+	lookups := []func(context.Context, string) (IntrospectionResponse, bool, error){h.lookupAccessToken, h.lookupRefreshToken}
+	types := []string{login.TokenTypeAccess, login.TokenTypeRefresh}
+	if hint == TokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+		types[0], types[1] = types[1], types[0]
+	}
+	for i, lookup := range lookups {
+		resp, ok, err := lookup(ctx, token)
+		if err != nil {
+			return IntrospectionResponse{}, "", err
+		}
+		if ok {
+			return resp, types[i], nil
+		}
+	}
+	return IntrospectionResponse{Active: false}, tokenTypeUnknown, nil
+}
+
+// lookupAccessToken returns the introspection of token if it is a valid access token that was not revoked.
+func (h *Handler) lookupAccessToken(ctx context.Context, token string) (IntrospectionResponse, bool, error) {
+	claims, err := h.issuer.VerifyAccessToken(ctx, token)
+	if errors.Is(err, tokens.ErrInvalidToken) {
+		return IntrospectionResponse{}, false, nil
+	}
+	if err != nil {
+		return IntrospectionResponse{}, false, err
+	}
+	if h.revocations.Revoked(claims.JWTID) {
+		return IntrospectionResponse{}, false, nil
+	}
+	return IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Username:  claims.Username,
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		JWTID:     claims.JWTID,
+	}, true, nil
+}
+
+// lookupRefreshToken returns the introspection of token if it is an active refresh token.
+func (h *Handler) lookupRefreshToken(ctx context.Context, token string) (IntrospectionResponse, bool, error) {
+	t, err := h.refreshTokens.Lookup(ctx, token)
+	if errors.Is(err, tokens.ErrInvalidToken) {
+		return IntrospectionResponse{}, false, nil
+	}
+	if err != nil {
+		return IntrospectionResponse{}, false, err
+	}
+	expiresAt := t.ExpiresAt
+	if t.FamilyExpiresAt.Before(expiresAt) {
+		expiresAt = t.FamilyExpiresAt
+	}
+	return IntrospectionResponse{
+		Active:    true,
+		Scope:     t.Scope,
+		ClientID:  t.ClientID,
+		TokenType: TokenTypeHintRefreshToken,
+		ExpiresAt: expiresAt.Unix(),
+		IssuedAt:  t.CreatedAt.Unix(),
+		Subject:   t.Subject,
+		Issuer:    h.issuer.IssuerURL(),
+	}, true, nil
+}
@@ -0,0 +1,67 @@
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntrospectAccessToken(t *testing.T) {
+	env := newTestEnv(t)
+	token, claims := env.accessToken(t, testSPA)
+
+	rec := env.post(IntrospectPath, testGateway, url.Values{"token": {token}})
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Error("introspection cacheable")
+	}
+	var resp IntrospectionResponse
+	decode(t, rec, http.StatusOK, &resp)
+	if !resp.Active || resp.Subject != "alice" || resp.ClientID != testSPA || resp.JWTID != claims.JWTID || resp.TokenType != "Bearer" {
+		t.Errorf("unexpected introspection %+v", resp)
+	}
+
+	if err := env.revocations.Revoke(context.Background(), claims.JWTID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		t.Fatal(err)
+	}
+	rec = env.post(IntrospectPath, testGateway, url.Values{"token": {token}})
+	if body := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || body != `{"active":false}` {
+		t.Errorf("revoked token: got %d %s, want only active false", rec.Code, body)
+	}
+}
+
+func TestIntrospectRefreshToken(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.refreshToken(t, testSPA)
+
+	// the hint only changes the order of the lookups
+	for _, hint := range []string{"", TokenTypeHintAccessToken, TokenTypeHintRefreshToken} {
+		var resp IntrospectionResponse
+		decode(t, env.post(IntrospectPath, testGateway, url.Values{"token": {token}, "token_type_hint": {hint}}), http.StatusOK, &resp)
+		if !resp.Active || resp.TokenType != TokenTypeHintRefreshToken || resp.ClientID != testSPA || resp.Issuer != "https://auth.example.com" {
+			t.Errorf("hint %q: unexpected introspection %+v", hint, resp)
+		}
+	}
+
+	var resp IntrospectionResponse
+	decode(t, env.post(IntrospectPath, testGateway, url.Values{"token": {"unknown"}}), http.StatusOK, &resp)
+	if resp.Active {
+		t.Error("unknown token active")
+	}
+}
+
+func TestIntrospectRejectsPublicClients(t *testing.T) {
+	env := newTestEnv(t)
+	token, _ := env.accessToken(t, testSPA)
+	var resp ErrorResponse
+	decode(t, env.post(IntrospectPath, testSPA, url.Values{"token": {token}}), http.StatusUnauthorized, &resp)
+	if resp.Error != ErrorUnauthorizedClient {
+		t.Errorf("got error %q, want %q", resp.Error, ErrorUnauthorizedClient)
+	}
+	decode(t, env.post(IntrospectPath, testGateway, url.Values{}), http.StatusBadRequest, &resp)
+	if resp.Error != ErrorInvalidRequest {
+		t.Errorf("missing token: got error %q, want %q", resp.Error, ErrorInvalidRequest)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Package oauth2 contains the endpoints of the authorization server used by the resource servers and clients:
+the token endpoint of RFC 6749 with the client_credentials grant, the token introspection of RFC 7662
+and the token revocation of RFC 7009. The endpoints are restricted to the clients authenticated by clients.Middleware.
+*/
+package oauth2
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/revocation"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// The paths of the endpoints relative to the versioned API group.
+const (
+	TokenPath      string = "/token"
+	IntrospectPath string = "/introspect"
+	RevokePath     string = "/revoke"
+	// AdminPath is the path of the emergency revocation of the admins.
+	AdminPath string = "/admin/revocations"
+)
+
+// The token type hints of RFC 7009 section 2.1, also used by the introspection.
+const (
+	TokenTypeHintAccessToken  string = "access_token"
+	TokenTypeHintRefreshToken string = "refresh_token"
+)
+
+// The error codes of RFC 6749 section 5.2 and RFC 8707 section 2 returned by the endpoints.
+const (
+	ErrorInvalidRequest       string = "invalid_request"
+	ErrorInvalidGrant         string = "invalid_grant"
+	ErrorUnauthorizedClient   string = "unauthorized_client"
+	ErrorUnsupportedGrantType string = "unsupported_grant_type"
+	ErrorInvalidScope         string = "invalid_scope"
+	ErrorInvalidTarget        string = "invalid_target"
+)
+
+// ErrorResponse is a struct that represents an OAuth2 error response, see RFC 6749 section 5.2.
+type ErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Handler serves the token, introspection and revocation endpoints.
+type Handler struct {
+	issuer        *tokens.Issuer
+	refreshTokens *tokens.RefreshTokens
+	revocations   *revocation.List
+	metrics       *telemetry.Metrics
+	logger        *slog.Logger
+	// grants are the grants of the token endpoint registered with RegisterGrant.
+	grants map[string]GrantFunc
+}
+
+// GrantFunc is a function that handles a grant of the token endpoint for the authenticated client.
+// It writes the TokenResponse with WriteTokenResponse, or the error with WriteError.
+type GrantFunc func(c echo.Context, client *clients.Client) error
+
+/*
+NewHandler creates the Handler of the token, introspection and revocation endpoints.
+
+Parameters:
+  - issuer: The issuer of the access tokens.
+  - refreshTokens: The refresh tokens.
+  - revocations: The list the revoked access tokens are added to.
+  - metrics: The metrics the issued tokens, introspections and revocations are recorded with.
+  - logger: The logger of the revocations.
+
+Returns:
+  - *Handler: The created handler, registered with RegisterRoutes.
+*/
+func NewHandler(issuer *tokens.Issuer, refreshTokens *tokens.RefreshTokens, revocations *revocation.List, metrics *telemetry.Metrics, logger *slog.Logger) *Handler {
+	return &Handler{
+		issuer:        issuer,
+		refreshTokens: refreshTokens,
+		revocations:   revocations,
+		metrics:       metrics,
+		logger:        logger,
+		grants:        make(map[string]GrantFunc),
+	}
+}
+
+// RegisterGrant adds the grant with grantType to the token endpoint, e.g. the authorization_code grant of OpenID Connect.
+// It has to be called before the routes are served.
+func (h *Handler) RegisterGrant(grantType string, grant GrantFunc) {
+	h.grants[grantType] = grant
+}
+
+/*
+RegisterRoutes registers the endpoints of h on g. All endpoints require the client authentication of authenticator.
+The introspection and the revocation list disclose token information, so they are restricted to confidential clients.
+
+The following endpoints are registered:
+  - POST /token: Issues a TokenResponse for the grant of the form body: client_credentials and the grants of RegisterGrant.
+  - POST /introspect: Returns the IntrospectionResponse of the token of the form body, see RFC 7662.
+  - POST /revoke: Revokes the token of the form body if it was issued to the client, see RFC 7009.
+  - GET /revocations: Returns the revoked access tokens after the epoch and sequence number of the previous page, see revocation.Handler.
+*/
+func (h *Handler) RegisterRoutes(g *echo.Group, authenticator *clients.Authenticator) {
+	auth := clients.Middleware(authenticator)
+	g.POST(TokenPath, h.token, auth)
+	g.POST(IntrospectPath, h.introspect, auth, confidential)
+	g.POST(RevokePath, h.revoke, auth)
+	g.GET(revocation.Path, revocation.Handler(h.revocations), auth, confidential)
+}
+
+// confidential rejects the public clients authenticated by clients.Middleware.
+func confidential(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if clients.FromContext(c).Public() {
+			return WriteError(c, http.StatusUnauthorized, ErrorUnauthorizedClient, "public clients are not allowed")
+		}
+		return next(c)
+	}
+}
+
+/*
+RegisterAdminRoutes registers the emergency revocation on g, which has to be restricted to the admins.
+
+The following endpoints are registered:
+  - POST /: Revokes the token of an AdminRevocationRequest regardless of the client it was issued to,
+    or the access token with its jti and exp.
+
+Example usage:
+
+	h.RegisterAdminRoutes(api.Group(oauth2.AdminPath, adminAuth))
+*/
+func (h *Handler) RegisterAdminRoutes(g *echo.Group) {
+	g.POST("", h.adminRevoke)
+}
+
+// WriteError writes the OAuth2 error response with status.
+func WriteError(c echo.Context, status int, code, description string) error {
+	return c.JSON(status, ErrorResponse{Error: code, ErrorDescription: description})
+}
+
+// WriteTokenResponse writes the TokenResponse, which must not be cached, see RFC 6749 section 5.1.
+func WriteTokenResponse(c echo.Context, resp TokenResponse) error {
+	noStore(c)
+	return c.JSON(http.StatusOK, resp)
+}
+
+// noStore sets the headers preventing the caching of responses holding token information.
+func noStore(c echo.Context) {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+}
@@ -0,0 +1,181 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/revocation"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// The clients of the tests: gateway is confidential, spa is public.
+const (
+	testGateway       string = "gateway"
+	testGatewaySecret string = "gateway-secret-of-at-least-32-bytes"
+	testSPA           string = "spa"
+)
+
+// testEnv is an oauth2 handler with its dependencies.
+type testEnv struct {
+	e             *echo.Echo
+	issuer        *tokens.Issuer
+	refreshTokens *tokens.RefreshTokens
+	revocations   *revocation.List
+	handler       *Handler
+}
+
+// newTestEnv returns an oauth2 handler with the clients gateway and spa, and the admin routes under AdminPath.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := clients.NewService(clients.NewMemoryRepository(), time.Hour, logger)
+	for _, input := range []clients.CreateInput{
+		{
+			ID:         testGateway,
+			GrantTypes: []string{clients.GrantTypeClientCredentials},
+			Scopes:     []string{"orders:read", "orders:write"},
+			Audiences:  []string{"orders", "billing"},
+			Secret:     testGatewaySecret,
+		},
+		{
+			ID:           testSPA,
+			AuthMethod:   clients.AuthMethodNone,
+			GrantTypes:   []string{clients.GrantTypeAuthorizationCode, clients.GrantTypeRefreshToken},
+			RedirectURIs: []string{"https://spa.example.com/callback"},
+		},
+	} {
+		if _, _, err := svc.Create(ctx, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signer, err := tokens.GenerateKey(tokens.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := tokens.NewStaticKeySource(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := telemetry.NewMetrics(noop.NewMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &testEnv{
+		e: echo.New(),
+		issuer: tokens.NewIssuer(keys, tokens.AccessTokenConfig{
+			Issuer:   "https://auth.example.com",
+			Audience: []string{"cc-microsvcs"},
+			TTL:      5 * time.Minute,
+		}),
+		refreshTokens: tokens.NewRefreshTokens(tokens.NewMemoryRefreshStore(), tokens.RefreshTokenConfig{
+			TTL:       time.Hour,
+			FamilyTTL: 24 * time.Hour,
+		}),
+		revocations: revocation.NewList(),
+	}
+	env.handler = NewHandler(env.issuer, env.refreshTokens, env.revocations, metrics, logger)
+	env.handler.RegisterRoutes(env.e.Group(""), clients.NewAuthenticator(svc.Registry(), []string{"https://auth.example.com"}))
+	env.handler.RegisterAdminRoutes(env.e.Group(AdminPath))
+	return env
+}
+
+// post sends a form to path, authenticated as clientID, and returns the recorded response.
+// The gateway authenticates with client_secret_basic, the other clients send their client_id.
+func (env *testEnv) post(path, clientID string, form url.Values) *httptest.ResponseRecorder {
+	if clientID != testGateway {
+		form.Set("client_id", clientID)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	if clientID == testGateway {
+		req.SetBasicAuth(testGateway, testGatewaySecret)
+	}
+	rec := httptest.NewRecorder()
+	env.e.ServeHTTP(rec, req)
+	return rec
+}
+
+// accessToken issues an access token to clientID.
+func (env *testEnv) accessToken(t *testing.T, clientID string) (string, *tokens.AccessClaims) {
+	t.Helper()
+	token, claims, err := env.issuer.IssueAccessToken(context.Background(), tokens.AccessTokenRequest{Subject: "alice", ClientID: clientID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, claims
+}
+
+// refreshToken issues a refresh token to clientID.
+func (env *testEnv) refreshToken(t *testing.T, clientID string) string {
+	t.Helper()
+	token, err := env.refreshTokens.Issue(context.Background(), tokens.RefreshGrant{Subject: "alice", ClientID: clientID, AuthTime: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// decode decodes the JSON body of rec into v, failing the test if the status is not want.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, want int, v any) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("got %d, want %d: %s", rec.Code, want, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoutesRequireClientAuthentication(t *testing.T) {
+	env := newTestEnv(t)
+	for _, path := range []string{TokenPath, IntrospectPath, RevokePath} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("token=x"))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.SetBasicAuth(testGateway, "wrong")
+		rec := httptest.NewRecorder()
+		env.e.ServeHTTP(rec, req)
+		var resp ErrorResponse
+		decode(t, rec, http.StatusUnauthorized, &resp)
+		if resp.Error != "invalid_client" || rec.Header().Get(echo.HeaderWWWAuthenticate) == "" {
+			t.Errorf("%s: unexpected response %+v", path, resp)
+		}
+	}
+}
+
+func TestRevocationListRestrictedToConfidentialClients(t *testing.T) {
+	env := newTestEnv(t)
+	_, claims := env.accessToken(t, testGateway)
+	if err := env.revocations.Revoke(context.Background(), claims.JWTID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, revocation.Path, nil)
+	req.SetBasicAuth(testGateway, testGatewaySecret)
+	rec := httptest.NewRecorder()
+	env.e.ServeHTTP(rec, req)
+	var page revocation.Page
+	decode(t, rec, http.StatusOK, &page)
+	if len(page.Entries) != 1 || page.Entries[0].JTI != claims.JWTID {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	rec = httptest.NewRecorder()
+	env.e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, revocation.Path+"?client_id="+testSPA, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("public client: got %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,121 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/login"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// errOtherClient is returned if a client revokes a token issued to another client.
+var errOtherClient = errors.New("the token was issued to another client")
+
+// AdminRevocationRequest is a struct that represents the body of an emergency revocation.
+// Either Token, or JTI and ExpiresAt of an access token, e.g. taken from the logs, have to be set.
+type AdminRevocationRequest struct {
+	Token string `json:"token"`
+	JTI   string `json:"jti"`
+	// ExpiresAt is the exp claim of the access token with JTI, in seconds since the epoch.
+	ExpiresAt int64 `json:"exp"`
+}
+
+func (h *Handler) revoke(c echo.Context) error {
+	token := c.FormValue("token")
+	if token == "" {
+		return WriteError(c, http.StatusBadRequest, ErrorInvalidRequest, "missing token")
+	}
+	client := clients.FromContext(c)
+	err := h.revokeToken(c.Request().Context(), token, c.FormValue("token_type_hint"), client.ID)
+	if errors.Is(err, errOtherClient) {
+		return WriteError(c, http.StatusBadRequest, ErrorUnauthorizedClient, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	// invalid and already revoked tokens are answered with 200 as well, see RFC 7009 section 2.2
+	noStore(c)
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) adminRevoke(c echo.Context) error {
+	var req AdminRevocationRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	switch {
+	case req.Token != "":
+		if err := h.revokeToken(ctx, req.Token, "", ""); err != nil {
+			return err
+		}
+	case req.JTI != "" && req.ExpiresAt > 0:
+		if err := h.revokeAccessToken(ctx, req.JTI, time.Unix(req.ExpiresAt, 0)); err != nil {
+			return err
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "token, or jti and exp are required")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// revokeToken revokes token, trying the token type of hint first. If clientID is not empty, the token has to be issued to it.
+// Invalid tokens are ignored.
+func (h *Handler) revokeToken(ctx context.Context, token, hint, clientID string) error {
+	if hint == TokenTypeHintRefreshToken {
+		if ok, err := h.revokeRefreshToken(ctx, token, clientID); ok || err != nil {
+			return err
+		}
+	}
+	claims, err := h.issuer.VerifyAccessToken(ctx, token)
+	if err == nil {
+		if clientID != "" && claims.ClientID != clientID {
+			return errOtherClient
+		}
+		return h.revokeAccessToken(ctx, claims.JWTID, time.Unix(claims.ExpiresAt, 0))
+	}
+	if !errors.Is(err, tokens.ErrInvalidToken) {
+		return err
+	}
+	if hint != TokenTypeHintRefreshToken {
+		_, err := h.revokeRefreshToken(ctx, token, clientID)
+		return err
+	}
+	return nil
+}
+
+// revokeAccessToken adds the access token with jti to the revocation list.
+func (h *Handler) revokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := h.revocations.Revoke(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	h.metrics.Revocations.Add(ctx, 1, telemetry.TokenTypeKey.String(login.TokenTypeAccess))
+	h.logger.Info("access token revoked", "jti", jti)
+	return nil
+}
+
+// revokeRefreshToken revokes the family of token and reports whether it was an active refresh token.
+func (h *Handler) revokeRefreshToken(ctx context.Context, token, clientID string) (bool, error) {
+	t, err := h.refreshTokens.Lookup(ctx, token)
+	if errors.Is(err, tokens.ErrInvalidToken) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if clientID != "" && t.ClientID != clientID {
+		return true, errOtherClient
+	}
+	if err := h.refreshTokens.Revoke(ctx, token); err != nil {
+		return true, err
+	}
+	h.metrics.Revocations.Add(ctx, 1, telemetry.TokenTypeKey.String(login.TokenTypeRefresh))
+	h.logger.Info("refresh token family revoked", "family_id", t.FamilyID, "client_id", t.ClientID)
+	return true, nil
+}
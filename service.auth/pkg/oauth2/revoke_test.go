@@ -0,0 +1,115 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+func TestRevokeAccessToken(t *testing.T) {
+	env := newTestEnv(t)
+	token, claims := env.accessToken(t, testSPA)
+
+	// a token of another client is not revoked
+	var resp ErrorResponse
+	decode(t, env.post(RevokePath, testGateway, url.Values{"token": {token}}), http.StatusBadRequest, &resp)
+	if resp.Error != ErrorUnauthorizedClient || env.revocations.Revoked(claims.JWTID) {
+		t.Fatalf("revoked the token of another client: %+v", resp)
+	}
+
+	if rec := env.post(RevokePath, testSPA, url.Values{"token": {token}}); rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if !env.revocations.Revoked(claims.JWTID) {
+		t.Error("token not revoked")
+	}
+	// revoking again and revoking invalid tokens succeeds, see RFC 7009 section 2.2
+	for _, token := range []string{token, "unknown"} {
+		if rec := env.post(RevokePath, testSPA, url.Values{"token": {token}}); rec.Code != http.StatusOK {
+			t.Errorf("got %d, want 200", rec.Code)
+		}
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	token := env.refreshToken(t, testSPA)
+	rotated, _, err := env.refreshTokens.Rotate(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := env.post(RevokePath, testGateway, url.Values{"token": {rotated}, "token_type_hint": {TokenTypeHintRefreshToken}}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("other client: got %d, want 400", rec.Code)
+	}
+	if _, err := env.refreshTokens.Lookup(ctx, rotated); err != nil {
+		t.Fatalf("token revoked by another client: %v", err)
+	}
+
+	for _, hint := range []string{"", TokenTypeHintRefreshToken} {
+		token := env.refreshToken(t, testSPA)
+		if rec := env.post(RevokePath, testSPA, url.Values{"token": {token}, "token_type_hint": {hint}}); rec.Code != http.StatusOK {
+			t.Fatalf("hint %q: got %d: %s", hint, rec.Code, rec.Body)
+		}
+		if _, err := env.refreshTokens.Lookup(ctx, token); !errors.Is(err, tokens.ErrInvalidToken) {
+			t.Errorf("hint %q: got %v, want ErrInvalidToken", hint, err)
+		}
+	}
+	if rec := env.post(RevokePath, testSPA, url.Values{"token": {rotated}}); rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := env.refreshTokens.Lookup(ctx, rotated); !errors.Is(err, tokens.ErrInvalidToken) {
+		t.Errorf("token not revoked: %v", err)
+	}
+}
+
+func TestAdminRevoke(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	adminRevoke := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, AdminPath, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		env.e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// tokens of any client are revoked
+	token, claims := env.accessToken(t, testSPA)
+	if code := adminRevoke(`{"token":"` + token + `"}`); code != http.StatusNoContent {
+		t.Fatalf("access token: got %d, want 204", code)
+	}
+	if !env.revocations.Revoked(claims.JWTID) {
+		t.Error("access token not revoked")
+	}
+	refreshToken := env.refreshToken(t, testGateway)
+	if code := adminRevoke(`{"token":"` + refreshToken + `"}`); code != http.StatusNoContent {
+		t.Fatalf("refresh token: got %d, want 204", code)
+	}
+	if _, err := env.refreshTokens.Lookup(ctx, refreshToken); !errors.Is(err, tokens.ErrInvalidToken) {
+		t.Errorf("refresh token not revoked: %v", err)
+	}
+
+	// an access token is revoked with its jti and exp, e.g. taken from the logs
+	_, claims = env.accessToken(t, testGateway)
+	if code := adminRevoke(`{"jti":"` + claims.JWTID + `","exp":` + strconv.FormatInt(claims.ExpiresAt, 10) + `}`); code != http.StatusNoContent {
+		t.Fatalf("jti: got %d, want 204", code)
+	}
+	if !env.revocations.Revoked(claims.JWTID) {
+		t.Error("jti not revoked")
+	}
+
+	if code := adminRevoke(`{"jti":"x"}`); code != http.StatusBadRequest {
+		t.Errorf("jti without exp: got %d, want 400", code)
+	}
+}
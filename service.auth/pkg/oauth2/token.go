@@ -0,0 +1,93 @@
+package oauth2
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/login"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// TokenResponse is a struct that represents the issued tokens, see RFC 6749 section 5.1.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// IDToken is the OpenID Connect ID token of the grants with the openid scope.
+	IDToken string `json:"id_token,omitempty"`
+}
+
+func (h *Handler) token(c echo.Context) error {
+	switch grantType := c.FormValue("grant_type"); grantType {
+	case "":
+		return WriteError(c, http.StatusBadRequest, ErrorInvalidRequest, "missing grant_type")
+	case clients.GrantTypeClientCredentials:
+		return h.clientCredentials(c)
+	default:
+		grant, ok := h.grants[grantType]
+		if !ok {
+			return WriteError(c, http.StatusBadRequest, ErrorUnsupportedGrantType, "unsupported grant_type "+grantType)
+		}
+		client := clients.FromContext(c)
+		if !client.HasGrantType(grantType) {
+			return WriteError(c, http.StatusBadRequest, ErrorUnauthorizedClient, "the client may not use "+grantType)
+		}
+		return grant(c, client)
+	}
+}
+
+// clientCredentials issues an access token to the authenticated client itself, see RFC 6749 section 4.4.
+func (h *Handler) clientCredentials(c echo.Context) error {
+	client := clients.FromContext(c)
+	if !client.HasGrantType(clients.GrantTypeClientCredentials) {
+		return WriteError(c, http.StatusBadRequest, ErrorUnauthorizedClient, "the client may not use client_credentials")
+	}
+	scopes := strings.Fields(c.FormValue("scope"))
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return WriteError(c, http.StatusBadRequest, ErrorInvalidScope, "scope "+scope+" is not allowed")
+		}
+	}
+	params, err := c.FormParams()
+	if err != nil {
+		return WriteError(c, http.StatusBadRequest, ErrorInvalidRequest, "invalid form body")
+	}
+	// the audiences are requested with the resource parameter of RFC 8707 or the audience parameter of RFC 8693
+	// clients without allowed audiences get the default audience of the issuer
+	audiences := append(slices.Clone(params["resource"]), params["audience"]...)
+	if len(audiences) == 0 {
+		audiences = client.Audiences
+	}
+	for _, audience := range audiences {
+		if !slices.Contains(client.Audiences, audience) {
+			return WriteError(c, http.StatusBadRequest, ErrorInvalidTarget, "audience "+audience+" is not allowed")
+		}
+	}
+	ctx := c.Request().Context()
+	accessToken, claims, err := h.issuer.IssueAccessToken(ctx, tokens.AccessTokenRequest{
+		Subject:  client.ID,
+		ClientID: client.ID,
+		Scope:    strings.Join(scopes, " "),
+		Audience: audiences,
+	})
+	if err != nil {
+		return err
+	}
+	h.metrics.TokensIssued.Add(ctx, 1, telemetry.GrantTypeKey.String(clients.GrantTypeClientCredentials), telemetry.TokenTypeKey.String(login.TokenTypeAccess))
+	return WriteTokenResponse(c, TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   claims.ExpiresAt - claims.IssuedAt,
+		Scope:       claims.Scope,
+	})
+}
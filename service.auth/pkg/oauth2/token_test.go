@@ -0,0 +1,89 @@
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+)
+
+func TestClientCredentials(t *testing.T) {
+	env := newTestEnv(t)
+
+	rec := env.post(TokenPath, testGateway, url.Values{"grant_type": {clients.GrantTypeClientCredentials}})
+	if rec.Header().Get(echo.HeaderCacheControl) != "no-store" || rec.Header().Get("Pragma") != "no-cache" {
+		t.Error("token response cacheable")
+	}
+	var resp TokenResponse
+	decode(t, rec, http.StatusOK, &resp)
+	if resp.TokenType != "Bearer" || resp.ExpiresIn != 300 || resp.RefreshToken != "" || resp.Scope != "orders:read orders:write" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	claims, err := env.issuer.VerifyAccessToken(context.Background(), resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != testGateway || claims.ClientID != testGateway || len(claims.Audience) != 2 {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	// the scopes and audiences are narrowed with the scope, resource and audience parameters
+	resp = TokenResponse{}
+	decode(t, env.post(TokenPath, testGateway, url.Values{
+		"grant_type": {clients.GrantTypeClientCredentials},
+		"scope":      {"orders:read"},
+		"resource":   {"orders"},
+	}), http.StatusOK, &resp)
+	claims, err = env.issuer.VerifyAccessToken(context.Background(), resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Scope != "orders:read" || len(claims.Audience) != 1 || claims.Audience[0] != "orders" {
+		t.Errorf("unexpected narrowed claims %+v", claims)
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	env := newTestEnv(t)
+	for _, tc := range []struct {
+		name     string
+		clientID string
+		form     url.Values
+		want     string
+	}{
+		{"no grant", testGateway, url.Values{}, ErrorInvalidRequest},
+		{"unknown grant", testGateway, url.Values{"grant_type": {"password"}}, ErrorUnsupportedGrantType},
+		{"unregistered grant", testSPA, url.Values{"grant_type": {clients.GrantTypeClientCredentials}}, ErrorUnauthorizedClient},
+		{"unallowed scope", testGateway, url.Values{"grant_type": {clients.GrantTypeClientCredentials}, "scope": {"admin"}}, ErrorInvalidScope},
+		{"unallowed audience", testGateway, url.Values{"grant_type": {clients.GrantTypeClientCredentials}, "audience": {"users"}}, ErrorInvalidTarget},
+	} {
+		var resp ErrorResponse
+		decode(t, env.post(TokenPath, tc.clientID, tc.form), http.StatusBadRequest, &resp)
+		if resp.Error != tc.want {
+			t.Errorf("%s: got error %q, want %q", tc.name, resp.Error, tc.want)
+		}
+	}
+}
+
+func TestRegisterGrant(t *testing.T) {
+	env := newTestEnv(t)
+	env.handler.RegisterGrant(clients.GrantTypeAuthorizationCode, func(c echo.Context, client *clients.Client) error {
+		return WriteTokenResponse(c, TokenResponse{AccessToken: "code-of-" + client.ID, TokenType: "Bearer"})
+	})
+
+	var resp TokenResponse
+	decode(t, env.post(TokenPath, testSPA, url.Values{"grant_type": {clients.GrantTypeAuthorizationCode}}), http.StatusOK, &resp)
+	if resp.AccessToken != "code-of-"+testSPA {
+		t.Errorf("grant not called: %+v", resp)
+	}
+	// clients not registered for the grant can not use it
+	var errResp ErrorResponse
+	decode(t, env.post(TokenPath, testGateway, url.Values{"grant_type": {clients.GrantTypeAuthorizationCode}}), http.StatusBadRequest, &errResp)
+	if errResp.Error != ErrorUnauthorizedClient {
+		t.Errorf("got error %q, want %q", errResp.Error, ErrorUnauthorizedClient)
+	}
+}
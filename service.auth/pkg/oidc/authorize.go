@@ -0,0 +1,291 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+// The prompt values of the authorization request, see OpenID Connect Core 1.0 section 3.1.2.1.
+const (
+	PromptNone          string = "none"
+	PromptLogin         string = "login"
+	PromptConsent       string = "consent"
+	PromptSelectAccount string = "select_account"
+)
+
+// The error codes of the authorization endpoint, see RFC 6749 section 4.1.2.1 and OpenID Connect Core 1.0 section 3.1.2.6.
+const (
+	ErrorAccessDenied            string = "access_denied"
+	ErrorUnsupportedResponseType string = "unsupported_response_type"
+	ErrorLoginRequired           string = "login_required"
+	ErrorConsentRequired         string = "consent_required"
+	ErrorRequestNotSupported     string = "request_not_supported"
+)
+
+// CodeChallengeMethodS256 is the only supported PKCE method, see RFC 7636 section 4.2.
+const CodeChallengeMethodS256 string = "S256"
+
+// pkcePattern are the valid code verifiers and S256 code challenges, see RFC 7636 section 4.1.
+var pkcePattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// authRequest is a struct that represents a pending authorization request while the user logs in and consents.
+type authRequest struct {
+	ID string
+	// CSRF is the token of the login and consent forms.
+	CSRF          string
+	ClientID      string
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	Nonce         string
+	CodeChallenge string
+	Prompt        []string
+	// MaxAge is the maximum time since the login of the user in seconds, or -1.
+	MaxAge    int
+	LoginHint string
+	// LoggedIn and Consented are set once the user logged in and consented during the request.
+	LoggedIn  bool
+	Consented bool
+	// UserID is the user of the session the request is continued with.
+	UserID string
+}
+
+// authCode is a struct that represents an issued authorization code.
+type authCode struct {
+	ClientID      string
+	RedirectURI   string
+	UserID        string
+	SessionID     string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	// Used is set once the code was exchanged. AccessJTI, AccessExpiresAt and RefreshToken identify the tokens issued for it,
+	// which are revoked if the code is used again.
+	Used            bool
+	AccessJTI       string
+	AccessExpiresAt time.Time
+	RefreshToken    string
+}
+
+func (p *Provider) authorize(c echo.Context) error {
+	ctx := c.Request().Context()
+	client, err := p.client(ctx, c.FormValue("client_id"))
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return p.messagePage(c, http.StatusBadRequest, "Unknown application", "The application you came from is not registered.")
+	}
+	// errors are only redirected to registered URIs, so the endpoint can not be used as an open redirect
+	redirectURI := c.FormValue("redirect_uri")
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		return p.messagePage(c, http.StatusBadRequest, "Invalid redirect URI", "The application sent a redirect URI it did not register.")
+	}
+	state := c.FormValue("state")
+	fail := func(code, description string) error {
+		return p.redirectError(c, redirectURI, state, code, description)
+	}
+	if c.FormValue("request") != "" || c.FormValue("request_uri") != "" {
+		return fail(ErrorRequestNotSupported, "request objects are not supported")
+	}
+	if c.FormValue("response_type") != "code" {
+		return fail(ErrorUnsupportedResponseType, "only the code response type is supported")
+	}
+	if mode := c.FormValue("response_mode"); mode != "" && mode != "query" {
+		return fail(oauth2.ErrorInvalidRequest, "only the query response mode is supported")
+	}
+	if !client.HasGrantType(clients.GrantTypeAuthorizationCode) {
+		return fail(oauth2.ErrorUnauthorizedClient, "the client may not use the authorization code grant")
+	}
+	// PKCE is required for all clients, see RFC 9700 section 2.1.1
+	challenge := c.FormValue("code_challenge")
+	if !pkcePattern.MatchString(challenge) || c.FormValue("code_challenge_method") != CodeChallengeMethodS256 {
+		return fail(oauth2.ErrorInvalidRequest, "a S256 code_challenge is required")
+	}
+	var scopes []string
+	for _, scope := range strings.Fields(c.FormValue("scope")) {
+		if !slices.Contains(standardScopes, scope) && !slices.Contains(client.Scopes, scope) {
+			return fail(oauth2.ErrorInvalidScope, "scope "+scope+" is not allowed")
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	prompt := strings.Fields(c.FormValue("prompt"))
+	for _, v := range prompt {
+		if v != PromptNone && v != PromptLogin && v != PromptConsent && v != PromptSelectAccount {
+			return fail(oauth2.ErrorInvalidRequest, "unsupported prompt "+v)
+		}
+	}
+	if slices.Contains(prompt, PromptNone) && len(prompt) > 1 {
+		return fail(oauth2.ErrorInvalidRequest, "prompt none can not be combined with other values")
+	}
+	maxAge := -1
+	if s := c.FormValue("max_age"); s != "" {
+		if maxAge, err = strconv.Atoi(s); err != nil || maxAge < 0 {
+			return fail(oauth2.ErrorInvalidRequest, "invalid max_age")
+		}
+	}
+	id, err := randomToken()
+	if err != nil {
+		return err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return err
+	}
+	req := &authRequest{
+		ID:            id,
+		CSRF:          csrf,
+		ClientID:      client.ID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		State:         state,
+		Nonce:         c.FormValue("nonce"),
+		CodeChallenge: challenge,
+		Prompt:        prompt,
+		MaxAge:        maxAge,
+		LoginHint:     c.FormValue("login_hint"),
+	}
+	return p.proceed(c, req, p.currentSession(c))
+}
+
+/*
+proceed continues the authorization request req with the login session sess. The user is sent to the login page
+if there is no session or it is too old, and to the consent page if the client needs consent to the requested scopes.
+Otherwise, an authorization code is issued and the user is redirected back to the client.
+*/
+func (p *Provider) proceed(c echo.Context, req *authRequest, sess *session) error {
+	ctx := c.Request().Context()
+	client, err := p.client(ctx, req.ClientID)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		p.requests.delete(req.ID)
+		return p.messagePage(c, http.StatusBadRequest, "Unknown application", "The application you came from is not registered.")
+	}
+	none := slices.Contains(req.Prompt, PromptNone)
+	reauthenticate := slices.Contains(req.Prompt, PromptLogin) || slices.Contains(req.Prompt, PromptSelectAccount)
+	if sess != nil && !req.LoggedIn && (reauthenticate || (req.MaxAge >= 0 && time.Since(sess.AuthTime) > time.Duration(req.MaxAge)*time.Second)) {
+		sess = nil
+	}
+	if sess != nil {
+		if _, err := p.activeUser(ctx, sess.UserID); errors.Is(err, users.ErrInvalidCredentials) {
+			sess = nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if sess == nil {
+		if none {
+			return p.redirectError(c, req.RedirectURI, req.State, ErrorLoginRequired, "the user is not logged in")
+		}
+		p.requests.put(req.ID, *req, time.Now().Add(p.config.RequestTTL))
+		return c.Redirect(http.StatusFound, p.endpoint(LoginPath)+"?request="+url.QueryEscape(req.ID))
+	}
+	req.UserID = sess.UserID
+	if !client.SkipConsent && !req.Consented && (slices.Contains(req.Prompt, PromptConsent) || !p.consented(sess.UserID, client.ID, req.Scopes)) {
+		if none {
+			return p.redirectError(c, req.RedirectURI, req.State, ErrorConsentRequired, "the user did not consent")
+		}
+		p.requests.put(req.ID, *req, time.Now().Add(p.config.RequestTTL))
+		return c.Redirect(http.StatusFound, p.endpoint(ConsentPath)+"?request="+url.QueryEscape(req.ID))
+	}
+	p.requests.delete(req.ID)
+	code, err := randomToken()
+	if err != nil {
+		return err
+	}
+	p.codes.put(hashToken(code), authCode{
+		ClientID:      client.ID,
+		RedirectURI:   req.RedirectURI,
+		UserID:        sess.UserID,
+		SessionID:     sess.ID,
+		Scopes:        req.Scopes,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      sess.AuthTime,
+	}, time.Now().Add(p.config.CodeTTL))
+	return c.Redirect(http.StatusFound, p.redirectURL(req.RedirectURI, url.Values{"code": {code}}, req.State))
+}
+
+// redirectError redirects the user back to the client with the error code, see RFC 6749 section 4.1.2.1.
+func (p *Provider) redirectError(c echo.Context, redirectURI, state, code, description string) error {
+	return c.Redirect(http.StatusFound, p.redirectURL(redirectURI, url.Values{"error": {code}, "error_description": {description}}, state))
+}
+
+// redirectURL returns redirectURI with params, the state and the iss parameter of RFC 9207 added to its query.
+func (p *Provider) redirectURL(redirectURI string, params url.Values, state string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		// the registered redirect URIs are validated
+		return redirectURI
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	q.Set("iss", p.config.Issuer)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// client returns the enabled client with id, or nil if it does not exist or is disabled.
+func (p *Provider) client(ctx context.Context, id string) (*clients.Client, error) {
+	if id == "" {
+		return nil, nil
+	}
+	client, err := p.clients.Get(ctx, id)
+	if errors.Is(err, clients.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.Disabled {
+		return nil, nil
+	}
+	return client, nil
+}
+
+// activeUser returns the user with id, or users.ErrInvalidCredentials if it was deleted or disabled.
+func (p *Provider) activeUser(ctx context.Context, id string) (*users.User, error) {
+	u, err := p.users.Get(ctx, id)
+	if errors.Is(err, users.ErrNotFound) || (err == nil && u.Disabled) {
+		return nil, users.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// verifyPKCE reports whether verifier matches the S256 challenge, see RFC 7636 section 4.6.
+func verifyPKCE(verifier, challenge string) bool {
+	if !pkcePattern.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
@@ -0,0 +1,237 @@
+package oidc
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/server"
+)
+
+// authorizeQuery returns a valid authorization request of clientID with the challenge of testVerifier.
+func authorizeQuery(clientID, redirectURI string) url.Values {
+	return url.Values{
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"scope":                 {"openid profile openid"},
+		"state":                 {"state"},
+		"nonce":                 {"nonce"},
+		"code_challenge":        {challenge(testVerifier)},
+		"code_challenge_method": {CodeChallengeMethodS256},
+	}
+}
+
+// with returns a copy of q with key set to value, or removed if value is empty.
+func with(q url.Values, key, value string) url.Values {
+	c := url.Values{}
+	for k, v := range q {
+		c[k] = v
+	}
+	if value == "" {
+		c.Del(key)
+	} else {
+		c.Set(key, value)
+	}
+	return c
+}
+
+func TestAuthorizeRejectsUnknownClientAndRedirectURI(t *testing.T) {
+	env := newTestEnv(t)
+	q := authorizeQuery(testConsole, testConsoleRedirect)
+	for name, q := range map[string]url.Values{
+		"unknown client":           with(q, "client_id", "unknown"),
+		"missing client":           with(q, "client_id", ""),
+		"unregistered redirect":    with(q, "redirect_uri", "https://evil.example.com/callback"),
+		"redirect of other client": with(q, "redirect_uri", testAppRedirect),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// errors are not redirected to unverified URIs
+			if rec := env.get(AuthorizePath, q, ""); rec.Code != http.StatusBadRequest {
+				t.Errorf("got %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRedirectsErrors(t *testing.T) {
+	env := newTestEnv(t)
+	q := authorizeQuery(testConsole, testConsoleRedirect)
+	tests := []struct {
+		name  string
+		query url.Values
+		want  string
+	}{
+		{name: "request object", query: with(q, "request", "eyJ"), want: ErrorRequestNotSupported},
+		{name: "request URI", query: with(q, "request_uri", "https://console.example.com/request"), want: ErrorRequestNotSupported},
+		{name: "implicit flow", query: with(q, "response_type", "token"), want: ErrorUnsupportedResponseType},
+		{name: "fragment response mode", query: with(q, "response_mode", "fragment"), want: oauth2.ErrorInvalidRequest},
+		{name: "missing challenge", query: with(q, "code_challenge", ""), want: oauth2.ErrorInvalidRequest},
+		{name: "short challenge", query: with(q, "code_challenge", "abc"), want: oauth2.ErrorInvalidRequest},
+		{name: "plain challenge", query: with(q, "code_challenge_method", "plain"), want: oauth2.ErrorInvalidRequest},
+		{name: "unknown scope", query: with(q, "scope", "openid orders:write"), want: oauth2.ErrorInvalidScope},
+		{name: "unknown prompt", query: with(q, "prompt", "create"), want: oauth2.ErrorInvalidRequest},
+		{name: "prompt none with login", query: with(q, "prompt", "none login"), want: oauth2.ErrorInvalidRequest},
+		{name: "invalid max_age", query: with(q, "max_age", "-1"), want: oauth2.ErrorInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := redirect(t, env.get(AuthorizePath, tt.query, ""), testConsoleRedirect).Query()
+			if query.Get("error") != tt.want || query.Get("state") != "state" || query.Get("iss") != testIssuer {
+				t.Errorf("got %v, want error %s", query, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRedirectsToLogin(t *testing.T) {
+	env := newTestEnv(t)
+	q := with(authorizeQuery(testConsole, testConsoleRedirect), "login_hint", "alice")
+	id := redirect(t, env.get(AuthorizePath, q, ""), testIssuer+server.V1Path+LoginPath).Query().Get("request")
+	req, ok := env.provider.requests.get(id)
+	if !ok {
+		t.Fatal("authorization request not stored")
+	}
+	if req.ClientID != testConsole || req.State != "state" || req.Nonce != "nonce" || strings.Join(req.Scopes, " ") != "openid profile" {
+		t.Errorf("got request %+v", req)
+	}
+
+	// prompt=none does not show the login page
+	query := redirect(t, env.get(AuthorizePath, with(q, "prompt", PromptNone), ""), testConsoleRedirect).Query()
+	if query.Get("error") != ErrorLoginRequired {
+		t.Errorf("got %v, want error %s", query, ErrorLoginRequired)
+	}
+}
+
+func TestAuthorizeWithSession(t *testing.T) {
+	env := newTestEnv(t)
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	cookie := env.session(t, authTime)
+	q := authorizeQuery(testConsole, testConsoleRedirect)
+	query := redirect(t, env.get(AuthorizePath, q, cookie), testConsoleRedirect).Query()
+	if query.Get("state") != "state" || query.Get("iss") != testIssuer {
+		t.Errorf("got %v", query)
+	}
+	code, ok := env.provider.codes.get(hashToken(query.Get("code")))
+	if !ok {
+		t.Fatal("authorization code not stored")
+	}
+	if code.ClientID != testConsole || code.UserID != env.user.ID || code.Nonce != "nonce" || !code.AuthTime.Equal(authTime) ||
+		strings.Join(code.Scopes, " ") != "openid profile" || code.CodeChallenge != challenge(testVerifier) {
+		t.Errorf("got code %+v", code)
+	}
+
+	// the session is too old or the client asks to log in again
+	for name, q := range map[string]url.Values{
+		"max_age":        with(q, "max_age", "30"),
+		"prompt login":   with(q, "prompt", PromptLogin),
+		"select account": with(q, "prompt", PromptSelectAccount),
+	} {
+		t.Run(name, func(t *testing.T) {
+			redirect(t, env.get(AuthorizePath, q, cookie), testIssuer+server.V1Path+LoginPath)
+		})
+	}
+	redirect(t, env.get(AuthorizePath, with(q, "max_age", "3600"), cookie), testConsoleRedirect)
+}
+
+func TestAuthorizeDisabledUser(t *testing.T) {
+	env := newTestEnv(t)
+	cookie := env.session(t, time.Now())
+	env.disable(t)
+	redirect(t, env.get(AuthorizePath, authorizeQuery(testConsole, testConsoleRedirect), cookie), testIssuer+server.V1Path+LoginPath)
+}
+
+func TestLogin(t *testing.T) {
+	env := newTestEnv(t)
+	id := redirect(t, env.get(AuthorizePath, authorizeQuery(testConsole, testConsoleRedirect), ""), testIssuer+server.V1Path+LoginPath).Query().Get("request")
+	req, _ := env.provider.requests.get(id)
+	if rec := env.get(LoginPath, url.Values{"request": {id}}, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), req.CSRF) {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	form := url.Values{"request": {id}, "csrf": {req.CSRF}, "username": {"alice"}, "password": {"wrong"}}
+	if rec := env.post(server.V1Path+LoginPath, form); rec.Code != http.StatusUnauthorized {
+		t.Errorf("got %d for a wrong password", rec.Code)
+	}
+	if rec := env.post(server.V1Path+LoginPath, with(with(form, "password", "correct horse"), "csrf", "forged")); rec.Code != http.StatusForbidden {
+		t.Errorf("got %d for a forged form", rec.Code)
+	}
+
+	rec := env.post(server.V1Path+LoginPath, with(form, "password", "correct horse"))
+	code := redirect(t, rec, testConsoleRedirect).Query().Get("code")
+	var resp map[string]any
+	decode(t, env.exchange(testConsole, code, testConsoleRedirect, testVerifier), http.StatusOK, &resp)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly || !cookies[0].Secure || cookies[0].Path != server.V1Path {
+		t.Fatalf("got cookies %+v", cookies)
+	}
+	if _, ok := env.provider.sessions.get(hashToken(cookies[0].Value)); !ok {
+		t.Error("session not stored")
+	}
+
+	// the request is completed
+	if rec := env.post(server.V1Path+LoginPath, with(form, "password", "correct horse")); rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d for a completed request", rec.Code)
+	}
+}
+
+func TestConsent(t *testing.T) {
+	env := newTestEnv(t)
+	cookie := env.session(t, time.Now())
+	q := authorizeQuery(testApp, testAppRedirect)
+	query := redirect(t, env.get(AuthorizePath, with(q, "prompt", PromptNone), cookie), testAppRedirect).Query()
+	if query.Get("error") != ErrorConsentRequired {
+		t.Errorf("got %v, want error %s", query, ErrorConsentRequired)
+	}
+	id := redirect(t, env.get(AuthorizePath, q, cookie), testIssuer+server.V1Path+ConsentPath).Query().Get("request")
+	req, _ := env.provider.requests.get(id)
+	if rec := env.get(ConsentPath, url.Values{"request": {id}}, cookie); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), scopeDescriptions[ScopeProfile]) {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	// the consent page of another user's request is not shown
+	env.provider.sessions.put(hashToken("bob"), session{ID: "bob", UserID: "bob", AuthTime: time.Now()}, time.Now().Add(time.Hour))
+	if rec := env.get(ConsentPath, url.Values{"request": {id}}, "bob"); rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d for another session", rec.Code)
+	}
+
+	form := url.Values{"request": {id}, "csrf": {req.CSRF}, "action": {"allow"}}
+	query = redirect(t, env.postWithCookie(server.V1Path+ConsentPath, form, cookie), testAppRedirect).Query()
+	if query.Get("code") == "" {
+		t.Fatalf("got %v, want a code", query)
+	}
+	// the consent is remembered for the granted scopes only
+	redirect(t, env.get(AuthorizePath, with(q, "prompt", PromptNone), cookie), testAppRedirect)
+	redirect(t, env.get(AuthorizePath, with(q, "scope", "openid email"), cookie), testIssuer+server.V1Path+ConsentPath)
+	redirect(t, env.get(AuthorizePath, with(q, "prompt", PromptConsent), cookie), testIssuer+server.V1Path+ConsentPath)
+}
+
+func TestConsentDenied(t *testing.T) {
+	env := newTestEnv(t)
+	cookie := env.session(t, time.Now())
+	id := redirect(t, env.get(AuthorizePath, authorizeQuery(testApp, testAppRedirect), cookie), testIssuer+server.V1Path+ConsentPath).Query().Get("request")
+	req, _ := env.provider.requests.get(id)
+	form := url.Values{"request": {id}, "csrf": {req.CSRF}, "action": {"deny"}}
+	if rec := env.postWithCookie(server.V1Path+ConsentPath, with(form, "csrf", "forged"), cookie); rec.Code != http.StatusForbidden {
+		t.Errorf("got %d for a forged form", rec.Code)
+	}
+	query := redirect(t, env.postWithCookie(server.V1Path+ConsentPath, form, cookie), testAppRedirect).Query()
+	if query.Get("error") != ErrorAccessDenied || query.Get("state") != "state" {
+		t.Errorf("got %v, want error %s", query, ErrorAccessDenied)
+	}
+	if _, ok := env.provider.requests.get(id); ok {
+		t.Error("denied request not removed")
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	if !verifyPKCE(testVerifier, "tiM_PAw-JDT1gJpwhRelXhaoSwuVtWo2F6O2xBm1QG0") {
+		t.Error("valid verifier rejected")
+	}
+	for _, verifier := range []string{"", "short", testVerifier + "A", strings.Repeat("a", 129)} {
+		if verifyPKCE(verifier, challenge(testVerifier)) {
+			t.Errorf("verifier %q accepted", verifier)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/keys"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// discoveryMaxAge is the time the clients may cache the discovery document for.
+const discoveryMaxAge time.Duration = time.Hour
+
+// Discovery is a struct that represents the OpenID Provider Metadata, see OpenID Connect Discovery 1.0 section 3.
+type Discovery struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	EndSessionEndpoint                         string   `json:"end_session_endpoint"`
+	IntrospectionEndpoint                      string   `json:"introspection_endpoint"`
+	RevocationEndpoint                         string   `json:"revocation_endpoint"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	PromptValuesSupported                      []string `json:"prompt_values_supported"`
+	// AuthorizationResponseIssParameterSupported is set as the authorization responses carry the iss parameter of RFC 9207.
+	AuthorizationResponseIssParameterSupported bool `json:"authorization_response_iss_parameter_supported"`
+	RequestParameterSupported                  bool `json:"request_parameter_supported"`
+	RequestURIParameterSupported               bool `json:"request_uri_parameter_supported"`
+}
+
+// newDiscovery returns the discovery document of p.
+func (p *Provider) newDiscovery() Discovery {
+	return Discovery{
+		Issuer:                 p.config.Issuer,
+		AuthorizationEndpoint:  p.endpoint(AuthorizePath),
+		TokenEndpoint:          p.endpoint(oauth2.TokenPath),
+		UserinfoEndpoint:       p.endpoint(UserinfoPath),
+		JWKSURI:                p.config.Issuer + keys.JWKSPath,
+		EndSessionEndpoint:     p.endpoint(EndSessionPath),
+		IntrospectionEndpoint:  p.endpoint(oauth2.IntrospectPath),
+		RevocationEndpoint:     p.endpoint(oauth2.RevokePath),
+		ScopesSupported:        standardScopes,
+		ResponseTypesSupported: []string{"code"},
+		ResponseModesSupported: []string{"query"},
+		GrantTypesSupported: []string{
+			clients.GrantTypeAuthorizationCode,
+			clients.GrantTypeRefreshToken,
+			clients.GrantTypeClientCredentials,
+		},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{p.config.SigningAlgorithm},
+		TokenEndpointAuthMethodsSupported: []string{
+			clients.AuthMethodSecretBasic,
+			clients.AuthMethodSecretPost,
+			clients.AuthMethodPrivateKeyJWT,
+			clients.AuthMethodNone,
+		},
+		TokenEndpointAuthSigningAlgValuesSupported: []string{tokens.AlgEdDSA, tokens.AlgES256, tokens.AlgES384, tokens.AlgES512, tokens.AlgRS256},
+		CodeChallengeMethodsSupported:              []string{CodeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp", "at_hash", "sid",
+			"preferred_username", "name", "email",
+		},
+		PromptValuesSupported:                      []string{PromptNone, PromptLogin, PromptConsent, PromptSelectAccount},
+		AuthorizationResponseIssParameterSupported: true,
+	}
+}
+
+func (p *Provider) discoveryHandler(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", int(discoveryMaxAge.Seconds())))
+	return c.JSON(http.StatusOK, p.discovery)
+}
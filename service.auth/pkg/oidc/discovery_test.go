@@ -0,0 +1,54 @@
+package oidc
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+func TestNewProviderValidatesIssuer(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, issuer := range []string{"", "auth.example.com", "/auth", "https://"} {
+		if _, err := NewProvider(Config{Issuer: issuer}, nil, clients.NewMemoryRepository(), nil, nil, nil, nil, logger); err == nil {
+			t.Errorf("issuer %q accepted", issuer)
+		}
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	env := newTestEnv(t)
+	rec := httptest.NewRecorder()
+	env.e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DiscoveryPath, nil))
+	var d Discovery
+	decode(t, rec, http.StatusOK, &d)
+	if d.Issuer != testIssuer || d.AuthorizationEndpoint != testIssuer+"/v1/authorize" || d.TokenEndpoint != testIssuer+"/v1/token" ||
+		d.JWKSURI != testIssuer+"/.well-known/jwks.json" || d.EndSessionEndpoint != testIssuer+"/v1/end-session" {
+		t.Errorf("got endpoints %+v", d)
+	}
+	if len(d.IDTokenSigningAlgValuesSupported) != 1 || d.IDTokenSigningAlgValuesSupported[0] != tokens.AlgES256 {
+		t.Errorf("got signing algorithms %v", d.IDTokenSigningAlgValuesSupported)
+	}
+	if rec.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Errorf("got Cache-Control %q", rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestProviderIssuerWithPath(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p, err := NewProvider(Config{Issuer: "http://localhost:8080/auth/"}, nil, clients.NewMemoryRepository(), nil, nil, nil, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.discovery.Issuer != "http://localhost:8080/auth" || p.discovery.UserinfoEndpoint != "http://localhost:8080/auth/v1/userinfo" {
+		t.Errorf("got %+v", p.discovery)
+	}
+	// the session cookie is sent to the endpoints of the issuer only, and over plain HTTP in development
+	if p.cookiePath != "/auth/v1" || p.secureCookie {
+		t.Errorf("got cookie path %q, secure %t", p.cookiePath, p.secureCookie)
+	}
+}
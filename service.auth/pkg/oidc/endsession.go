@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+/*
+endSession logs the user out of the login session of the browser, see OpenID Connect RP-Initiated Logout 1.0 section 2.
+
+The user is redirected to the post_logout_redirect_uri if it is registered for the client identified by
+the id_token_hint or client_id, otherwise a logged out page is shown.
+*/
+func (p *Provider) endSession(c echo.Context) error {
+	ctx := c.Request().Context()
+	clientID := c.FormValue("client_id")
+	if hint := c.FormValue("id_token_hint"); hint != "" {
+		claims, err := p.issuer.VerifyIDTokenHint(ctx, hint)
+		if errors.Is(err, tokens.ErrInvalidToken) {
+			return p.messagePage(c, http.StatusBadRequest, "Invalid logout request", "The application sent an invalid ID token.")
+		}
+		if err != nil {
+			return err
+		}
+		if clientID != "" && !slices.Contains(claims.Audience, clientID) {
+			return p.messagePage(c, http.StatusBadRequest, "Invalid logout request", "The ID token was not issued to the application.")
+		}
+		if clientID == "" {
+			clientID = claims.Audience[0]
+		}
+	}
+	redirectURI := c.FormValue("post_logout_redirect_uri")
+	if redirectURI != "" {
+		client, err := p.client(ctx, clientID)
+		if err != nil {
+			return err
+		}
+		if client == nil || !slices.Contains(client.PostLogoutRedirectURIs, redirectURI) {
+			return p.messagePage(c, http.StatusBadRequest, "Invalid logout request", "The application sent a redirect URI it did not register.")
+		}
+	}
+	if s := p.endCurrentSession(c); s != nil {
+		p.logger.Info("user logged out", "user_id", s.UserID, "client_id", clientID)
+	}
+	if redirectURI == "" {
+		return p.messagePage(c, http.StatusOK, "Logged out", "You have been logged out. You can close this page.")
+	}
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return err
+	}
+	if state := c.FormValue("state"); state != "" {
+		q := u.Query()
+		q.Set("state", state)
+		u.RawQuery = q.Encode()
+	}
+	return c.Redirect(http.StatusFound, u.String())
+}
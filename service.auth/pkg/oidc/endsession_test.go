@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// idToken issues an ID token of alice to clientID.
+func (env *testEnv) idToken(t *testing.T, clientID string) string {
+	t.Helper()
+	token, _, err := env.issuer.IssueIDToken(context.Background(), tokens.IDTokenRequest{Subject: env.user.ID, ClientID: clientID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// loggedIn reports whether the session with cookie exists.
+func (env *testEnv) loggedIn(cookie string) bool {
+	_, ok := env.provider.sessions.get(hashToken(cookie))
+	return ok
+}
+
+func TestEndSession(t *testing.T) {
+	env := newTestEnv(t)
+	cookie := env.session(t, time.Now())
+	q := url.Values{"id_token_hint": {env.idToken(t, testConsole)}, "post_logout_redirect_uri": {testConsoleLogout}, "state": {"bye"}}
+	location := redirect(t, env.get(EndSessionPath, q, cookie), testConsoleLogout)
+	if location.Query().Get("state") != "bye" {
+		t.Errorf("got %s", location)
+	}
+	if env.loggedIn(cookie) {
+		t.Error("session not ended")
+	}
+}
+
+func TestEndSessionWithoutRedirect(t *testing.T) {
+	env := newTestEnv(t)
+	cookie := env.session(t, time.Now())
+	if rec := env.get(EndSessionPath, url.Values{}, cookie); rec.Code != http.StatusOK {
+		t.Fatalf("got %d", rec.Code)
+	}
+	if env.loggedIn(cookie) {
+		t.Error("session not ended")
+	}
+	// a logout without a session shows the same page
+	if rec := env.get(EndSessionPath, url.Values{}, ""); rec.Code != http.StatusOK {
+		t.Errorf("got %d without a session", rec.Code)
+	}
+}
+
+func TestEndSessionWithClientID(t *testing.T) {
+	env := newTestEnv(t)
+	cookie := env.session(t, time.Now())
+	q := url.Values{"client_id": {testConsole}, "post_logout_redirect_uri": {testConsoleLogout}}
+	redirect(t, env.get(EndSessionPath, q, cookie), testConsoleLogout)
+	if env.loggedIn(cookie) {
+		t.Error("session not ended")
+	}
+}
+
+func TestEndSessionRejectsInvalidRequests(t *testing.T) {
+	env := newTestEnv(t)
+	cookie := env.session(t, time.Now())
+	hint := env.idToken(t, testConsole)
+	for name, q := range map[string]url.Values{
+		"invalid hint":              {"id_token_hint": {hint[:len(hint)-2]}},
+		"hint of other client":      {"id_token_hint": {hint}, "client_id": {testApp}},
+		"unregistered redirect":     {"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://evil.example.com/"}},
+		"redirect of other client":  {"id_token_hint": {env.idToken(t, testApp)}, "post_logout_redirect_uri": {testConsoleLogout}},
+		"redirect without a client": {"post_logout_redirect_uri": {testConsoleLogout}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if rec := env.get(EndSessionPath, q, cookie); rec.Code != http.StatusBadRequest {
+				t.Errorf("got %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+	if !env.loggedIn(cookie) {
+		t.Error("session ended by an invalid request")
+	}
+}
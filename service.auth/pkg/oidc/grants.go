@@ -0,0 +1,256 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/login"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+// grant is a struct that represents the user, scopes and session the tokens of a grant are issued for.
+type grant struct {
+	user      *users.User
+	scopes    []string
+	nonce     string
+	authTime  time.Time
+	sessionID string
+	// refreshToken is the rotated refresh token of the refresh_token grant. If empty, a new token family is started.
+	refreshToken string
+}
+
+// authorizationCodeGrant exchanges an authorization code for tokens, see RFC 6749 section 4.1.3.
+// The code is validated before it is consumed, so a request without the client's redirect URI and code verifier
+// can not burn it. It is consumed together with recording the issued tokens, which are revoked if the code is used again.
+func (p *Provider) authorizationCodeGrant(c echo.Context, client *clients.Client) error {
+	ctx := c.Request().Context()
+	key := hashToken(c.FormValue("code"))
+	code, ok := p.codes.get(key)
+	if !ok {
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "invalid authorization code")
+	}
+	if code.ClientID != client.ID {
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "the authorization code was issued to another client")
+	}
+	if c.FormValue("redirect_uri") != code.RedirectURI {
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if !verifyPKCE(c.FormValue("code_verifier"), code.CodeChallenge) {
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "invalid code_verifier")
+	}
+	if code.Used {
+		p.revokeCodeTokens(ctx, key, code)
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "invalid authorization code")
+	}
+	u, err := p.activeUser(ctx, code.UserID)
+	if errors.Is(err, users.ErrInvalidCredentials) {
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "the user is disabled")
+	}
+	if err != nil {
+		return err
+	}
+	resp, claims, err := p.issueTokens(ctx, clients.GrantTypeAuthorizationCode, client, grant{
+		user:      u,
+		scopes:    code.Scopes,
+		nonce:     code.Nonce,
+		authTime:  code.AuthTime,
+		sessionID: code.SessionID,
+	})
+	if err != nil {
+		return err
+	}
+	issued := authCode{
+		ClientID:        code.ClientID,
+		UserID:          code.UserID,
+		AccessJTI:       claims.JWTID,
+		AccessExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		RefreshToken:    resp.RefreshToken,
+	}
+	prev, ok := p.codes.modify(key, func(code *authCode) {
+		if !code.Used {
+			code.Used = true
+			code.AccessJTI, code.AccessExpiresAt, code.RefreshToken = issued.AccessJTI, issued.AccessExpiresAt, issued.RefreshToken
+		}
+	})
+	if !ok || prev.Used {
+		// the code expired or was exchanged concurrently, so the tokens of both exchanges are revoked
+		p.revokeCodeTokens(ctx, key, issued)
+		if ok {
+			p.revokeCodeTokens(ctx, key, prev)
+		}
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "invalid authorization code")
+	}
+	return oauth2.WriteTokenResponse(c, *resp)
+}
+
+// revokeCodeTokens removes the reused authorization code with key and revokes the tokens issued for code.
+// The code was likely intercepted, see RFC 6749 section 4.1.2.
+func (p *Provider) revokeCodeTokens(ctx context.Context, key string, code authCode) {
+	p.codes.delete(key)
+	if code.AccessJTI != "" {
+		if err := p.revocations.Revoke(context.WithoutCancel(ctx), code.AccessJTI, code.AccessExpiresAt); err != nil {
+			p.logger.Error("revoking access token of reused authorization code failed", "client_id", code.ClientID, "error", err.Error())
+		}
+	}
+	if code.RefreshToken != "" {
+		if err := p.refreshTokens.Revoke(context.WithoutCancel(ctx), code.RefreshToken); err != nil {
+			p.logger.Error("revoking refresh token of reused authorization code failed", "client_id", code.ClientID, "error", err.Error())
+		}
+	}
+	p.logger.Warn("authorization code reused, tokens revoked", "user_id", code.UserID, "client_id", code.ClientID)
+}
+
+// refreshTokenGrant exchanges a refresh token for new tokens, see RFC 6749 section 6. The refresh token is rotated.
+func (p *Provider) refreshTokenGrant(c echo.Context, client *clients.Client) error {
+	ctx := c.Request().Context()
+	token := c.FormValue("refresh_token")
+	current, err := p.refreshTokens.Lookup(ctx, token)
+	if err != nil && !errors.Is(err, tokens.ErrInvalidToken) {
+		return err
+	}
+	// a reused token is not active, but it has to be rotated to revoke its family
+	if current != nil && current.ClientID != client.ID {
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "the refresh token was issued to another client")
+	}
+	var scopes []string
+	if current != nil {
+		granted := strings.Fields(current.Scope)
+		scopes = strings.Fields(c.FormValue("scope"))
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidScope, "scope "+scope+" was not granted")
+			}
+		}
+		if len(scopes) == 0 {
+			scopes = granted
+		}
+	}
+	refreshToken, old, err := p.refreshTokens.Rotate(ctx, token)
+	if errors.Is(err, tokens.ErrTokenReused) {
+		p.metrics.RefreshTokensReused.Add(ctx, 1)
+		p.logger.Warn("refresh token reused, token family revoked", "user_id", old.Subject, "client_id", old.ClientID, "family_id", old.FamilyID)
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "invalid refresh token")
+	}
+	if errors.Is(err, tokens.ErrInvalidToken) {
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "invalid refresh token")
+	}
+	if err != nil {
+		return err
+	}
+	if old.ClientID != client.ID {
+		// the token was rotated between the lookup and the rotation, e.g. by a password refresh
+		if err := p.refreshTokens.Revoke(context.WithoutCancel(ctx), refreshToken); err != nil {
+			p.logger.Error("revoking refresh token of another client failed", "client_id", client.ID, "error", err.Error())
+		}
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "the refresh token was issued to another client")
+	}
+	// the user may have been disabled or deleted since the login
+	u, err := p.activeUser(ctx, old.Subject)
+	if errors.Is(err, users.ErrInvalidCredentials) {
+		if err := p.refreshTokens.Revoke(context.WithoutCancel(ctx), refreshToken); err != nil {
+			p.logger.Error("revoking refresh token of disabled user failed", "user_id", old.Subject, "error", err.Error())
+		}
+		return oauth2.WriteError(c, http.StatusBadRequest, oauth2.ErrorInvalidGrant, "invalid refresh token")
+	}
+	if err != nil {
+		return err
+	}
+	if scopes == nil {
+		scopes = strings.Fields(old.Scope)
+	}
+	resp, _, err := p.issueTokens(ctx, clients.GrantTypeRefreshToken, client, grant{
+		user:         u,
+		scopes:       scopes,
+		authTime:     old.AuthTime,
+		refreshToken: refreshToken,
+	})
+	if err != nil {
+		return err
+	}
+	return oauth2.WriteTokenResponse(c, *resp)
+}
+
+/*
+issueTokens issues the tokens of a grant to client: an access token, an ID token if the openid scope was granted,
+and a refresh token if the client may use the refresh_token grant.
+
+Parameters:
+  - ctx: The context of the request.
+  - grantType: The grant type the tokens are recorded with.
+  - client: The client the tokens are issued to.
+  - g: The user, scopes and session of the grant.
+
+Returns:
+  - *oauth2.TokenResponse: The issued tokens.
+  - *tokens.AccessClaims: The claims of the access token.
+  - error: An error if a token could not be issued.
+*/
+func (p *Provider) issueTokens(ctx context.Context, grantType string, client *clients.Client, g grant) (*oauth2.TokenResponse, *tokens.AccessClaims, error) {
+	scope := strings.Join(g.scopes, " ")
+	accessToken, claims, err := p.issuer.IssueAccessToken(ctx, tokens.AccessTokenRequest{
+		Subject:  g.user.ID,
+		ClientID: client.ID,
+		Scope:    scope,
+		Audience: client.Audiences,
+		AuthTime: g.authTime,
+		Roles:    g.user.Roles,
+		Username: g.user.Username,
+		Email:    g.user.Email,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	p.metrics.TokensIssued.Add(ctx, 1, telemetry.GrantTypeKey.String(grantType), telemetry.TokenTypeKey.String(login.TokenTypeAccess))
+	resp := &oauth2.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   claims.ExpiresAt - claims.IssuedAt,
+		Scope:       scope,
+	}
+	if slices.Contains(g.scopes, ScopeOpenID) {
+		req := tokens.IDTokenRequest{
+			Subject:     g.user.ID,
+			ClientID:    client.ID,
+			Nonce:       g.nonce,
+			AuthTime:    g.authTime,
+			AccessToken: accessToken,
+			SessionID:   g.sessionID,
+		}
+		if slices.Contains(g.scopes, ScopeProfile) {
+			req.Username = g.user.Username
+		}
+		if slices.Contains(g.scopes, ScopeEmail) {
+			req.Email = g.user.Email
+		}
+		if resp.IDToken, _, err = p.issuer.IssueIDToken(ctx, req); err != nil {
+			return nil, nil, err
+		}
+		p.metrics.TokensIssued.Add(ctx, 1, telemetry.GrantTypeKey.String(grantType), telemetry.TokenTypeKey.String(TokenTypeID))
+	}
+	resp.RefreshToken = g.refreshToken
+	if resp.RefreshToken == "" && client.HasGrantType(clients.GrantTypeRefreshToken) {
+		resp.RefreshToken, err = p.refreshTokens.Issue(ctx, tokens.RefreshGrant{
+			Subject:  g.user.ID,
+			ClientID: client.ID,
+			Scope:    scope,
+			AuthTime: g.authTime,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if resp.RefreshToken != "" {
+		p.metrics.TokensIssued.Add(ctx, 1, telemetry.GrantTypeKey.String(grantType), telemetry.TokenTypeKey.String(login.TokenTypeRefresh))
+	}
+	return resp, claims, nil
+}
@@ -0,0 +1,224 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/server"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// refreshGrant sends the refresh token of clientID to the token endpoint and returns the recorded response.
+func (env *testEnv) refreshGrant(clientID, refreshToken, scope string) *httptest.ResponseRecorder {
+	form := url.Values{
+		"grant_type":    {clients.GrantTypeRefreshToken},
+		"client_id":     {clientID},
+		"refresh_token": {refreshToken},
+	}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	return env.post(server.V1Path+oauth2.TokenPath, form)
+}
+
+func TestAuthorizationCodeGrant(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	code := env.issueCode(t, testConsole, testConsoleRedirect, ScopeOpenID, ScopeProfile, ScopeEmail)
+	var resp oauth2.TokenResponse
+	decode(t, env.exchange(testConsole, code, testConsoleRedirect, testVerifier), http.StatusOK, &resp)
+	if resp.Scope != "openid profile email" || resp.RefreshToken == "" || resp.IDToken == "" {
+		t.Fatalf("got %+v", resp)
+	}
+	access, err := env.issuer.VerifyAccessToken(ctx, resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if access.Subject != env.user.ID || access.ClientID != testConsole || len(access.Roles) != 1 {
+		t.Errorf("got access claims %+v", access)
+	}
+	id, err := env.issuer.VerifyIDTokenHint(ctx, resp.IDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != env.user.ID || id.Nonce != "nonce" || id.SessionID != "session" || id.AuthorizedParty != testConsole ||
+		id.Username != "alice" || id.Email != "alice@example.com" || id.AccessTokenHash == "" {
+		t.Errorf("got ID claims %+v", id)
+	}
+}
+
+func TestAuthorizationCodeGrantWithoutOpenID(t *testing.T) {
+	env := newTestEnv(t)
+	code := env.issueCode(t, testApp, testAppRedirect)
+	var resp oauth2.TokenResponse
+	decode(t, env.exchange(testApp, code, testAppRedirect, testVerifier), http.StatusOK, &resp)
+	// app may not use the refresh_token grant
+	if resp.AccessToken == "" || resp.IDToken != "" || resp.RefreshToken != "" {
+		t.Errorf("got %+v", resp)
+	}
+}
+
+func TestAuthorizationCodeGrantKeepsCodeOnInvalidRequest(t *testing.T) {
+	env := newTestEnv(t)
+	code := env.issueCode(t, testConsole, testConsoleRedirect, ScopeOpenID)
+	tests := []struct {
+		name        string
+		clientID    string
+		code        string
+		redirectURI string
+		verifier    string
+	}{
+		{name: "unknown code", clientID: testConsole, code: "unknown", redirectURI: testConsoleRedirect, verifier: testVerifier},
+		{name: "other client", clientID: testApp, code: code, redirectURI: testAppRedirect, verifier: testVerifier},
+		{name: "other redirect URI", clientID: testConsole, code: code, redirectURI: testAppRedirect, verifier: testVerifier},
+		{name: "missing verifier", clientID: testConsole, code: code, redirectURI: testConsoleRedirect},
+		{name: "wrong verifier", clientID: testConsole, code: code, redirectURI: testConsoleRedirect, verifier: testVerifier[1:] + "A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, env.exchange(tt.clientID, tt.code, tt.redirectURI, tt.verifier), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+		})
+	}
+	var resp oauth2.TokenResponse
+	decode(t, env.exchange(testConsole, code, testConsoleRedirect, testVerifier), http.StatusOK, &resp)
+}
+
+func TestAuthorizationCodeReuseRevokesTokens(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	code := env.issueCode(t, testConsole, testConsoleRedirect, ScopeOpenID)
+	var resp oauth2.TokenResponse
+	decode(t, env.exchange(testConsole, code, testConsoleRedirect, testVerifier), http.StatusOK, &resp)
+	claims, err := env.issuer.VerifyAccessToken(ctx, resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a replay without the verifier can not revoke the tokens of the client
+	assertError(t, env.exchange(testConsole, code, testConsoleRedirect, ""), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+	if env.revocations.Revoked(claims.JWTID) {
+		t.Fatal("access token revoked by a replay without the verifier")
+	}
+	assertError(t, env.exchange(testConsole, code, testConsoleRedirect, testVerifier), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+	if !env.revocations.Revoked(claims.JWTID) {
+		t.Error("access token not revoked")
+	}
+	if _, err := env.refreshTokens.Lookup(ctx, resp.RefreshToken); !errors.Is(err, tokens.ErrInvalidToken) {
+		t.Errorf("got %v, want %v", err, tokens.ErrInvalidToken)
+	}
+	if _, ok := env.provider.codes.get(hashToken(code)); ok {
+		t.Error("reused code not removed")
+	}
+}
+
+func TestAuthorizationCodeConcurrentExchange(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	code := env.issueCode(t, testConsole, testConsoleRedirect, ScopeOpenID)
+	const n = 8
+	responses := make([]oauth2.TokenResponse, n)
+	statuses := make([]int, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := env.exchange(testConsole, code, testConsoleRedirect, testVerifier)
+			statuses[i] = rec.Code
+			if rec.Code == http.StatusOK {
+				errs[i] = json.Unmarshal(rec.Body.Bytes(), &responses[i])
+			}
+		}(i)
+	}
+	wg.Wait()
+	succeeded := 0
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			continue
+		}
+		succeeded++
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		// the later exchanges revoke the tokens of the first one
+		claims, err := env.issuer.VerifyAccessToken(ctx, responses[i].AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !env.revocations.Revoked(claims.JWTID) {
+			t.Error("access token of a reused code not revoked")
+		}
+		if _, err := env.refreshTokens.Lookup(ctx, responses[i].RefreshToken); !errors.Is(err, tokens.ErrInvalidToken) {
+			t.Errorf("got %v, want %v", err, tokens.ErrInvalidToken)
+		}
+	}
+	if succeeded > 1 {
+		t.Errorf("the code was exchanged %d times", succeeded)
+	}
+}
+
+func TestAuthorizationCodeGrantDisabledUser(t *testing.T) {
+	env := newTestEnv(t)
+	code := env.issueCode(t, testConsole, testConsoleRedirect, ScopeOpenID)
+	env.disable(t)
+	assertError(t, env.exchange(testConsole, code, testConsoleRedirect, testVerifier), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+}
+
+func TestRefreshTokenGrant(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	code := env.issueCode(t, testConsole, testConsoleRedirect, ScopeOpenID, ScopeProfile, ScopeOfflineAccess)
+	var first oauth2.TokenResponse
+	decode(t, env.exchange(testConsole, code, testConsoleRedirect, testVerifier), http.StatusOK, &first)
+
+	assertError(t, env.refreshGrant(testConsole, first.RefreshToken, "openid email"), http.StatusBadRequest, oauth2.ErrorInvalidScope)
+	var second oauth2.TokenResponse
+	decode(t, env.refreshGrant(testConsole, first.RefreshToken, "openid profile"), http.StatusOK, &second)
+	if second.Scope != "openid profile" || second.RefreshToken == "" || second.RefreshToken == first.RefreshToken || second.IDToken == "" {
+		t.Fatalf("got %+v", second)
+	}
+	claims, err := env.issuer.VerifyIDTokenHint(ctx, second.IDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Nonce != "" || claims.Username != "alice" {
+		t.Errorf("got ID claims %+v", claims)
+	}
+
+	// reusing the rotated token revokes the family
+	assertError(t, env.refreshGrant(testConsole, first.RefreshToken, ""), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+	assertError(t, env.refreshGrant(testConsole, second.RefreshToken, ""), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+}
+
+func TestRefreshTokenGrantOtherClient(t *testing.T) {
+	env := newTestEnv(t)
+	token, err := env.refreshTokens.Issue(context.Background(), tokens.RefreshGrant{Subject: env.user.ID, ClientID: testApp, Scope: ScopeOpenID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertError(t, env.refreshGrant(testConsole, token, ""), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+	// the token is still usable by its client
+	if _, err := env.refreshTokens.Lookup(context.Background(), token); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefreshTokenGrantDisabledUser(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	token, err := env.refreshTokens.Issue(ctx, tokens.RefreshGrant{Subject: env.user.ID, ClientID: testConsole, Scope: ScopeOpenID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.disable(t)
+	assertError(t, env.refreshGrant(testConsole, token, ""), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+	assertError(t, env.refreshGrant(testConsole, "unknown", ""), http.StatusBadRequest, oauth2.ErrorInvalidGrant)
+}
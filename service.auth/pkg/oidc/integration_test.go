@@ -0,0 +1,447 @@
+package oidc_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/metric/noop"
+	"golang.org/x/oauth2"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/keys"
+	authoauth2 "github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oidc"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/revocation"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/server"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+// The password of alice and the secret of the tool client in the integration tests.
+const (
+	alicePassword string = "correct horse"
+	toolSecret    string = "tool-secret-of-at-least-32-bytes"
+)
+
+// hiddenFields matches the hidden fields of the login and consent forms.
+var hiddenFields = regexp.MustCompile(`name="(request|csrf)" value="([^"]+)"`)
+
+// relyingParties are the clients of the integration tests, configured from the discovery document of the provider.
+// console is a first-party public client, tool is a confidential client that needs the consent of the user.
+type relyingParties struct {
+	issuer   string
+	provider *gooidc.Provider
+	console  oauth2.Config
+	tool     oauth2.Config
+}
+
+// newRelyingParties serves the auth service with the clients console and tool and the user alice, and returns the
+// clients configured by go-oidc from its discovery document.
+func newRelyingParties(t *testing.T) *relyingParties {
+	t.Helper()
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	userService, err := users.NewService(users.NewMemoryRepository(), users.NewHasher(users.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := userService.Create(ctx, users.CreateInput{Username: "alice", Email: "alice@example.com", Password: alicePassword}); err != nil {
+		t.Fatal(err)
+	}
+	clientService := clients.NewService(clients.NewMemoryRepository(), time.Hour, logger)
+	for _, input := range []clients.CreateInput{
+		{
+			ID:                     "console",
+			AuthMethod:             clients.AuthMethodNone,
+			GrantTypes:             []string{clients.GrantTypeAuthorizationCode, clients.GrantTypeRefreshToken},
+			RedirectURIs:           []string{"https://console.example.com/callback"},
+			PostLogoutRedirectURIs: []string{"https://console.example.com/"},
+			SkipConsent:            true,
+		},
+		{
+			ID:           "tool",
+			Name:         "Third-party <Tool>",
+			GrantTypes:   []string{clients.GrantTypeAuthorizationCode},
+			RedirectURIs: []string{"https://tool.example.com/callback"},
+			Secret:       toolSecret,
+		},
+	} {
+		if _, _, err := clientService.Create(ctx, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signingKeys, err := keys.NewManager(keys.Config{Algorithm: tokens.AlgES256}, keys.NewMemoryStore(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signingKeys.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = signingKeys.Stop(context.Background()) })
+	metrics, err := telemetry.NewMetrics(noop.NewMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	issuer := tokens.NewIssuer(signingKeys, tokens.AccessTokenConfig{Issuer: srv.URL, Audience: []string{"cc-microsvcs"}, TTL: 5 * time.Minute, IDTokenTTL: 5 * time.Minute})
+	refreshTokens := tokens.NewRefreshTokens(tokens.NewMemoryRefreshStore(), tokens.RefreshTokenConfig{TTL: time.Hour, FamilyTTL: 24 * time.Hour})
+	revocations := revocation.NewList()
+	provider, err := oidc.NewProvider(oidc.Config{Issuer: srv.URL, SigningAlgorithm: tokens.AlgES256}, userService, clientService.Registry(),
+		issuer, refreshTokens, revocations, metrics, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := e.Group(server.V1Path)
+	handler := authoauth2.NewHandler(issuer, refreshTokens, revocations, metrics, logger)
+	handler.RegisterRoutes(api, clients.NewAuthenticator(clientService.Registry(), []string{srv.URL, srv.URL + server.V1Path + authoauth2.TokenPath}))
+	provider.RegisterGrants(handler)
+	provider.RegisterRoutes(e, api)
+	e.GET(keys.JWKSPath, keys.JWKSHandler(signingKeys, 0))
+
+	discovered, err := gooidc.NewProvider(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &relyingParties{
+		issuer:   srv.URL,
+		provider: discovered,
+		console: oauth2.Config{
+			ClientID:    "console",
+			Endpoint:    discovered.Endpoint(),
+			RedirectURL: "https://console.example.com/callback",
+			Scopes:      []string{gooidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail},
+		},
+		tool: oauth2.Config{
+			ClientID:     "tool",
+			ClientSecret: toolSecret,
+			Endpoint:     discovered.Endpoint(),
+			RedirectURL:  "https://tool.example.com/callback",
+			Scopes:       []string{gooidc.ScopeOpenID, oidc.ScopeEmail},
+		},
+	}
+}
+
+// verifyIDToken verifies the ID token of tok issued to clientID with the JWKS of the provider.
+func (rp *relyingParties) verifyIDToken(t *testing.T, clientID string, tok *oauth2.Token) *gooidc.IDToken {
+	t.Helper()
+	raw, _ := tok.Extra("id_token").(string)
+	idToken, err := rp.provider.Verifier(&gooidc.Config{ClientID: clientID}).Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idToken
+}
+
+// browser is a user agent with a cookie jar. It follows the redirects within the issuer and stops at the redirects to the clients.
+type browser struct {
+	t      *testing.T
+	issuer string
+	client *http.Client
+}
+
+// page is a response of the issuer to the browser.
+type page struct {
+	status int
+	body   string
+	// location is the redirect to a client.
+	location *url.URL
+}
+
+// newBrowser returns a browser without cookies.
+func newBrowser(t *testing.T, issuer string) *browser {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &browser{t: t, issuer: issuer, client: &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !strings.HasPrefix(req.URL.String(), issuer) {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}}
+}
+
+// get opens rawURL.
+func (b *browser) get(rawURL string) page {
+	b.t.Helper()
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	return b.do(req)
+}
+
+// submit posts form to the endpoint with path relative to the versioned API group.
+func (b *browser) submit(path string, form url.Values) page {
+	b.t.Helper()
+	req, err := http.NewRequest(http.MethodPost, b.issuer+server.V1Path+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	return b.do(req)
+}
+
+// do sends req and returns the response, failing the test on errors.
+func (b *browser) do(req *http.Request) page {
+	b.t.Helper()
+	resp, err := b.client.Do(req)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	p := page{status: resp.StatusCode, body: string(body)}
+	if resp.StatusCode == http.StatusFound {
+		if p.location, err = resp.Location(); err != nil {
+			b.t.Fatal(err)
+		}
+	}
+	return p
+}
+
+// form returns the hidden fields of the form of p.
+func (p page) form() url.Values {
+	form := url.Values{}
+	for _, m := range hiddenFields.FindAllStringSubmatch(p.body, -1) {
+		form.Set(m[1], m[2])
+	}
+	return form
+}
+
+// redirectedTo fails the test if p is not a redirect to the client with redirectURL, and returns its query.
+func (p page) redirectedTo(t *testing.T, redirectURL string) url.Values {
+	t.Helper()
+	if p.location == nil {
+		t.Fatalf("got status %d, want a redirect to %s: %s", p.status, redirectURL, p.body)
+	}
+	target := *p.location
+	target.RawQuery = ""
+	if target.String() != redirectURL {
+		t.Fatalf("redirected to %s, want %s", p.location, redirectURL)
+	}
+	return p.location.Query()
+}
+
+// login logs alice in on the login page p and returns the redirect to the client.
+func (b *browser) login(p page) page {
+	b.t.Helper()
+	if p.status != http.StatusOK || !strings.Contains(p.body, "Log in") {
+		b.t.Fatalf("got status %d, want the login page: %s", p.status, p.body)
+	}
+	form := p.form()
+	form.Set("username", "alice")
+	form.Set("password", alicePassword)
+	return b.submit(oidc.LoginPath, form)
+}
+
+// authorize runs the authorization code flow of cfg in b, logging alice in if needed, and exchanges the code.
+func (rp *relyingParties) authorize(t *testing.T, b *browser, cfg oauth2.Config) *oauth2.Token {
+	t.Helper()
+	verifier := oauth2.GenerateVerifier()
+	p := b.get(cfg.AuthCodeURL("state", oauth2.S256ChallengeOption(verifier), gooidc.Nonce("nonce")))
+	if p.status == http.StatusOK {
+		p = b.login(p)
+	}
+	tok, err := cfg.Exchange(context.Background(), p.redirectedTo(t, cfg.RedirectURL).Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestIntegrationAuthorizationCodeFlow(t *testing.T) {
+	rp := newRelyingParties(t)
+	ctx := context.Background()
+	b := newBrowser(t, rp.issuer)
+	verifier := oauth2.GenerateVerifier()
+	login := b.get(rp.console.AuthCodeURL("state", oauth2.S256ChallengeOption(verifier), gooidc.Nonce("nonce")))
+
+	form := login.form()
+	form.Set("username", "alice")
+	form.Set("password", "wrong")
+	if p := b.submit(oidc.LoginPath, form); p.status != http.StatusUnauthorized || !strings.Contains(p.body, "Invalid username or password") {
+		t.Fatalf("got status %d for a wrong password: %s", p.status, p.body)
+	}
+	query := b.login(login).redirectedTo(t, rp.console.RedirectURL)
+	if query.Get("state") != "state" || query.Get("iss") != rp.issuer {
+		t.Errorf("got query %v", query)
+	}
+	code := query.Get("code")
+
+	// a failed exchange does not consume the code
+	if _, err := rp.console.Exchange(ctx, code, oauth2.VerifierOption(oauth2.GenerateVerifier())); err == nil || !strings.Contains(err.Error(), authoauth2.ErrorInvalidGrant) {
+		t.Fatalf("got %v for a wrong code_verifier", err)
+	}
+	tok, err := rp.console.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.RefreshToken == "" {
+		t.Error("no refresh token issued")
+	}
+	idToken := rp.verifyIDToken(t, "console", tok)
+	if err := idToken.VerifyAccessToken(tok.AccessToken); err != nil {
+		t.Error(err)
+	}
+	var claims struct {
+		Username  string `json:"preferred_username"`
+		Email     string `json:"email"`
+		SessionID string `json:"sid"`
+		AuthTime  int64  `json:"auth_time"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		t.Fatal(err)
+	}
+	if idToken.Nonce != "nonce" || claims.Username != "alice" || claims.Email != "alice@example.com" || claims.SessionID == "" || claims.AuthTime == 0 {
+		t.Errorf("got nonce %q and claims %+v", idToken.Nonce, claims)
+	}
+
+	info, err := rp.provider.UserInfo(ctx, oauth2.StaticTokenSource(tok))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Subject != idToken.Subject || info.Email != "alice@example.com" {
+		t.Errorf("got userinfo %+v", info)
+	}
+
+	// reusing the code revokes the tokens issued for it
+	if _, err := rp.console.Exchange(ctx, code, oauth2.VerifierOption(verifier)); err == nil {
+		t.Fatal("code exchanged twice")
+	}
+	if _, err := rp.provider.UserInfo(ctx, oauth2.StaticTokenSource(tok)); err == nil {
+		t.Error("userinfo accepted the access token of a reused code")
+	}
+
+	// the session logs alice in to the next request
+	second := rp.authorize(t, b, rp.console)
+	if rp.verifyIDToken(t, "console", second).Subject != idToken.Subject {
+		t.Error("got another user in the session")
+	}
+}
+
+func TestIntegrationRefresh(t *testing.T) {
+	rp := newRelyingParties(t)
+	ctx := context.Background()
+	tok := rp.authorize(t, newBrowser(t, rp.issuer), rp.console)
+	expired := *tok
+	expired.Expiry = time.Now().Add(-time.Hour)
+	refreshed, err := rp.console.TokenSource(ctx, &expired).Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.AccessToken == tok.AccessToken || refreshed.RefreshToken == tok.RefreshToken {
+		t.Error("tokens not rotated")
+	}
+	if rp.verifyIDToken(t, "console", refreshed).Subject != rp.verifyIDToken(t, "console", tok).Subject {
+		t.Error("refreshed ID token of another user")
+	}
+	// reusing the rotated token revokes the family
+	if _, err := rp.console.TokenSource(ctx, &expired).Token(); err == nil {
+		t.Fatal("rotated refresh token reused")
+	}
+	if _, err := rp.console.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshed.RefreshToken}).Token(); err == nil {
+		t.Error("refresh token of a revoked family accepted")
+	}
+}
+
+func TestIntegrationConsent(t *testing.T) {
+	rp := newRelyingParties(t)
+	b := newBrowser(t, rp.issuer)
+	verifier := oauth2.GenerateVerifier()
+	consent := b.login(b.get(rp.tool.AuthCodeURL("deny", oauth2.S256ChallengeOption(verifier))))
+	if consent.status != http.StatusOK || !strings.Contains(consent.body, "See your email address") || !strings.Contains(consent.body, "Third-party &lt;Tool&gt;") {
+		t.Fatalf("got status %d, want the consent page: %s", consent.status, consent.body)
+	}
+	form := consent.form()
+	form.Set("action", "deny")
+	if query := b.submit(oidc.ConsentPath, form).redirectedTo(t, rp.tool.RedirectURL); query.Get("error") != "access_denied" || query.Get("state") != "deny" {
+		t.Errorf("got query %v", query)
+	}
+
+	consent = b.get(rp.tool.AuthCodeURL("allow", oauth2.S256ChallengeOption(verifier)))
+	form = consent.form()
+	form.Set("csrf", "forged")
+	form.Set("action", "allow")
+	if p := b.submit(oidc.ConsentPath, form); p.status != http.StatusForbidden {
+		t.Errorf("got status %d for a forged form", p.status)
+	}
+	form = consent.form()
+	form.Set("action", "allow")
+	code := b.submit(oidc.ConsentPath, form).redirectedTo(t, rp.tool.RedirectURL).Get("code")
+	tok, err := rp.tool.Exchange(context.Background(), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp.verifyIDToken(t, "tool", tok)
+	if tok.RefreshToken != "" {
+		t.Error("refresh token issued to a client without the refresh_token grant")
+	}
+
+	// the consent is remembered
+	p := b.get(rp.tool.AuthCodeURL("none", oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("prompt", "none")))
+	if p.redirectedTo(t, rp.tool.RedirectURL).Get("code") == "" {
+		t.Errorf("got %v, want a code", p.location)
+	}
+}
+
+func TestIntegrationAuthorizeErrors(t *testing.T) {
+	rp := newRelyingParties(t)
+	b := newBrowser(t, rp.issuer)
+	verifier := oauth2.GenerateVerifier()
+	if query := b.get(rp.tool.AuthCodeURL("state")).redirectedTo(t, rp.tool.RedirectURL); query.Get("error") != authoauth2.ErrorInvalidRequest {
+		t.Errorf("got query %v without PKCE", query)
+	}
+	unregistered := rp.tool
+	unregistered.RedirectURL = "https://evil.example.com/callback"
+	if p := b.get(unregistered.AuthCodeURL("state", oauth2.S256ChallengeOption(verifier))); p.status != http.StatusBadRequest {
+		t.Errorf("got status %d for an unregistered redirect URI", p.status)
+	}
+	p := b.get(rp.console.AuthCodeURL("state", oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("prompt", "none")))
+	if query := p.redirectedTo(t, rp.console.RedirectURL); query.Get("error") != oidc.ErrorLoginRequired {
+		t.Errorf("got query %v without a session", query)
+	}
+}
+
+func TestIntegrationEndSession(t *testing.T) {
+	rp := newRelyingParties(t)
+	b := newBrowser(t, rp.issuer)
+	tok := rp.authorize(t, b, rp.console)
+	hint, _ := tok.Extra("id_token").(string)
+	endSession := rp.issuer + server.V1Path + oidc.EndSessionPath + "?"
+
+	p := b.get(endSession + url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://evil.example.com/"}}.Encode())
+	if p.status != http.StatusBadRequest {
+		t.Errorf("got status %d for an unregistered post logout redirect URI", p.status)
+	}
+	p = b.get(endSession + url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://console.example.com/"}, "state": {"bye"}}.Encode())
+	if p.location == nil || p.location.String() != "https://console.example.com/?state=bye" {
+		t.Fatalf("got status %d and location %v", p.status, p.location)
+	}
+	verifier := oauth2.GenerateVerifier()
+	p = b.get(rp.console.AuthCodeURL("state", oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("prompt", "none")))
+	if query := p.redirectedTo(t, rp.console.RedirectURL); query.Get("error") != oidc.ErrorLoginRequired {
+		t.Errorf("got query %v after the logout", query)
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Package oidc contains the OpenID Connect provider of the auth service: the discovery document, the authorization endpoint
+of the authorization code flow with PKCE, the login and consent pages, the authorization_code and refresh_token grants
+of the token endpoint, the userinfo endpoint and the RP-initiated logout.
+
+The provider keeps the login sessions, pending authorization requests, authorization codes and consents in memory,
+so they are lost on restart and not shared between instances.
+*/
+package oidc
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/revocation"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/server"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+// DiscoveryPath is the path of the discovery document relative to the issuer, see OpenID Connect Discovery 1.0 section 4.
+const DiscoveryPath string = "/.well-known/openid-configuration"
+
+// The paths of the endpoints relative to the versioned API group.
+const (
+	AuthorizePath  string = "/authorize"
+	LoginPath      string = "/oidc/login"
+	ConsentPath    string = "/oidc/consent"
+	UserinfoPath   string = "/userinfo"
+	EndSessionPath string = "/end-session"
+)
+
+// The scopes of OpenID Connect, which all clients may request.
+const (
+	ScopeOpenID        string = "openid"
+	ScopeProfile       string = "profile"
+	ScopeEmail         string = "email"
+	ScopeOfflineAccess string = "offline_access"
+)
+
+// standardScopes are the scopes all clients may request.
+var standardScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// TokenTypeID is the token type the issued ID tokens are recorded with.
+const TokenTypeID string = "id"
+
+// The defaults of Config.
+const (
+	DefaultSessionTTL time.Duration = 8 * time.Hour
+	DefaultCodeTTL    time.Duration = time.Minute
+	DefaultRequestTTL time.Duration = 10 * time.Minute
+)
+
+// Config is a struct that represents the configuration of the provider.
+type Config struct {
+	// Issuer is the URL of the auth service, the base of the endpoint URLs. It has to be the URL the clients reach the service at.
+	Issuer string
+	// SigningAlgorithm is the algorithm of the signing keys, published in the discovery document.
+	SigningAlgorithm string
+	// SessionTTL is the lifetime of a login session, after which the user has to log in again.
+	SessionTTL time.Duration
+	// CodeTTL is the time an authorization code can be exchanged in.
+	CodeTTL time.Duration
+	// RequestTTL is the time the user has to log in and consent in.
+	RequestTTL time.Duration
+}
+
+// withDefaults returns c with the zero fields set to the defaults.
+func (c Config) withDefaults() Config {
+	if c.SessionTTL == 0 {
+		c.SessionTTL = DefaultSessionTTL
+	}
+	if c.CodeTTL == 0 {
+		c.CodeTTL = DefaultCodeTTL
+	}
+	if c.RequestTTL == 0 {
+		c.RequestTTL = DefaultRequestTTL
+	}
+	return c
+}
+
+// Provider is the OpenID Connect provider.
+type Provider struct {
+	config        Config
+	users         *users.Service
+	clients       clients.Registry
+	issuer        *tokens.Issuer
+	refreshTokens *tokens.RefreshTokens
+	revocations   *revocation.List
+	metrics       *telemetry.Metrics
+	logger        *slog.Logger
+	pages         *pages
+	discovery     Discovery
+
+	sessions *store[session]
+	requests *store[authRequest]
+	codes    *store[authCode]
+
+	consentsMu sync.Mutex
+	// consents are the scopes the users consented to by user and client ID.
+	consents map[string][]string
+
+	// cookiePath and secureCookie are the attributes of the session cookie, derived from the issuer URL.
+	cookiePath   string
+	secureCookie bool
+}
+
+/*
+NewProvider creates the OpenID Connect provider.
+
+Parameters:
+  - config: The configuration of the provider.
+  - users: The users logging in.
+  - clientRegistry: The registered clients.
+  - issuer: The issuer of the access and ID tokens.
+  - refreshTokens: The refresh tokens.
+  - revocations: The list the access tokens of reused authorization codes are revoked in.
+  - metrics: The metrics the logins and issued tokens are recorded with.
+  - logger: The logger of the logins and logouts.
+
+Returns:
+  - *Provider: The created provider, registered with RegisterRoutes and RegisterGrants.
+  - error: An error if the issuer is not an absolute URL.
+*/
+func NewProvider(config Config, users *users.Service, clientRegistry clients.Registry, issuer *tokens.Issuer, refreshTokens *tokens.RefreshTokens, revocations *revocation.List, metrics *telemetry.Metrics, logger *slog.Logger) (*Provider, error) {
+	config = config.withDefaults()
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	u, err := url.Parse(config.Issuer)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("invalid issuer %q: has to be an absolute URL", config.Issuer)
+	}
+	pages, err := newPages()
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{
+		config:        config,
+		users:         users,
+		clients:       clientRegistry,
+		issuer:        issuer,
+		refreshTokens: refreshTokens,
+		revocations:   revocations,
+		metrics:       metrics,
+		logger:        logger,
+		pages:         pages,
+		sessions:      newStore[session](),
+		requests:      newStore[authRequest](),
+		codes:         newStore[authCode](),
+		consents:      make(map[string][]string),
+		cookiePath:    u.Path + server.V1Path,
+		secureCookie:  u.Scheme == "https",
+	}
+	p.discovery = p.newDiscovery()
+	return p, nil
+}
+
+/*
+RegisterRoutes registers the endpoints of p. The discovery document is registered on e, the other endpoints on api.
+
+The following endpoints are registered:
+  - GET /.well-known/openid-configuration: Returns the Discovery document.
+  - GET, POST /authorize: Starts the authorization code flow, see OpenID Connect Core 1.0 section 3.1.2.
+  - GET, POST /oidc/login: The login page.
+  - GET, POST /oidc/consent: The consent page.
+  - GET, POST /userinfo: Returns the claims of the user of the access token, see OpenID Connect Core 1.0 section 5.3.
+  - GET, POST /end-session: Logs the user out, see OpenID Connect RP-Initiated Logout 1.0.
+*/
+func (p *Provider) RegisterRoutes(e *echo.Echo, api *echo.Group) {
+	e.GET(DiscoveryPath, p.discoveryHandler)
+	api.GET(AuthorizePath, p.authorize)
+	api.POST(AuthorizePath, p.authorize)
+	api.GET(LoginPath, p.loginPage)
+	api.POST(LoginPath, p.login)
+	api.GET(ConsentPath, p.consentPage)
+	api.POST(ConsentPath, p.consent)
+	api.GET(UserinfoPath, p.userinfo)
+	api.POST(UserinfoPath, p.userinfo)
+	api.GET(EndSessionPath, p.endSession)
+	api.POST(EndSessionPath, p.endSession)
+}
+
+// RegisterGrants adds the authorization_code and refresh_token grants to the token endpoint of h.
+func (p *Provider) RegisterGrants(h *oauth2.Handler) {
+	h.RegisterGrant(clients.GrantTypeAuthorizationCode, p.authorizationCodeGrant)
+	h.RegisterGrant(clients.GrantTypeRefreshToken, p.refreshTokenGrant)
+}
+
+// endpoint returns the URL of the endpoint with path relative to the versioned API group.
+func (p *Provider) endpoint(path string) string {
+	return p.config.Issuer + server.V1Path + path
+}
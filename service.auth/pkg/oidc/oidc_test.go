@@ -0,0 +1,253 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/revocation"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/server"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+// testIssuer is the issuer of the tests.
+const testIssuer string = "https://auth.example.com"
+
+// The clients of the tests: console skips the consent and may refresh its tokens, app needs the consent of the user.
+const (
+	testConsole         string = "console"
+	testConsoleRedirect string = "https://console.example.com/callback"
+	testConsoleLogout   string = "https://console.example.com/"
+	testApp             string = "app"
+	testAppRedirect     string = "https://app.example.com/callback"
+)
+
+// testVerifier is the PKCE code verifier of the tests.
+const testVerifier string = "dBjftJeZ4CVP-mJ0kr3lhvmbS4pCNnOmbCDdDQYqFJs"
+
+// testEnv is a provider with its dependencies and the token endpoint.
+type testEnv struct {
+	e             *echo.Echo
+	provider      *Provider
+	users         *users.Service
+	issuer        *tokens.Issuer
+	refreshTokens *tokens.RefreshTokens
+	revocations   *revocation.List
+	user          *users.User
+}
+
+// newTestEnv returns a provider with the clients console and app, and the user alice, whose password is "correct horse".
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	userService, err := users.NewService(users.NewMemoryRepository(), users.NewHasher(users.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := userService.Create(ctx, users.CreateInput{Username: "alice", Email: "alice@example.com", Password: "correct horse", Roles: []string{"reader"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientService := clients.NewService(clients.NewMemoryRepository(), time.Hour, logger)
+	for _, input := range []clients.CreateInput{
+		{
+			ID:                     testConsole,
+			AuthMethod:             clients.AuthMethodNone,
+			GrantTypes:             []string{clients.GrantTypeAuthorizationCode, clients.GrantTypeRefreshToken},
+			RedirectURIs:           []string{testConsoleRedirect},
+			PostLogoutRedirectURIs: []string{testConsoleLogout},
+			SkipConsent:            true,
+		},
+		{
+			ID:           testApp,
+			AuthMethod:   clients.AuthMethodNone,
+			GrantTypes:   []string{clients.GrantTypeAuthorizationCode},
+			RedirectURIs: []string{testAppRedirect},
+		},
+	} {
+		if _, _, err := clientService.Create(ctx, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signer, err := tokens.GenerateKey(tokens.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := tokens.NewStaticKeySource(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := telemetry.NewMetrics(noop.NewMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &testEnv{
+		e:     echo.New(),
+		users: userService,
+		issuer: tokens.NewIssuer(keys, tokens.AccessTokenConfig{
+			Issuer:     testIssuer,
+			Audience:   []string{"cc-microsvcs"},
+			TTL:        5 * time.Minute,
+			IDTokenTTL: 5 * time.Minute,
+		}),
+		refreshTokens: tokens.NewRefreshTokens(tokens.NewMemoryRefreshStore(), tokens.RefreshTokenConfig{
+			TTL:       time.Hour,
+			FamilyTTL: 24 * time.Hour,
+		}),
+		revocations: revocation.NewList(),
+		user:        u,
+	}
+	env.provider, err = NewProvider(Config{Issuer: testIssuer, SigningAlgorithm: tokens.AlgES256}, userService, clientService.Registry(),
+		env.issuer, env.refreshTokens, env.revocations, metrics, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := env.e.Group(server.V1Path)
+	handler := oauth2.NewHandler(env.issuer, env.refreshTokens, env.revocations, metrics, logger)
+	handler.RegisterRoutes(api, clients.NewAuthenticator(clientService.Registry(), []string{testIssuer}))
+	env.provider.RegisterGrants(handler)
+	env.provider.RegisterRoutes(env.e, api)
+	return env
+}
+
+// challenge returns the S256 code challenge of verifier.
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// issueCode stores an authorization code of alice for clientID with the challenge of testVerifier, and returns the code.
+func (env *testEnv) issueCode(t *testing.T, clientID, redirectURI string, scopes ...string) string {
+	t.Helper()
+	code, err := randomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.provider.codes.put(hashToken(code), authCode{
+		ClientID:      clientID,
+		RedirectURI:   redirectURI,
+		UserID:        env.user.ID,
+		SessionID:     "session",
+		Scopes:        scopes,
+		Nonce:         "nonce",
+		CodeChallenge: challenge(testVerifier),
+		AuthTime:      time.Now(),
+	}, time.Now().Add(time.Minute))
+	return code
+}
+
+// exchange sends the authorization code of clientID to the token endpoint and returns the recorded response.
+func (env *testEnv) exchange(clientID, code, redirectURI, verifier string) *httptest.ResponseRecorder {
+	return env.post(server.V1Path+oauth2.TokenPath, url.Values{
+		"grant_type":    {clients.GrantTypeAuthorizationCode},
+		"client_id":     {clientID},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+}
+
+// session starts a login session of alice that authenticated at authTime, and returns the value of its cookie.
+func (env *testEnv) session(t *testing.T, authTime time.Time) string {
+	t.Helper()
+	id, err := randomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.provider.sessions.put(hashToken(id), session{ID: hashToken(id)[:32], UserID: env.user.ID, AuthTime: authTime}, authTime.Add(time.Hour))
+	return id
+}
+
+// disable disables alice.
+func (env *testEnv) disable(t *testing.T) {
+	t.Helper()
+	disabled := true
+	if _, err := env.users.Update(context.Background(), env.user.ID, users.UpdateInput{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get requests the endpoint with path relative to the versioned API group with query and the session cookie, if not empty,
+// and returns the recorded response.
+func (env *testEnv) get(path string, query url.Values, cookie string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, server.V1Path+path+"?"+query.Encode(), nil)
+	return env.serve(req, cookie)
+}
+
+// post sends a form to path and returns the recorded response.
+func (env *testEnv) post(path string, form url.Values) *httptest.ResponseRecorder {
+	return env.postWithCookie(path, form, "")
+}
+
+// postWithCookie sends a form to path with the session cookie and returns the recorded response.
+func (env *testEnv) postWithCookie(path string, form url.Values, cookie string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	return env.serve(req, cookie)
+}
+
+// serve serves req with the session cookie, if not empty.
+func (env *testEnv) serve(req *http.Request, cookie string) *httptest.ResponseRecorder {
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: cookie})
+	}
+	rec := httptest.NewRecorder()
+	env.e.ServeHTTP(rec, req)
+	return rec
+}
+
+// redirect returns the location of the redirect rec, failing the test if rec is not a redirect to redirectURI or the provider.
+func redirect(t *testing.T, rec *httptest.ResponseRecorder, redirectURI string) *url.URL {
+	t.Helper()
+	if rec.Code != http.StatusFound {
+		t.Fatalf("got %d, want a redirect to %s: %s", rec.Code, redirectURI, rec.Body)
+	}
+	location, err := url.Parse(rec.Header().Get(echo.HeaderLocation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := *location
+	target.RawQuery = ""
+	if target.String() != redirectURI {
+		t.Fatalf("redirected to %s, want %s", location, redirectURI)
+	}
+	return location
+}
+
+// decode decodes the JSON body of rec into v, failing the test if the status is not want.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, want int, v any) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("got %d, want %d: %s", rec.Code, want, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertError fails the test if rec is not an OAuth 2.0 error response with status and code.
+func assertError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	var resp oauth2.ErrorResponse
+	decode(t, rec, status, &resp)
+	if resp.Error != code {
+		t.Errorf("got error %q, want %q", resp.Error, code)
+	}
+}
@@ -0,0 +1,106 @@
+package oidc
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// sessionCookie is the name of the cookie holding the ID of the login session.
+const sessionCookie string = "auth_session"
+
+// session is a struct that represents the login session of a user in the browser.
+type session struct {
+	ID       string
+	UserID   string
+	AuthTime time.Time
+}
+
+// currentSession returns the login session of the request, or nil.
+func (p *Provider) currentSession(c echo.Context) *session {
+	cookie, err := c.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	s, ok := p.sessions.get(hashToken(cookie.Value))
+	if !ok {
+		return nil
+	}
+	return &s
+}
+
+// startSession starts a login session of the user with userID, replacing the current session of the request.
+// A new session ID on every login prevents session fixation.
+func (p *Provider) startSession(c echo.Context, userID string) (*session, error) {
+	if cookie, err := c.Cookie(sessionCookie); err == nil {
+		p.sessions.delete(hashToken(cookie.Value))
+	}
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(p.config.SessionTTL)
+	// the session ID in the store is hashed, the sid claim of the ID tokens is derived from it
+	s := session{ID: hashToken(id)[:32], UserID: userID, AuthTime: now}
+	p.sessions.put(hashToken(id), s, expiresAt)
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     p.cookiePath,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   p.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return &s, nil
+}
+
+// endCurrentSession removes the login session of the request and clears the cookie.
+func (p *Provider) endCurrentSession(c echo.Context) *session {
+	cookie, err := c.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	s, ok := p.sessions.get(hashToken(cookie.Value))
+	p.sessions.delete(hashToken(cookie.Value))
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Path:     p.cookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   p.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if !ok {
+		return nil
+	}
+	return &s
+}
+
+// consented reports whether the user with userID consented to all scopes of the client with clientID.
+func (p *Provider) consented(userID, clientID string, scopes []string) bool {
+	p.consentsMu.Lock()
+	defer p.consentsMu.Unlock()
+	granted := p.consents[userID+"\x00"+clientID]
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// grantConsent records the consent of the user with userID to scopes of the client with clientID.
+func (p *Provider) grantConsent(userID, clientID string, scopes []string) {
+	p.consentsMu.Lock()
+	defer p.consentsMu.Unlock()
+	key := userID + "\x00" + clientID
+	for _, scope := range scopes {
+		if !slices.Contains(p.consents[key], scope) {
+			p.consents[key] = append(p.consents[key], scope)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval the expired items are removed from a store in.
+const sweepInterval = time.Minute
+
+// store is a map of items expiring after their TTL. It is safe for concurrent use.
+type store[T any] struct {
+	mu        sync.Mutex
+	items     map[string]item[T]
+	lastSweep time.Time
+}
+
+// item is an item of a store.
+type item[T any] struct {
+	value     T
+	expiresAt time.Time
+}
+
+// newStore returns an empty store.
+func newStore[T any]() *store[T] {
+	return &store[T]{items: make(map[string]item[T]), lastSweep: time.Now()}
+}
+
+// put stores value with key until expiresAt.
+func (s *store[T]) put(key string, value T, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	s.items[key] = item[T]{value: value, expiresAt: expiresAt}
+}
+
+// get returns the unexpired value with key.
+func (s *store[T]) get(key string) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	if !ok || !time.Now().Before(it.expiresAt) {
+		var zero T
+		return zero, false
+	}
+	return it.value, true
+}
+
+// modify applies fn to the unexpired value with key and returns the value before the change.
+func (s *store[T]) modify(key string, fn func(value *T)) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	if !ok || !time.Now().Before(it.expiresAt) {
+		var zero T
+		return zero, false
+	}
+	prev := it.value
+	fn(&it.value)
+	s.items[key] = it
+	return prev, true
+}
+
+// delete removes the value with key.
+func (s *store[T]) delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+}
+
+// sweep removes the expired items if the sweep interval passed. The mutex has to be held.
+func (s *store[T]) sweep() {
+	now := time.Now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, it := range s.items {
+		if !now.Before(it.expiresAt) {
+			delete(s.items, key)
+		}
+	}
+}
+
+// randomToken returns a random 256 bit token encoded in base64url.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the key of a token in a store, so a dump of the store does not contain usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
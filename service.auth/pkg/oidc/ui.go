@@ -0,0 +1,230 @@
+package oidc
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+// scopeDescriptions are the descriptions of the standard scopes on the consent page.
+var scopeDescriptions = map[string]string{
+	ScopeOpenID:        "Sign you in with your account",
+	ScopeProfile:       "See your username",
+	ScopeEmail:         "See your email address",
+	ScopeOfflineAccess: "Stay signed in while you are away",
+}
+
+// pages are the templates of the pages served to the users.
+type pages struct {
+	login   *template.Template
+	consent *template.Template
+	message *template.Template
+}
+
+// newPages parses the embedded templates. Each page is rendered within the layout.
+func newPages() (*pages, error) {
+	parse := func(name string) (*template.Template, error) {
+		return template.ParseFS(templatesFS, "templates/layout.html", "templates/"+name)
+	}
+	login, err := parse("login.html")
+	if err != nil {
+		return nil, err
+	}
+	consent, err := parse("consent.html")
+	if err != nil {
+		return nil, err
+	}
+	message, err := parse("message.html")
+	if err != nil {
+		return nil, err
+	}
+	return &pages{login: login, consent: consent, message: message}, nil
+}
+
+// loginData is the data of the login page.
+type loginData struct {
+	Title     string
+	Request   string
+	CSRF      string
+	Client    string
+	Username  string
+	Error     string
+	LoginPath string
+}
+
+// consentData is the data of the consent page.
+type consentData struct {
+	Title       string
+	Request     string
+	CSRF        string
+	Client      string
+	Username    string
+	Scopes      []string
+	ConsentPath string
+}
+
+// messageData is the data of the message page, e.g. an error.
+type messageData struct {
+	Title   string
+	Message string
+}
+
+func (p *Provider) loginPage(c echo.Context) error {
+	req, ok := p.requests.get(c.QueryParam("request"))
+	if !ok {
+		return p.expiredPage(c)
+	}
+	return p.renderLogin(c, http.StatusOK, &req, req.LoginHint, "")
+}
+
+func (p *Provider) login(c echo.Context) error {
+	req, ok := p.requests.get(c.FormValue("request"))
+	if !ok {
+		return p.expiredPage(c)
+	}
+	if !csrfMatches(c.FormValue("csrf"), req.CSRF) {
+		return p.messagePage(c, http.StatusForbidden, "Invalid form", "The form was not sent from this page. Please try again.")
+	}
+	ctx := c.Request().Context()
+	username := c.FormValue("username")
+	u, err := p.users.Authenticate(ctx, username, c.FormValue("password"))
+	if err != nil {
+		p.metrics.Logins.Add(ctx, 1, telemetry.OutcomeKey.String(telemetry.OutcomeFailure))
+		if errors.Is(err, users.ErrInvalidCredentials) {
+			p.logger.Info("login failed", "username", username, "client_id", req.ClientID)
+			return p.renderLogin(c, http.StatusUnauthorized, &req, username, "Invalid username or password.")
+		}
+		return err
+	}
+	p.metrics.Logins.Add(ctx, 1, telemetry.OutcomeKey.String(telemetry.OutcomeSuccess))
+	sess, err := p.startSession(c, u.ID)
+	if err != nil {
+		return err
+	}
+	p.logger.Info("user logged in", "user_id", u.ID, "client_id", req.ClientID)
+	req.LoggedIn = true
+	return p.proceed(c, &req, sess)
+}
+
+func (p *Provider) consentPage(c echo.Context) error {
+	req, ok := p.requests.get(c.QueryParam("request"))
+	if !ok {
+		return p.expiredPage(c)
+	}
+	sess := p.currentSession(c)
+	if sess == nil || sess.UserID != req.UserID {
+		return p.expiredPage(c)
+	}
+	ctx := c.Request().Context()
+	client, err := p.client(ctx, req.ClientID)
+	if err != nil {
+		return err
+	}
+	u, err := p.activeUser(ctx, sess.UserID)
+	if err != nil || client == nil {
+		return p.expiredPage(c)
+	}
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if description, ok := scopeDescriptions[scope]; ok {
+			scope = description
+		}
+		scopes = append(scopes, scope)
+	}
+	return p.render(c, http.StatusOK, p.pages.consent, consentData{
+		Title:       "Authorize " + clientName(client.Name, client.ID),
+		Request:     req.ID,
+		CSRF:        req.CSRF,
+		Client:      clientName(client.Name, client.ID),
+		Username:    u.Username,
+		Scopes:      scopes,
+		ConsentPath: p.endpoint(ConsentPath),
+	})
+}
+
+func (p *Provider) consent(c echo.Context) error {
+	req, ok := p.requests.get(c.FormValue("request"))
+	if !ok {
+		return p.expiredPage(c)
+	}
+	if !csrfMatches(c.FormValue("csrf"), req.CSRF) {
+		return p.messagePage(c, http.StatusForbidden, "Invalid form", "The form was not sent from this page. Please try again.")
+	}
+	sess := p.currentSession(c)
+	if sess == nil || sess.UserID != req.UserID {
+		return p.expiredPage(c)
+	}
+	if c.FormValue("action") != "allow" {
+		p.requests.delete(req.ID)
+		p.logger.Info("consent denied", "user_id", sess.UserID, "client_id", req.ClientID)
+		return p.redirectError(c, req.RedirectURI, req.State, ErrorAccessDenied, "the user denied the request")
+	}
+	p.grantConsent(sess.UserID, req.ClientID, req.Scopes)
+	req.Consented = true
+	return p.proceed(c, &req, sess)
+}
+
+// renderLogin renders the login page of req with the prefilled username and an error message.
+func (p *Provider) renderLogin(c echo.Context, status int, req *authRequest, username, message string) error {
+	name := req.ClientID
+	if client, err := p.client(c.Request().Context(), req.ClientID); err == nil && client != nil {
+		name = clientName(client.Name, client.ID)
+	}
+	return p.render(c, status, p.pages.login, loginData{
+		Title:     "Log in",
+		Request:   req.ID,
+		CSRF:      req.CSRF,
+		Client:    name,
+		Username:  username,
+		Error:     message,
+		LoginPath: p.endpoint(LoginPath),
+	})
+}
+
+// expiredPage renders the message of an unknown or expired authorization request.
+func (p *Provider) expiredPage(c echo.Context) error {
+	return p.messagePage(c, http.StatusBadRequest, "Login expired", "The login took too long or was already completed. Please return to the application and try again.")
+}
+
+// messagePage renders a page with title and message.
+func (p *Provider) messagePage(c echo.Context, status int, title, message string) error {
+	return p.render(c, status, p.pages.message, messageData{Title: title, Message: message})
+}
+
+// render writes the page t with data. The pages must not be cached or framed, so they can not be used for clickjacking.
+func (p *Provider) render(c echo.Context, status int, t *template.Template, data any) error {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return err
+	}
+	h := c.Response().Header()
+	h.Set(echo.HeaderCacheControl, "no-store")
+	h.Set(echo.HeaderContentSecurityPolicy, "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	h.Set(echo.HeaderXFrameOptions, "DENY")
+	h.Set("Referrer-Policy", "no-referrer")
+	return c.HTMLBlob(status, buf.Bytes())
+}
+
+// clientName returns the name of a client shown to the users, falling back to its ID.
+func clientName(name, id string) string {
+	if name != "" {
+		return name
+	}
+	return id
+}
+
+// csrfMatches reports whether the CSRF token of a form matches the token of the authorization request.
+func csrfMatches(got, want string) bool {
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,77 @@
+package oidc
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
+)
+
+// The error codes of the bearer token authentication, see RFC 6750 section 3.1.
+const (
+	ErrorInvalidToken      string = "invalid_token"
+	ErrorInsufficientScope string = "insufficient_scope"
+)
+
+// Userinfo is a struct that represents the claims of the userinfo endpoint, see OpenID Connect Core 1.0 section 5.3.2.
+type Userinfo struct {
+	Subject  string `json:"sub"`
+	Username string `json:"preferred_username,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+func (p *Provider) userinfo(c echo.Context) error {
+	token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok && c.Request().Method == http.MethodPost {
+		// the form parameter of RFC 6750 section 2.2
+		token = c.FormValue("access_token")
+	}
+	if token == "" {
+		return bearerError(c, http.StatusUnauthorized, "", "")
+	}
+	ctx := c.Request().Context()
+	claims, err := p.issuer.VerifyAccessToken(ctx, token)
+	if errors.Is(err, tokens.ErrInvalidToken) || (err == nil && p.revocations.Revoked(claims.JWTID)) {
+		return bearerError(c, http.StatusUnauthorized, ErrorInvalidToken, "the access token is not valid")
+	}
+	if err != nil {
+		return err
+	}
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return bearerError(c, http.StatusForbidden, ErrorInsufficientScope, "the access token does not have the openid scope")
+	}
+	u, err := p.activeUser(ctx, claims.Subject)
+	if errors.Is(err, users.ErrInvalidCredentials) {
+		return bearerError(c, http.StatusUnauthorized, ErrorInvalidToken, "the user is disabled")
+	}
+	if err != nil {
+		return err
+	}
+	info := Userinfo{Subject: u.ID}
+	if slices.Contains(scopes, ScopeProfile) {
+		info.Username = u.Username
+		info.Name = u.Username
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		info.Email = u.Email
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusOK, info)
+}
+
+// bearerError writes the error of the bearer token authentication in the WWW-Authenticate header, see RFC 6750 section 3.
+func bearerError(c echo.Context, status int, code, description string) error {
+	challenge := "Bearer"
+	if code != "" {
+		challenge += ` error="` + code + `", error_description="` + description + `"`
+	}
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+	return c.NoContent(status)
+}
@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/server"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+)
+
+// accessToken issues an access token of alice to console with scope.
+func (env *testEnv) accessToken(t *testing.T, scope string) (string, *tokens.AccessClaims) {
+	t.Helper()
+	token, claims, err := env.issuer.IssueAccessToken(context.Background(), tokens.AccessTokenRequest{Subject: env.user.ID, ClientID: testConsole, Scope: scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, claims
+}
+
+// userinfo requests the userinfo endpoint with the bearer token and returns the recorded response.
+func (env *testEnv) userinfo(token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, server.V1Path+UserinfoPath, nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	return env.serve(req, "")
+}
+
+func TestUserinfo(t *testing.T) {
+	env := newTestEnv(t)
+	tests := []struct {
+		scope string
+		want  Userinfo
+	}{
+		{scope: "openid", want: Userinfo{Subject: env.user.ID}},
+		{scope: "openid profile", want: Userinfo{Subject: env.user.ID, Username: "alice", Name: "alice"}},
+		{scope: "openid email", want: Userinfo{Subject: env.user.ID, Email: "alice@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			token, _ := env.accessToken(t, tt.scope)
+			rec := env.userinfo(token)
+			var info Userinfo
+			decode(t, rec, http.StatusOK, &info)
+			if info != tt.want {
+				t.Errorf("got %+v, want %+v", info, tt.want)
+			}
+			if rec.Header().Get(echo.HeaderCacheControl) != "no-store" {
+				t.Error("userinfo response cacheable")
+			}
+		})
+	}
+}
+
+func TestUserinfoFormToken(t *testing.T) {
+	env := newTestEnv(t)
+	token, _ := env.accessToken(t, "openid")
+	var info Userinfo
+	decode(t, env.post(server.V1Path+UserinfoPath, url.Values{"access_token": {token}}), http.StatusOK, &info)
+	if info.Subject != env.user.ID {
+		t.Errorf("got %+v", info)
+	}
+}
+
+func TestUserinfoRejectsTokens(t *testing.T) {
+	env := newTestEnv(t)
+	token, _ := env.accessToken(t, "openid")
+	revoked, claims := env.accessToken(t, "openid")
+	if err := env.revocations.Revoke(context.Background(), claims.JWTID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		t.Fatal(err)
+	}
+	withoutOpenID, _ := env.accessToken(t, "profile")
+	tests := []struct {
+		name   string
+		token  string
+		status int
+		error  string
+	}{
+		{name: "missing token", status: http.StatusUnauthorized},
+		{name: "invalid token", token: token[:len(token)-2], status: http.StatusUnauthorized, error: ErrorInvalidToken},
+		{name: "revoked token", token: revoked, status: http.StatusUnauthorized, error: ErrorInvalidToken},
+		{name: "without openid scope", token: withoutOpenID, status: http.StatusForbidden, error: ErrorInsufficientScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := env.userinfo(tt.token)
+			if rec.Code != tt.status {
+				t.Fatalf("got %d, want %d", rec.Code, tt.status)
+			}
+			challenge := rec.Header().Get(echo.HeaderWWWAuthenticate)
+			if !strings.HasPrefix(challenge, "Bearer") || (tt.error != "" && !strings.Contains(challenge, `error="`+tt.error+`"`)) {
+				t.Errorf("got challenge %q, want error %q", challenge, tt.error)
+			}
+		})
+	}
+
+	env.disable(t)
+	if rec := env.userinfo(token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("got %d for a disabled user", rec.Code)
+	}
+}
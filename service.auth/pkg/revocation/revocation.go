@@ -0,0 +1,209 @@
+/*
+Package revocation contains the list of the revoked access tokens. The access tokens are self-contained JWTs,
+so verifiers pulling the list incrementally can reject revoked tokens without calling the auth service for every request.
+An entry is kept until the token it revokes expired.
+
+The list is kept in memory by every replica of the auth service. A list created with NewPersistentList writes its entries
+through to a Store, e.g. a SQLiteStore, so it survives restarts with its epoch and sequence numbers. Otherwise a restart empties the list.
+The list is not shared between the replicas, so a token revoked at one replica is only listed by that replica.
+Every list has a random epoch, which changes when an unpersisted list is restarted, so verifiers detect the reset and pull the whole list again.
+*/
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Path is the path of the revocation list relative to the versioned API group.
+const Path string = "/revocations"
+
+// The names of the Store implementations. The memory store keeps the list in memory only.
+const (
+	StoreMemory string = "memory"
+	StoreSQLite string = "sqlite"
+)
+
+// Entry is a struct that represents a revoked access token.
+type Entry struct {
+	// Seq is the sequence number of the entry. It increases with every revocation.
+	Seq uint64 `json:"seq"`
+	// JTI is the jti claim of the revoked token.
+	JTI string `json:"jti"`
+	// ExpiresAt is the expiry of the revoked token, after which the entry is removed.
+	ExpiresAt time.Time `json:"exp"`
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+// Page is a struct that represents the entries of the list after a sequence number.
+type Page struct {
+	// Epoch is the epoch of the list, to pass with Next. It changes when the list is reset, e.g. by a restart.
+	Epoch   string  `json:"epoch"`
+	Entries []Entry `json:"entries"`
+	// Next is the sequence number to pass as since to get the entries added after this page.
+	Next uint64 `json:"next"`
+}
+
+// Store persists the entries of a List. A Store is used by a single List.
+type Store interface {
+	// Load returns the epoch, the last sequence number and the entries of the list ordered by their sequence number.
+	// The epoch of a new store is created by the store.
+	Load(ctx context.Context) (epoch string, seq uint64, entries []Entry, err error)
+	// Add stores e as the entry with the last sequence number and removes the entries which expired at now.
+	Add(ctx context.Context, e Entry, now time.Time) error
+}
+
+// List is the list of the revoked access tokens. It is safe for concurrent use.
+type List struct {
+	// epoch identifies the list, so the sequence numbers of a list are not mistaken for the ones of a previous list.
+	epoch string
+	// store persists the entries, it is nil for a list kept in memory only.
+	store Store
+
+	mu sync.RWMutex
+	// entries are ordered by their sequence number.
+	entries []Entry
+	index   map[string]struct{}
+	seq     uint64
+}
+
+// NewList returns an empty List with a new epoch.
+func NewList() *List {
+	return &List{epoch: uuid.NewString(), index: make(map[string]struct{})}
+}
+
+/*
+NewPersistentList returns the List stored in store, loading its epoch and unexpired entries. Revoked tokens are written to store.
+
+Parameters:
+  - ctx: The context of the loading of the list.
+  - store: The store of the list, e.g. a SQLiteStore.
+
+Returns:
+  - *List: The loaded list.
+  - error: An error if the list could not be loaded.
+
+Example usage:
+
+	store, err := revocation.NewSQLiteStore(ctx, "/var/lib/auth-service/revocations.db")
+	if err != nil {
+		return err
+	}
+	list, err := revocation.NewPersistentList(ctx, store)
+*/
+func NewPersistentList(ctx context.Context, store Store) (*List, error) {
+	epoch, seq, entries, err := store.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading revocation list: %w", err)
+	}
+	l := &List{epoch: epoch, store: store, index: make(map[string]struct{}, len(entries)), seq: seq}
+	now := time.Now()
+	for _, e := range entries {
+		if now.Before(e.ExpiresAt) {
+			l.entries = append(l.entries, e)
+			l.index[e.JTI] = struct{}{}
+		}
+	}
+	return l, nil
+}
+
+// Revoke adds the token with jti, which expires at expiresAt, to the list. Expired entries are removed.
+// It returns an error if the entry could not be stored, the token is not revoked then.
+func (l *List) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.prune(now)
+	if _, ok := l.index[jti]; ok || !now.Before(expiresAt) {
+		return nil
+	}
+	e := Entry{Seq: l.seq + 1, JTI: jti, ExpiresAt: expiresAt, RevokedAt: now}
+	if l.store != nil {
+		if err := l.store.Add(ctx, e, now); err != nil {
+			return fmt.Errorf("storing revocation: %w", err)
+		}
+	}
+	l.seq = e.Seq
+	l.entries = append(l.entries, e)
+	l.index[jti] = struct{}{}
+	return nil
+}
+
+// Revoked reports whether the token with jti was revoked.
+func (l *List) Revoked(jti string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.index[jti]
+	return ok
+}
+
+/*
+Since returns the unexpired entries added after the sequence number since.
+Entries removed after their token expired are not returned, so a verifier passing an old sequence number
+gets all entries it still needs.
+If epoch is not the epoch of the list or since is ahead of the list, since belongs to a list before a reset, and all entries are returned.
+
+Parameters:
+  - epoch: The Epoch of the previous page. If empty, only a since ahead of the list is detected as a reset.
+  - since: The Next of the previous page, or zero for all entries.
+
+Returns:
+  - Page: The epoch, the entries and the sequence number of the next page.
+*/
+func (l *List) Since(epoch string, since uint64) Page {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if (epoch != "" && epoch != l.epoch) || since > l.seq {
+		since = 0
+	}
+	now := time.Now()
+	page := Page{Epoch: l.epoch, Entries: []Entry{}, Next: l.seq}
+	for _, e := range l.entries {
+		if e.Seq > since && now.Before(e.ExpiresAt) {
+			page.Entries = append(page.Entries, e)
+		}
+	}
+	return page
+}
+
+// prune removes the expired entries. The mutex has to be held.
+func (l *List) prune(now time.Time) {
+	kept := l.entries[:0]
+	for _, e := range l.entries {
+		if now.Before(e.ExpiresAt) {
+			kept = append(kept, e)
+			continue
+		}
+		delete(l.index, e.JTI)
+	}
+	l.entries = kept
+}
+
+/*
+Handler returns the handler of the revocation list. GET ?epoch=<epoch>&since=<seq> returns the Page of the entries after since,
+or all entries if the list was reset since the epoch. The handler has to be restricted to authenticated clients.
+
+Example usage:
+
+	api.GET(revocation.Path, revocation.Handler(list), clients.Middleware(registry))
+*/
+func Handler(l *List) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var since uint64
+		if s := c.QueryParam("since"); s != "" {
+			var err error
+			if since, err = strconv.ParseUint(s, 10, 64); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid since")
+			}
+		}
+		c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+		return c.JSON(http.StatusOK, l.Since(c.QueryParam("epoch"), since))
+	}
+}
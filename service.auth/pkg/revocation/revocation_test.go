@@ -0,0 +1,236 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// revoke revokes the token with jti at l.
+func revoke(t *testing.T, l *List, jti string, expiresAt time.Time) {
+	t.Helper()
+	if err := l.Revoke(context.Background(), jti, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// jtis returns the jti of the entries of page.
+func jtis(page Page) []string {
+	jtis := make([]string, 0, len(page.Entries))
+	for _, e := range page.Entries {
+		jtis = append(jtis, e.JTI)
+	}
+	return jtis
+}
+
+// assertJTIs fails the test if page does not list exactly want in order.
+func assertJTIs(t *testing.T, page Page, want ...string) {
+	t.Helper()
+	got := jtis(page)
+	if len(got) != len(want) {
+		t.Fatalf("got entries %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got entries %v, want %v", got, want)
+		}
+	}
+}
+
+func TestListSince(t *testing.T) {
+	l := NewList()
+	exp := time.Now().Add(time.Hour)
+	revoke(t, l, "a", exp)
+	revoke(t, l, "b", exp)
+	revoke(t, l, "a", exp)
+
+	first := l.Since("", 0)
+	assertJTIs(t, first, "a", "b")
+	if first.Next != 2 || first.Epoch == "" {
+		t.Fatalf("unexpected page %+v", first)
+	}
+	if !l.Revoked("a") || l.Revoked("c") {
+		t.Error("unexpected Revoked")
+	}
+
+	revoke(t, l, "c", exp)
+	next := l.Since(first.Epoch, first.Next)
+	assertJTIs(t, next, "c")
+	if next.Next != 3 || next.Epoch != first.Epoch {
+		t.Errorf("unexpected page %+v", next)
+	}
+	assertJTIs(t, l.Since(next.Epoch, next.Next))
+}
+
+func TestListSkipsExpired(t *testing.T) {
+	l := NewList()
+	revoke(t, l, "expired", time.Now().Add(-time.Second))
+	revoke(t, l, "expiring", time.Now().Add(50*time.Millisecond))
+	revoke(t, l, "valid", time.Now().Add(time.Hour))
+	if l.Revoked("expired") {
+		t.Error("expired token listed")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	assertJTIs(t, l.Since("", 0), "valid")
+	// revoking removes the expired entries
+	revoke(t, l, "other", time.Now().Add(time.Hour))
+	if l.Revoked("expiring") {
+		t.Error("expired entry not removed")
+	}
+}
+
+func TestListSinceAfterReset(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	old := NewList()
+	for _, jti := range []string{"a", "b", "c"} {
+		revoke(t, old, jti, exp)
+	}
+	held := old.Since("", 0)
+
+	// the restarted replica revoked fewer tokens than the verifier has seen
+	restarted := NewList()
+	revoke(t, restarted, "d", exp)
+	page := restarted.Since(held.Epoch, held.Next)
+	assertJTIs(t, page, "d")
+	if page.Epoch == held.Epoch || page.Next != 1 {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	// the restarted replica caught up with the sequence number of the verifier
+	revoke(t, restarted, "e", exp)
+	revoke(t, restarted, "f", exp)
+	assertJTIs(t, restarted.Since(held.Epoch, held.Next), "d", "e", "f")
+
+	// a verifier without the epoch is only reset if it is ahead of the list
+	assertJTIs(t, NewList().Since("", held.Next))
+	fresh := NewList()
+	revoke(t, fresh, "g", exp)
+	assertJTIs(t, fresh.Since("", held.Next), "g")
+}
+
+func TestHandler(t *testing.T) {
+	l := NewList()
+	revoke(t, l, "a", time.Now().Add(time.Hour))
+	revoke(t, l, "b", time.Now().Add(time.Hour))
+	e := echo.New()
+	e.GET(Path, Handler(l))
+	epoch := l.Since("", 0).Epoch
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"a", "b"}},
+		{"?epoch=" + epoch + "&since=1", []string{"b"}},
+		{"?epoch=other&since=1", []string{"a", "b"}},
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path+tc.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: got %d: %s", tc.query, rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get(echo.HeaderCacheControl); got != "no-store" {
+			t.Errorf("%q: Cache-Control = %q, want no-store", tc.query, got)
+		}
+		var page Page
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Epoch != epoch {
+			t.Errorf("%q: got epoch %q, want %q", tc.query, page.Epoch, epoch)
+		}
+		assertJTIs(t, page, tc.want...)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path+"?since=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: got %d, want 400", rec.Code)
+	}
+}
+
+// testStore is a Store keeping the entries in memory, failing the adds with err.
+type testStore struct {
+	epoch   string
+	seq     uint64
+	entries []Entry
+	err     error
+}
+
+func (s *testStore) Load(context.Context) (string, uint64, []Entry, error) {
+	return s.epoch, s.seq, s.entries, nil
+}
+
+func (s *testStore) Add(_ context.Context, e Entry, _ time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.seq = e.Seq
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// testPersistentList revokes tokens at the list of store and checks they survive reloading it.
+func testPersistentList(t *testing.T, store Store, reopen func() Store) {
+	t.Helper()
+	ctx := context.Background()
+	l, err := NewPersistentList(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour)
+	revoke(t, l, "a", exp)
+	revoke(t, l, "expiring", time.Now().Add(50*time.Millisecond))
+	revoke(t, l, "b", exp)
+	held := l.Since("", 0)
+	time.Sleep(100 * time.Millisecond)
+
+	// the restarted list keeps its epoch and sequence numbers, so verifiers continue where they stopped
+	restarted, err := NewPersistentList(ctx, reopen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restarted.Revoked("a") || !restarted.Revoked("b") || restarted.Revoked("expiring") {
+		t.Error("revocations not restored")
+	}
+	page := restarted.Since(held.Epoch, held.Next)
+	assertJTIs(t, page)
+	if page.Epoch != held.Epoch || page.Next != 3 {
+		t.Errorf("unexpected page %+v, want epoch %s and next 3", page, held.Epoch)
+	}
+	revoke(t, restarted, "c", exp)
+	next := restarted.Since(page.Epoch, page.Next)
+	assertJTIs(t, next, "c")
+	if next.Entries[0].Seq != 4 {
+		t.Errorf("got sequence number %d, want 4", next.Entries[0].Seq)
+	}
+	assertJTIs(t, restarted.Since("", 0), "a", "b", "c")
+}
+
+func TestPersistentList(t *testing.T) {
+	store := &testStore{epoch: "epoch"}
+	testPersistentList(t, store, func() Store { return store })
+}
+
+func TestPersistentListStoreFailure(t *testing.T) {
+	l, err := NewPersistentList(context.Background(), &testStore{epoch: "epoch", err: errors.New("disk full")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Revoke(context.Background(), "a", time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("failed store not reported")
+	}
+	if l.Revoked("a") {
+		t.Error("token revoked without being stored")
+	}
+	if page := l.Since("", 0); page.Next != 0 {
+		t.Errorf("sequence number advanced to %d", page.Next)
+	}
+}
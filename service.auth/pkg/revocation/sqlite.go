@@ -0,0 +1,134 @@
+package revocation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SQLiteDriver is the name of the database/sql driver SQLite databases are opened with.
+// The driver is registered by building with the sqlite tag, see sqlite_driver.go.
+const SQLiteDriver string = "sqlite3"
+
+// sqliteSchema creates the tables of the list if they do not exist. The single row of revocation_list holds the epoch and the last sequence number,
+// which is kept when the entries with the highest sequence numbers expire.
+const sqliteSchema string = `
+CREATE TABLE IF NOT EXISTS revocation_list (
+	id    INTEGER PRIMARY KEY CHECK (id = 1),
+	epoch TEXT NOT NULL,
+	seq   INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS revocations (
+	seq        INTEGER PRIMARY KEY,
+	jti        TEXT NOT NULL UNIQUE,
+	expires_at INTEGER NOT NULL,
+	revoked_at INTEGER NOT NULL
+)`
+
+// SQLiteStore is an implementation of Store keeping the revocation list in a SQLite database, so it survives restarts.
+// It is meant for small deployments, the database is not shared between the replicas.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// interface guard
+var _ Store = (*SQLiteStore)(nil)
+
+/*
+NewSQLiteStore opens the SQLite database at path and creates the tables of the list and its epoch if they do not exist.
+
+Parameters:
+  - ctx: The context of the schema creation.
+  - path: The path of the database file, or a data source name understood by the driver.
+
+Returns:
+  - *SQLiteStore: The created store, closed with Close.
+  - error: An error if the database could not be opened or migrated, e.g. if the binary was built without the sqlite tag.
+
+Example usage:
+
+	store, err := revocation.NewSQLiteStore(ctx, "/var/lib/auth-service/revocations.db")
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+*/
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, serializing the connections avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("creating revocation tables: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT OR IGNORE INTO revocation_list (id, epoch, seq) VALUES (1, ?, 0)", uuid.NewString()); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("creating revocation list epoch: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// PingContext checks the connection to the database. It implements health.Pinger.
+func (s *SQLiteStore) PingContext(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Load implements Store.
+func (s *SQLiteStore) Load(ctx context.Context) (string, uint64, []Entry, error) {
+	var epoch string
+	var seq uint64
+	if err := s.db.QueryRowContext(ctx, "SELECT epoch, seq FROM revocation_list WHERE id = 1").Scan(&epoch, &seq); err != nil {
+		return "", 0, nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT seq, jti, expires_at, revoked_at FROM revocations ORDER BY seq")
+	if err != nil {
+		return "", 0, nil, err
+	}
+	defer rows.Close()
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var expiresAt, revokedAt int64
+		if err := rows.Scan(&e.Seq, &e.JTI, &expiresAt, &revokedAt); err != nil {
+			return "", 0, nil, err
+		}
+		e.ExpiresAt = time.Unix(0, expiresAt).UTC()
+		e.RevokedAt = time.Unix(0, revokedAt).UTC()
+		entries = append(entries, e)
+	}
+	return epoch, seq, entries, rows.Err()
+}
+
+// Add implements Store.
+func (s *SQLiteStore) Add(ctx context.Context, e Entry, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback of a committed transaction is a no-op
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM revocations WHERE expires_at <= ?", now.UnixNano()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO revocations (seq, jti, expires_at, revoked_at) VALUES (?, ?, ?, ?)",
+		e.Seq, e.JTI, e.ExpiresAt.UnixNano(), e.RevokedAt.UnixNano(),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE revocation_list SET seq = ? WHERE id = 1", e.Seq); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
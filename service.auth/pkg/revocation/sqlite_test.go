@@ -0,0 +1,32 @@
+//go:build sqlite
+
+package revocation
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "revocations.db")
+	store, err := NewSQLiteStore(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	testPersistentList(t, store, func() Store {
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+		store, err = NewSQLiteStore(ctx, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/config"
+)
+
+/*
+AdminAuth returns the middleware restricting the admin API to requests with the bearer token of adminConfig.
+
+Parameters:
+  - adminConfig: The config of the admin API holding the token or the file it is read from.
+
+Returns:
+  - echo.MiddlewareFunc: The middleware, or nil if no token is configured and the admin API is disabled.
+  - error: An error if the token file could not be read.
+*/
+func AdminAuth(adminConfig config.AdminAPIConfig) (echo.MiddlewareFunc, error) {
+	token := adminConfig.Token
+	if token == "" && adminConfig.TokenFile != "" {
+		b, err := os.ReadFile(adminConfig.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading admin API token: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token == "" {
+		return nil, nil
+	}
+	return middleware.KeyAuth(func(key string, _ echo.Context) (bool, error) {
+		return subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+	}), nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/config"
+)
+
+func TestAdminAuth(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		config config.AdminAPIConfig
+		token  string
+	}{
+		{"token", config.AdminAPIConfig{Token: "token", TokenFile: tokenFile}, "token"},
+		{"token file", config.AdminAPIConfig{TokenFile: tokenFile}, "file-token"},
+	} {
+		mw, err := AdminAuth(tc.config)
+		if err != nil || mw == nil {
+			t.Fatalf("%s: got %v", tc.name, err)
+		}
+		e := echo.New()
+		e.GET("/admin", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }, mw)
+
+		for _, auth := range []struct {
+			header string
+			want   int
+		}{
+			{"", http.StatusBadRequest},
+			{"Bearer wrong", http.StatusUnauthorized},
+			{"Basic " + tc.token, http.StatusBadRequest},
+			{"Bearer " + tc.token, http.StatusNoContent},
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if auth.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, auth.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != auth.want {
+				t.Errorf("%s: %q: got %d, want %d", tc.name, auth.header, rec.Code, auth.want)
+			}
+		}
+	}
+}
+
+func TestAdminAuthDisabled(t *testing.T) {
+	mw, err := AdminAuth(config.AdminAPIConfig{})
+	if err != nil || mw != nil {
+		t.Errorf("got %v, want no middleware", err)
+	}
+	if _, err := AdminAuth(config.AdminAPIConfig{TokenFile: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("missing token file accepted")
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/health"
+	"github.com/SaimonWoidig/cc-microsvcs/common/logging"
+	otelmetrics "github.com/SaimonWoidig/cc-microsvcs/common/otel/metrics"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/config"
+)
+
+// V1Path is the path prefix of version 1 of the auth API.
+const V1Path string = "/v1"
+
+// The defaults of the unset server config fields.
+const (
+	DefaultBodyLimit         string        = "1M"
+	DefaultReadTimeout       time.Duration = 15 * time.Second
+	DefaultReadHeaderTimeout time.Duration = 5 * time.Second
+	DefaultWriteTimeout      time.Duration = 30 * time.Second
+	DefaultIdleTimeout       time.Duration = 2 * time.Minute
+	DefaultRequestTimeout    time.Duration = 10 * time.Second
+)
+
+// probePaths are the paths of the health probes, which are not recorded by the metrics middleware.
+var probePaths = map[string]struct{}{
+	health.LivezPath:   {},
+	health.ReadyzPath:  {},
+	health.HealthzPath: {},
+}
+
+/*
+NewEcho creates the Echo instance of the auth API with the middleware shared by all routes.
+The middleware assigns request IDs, recovers from panics, records the HTTP semantic convention metrics,
+limits the size of request bodies and cancels the context of requests running longer than the request timeout.
+
+Parameters:
+  - serverConfig: The config of the server.
+  - meterProvider: The MeterProvider the HTTP metrics are recorded with.
+  - logger: The logger the recovered panics are logged with.
+
+Returns:
+  - *echo.Echo: The created Echo instance. The API is registered on its V1Path group.
+  - error: An error if the metrics middleware could not be created.
+*/
+func NewEcho(serverConfig config.ServerConfig, meterProvider metric.MeterProvider, logger *slog.Logger) (*echo.Echo, error) {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.Logger = logging.NewNoopLogger()
+
+	metricsMiddleware, err := otelmetrics.NewWithConfig(otelmetrics.Config{
+		MeterProvider: meterProvider,
+		Skipper: func(c echo.Context) bool {
+			_, ok := probePaths[c.Path()]
+			return ok
+		},
+		MeterName: otelmetrics.DefaultMeterName,
+		SemConv:   true,
+		Exemplars: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bodyLimit := serverConfig.BodyLimit
+	if bodyLimit == "" {
+		bodyLimit = DefaultBodyLimit
+	}
+	requestTimeout := seconds(serverConfig.RequestTimeoutSeconds, DefaultRequestTimeout)
+
+	e.Use(
+		middleware.RequestID(),
+		middleware.RecoverWithConfig(middleware.RecoverConfig{
+			DisablePrintStack: true,
+			LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+				logger.Error("recovered from panic",
+					"error", err.Error(),
+					"request_id", c.Response().Header().Get(echo.HeaderXRequestID),
+					"stack", string(stack),
+				)
+				return err
+			},
+		}),
+		metricsMiddleware,
+		middleware.BodyLimit(bodyLimit),
+		middleware.ContextTimeout(requestTimeout),
+	)
+	return e, nil
+}
+
+/*
+NewHTTPServer returns the http.Server serving handler with the timeouts and TLS options of serverConfig.
+The server is not started. If TLS is enabled, it has to be served with ServeTLS(listener, "", "").
+
+Parameters:
+  - serverConfig: The config of the server.
+  - handler: The handler of the server, usually the Echo instance created by NewEcho.
+
+Returns:
+  - *http.Server: The created server.
+  - error: An error if the TLS certificate or client CA could not be loaded.
+*/
+func NewHTTPServer(serverConfig config.ServerConfig, handler http.Handler) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:              net.JoinHostPort(serverConfig.ListenAddr, strconv.Itoa(serverConfig.Port)),
+		Handler:           handler,
+		ReadTimeout:       seconds(serverConfig.ReadTimeoutSeconds, DefaultReadTimeout),
+		ReadHeaderTimeout: seconds(serverConfig.ReadHeaderTimeoutSeconds, DefaultReadHeaderTimeout),
+		WriteTimeout:      seconds(serverConfig.WriteTimeoutSeconds, DefaultWriteTimeout),
+		IdleTimeout:       seconds(serverConfig.IdleTimeoutSeconds, DefaultIdleTimeout),
+	}
+	if serverConfig.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(serverConfig.TLS)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+	return srv, nil
+}
+
+// newTLSConfig returns the TLS config of the server, verifying client certificates against the client CA if set.
+func newTLSConfig(tlsConfig config.TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading server certificate: %w", err)
+	}
+	minVersion, err := tlsVersion(tlsConfig.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}
+	if tlsConfig.ClientCAFile != "" {
+		pem, err := os.ReadFile(tlsConfig.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates in client CA")
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return c, nil
+}
+
+// tlsVersion returns the TLS version named version, "1.2" or "1.3". An empty version is TLS 1.2.
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q", version)
+	}
+}
+
+// seconds returns s seconds as a duration, or def if s is not positive.
+func seconds(s int, def time.Duration) time.Duration {
+	if s <= 0 {
+		return def
+	}
+	return time.Duration(s) * time.Second
+}
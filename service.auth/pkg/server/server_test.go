@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/config"
+)
+
+// newTestEcho returns the Echo instance of serverConfig and the buffer its logger writes to.
+func newTestEcho(t *testing.T, serverConfig config.ServerConfig) (*echo.Echo, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	e, err := NewEcho(serverConfig, noop.NewMeterProvider(), slog.New(slog.NewJSONHandler(&logs, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, &logs
+}
+
+func TestNewEchoRequestID(t *testing.T) {
+	e, _ := newTestEcho(t, config.ServerConfig{})
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get(echo.HeaderXRequestID) == "" {
+		t.Error("no request ID assigned")
+	}
+
+	// the request ID of the caller is kept
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderXRequestID, "caller-id")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if got := rec.Header().Get(echo.HeaderXRequestID); got != "caller-id" {
+		t.Errorf("got request ID %q, want caller-id", got)
+	}
+}
+
+func TestNewEchoRecovers(t *testing.T) {
+	e, logs := newTestEcho(t, config.ServerConfig{})
+	e.GET("/panic", func(echo.Context) error { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(echo.HeaderXRequestID, "panic-id")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got %d, want 500", rec.Code)
+	}
+	for _, want := range []string{"recovered from panic", "boom", "panic-id", `"stack"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log %s does not contain %q", logs, want)
+		}
+	}
+}
+
+func TestNewEchoBodyLimit(t *testing.T) {
+	e, _ := newTestEcho(t, config.ServerConfig{BodyLimit: "1K"})
+	e.POST("/", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	for _, tc := range []struct {
+		size int
+		want int
+	}{
+		{512, http.StatusNoContent},
+		{2048, http.StatusRequestEntityTooLarge},
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(make([]byte, tc.size))))
+		if rec.Code != tc.want {
+			t.Errorf("body of %d bytes: got %d, want %d", tc.size, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestNewEchoRequestTimeout(t *testing.T) {
+	e, _ := newTestEcho(t, config.ServerConfig{RequestTimeoutSeconds: 1})
+	var deadline time.Time
+	e.GET("/slow", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		deadline, _ = ctx.Deadline()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	begin := time.Now()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, want 503", rec.Code)
+	}
+	if d := deadline.Sub(begin); d <= 0 || d > 2*time.Second {
+		t.Errorf("got request deadline in %s, want 1s", d)
+	}
+}
+
+// writeTestCert writes a self-signed certificate and its key to dir and returns their paths.
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "auth"},
+		DNSNames:              []string{"auth"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "auth.crt")
+	keyFile := filepath.Join(dir, "auth.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	srv, err := NewHTTPServer(config.ServerConfig{ListenAddr: "127.0.0.1", Port: 8080, WriteTimeoutSeconds: 60}, http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Addr != "127.0.0.1:8080" {
+		t.Errorf("got address %q", srv.Addr)
+	}
+	if srv.ReadTimeout != DefaultReadTimeout || srv.ReadHeaderTimeout != DefaultReadHeaderTimeout || srv.IdleTimeout != DefaultIdleTimeout {
+		t.Errorf("defaults not applied: %+v", srv)
+	}
+	if srv.WriteTimeout != time.Minute {
+		t.Errorf("got write timeout %s, want 1m", srv.WriteTimeout)
+	}
+	if srv.TLSConfig != nil {
+		t.Error("TLS configured without being enabled")
+	}
+}
+
+func TestNewHTTPServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+	srv, err := NewHTTPServer(config.ServerConfig{TLS: config.TLSConfig{
+		Enabled:      true,
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: certFile,
+		MinVersion:   "1.3",
+	}}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.TLSConfig.MinVersion != tls.VersionTLS13 || srv.TLSConfig.ClientAuth != tls.VerifyClientCertIfGiven || srv.TLSConfig.ClientCAs == nil {
+		t.Errorf("unexpected TLS config %+v", srv.TLSConfig)
+	}
+
+	ts := httptest.NewUnstartedServer(srv.Handler)
+	ts.TLS = srv.TLSConfig
+	ts.StartTLS()
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pem, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.AppendCertsFromPEM(pem)
+	for _, tc := range []struct {
+		maxVersion uint16
+		ok         bool
+	}{
+		{tls.VersionTLS13, true},
+		{tls.VersionTLS12, false},
+	} {
+		// the client certificate is optional
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:    pool,
+			ServerName: "auth",
+			MaxVersion: tc.maxVersion,
+		}}}
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := client.Do(req)
+		if tc.ok != (err == nil) {
+			t.Errorf("TLS version %x: got error %v, want success %t", tc.maxVersion, err, tc.ok)
+		}
+		if err == nil {
+			res.Body.Close()
+		}
+	}
+}
+
+func TestNewHTTPServerTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir)
+	emptyCA := filepath.Join(dir, "empty.crt")
+	if err := os.WriteFile(emptyCA, []byte("no certificates"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for name, tlsConfig := range map[string]config.TLSConfig{
+		"missing certificate": {Enabled: true, CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile},
+		"unsupported version": {Enabled: true, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.1"},
+		"missing client CA":   {Enabled: true, CertFile: certFile, KeyFile: keyFile, ClientCAFile: filepath.Join(dir, "missing-ca.crt")},
+		"empty client CA":     {Enabled: true, CertFile: certFile, KeyFile: keyFile, ClientCAFile: emptyCA},
+	} {
+		if _, err := NewHTTPServer(config.ServerConfig{TLS: tlsConfig}, http.NotFoundHandler()); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestTLSVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version string
+		want    uint16
+		ok      bool
+	}{
+		{"", tls.VersionTLS12, true},
+		{"1.2", tls.VersionTLS12, true},
+		{"1.3", tls.VersionTLS13, true},
+		{"1.1", 0, false},
+		{"TLS1.3", 0, false},
+	} {
+		got, err := tlsVersion(tc.version)
+		if got != tc.want || tc.ok != (err == nil) {
+			t.Errorf("tlsVersion(%q) = %x, %v", tc.version, got, err)
+		}
+	}
+}
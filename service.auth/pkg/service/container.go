@@ -1,90 +1,748 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/agoda-com/opentelemetry-logs-go/exporters/otlp/otlplogs"
 	"github.com/agoda-com/opentelemetry-logs-go/logs"
-	"github.com/google/uuid"
+	sdklogs "github.com/agoda-com/opentelemetry-logs-go/sdk/logs"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 
+	"github.com/SaimonWoidig/cc-microsvcs/common/admin"
 	commonconfig "github.com/SaimonWoidig/cc-microsvcs/common/config"
+	"github.com/SaimonWoidig/cc-microsvcs/common/health"
+	"github.com/SaimonWoidig/cc-microsvcs/common/lifecycle"
 	"github.com/SaimonWoidig/cc-microsvcs/common/logging"
 	commonotel "github.com/SaimonWoidig/cc-microsvcs/common/otel"
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/diskqueue"
 	otellogging "github.com/SaimonWoidig/cc-microsvcs/common/otel/logging"
 	otelmetrics "github.com/SaimonWoidig/cc-microsvcs/common/otel/metrics"
+	"github.com/SaimonWoidig/cc-microsvcs/common/otel/selfobs"
 	oteltracing "github.com/SaimonWoidig/cc-microsvcs/common/otel/tracing"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/clients"
 	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/config"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/keys"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/login"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oauth2"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/oidc"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/revocation"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/server"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/telemetry"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/tokens"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/users"
 )
 
 const AppName = "auth-service"
 
 type Container struct {
 	Config         *config.Config
+	InstanceID     string
 	Logger         *slog.Logger
+	Levels         *logging.Levels
 	Resource       *resource.Resource
 	TracerProvider trace.TracerProvider
 	MeterProvider  metric.MeterProvider
 	LoggerProvider logs.LoggerProvider
+	MetricsServer  *http.Server
+	Metrics        *telemetry.Metrics
+	Health         *health.Health
+	Echo           *echo.Echo
+	// API is the group of the versioned auth API, see server.V1Path.
+	API         *echo.Group
+	HTTPServer  *http.Server
+	AdminServer *http.Server
+	Users       *users.Service
+	// SigningKeys are the keys the tokens are signed with.
+	SigningKeys   *keys.Manager
+	Issuer        *tokens.Issuer
+	RefreshTokens *tokens.RefreshTokens
+	// Clients are the OAuth2 clients authenticated at the token, introspection and revocation endpoints.
+	Clients *clients.Service
+	// ClientAuth authenticates the clients.
+	ClientAuth *clients.Authenticator
+	// Revocations is the list of the revoked access tokens.
+	Revocations *revocation.List
+	// OIDC is the OpenID Connect provider of the web console and third-party tools.
+	OIDC *oidc.Provider
+
+	// lifecycle starts and stops the components of the container.
+	lifecycle *lifecycle.Lifecycle
+	// stats are the self-observability instruments of the telemetry exporters.
+	stats *selfobs.Stats
+	// metricOpts are the additional options of the MeterProvider, e.g. the Prometheus reader.
+	metricOpts []sdkmetric.Option
+	// tracingMode, metricsMode and logsMode are the resolved telemetry modes of the signals.
+	tracingMode string
+	metricsMode string
+	logsMode    string
+	// userRepo is the repository of Users.
+	userRepo users.Repository
+	// clientRepo is the repository of Clients.
+	clientRepo clients.Repository
+	// revocationStore is the store of Revocations, nil for the memory store.
+	revocationStore revocation.Store
+}
+
+// provider is a telemetry provider which buffers telemetry until it is flushed or shut down.
+type provider interface {
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+/*
+NewContainer creates the container and starts its components in dependency order.
+If a component fails to start, the started components are stopped again and the error is returned.
+
+Parameters:
+  - ctx: The context of the startup.
+
+Returns:
+  - *Container: The started container, stopped with Shutdown.
+  - error: An error if a component failed to start.
+*/
+func NewContainer(ctx context.Context) (*Container, error) {
+	c := &Container{lifecycle: lifecycle.New(nil)}
+
+	hooks := []lifecycle.Hook{
+		{Name: "config", Start: c.startConfig},
+		{Name: "logger", DependsOn: []string{"config"}, Start: c.startLogger},
+		{Name: "health", DependsOn: []string{"config"}, Start: c.startHealth},
+		{Name: "telemetry.resource", DependsOn: []string{"logger"}, Start: c.startResource},
+		{Name: "telemetry.tracing", DependsOn: []string{"telemetry.resource"}, Start: c.startTracing, Stop: c.stopTracing},
+		{Name: "telemetry.metrics", DependsOn: []string{"telemetry.resource"}, Start: c.startMetrics, Stop: c.stopMetrics},
+		{Name: "telemetry.logging", DependsOn: []string{"telemetry.resource"}, Start: c.startLogging, Stop: c.stopLogging},
+		{Name: "telemetry.metrics.server", DependsOn: []string{"telemetry.metrics", "telemetry.logging"}, Start: c.startMetricsServer, Stop: c.stopMetricsServer},
+		{Name: "admin.server", DependsOn: []string{"telemetry.resource", "telemetry.logging"}, Start: c.startAdminServer, Stop: c.stopAdminServer},
+		{Name: "users", DependsOn: []string{"health", "telemetry.logging"}, Start: c.startUsers, Stop: c.stopUsers},
+		{Name: "signing.keys", DependsOn: []string{"health", "logger"}, Start: c.startSigningKeys, Stop: c.stopSigningKeys},
+		{Name: "tokens", DependsOn: []string{"health", "logger", "signing.keys"}, Start: c.startTokens, Stop: c.stopTokens},
+		{Name: "clients", DependsOn: []string{"health", "tokens", "logger"}, Start: c.startClients, Stop: c.stopClients},
+		{Name: "oidc", DependsOn: []string{"users", "tokens", "clients", "telemetry.metrics"}, Start: c.startOIDC},
+		{Name: "http.server", DependsOn: []string{"health", "users", "tokens", "clients", "oidc", "telemetry.metrics", "telemetry.logging"}, Start: c.startHTTPServer, Stop: c.stopHTTPServer},
+	}
+	for _, h := range hooks {
+		if err := c.lifecycle.Append(h); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.lifecycle.Start(ctx); err != nil {
+		return nil, err
+	}
+	c.Health.SetReady(true)
+	return c, nil
 }
 
-func NewContainer() *Container {
-	c := new(Container)
+/*
+Shutdown stops the components of the container in the reverse order they were started in.
+The telemetry providers are flushed before they are shut down, so the last batch of spans, metrics and log records is exported.
+All components are stopped even if stopping one of them fails. The readiness probe fails from the start of the shutdown.
 
+Parameters:
+  - ctx: The context bounding the shutdown. Components not stopped before it is done return its error.
+
+Returns:
+  - error: The errors of all components which failed to stop joined with errors.Join, or nil.
+
+Example usage:
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout())
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		c.Logger.Error("error while shutting down container", "error", err.Error())
+	}
+*/
+func (c *Container) Shutdown(ctx context.Context) error {
+	c.Health.SetReady(false)
+	return c.lifecycle.Stop(ctx)
+}
+
+// ShutdownTimeout returns the deadline of Shutdown from the config.
+func (c *Container) ShutdownTimeout() time.Duration {
+	return time.Duration(c.Config.Shutdown.TimeoutSeconds) * time.Second
+}
+
+func (c *Container) startConfig(_ context.Context) error {
 	cfg, err := initConfig()
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 	c.Config = cfg
+	if c.tracingMode, err = commonotel.SignalMode(cfg.Telemetry.Mode, cfg.Telemetry.Tracing.Mode); err != nil {
+		return err
+	}
+	if c.metricsMode, err = commonotel.SignalMode(cfg.Telemetry.Mode, cfg.Telemetry.Metrics.Mode); err != nil {
+		return err
+	}
+	if c.logsMode, err = commonotel.SignalMode(cfg.Telemetry.Mode, cfg.Telemetry.Logging.Mode); err != nil {
+		return err
+	}
+	return nil
+}
 
-	c.Logger = initLogger(c.Config.Logging.LogLevel, c.Config.Logging.Pretty)
+func (c *Container) startLogger(_ context.Context) error {
+	c.Levels = logging.NewLevels()
+	c.Logger = initLogger(c.Levels.Var("stdout", c.Config.Logging.LogLevel), c.Config.Logging.Pretty)
+	c.lifecycle.SetLogger(c.Logger)
 	c.Logger.Info("base logger initialized")
-
 	otel.SetErrorHandler(commonotel.NewOtelSlogErrorHandler(c.Logger))
-	iid := uuid.New().String()
-	res, err := initResource(iid, c.Config.Server.Addr, c.Config.Server.Port)
+	return nil
+}
+
+func (c *Container) startHealth(_ context.Context) error {
+	c.Health = health.New(health.Config{
+		CacheTTL: time.Duration(c.Config.Health.CacheTTLSeconds) * time.Second,
+		Timeout:  time.Duration(c.Config.Health.TimeoutSeconds) * time.Second,
+	})
+	for _, endpoint := range c.otlpEndpoints() {
+		c.Health.AddCheck(health.NewTCPCheck("otlp "+endpoint, endpoint))
+	}
+	if c.Config.Telemetry.Queue.Enabled {
+		minFree := uint64(c.Config.Health.MinFreeDiskMiB) << 20
+		c.Health.AddReadinessCheck(health.NewDiskSpaceCheck("disk telemetry queue", c.Config.Telemetry.Queue.Dir, minFree))
+	}
+	return nil
+}
+
+func (c *Container) startResource(ctx context.Context) error {
+	// the global MeterProvider forwards to the MeterProvider of the service once it is set by startMetrics
+	stats, err := selfobs.New(otel.GetMeterProvider())
+	if err != nil {
+		return err
+	}
+	c.stats = stats
+	iid, err := commonotel.NewInstanceID(commonotel.InstanceIDConfig{
+		ServiceName: AppName,
+		Source:      c.Config.Telemetry.Instance.Source,
+		StateDir:    c.Config.Telemetry.Instance.StateDir,
+	})
 	if err != nil {
-		panic(err.Error())
+		return err
+	}
+	c.InstanceID = iid
+	// the OTLP log records carry the ID in their resource, only the base logger needs it as an attribute
+	c.Logger = c.Logger.With("instance_id", c.InstanceID)
+	c.lifecycle.SetLogger(c.Logger)
+	c.Logger.Info("service instance ID resolved", "source", c.Config.Telemetry.Instance.Source)
+	res, err := initResource(ctx, c.InstanceID, c.Config.Server, c.Config.Telemetry)
+	if err != nil {
+		return err
 	}
 	c.Resource = res
+	return nil
+}
+
+func (c *Container) startTracing(_ context.Context) error {
+	c.Logger.Info("tracing mode", "mode", c.tracingMode)
+	switch c.tracingMode {
+	case commonotel.ModeOff:
+		c.TracerProvider = tracenoop.NewTracerProvider()
+		otel.SetTracerProvider(c.TracerProvider)
+		return nil
+	case commonotel.ModeConsole:
+		tp, err := initConsoleTracing(c.Resource, c.Config.Telemetry.Tracing.SamplingRatio)
+		if err != nil {
+			return err
+		}
+		c.TracerProvider = tp
+		otel.SetTracerProvider(c.TracerProvider)
+		return nil
+	}
 	tp, err := initOtelTracing(
 		c.Resource,
 		c.Config.Telemetry.Tracing.OTLPEndpoint,
 		c.Config.Telemetry.Tracing.ExportTimeoutSeconds,
 		c.Config.Telemetry.Tracing.SamplingRatio,
+		c.Config.Telemetry.Queue,
+		c.stats,
 	)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 	c.TracerProvider = tp
-	mp, err := initOtelMetrics(c.Resource, c.Config.Telemetry.Metrics.OTLPEndpoint, c.Config.Telemetry.Metrics.ExportTimeoutSeconds, c.Config.Telemetry.Metrics.ExportIntervalSeconds, c.Config.Telemetry.Metrics.MemStatsIntervalSeconds)
+	otel.SetTracerProvider(c.TracerProvider)
+	return nil
+}
+
+func (c *Container) startMetrics(_ context.Context) error {
+	c.Logger.Info("metrics mode", "mode", c.metricsMode)
+	if c.metricsMode == commonotel.ModeOff {
+		if c.Config.Telemetry.Metrics.Prometheus.Enabled {
+			c.Logger.Warn("prometheus metrics server disabled, as metrics are off")
+		}
+		c.MeterProvider = metricnoop.NewMeterProvider()
+		otel.SetMeterProvider(c.MeterProvider)
+		return c.initMetrics()
+	}
+	if c.Config.Telemetry.Metrics.Prometheus.Enabled {
+		promExporter, promServer, err := initPrometheus(c.Config.Telemetry.Metrics.Prometheus.Addr, c.Config.Telemetry.Metrics.Prometheus.Port)
+		if err != nil {
+			return err
+		}
+		c.metricOpts = append(c.metricOpts, sdkmetric.WithReader(promExporter))
+		c.MetricsServer = promServer
+	}
+	var mp *sdkmetric.MeterProvider
+	var err error
+	if c.metricsMode == commonotel.ModeConsole {
+		mp, err = initConsoleMetrics(c.Resource, c.Config.Telemetry.Metrics, c.metricOpts...)
+	} else {
+		mp, err = initOtelMetrics(c.Resource, c.Config.Telemetry.Metrics, c.Config.Telemetry.Queue, c.stats, c.metricOpts...)
+	}
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 	c.MeterProvider = mp
-	lp, err := initOtelLogging(c.Resource, c.Config.Telemetry.Logging.OTLPEndpoint, c.Config.Telemetry.Logging.ExportTimeoutSeconds, c.Config.Telemetry.Logging.BatchTimeoutSeconds)
+	otel.SetMeterProvider(c.MeterProvider)
+	return c.initMetrics()
+}
+
+// initMetrics creates the business metrics of the service with the MeterProvider.
+func (c *Container) initMetrics() error {
+	m, err := telemetry.NewMetrics(c.MeterProvider.Meter(AppName))
 	if err != nil {
-		panic(err.Error())
+		return err
+	}
+	c.Metrics = m
+	return nil
+}
+
+func (c *Container) startLogging(_ context.Context) error {
+	if c.logsMode != commonotel.ModeOTLP {
+		// the base logger already prints to stdout, so the console mode only disables the OTLP export
+		c.Logger.Info("OTLP logging disabled", "mode", c.logsMode)
+		c.LoggerProvider = logs.NewNoopLoggerProvider()
+		return nil
+	}
+	lp, err := initOtelLogging(c.Resource, c.Config.Telemetry.Logging, c.Config.Telemetry.Queue, c.stats)
+	if err != nil {
+		return err
 	}
 	c.LoggerProvider = lp
-	cl := otellogging.NewSlogOtelCompositeLogger(c.Logger.Handler(), otellogging.NewSlogOtelHandler(c.LoggerProvider, c.Config.Telemetry.Logging.LogLevel))
+	cl := otellogging.NewSlogOtelCompositeLogger(c.Logger.Handler(), otellogging.NewSlogOtelHandlerWithLeveler(c.LoggerProvider, c.Levels.Var("otlp", c.Config.Telemetry.Logging.LogLevel)))
 	c.Logger = cl
+	c.lifecycle.SetLogger(c.Logger)
 	c.Logger.Info("composite OTLP logger initialized")
+	return nil
+}
+
+func (c *Container) startMetricsServer(_ context.Context) error {
+	if c.MetricsServer == nil {
+		return nil
+	}
+	go func() {
+		if err := c.MetricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.Logger.Error("prometheus metrics server failed", "error", err.Error())
+		}
+	}()
+	c.Logger.Info("prometheus metrics server started", "addr", c.MetricsServer.Addr)
+	return nil
+}
+
+func (c *Container) stopMetricsServer(ctx context.Context) error {
+	if c.MetricsServer == nil {
+		return nil
+	}
+	return c.MetricsServer.Shutdown(ctx)
+}
+
+func (c *Container) startHTTPServer(_ context.Context) error {
+	e, err := server.NewEcho(c.Config.Server, c.MeterProvider, c.Logger)
+	if err != nil {
+		return err
+	}
+	c.Echo = e
+	health.RegisterRoutes(c.Echo, c.Health)
+	c.Echo.GET(keys.JWKSPath, keys.JWKSHandler(c.SigningKeys, time.Duration(c.Config.Tokens.SigningKeys.JWKSMaxAgeSeconds)*time.Second))
+	c.API = c.Echo.Group(server.V1Path)
+	login.NewHandler(c.Users, c.Clients.Registry(), c.Issuer, c.RefreshTokens, c.Metrics, c.Logger, c.Config.Tokens.Access.LoginClientID).RegisterRoutes(c.API)
+	adminAuth, err := server.AdminAuth(c.Config.Server.AdminAPI)
+	if err != nil {
+		return err
+	}
+	oauth2Handler := oauth2.NewHandler(c.Issuer, c.RefreshTokens, c.Revocations, c.Metrics, c.Logger)
+	oauth2Handler.RegisterRoutes(c.API, c.ClientAuth)
+	c.OIDC.RegisterGrants(oauth2Handler)
+	c.OIDC.RegisterRoutes(c.Echo, c.API)
+	if adminAuth != nil {
+		users.RegisterAdminRoutes(c.API.Group(users.AdminPath, adminAuth), c.Users)
+		clients.RegisterAdminRoutes(c.API.Group(clients.AdminPath, adminAuth), c.Clients)
+		oauth2Handler.RegisterAdminRoutes(c.API.Group(oauth2.AdminPath, adminAuth))
+	} else {
+		c.Logger.Warn("admin API disabled, as no token is configured")
+	}
+	srv, err := server.NewHTTPServer(c.Config.Server, c.Echo)
+	if err != nil {
+		return err
+	}
+	c.HTTPServer = srv
+	// listen before returning, so a port already in use fails the startup
+	ln, err := net.Listen("tcp", c.HTTPServer.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		var err error
+		if c.HTTPServer.TLSConfig != nil {
+			err = c.HTTPServer.ServeTLS(ln, "", "")
+		} else {
+			err = c.HTTPServer.Serve(ln)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.Logger.Error("http server failed", "error", err.Error())
+		}
+	}()
+	c.Logger.Info("http server started", "addr", ln.Addr().String(), "tls", c.HTTPServer.TLSConfig != nil)
+	return nil
+}
 
-	return c
+// stopHTTPServer keeps serving for the drain delay, as the readiness probe already fails, and then shuts the server down gracefully.
+func (c *Container) stopHTTPServer(ctx context.Context) error {
+	if delay := time.Duration(c.Config.Server.DrainDelaySeconds) * time.Second; delay > 0 {
+		c.Logger.Info("draining http server", "delay", delay.String())
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	return c.HTTPServer.Shutdown(ctx)
 }
 
-func (c *Container) Shutdown() error {
+func (c *Container) startUsers(ctx context.Context) error {
+	usersConfig := c.Config.Users
+	switch usersConfig.Store {
+	case users.StoreMemory:
+		c.userRepo = users.NewMemoryRepository()
+	case users.StoreSQLite:
+		repo, err := users.NewSQLiteRepository(ctx, usersConfig.SQLite.Path)
+		if err != nil {
+			return err
+		}
+		c.userRepo = repo
+		c.Health.AddReadinessCheck(health.NewPingCheck("users sqlite", repo))
+	default:
+		return fmt.Errorf("unknown users store %q", usersConfig.Store)
+	}
+	svc, err := users.NewService(c.userRepo, users.NewHasher(users.Argon2Params{
+		Memory:      usersConfig.Argon2.MemoryKiB,
+		Iterations:  usersConfig.Argon2.Iterations,
+		Parallelism: usersConfig.Argon2.Parallelism,
+		SaltLength:  usersConfig.Argon2.SaltLength,
+		KeyLength:   usersConfig.Argon2.KeyLength,
+	}), c.Logger)
+	if err != nil {
+		return err
+	}
+	c.Users = svc
+	c.Logger.Info("users store initialized", "store", usersConfig.Store)
+	return nil
+}
+
+func (c *Container) stopUsers(_ context.Context) error {
+	if closer, ok := c.userRepo.(io.Closer); ok {
+		return closer.Close()
+	}
 	return nil
 }
 
+func (c *Container) startSigningKeys(ctx context.Context) error {
+	keysConfig := c.Config.Tokens.SigningKeys
+	var store keys.Store
+	if keysConfig.File != "" {
+		secret, err := readSecret(keysConfig.Secret, keysConfig.SecretFile)
+		if err != nil {
+			return err
+		}
+		if store, err = keys.NewFileStore(keysConfig.File, secret); err != nil {
+			return err
+		}
+	} else {
+		c.Logger.Warn("no signing key file configured, tokens are invalid after a restart")
+		store = keys.NewMemoryStore()
+	}
+	m, err := keys.NewManager(keys.Config{
+		Algorithm:        keysConfig.Algorithm,
+		RotationInterval: time.Duration(keysConfig.RotationIntervalSeconds) * time.Second,
+		PublishAhead:     time.Duration(keysConfig.PublishAheadSeconds) * time.Second,
+		// the retired keys have to outlive the tokens they signed, including the tolerated clock skew
+		MaxTokenTTL: time.Duration(max(c.Config.Tokens.Access.TTLSeconds, c.Config.Tokens.ID.TTLSeconds))*time.Second + time.Minute,
+	}, store, c.Logger)
+	if err != nil {
+		return err
+	}
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+	c.SigningKeys = m
+	c.Health.AddReadinessCheck(health.NewSigningKeyCheck("signing key", m))
+	return nil
+}
+
+func (c *Container) stopSigningKeys(ctx context.Context) error {
+	return c.SigningKeys.Stop(ctx)
+}
+
+func (c *Container) startTokens(ctx context.Context) error {
+	tokensConfig := c.Config.Tokens
+	issuer := tokensConfig.Issuer
+	if issuer == "" {
+		issuer = "https://" + c.Config.Server.Addr
+	}
+	claims := make(map[string]any, len(tokensConfig.Access.Claims))
+	for _, claim := range tokensConfig.Access.Claims {
+		if tokens.RegisteredClaim(claim.Name) {
+			return fmt.Errorf("access token claim %q collides with a registered claim", claim.Name)
+		}
+		claims[claim.Name] = claim.Value
+	}
+	c.Issuer = tokens.NewIssuer(c.SigningKeys, tokens.AccessTokenConfig{
+		Issuer:          issuer,
+		Audience:        tokensConfig.Access.Audience,
+		TTL:             time.Duration(tokensConfig.Access.TTLSeconds) * time.Second,
+		IDTokenTTL:      time.Duration(tokensConfig.ID.TTLSeconds) * time.Second,
+		Claims:          claims,
+		IncludeUsername: tokensConfig.Access.IncludeUsername,
+		IncludeEmail:    tokensConfig.Access.IncludeEmail,
+	})
+	c.RefreshTokens = tokens.NewRefreshTokens(tokens.NewMemoryRefreshStore(), tokens.RefreshTokenConfig{
+		TTL:       time.Duration(tokensConfig.Refresh.TTLSeconds) * time.Second,
+		FamilyTTL: time.Duration(tokensConfig.Refresh.FamilyTTLSeconds) * time.Second,
+	})
+	return c.startRevocations(ctx)
+}
+
+func (c *Container) startRevocations(ctx context.Context) error {
+	revocationsConfig := c.Config.Tokens.Revocations
+	switch revocationsConfig.Store {
+	case revocation.StoreMemory:
+		c.Revocations = revocation.NewList()
+	case revocation.StoreSQLite:
+		store, err := revocation.NewSQLiteStore(ctx, revocationsConfig.SQLite.Path)
+		if err != nil {
+			return err
+		}
+		c.revocationStore = store
+		c.Health.AddReadinessCheck(health.NewPingCheck("revocations sqlite", store))
+		if c.Revocations, err = revocation.NewPersistentList(ctx, store); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown revocations store %q", revocationsConfig.Store)
+	}
+	c.Logger.Info("revocation list initialized", "store", revocationsConfig.Store)
+	return nil
+}
+
+func (c *Container) stopTokens(_ context.Context) error {
+	if closer, ok := c.revocationStore.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *Container) startClients(ctx context.Context) error {
+	oauth2Config := c.Config.OAuth2
+	switch oauth2Config.Store {
+	case clients.StoreMemory:
+		c.clientRepo = clients.NewMemoryRepository()
+	case clients.StoreSQLite:
+		repo, err := clients.NewSQLiteRepository(ctx, oauth2Config.SQLite.Path)
+		if err != nil {
+			return err
+		}
+		c.clientRepo = repo
+		c.Health.AddReadinessCheck(health.NewPingCheck("clients sqlite", repo))
+	default:
+		return fmt.Errorf("unknown clients store %q", oauth2Config.Store)
+	}
+	c.Clients = clients.NewService(c.clientRepo, time.Duration(oauth2Config.SecretGraceSeconds)*time.Second, c.Logger)
+	for _, clientConfig := range oauth2Config.Clients {
+		// the password login accepts the refresh tokens of its client without client authentication
+		if clientConfig.ID == c.Config.Tokens.Access.LoginClientID {
+			return fmt.Errorf("oauth2 client %q: the ID is reserved for the password login, see tokens.access.loginClientID", clientConfig.ID)
+		}
+		secret, err := readSecret(clientConfig.Secret, clientConfig.SecretFile)
+		if err != nil {
+			return fmt.Errorf("reading secret of oauth2 client %q: %w", clientConfig.ID, err)
+		}
+		input := clients.CreateInput{
+			ID:                     clientConfig.ID,
+			Name:                   clientConfig.Name,
+			AuthMethod:             clientConfig.AuthMethod,
+			GrantTypes:             clientConfig.GrantTypes,
+			Scopes:                 clientConfig.Scopes,
+			Audiences:              clientConfig.Audiences,
+			Secret:                 string(secret),
+			RedirectURIs:           clientConfig.RedirectURIs,
+			PostLogoutRedirectURIs: clientConfig.PostLogoutRedirectURIs,
+			SkipConsent:            clientConfig.SkipConsent,
+		}
+		if input.AuthMethod == "" && len(secret) == 0 && clientConfig.JWKSFile == "" {
+			input.AuthMethod = clients.AuthMethodNone
+		}
+		if clientConfig.JWKSFile != "" {
+			if input.JWKS, err = readJWKS(clientConfig.JWKSFile); err != nil {
+				return fmt.Errorf("reading jwks of oauth2 client %q: %w", clientConfig.ID, err)
+			}
+			if input.AuthMethod == "" {
+				input.AuthMethod = clients.AuthMethodPrivateKeyJWT
+			}
+		}
+		_, _, err = c.Clients.Create(ctx, input)
+		if errors.Is(err, clients.ErrAlreadyExists) {
+			// the config is authoritative for its clients, so changes made with the admin API are replaced
+			if err = c.Clients.Delete(ctx, clientConfig.ID); err == nil {
+				_, _, err = c.Clients.Create(ctx, input)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("registering oauth2 client %q: %w", clientConfig.ID, err)
+		}
+	}
+	c.Logger.Info("clients store initialized", "store", oauth2Config.Store)
+	// the assertions of private_key_jwt clients may name the issuer or the token endpoint as audience
+	issuer := c.Issuer.IssuerURL()
+	c.ClientAuth = clients.NewAuthenticator(c.Clients.Registry(), []string{issuer, issuer + server.V1Path + oauth2.TokenPath})
+	return nil
+}
+
+func (c *Container) stopClients(_ context.Context) error {
+	if closer, ok := c.clientRepo.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (c *Container) startOIDC(_ context.Context) error {
+	oidcConfig := c.Config.OIDC
+	algorithm := c.Config.Tokens.SigningKeys.Algorithm
+	if algorithm == "" {
+		algorithm = keys.DefaultAlgorithm
+	}
+	p, err := oidc.NewProvider(oidc.Config{
+		Issuer:           c.Issuer.IssuerURL(),
+		SigningAlgorithm: algorithm,
+		SessionTTL:       time.Duration(oidcConfig.SessionTTLSeconds) * time.Second,
+		CodeTTL:          time.Duration(oidcConfig.CodeTTLSeconds) * time.Second,
+		RequestTTL:       time.Duration(oidcConfig.RequestTTLSeconds) * time.Second,
+	}, c.Users, c.Clients.Registry(), c.Issuer, c.RefreshTokens, c.Revocations, c.Metrics, c.Logger)
+	if err != nil {
+		return err
+	}
+	c.OIDC = p
+	return nil
+}
+
+func (c *Container) startAdminServer(_ context.Context) error {
+	adminConfig := c.Config.Admin
+	if !adminConfig.Enabled {
+		return nil
+	}
+	srv, err := admin.NewServer(admin.Config{
+		Addr:         adminConfig.Addr,
+		Port:         adminConfig.Port,
+		Token:        adminConfig.Token,
+		TokenFile:    adminConfig.TokenFile,
+		CertFile:     adminConfig.CertFile,
+		KeyFile:      adminConfig.KeyFile,
+		ClientCAFile: adminConfig.ClientCAFile,
+	}, admin.Sources{
+		Resource: c.Resource,
+		Config:   c.Config,
+		Levels:   c.Levels,
+	})
+	if err != nil {
+		return err
+	}
+	c.AdminServer = srv
+	ln, err := net.Listen("tcp", c.AdminServer.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		var err error
+		if c.AdminServer.TLSConfig != nil {
+			err = c.AdminServer.ServeTLS(ln, "", "")
+		} else {
+			err = c.AdminServer.Serve(ln)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.Logger.Error("admin server failed", "error", err.Error())
+		}
+	}()
+	c.Logger.Info("admin server started", "addr", ln.Addr().String(), "tls", c.AdminServer.TLSConfig != nil)
+	return nil
+}
+
+func (c *Container) stopAdminServer(ctx context.Context) error {
+	if c.AdminServer == nil {
+		return nil
+	}
+	return c.AdminServer.Shutdown(ctx)
+}
+
+func (c *Container) stopTracing(ctx context.Context) error {
+	return flushAndShutdown(ctx, c.TracerProvider)
+}
+
+func (c *Container) stopMetrics(ctx context.Context) error {
+	return flushAndShutdown(ctx, c.MeterProvider)
+}
+
+func (c *Container) stopLogging(ctx context.Context) error {
+	return flushAndShutdown(ctx, c.LoggerProvider)
+}
+
+// flushAndShutdown flushes and shuts down p if it is a SDK provider. The provider is shut down even if the flush fails.
+func flushAndShutdown(ctx context.Context, p any) error {
+	prov, ok := p.(provider)
+	if !ok {
+		return nil
+	}
+	return errors.Join(prov.ForceFlush(ctx), prov.Shutdown(ctx))
+}
+
 func initConfig() (*config.Config, error) {
 	c := new(config.Config)
 	v := commonconfig.NewViper()
+	v.SetDefault("shutdown.timeoutSeconds", 15)
+	v.SetDefault("server.drainDelaySeconds", 5)
+	v.SetDefault("users.store", users.StoreMemory)
+	v.SetDefault("tokens.access.ttlSeconds", 300)
+	v.SetDefault("tokens.access.loginClientID", "login")
+	v.SetDefault("tokens.refresh.ttlSeconds", 24*60*60)
+	v.SetDefault("tokens.refresh.familyTTLSeconds", 30*24*60*60)
+	v.SetDefault("tokens.id.ttlSeconds", 60*60)
+	v.SetDefault("tokens.revocations.store", revocation.StoreMemory)
+	v.SetDefault("oauth2.store", clients.StoreMemory)
+	v.SetDefault("oauth2.secretGraceSeconds", 24*60*60)
+	v.SetDefault("health.minFreeDiskMiB", 64)
+	v.SetDefault("telemetry.metrics.hostMetrics", true)
+	v.SetDefault("telemetry.metrics.runtimeMetrics", true)
+	v.SetDefault("telemetry.instance.source", commonotel.InstanceIDSourceAuto)
 	if err := v.ReadInConfig(); err != nil {
 		return nil, err
 	}
@@ -94,34 +752,102 @@ func initConfig() (*config.Config, error) {
 	return c, nil
 }
 
-func initLogger(logLevel string, pretty bool) *slog.Logger {
-	l := logging.NewSlogLogger(logLevel, pretty)
+// readSecret returns secret, or the trimmed content of secretFile if secret is empty.
+func readSecret(secret string, secretFile string) ([]byte, error) {
+	if secret != "" || secretFile == "" {
+		return []byte(secret), nil
+	}
+	b, err := os.ReadFile(secretFile)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(b), nil
+}
+
+// readJWKS returns the JSON Web Key Set in path.
+func readJWKS(path string) (*tokens.JWKSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jwks tokens.JWKSet
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, err
+	}
+	return &jwks, nil
+}
+
+// otlpEndpoints returns the distinct OTLP endpoints of the signals and log pipelines exported with OTLP.
+func (c *Container) otlpEndpoints() []string {
+	telemetryConfig := c.Config.Telemetry
+	var endpoints []string
+	if c.tracingMode == commonotel.ModeOTLP {
+		endpoints = append(endpoints, telemetryConfig.Tracing.OTLPEndpoint)
+	}
+	if c.metricsMode == commonotel.ModeOTLP {
+		endpoints = append(endpoints, telemetryConfig.Metrics.OTLPEndpoint)
+	}
+	if c.logsMode == commonotel.ModeOTLP {
+		endpoints = append(endpoints, telemetryConfig.Logging.OTLPEndpoint)
+		for _, p := range telemetryConfig.Logging.Pipelines {
+			endpoints = append(endpoints, p.OTLPEndpoint)
+		}
+	}
+	seen := make(map[string]struct{}, len(endpoints))
+	distinct := endpoints[:0]
+	for _, e := range endpoints {
+		if _, ok := seen[e]; ok || e == "" {
+			continue
+		}
+		seen[e] = struct{}{}
+		distinct = append(distinct, e)
+	}
+	return distinct
+}
+
+func initLogger(logLevel slog.Leveler, pretty bool) *slog.Logger {
+	l := logging.NewSlogLoggerWithLeveler(logLevel, pretty)
 	return l
 }
 
-func initResource(instanceID string, serverAddr string, serverPort int) (*resource.Resource, error) {
-	return commonotel.NewResource(context.Background(), commonotel.ResourceConfig{
-		Name:       AppName,
-		Version:    "1.0.0",
-		InstanceID: instanceID,
-		Addr:       serverAddr,
-		Port:       serverPort,
+func initResource(ctx context.Context, instanceID string, serverConfig config.ServerConfig, telemetryConfig config.TelemetryConfig) (*resource.Resource, error) {
+	return commonotel.NewResource(ctx, commonotel.ResourceConfig{
+		Name:        AppName,
+		InstanceID:  instanceID,
+		Addr:        serverConfig.Addr,
+		Port:        serverConfig.Port,
+		Environment: telemetryConfig.Environment,
+		Attributes:  resourceAttributes(telemetryConfig.ResourceAttributes),
 	})
 }
 
-func initOtelTracing(resource *resource.Resource, otlpEndpoint string, exportTimeoutSeconds int, samplingRatio float64) (trace.TracerProvider, error) {
-	traceExporter, err := oteltracing.NewOTLPTraceExporter(
-		context.Background(),
-		otlpEndpoint,
-		time.Duration(exportTimeoutSeconds)*time.Second,
-	)
+func resourceAttributes(attrs []config.ResourceAttributeConfig) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		m[a.Key] = a.Value
+	}
+	return m
+}
+
+func initOtelTracing(resource *resource.Resource, otlpEndpoint string, exportTimeoutSeconds int, samplingRatio float64, queueConfig config.QueueConfig, stats *selfobs.Stats) (*sdktrace.TracerProvider, error) {
+	traceClient := oteltracing.NewOTLPTraceClient(otlpEndpoint, time.Duration(exportTimeoutSeconds)*time.Second)
+	if queueConfig.Enabled {
+		queue, err := initQueue(queueConfig, "traces", "traces", "traces")
+		if err != nil {
+			return nil, err
+		}
+		traceClient = diskqueue.NewTraceClient(traceClient, queue)
+	}
+	traceExporter, err := otlptrace.New(context.Background(), traceClient)
 	if err != nil {
 		return nil, err
 	}
+	tracker := stats.Track(selfobs.ExporterInfo{Name: "otlp", Signal: selfobs.SignalTraces, Endpoint: otlpEndpoint}, sdktrace.DefaultMaxQueueSize)
+	traceProcessor := tracker.SpanProcessor(sdktrace.NewBatchSpanProcessor(tracker.SpanExporter(traceExporter)))
 	traceSampler := oteltracing.NewRatioSampler(samplingRatio)
-	tp, err := oteltracing.NewTraceProvider(
+	tp, err := oteltracing.NewTraceProviderWithProcessor(
 		resource,
-		traceExporter,
+		traceProcessor,
 		traceSampler,
 	)
 	if err != nil {
@@ -130,20 +856,57 @@ func initOtelTracing(resource *resource.Resource, otlpEndpoint string, exportTim
 	return tp, nil
 }
 
-func initOtelMetrics(resource *resource.Resource, otlpEndpoint string, exportTimeoutSeconds, exportIntervalSeconds, memStatsIntervalSeconds int) (metric.MeterProvider, error) {
+func initConsoleTracing(resource *resource.Resource, samplingRatio float64) (*sdktrace.TracerProvider, error) {
+	traceExporter, err := oteltracing.NewStdoutTraceExporter()
+	if err != nil {
+		return nil, err
+	}
+	return oteltracing.NewTraceProvider(resource, traceExporter, oteltracing.NewRatioSampler(samplingRatio))
+}
+
+func initQueue(queueConfig config.QueueConfig, signal string, name string, dir string) (*diskqueue.Queue, error) {
+	return diskqueue.New(signal, diskqueue.Config{
+		Dir:           filepath.Join(queueConfig.Dir, dir),
+		Name:          name,
+		MaxBytes:      int64(queueConfig.MaxSizeMiB) * 1024 * 1024,
+		MaxItems:      queueConfig.MaxItems,
+		RetryInterval: time.Duration(queueConfig.RetryIntervalSeconds) * time.Second,
+	})
+}
+
+func initPrometheus(addr string, port int) (*otelprometheus.Exporter, *http.Server, error) {
+	registry := prometheus.NewRegistry()
+	exporter, err := otelmetrics.NewPrometheusExporter(registry)
+	if err != nil {
+		return nil, nil, err
+	}
+	return exporter, otelmetrics.NewPrometheusServer(addr, port, registry), nil
+}
+
+func initOtelMetrics(resource *resource.Resource, metricsConfig config.MetricsConfig, queueConfig config.QueueConfig, stats *selfobs.Stats, opts ...sdkmetric.Option) (*sdkmetric.MeterProvider, error) {
+	exportTimeout := time.Duration(metricsConfig.ExportTimeoutSeconds) * time.Second
+	var metricExporter sdkmetric.Exporter
 	metricExporter, err := otelmetrics.NewOTLPMetricExporter(
 		context.Background(),
-		otlpEndpoint,
-		time.Duration(exportTimeoutSeconds)*time.Second,
+		metricsConfig.OTLPEndpoint,
+		exportTimeout,
 	)
 	if err != nil {
 		return nil, err
 	}
-	mp, err := otelmetrics.NewMeterProvider(
+	if queueConfig.Enabled {
+		queue, err := initQueue(queueConfig, "metrics", "metrics", "metrics")
+		if err != nil {
+			return nil, err
+		}
+		metricExporter = diskqueue.NewMetricExporter(metricExporter, queue)
+	}
+	metricExporter = stats.Track(selfobs.ExporterInfo{Name: "otlp", Signal: selfobs.SignalMetrics, Endpoint: metricsConfig.OTLPEndpoint}, 0).MetricExporter(metricExporter)
+	mp, err := otelmetrics.NewMeterProviderWithConfig(
 		resource,
 		metricExporter,
-		time.Duration(exportIntervalSeconds)*time.Second,
-		time.Duration(memStatsIntervalSeconds)*time.Second,
+		meterProviderConfig(metricsConfig),
+		opts...,
 	)
 	if err != nil {
 		return nil, err
@@ -151,22 +914,75 @@ func initOtelMetrics(resource *resource.Resource, otlpEndpoint string, exportTim
 	return mp, nil
 }
 
-func initOtelLogging(resource *resource.Resource, otlpEndpoint string, exportTimeoutSeconds, batchTimeoutSeconds int) (logs.LoggerProvider, error) {
-	logExporter, err := otellogging.NewOTLPLogsExporter(
-		context.Background(),
-		otlpEndpoint,
-		time.Duration(exportTimeoutSeconds)*time.Second,
-	)
+func initConsoleMetrics(resource *resource.Resource, metricsConfig config.MetricsConfig, opts ...sdkmetric.Option) (*sdkmetric.MeterProvider, error) {
+	metricExporter, err := otelmetrics.NewStdoutMetricExporter()
 	if err != nil {
 		return nil, err
 	}
-	lp, err := otellogging.NewLogProvider(
-		resource,
-		logExporter,
-		time.Duration(batchTimeoutSeconds)*time.Second,
-	)
-	if err != nil {
-		return nil, err
+	return otelmetrics.NewMeterProviderWithConfig(resource, metricExporter, meterProviderConfig(metricsConfig), opts...)
+}
+
+func meterProviderConfig(metricsConfig config.MetricsConfig) otelmetrics.MeterProviderConfig {
+	return otelmetrics.MeterProviderConfig{
+		ExportInterval:   time.Duration(metricsConfig.ExportIntervalSeconds) * time.Second,
+		MemStatsInterval: time.Duration(metricsConfig.MemStatsIntervalSeconds) * time.Second,
+		HostMetrics:      metricsConfig.HostMetrics,
+		RuntimeMetrics:   metricsConfig.RuntimeMetrics,
+		Views:            metricsConfig.Views,
+		ExemplarFilter:   metricsConfig.ExemplarFilter,
+	}
+}
+
+func initOtelLogging(resource *resource.Resource, logsConfig config.LogsConfig, queueConfig config.QueueConfig, stats *selfobs.Stats) (*sdklogs.LoggerProvider, error) {
+	pipelineConfigs := logsConfig.Pipelines
+	if len(pipelineConfigs) == 0 {
+		pipelineConfigs = []config.LogPipelineConfig{{
+			Name:                 "otlp",
+			OTLPEndpoint:         logsConfig.OTLPEndpoint,
+			ExportTimeoutSeconds: logsConfig.ExportTimeoutSeconds,
+			BatchTimeoutSeconds:  logsConfig.BatchTimeoutSeconds,
+		}}
+	}
+
+	pipelines := make([]otellogging.Pipeline, 0, len(pipelineConfigs))
+	for _, p := range pipelineConfigs {
+		queueDir := filepath.Join("logs", p.Name)
+		if len(logsConfig.Pipelines) == 0 {
+			queueDir = "logs"
+		}
+		exporter, err := initOtelLogsExporter(p.OTLPEndpoint, p.ExportTimeoutSeconds, queueConfig, p.Name, queueDir)
+		if err != nil {
+			return nil, err
+		}
+		maxQueueSize := p.MaxQueueSize
+		if maxQueueSize <= 0 {
+			maxQueueSize = sdklogs.DefaultMaxQueueSize
+		}
+		pipelines = append(pipelines, otellogging.Pipeline{
+			Name:               p.Name,
+			Exporter:           exporter,
+			BatchTimeout:       time.Duration(p.BatchTimeoutSeconds) * time.Second,
+			MaxQueueSize:       p.MaxQueueSize,
+			MaxExportBatchSize: p.MaxExportBatchSize,
+			Route: otellogging.RouteConfig{
+				MinLevel:          p.MinLevel,
+				Categories:        p.Categories,
+				ExcludeCategories: p.ExcludeCategories,
+			},
+			Tracker: stats.Track(selfobs.ExporterInfo{Name: p.Name, Signal: selfobs.SignalLogs, Endpoint: p.OTLPEndpoint}, maxQueueSize),
+		})
+	}
+	return otellogging.NewLogProviderWithPipelines(resource, pipelines)
+}
+
+func initOtelLogsExporter(otlpEndpoint string, exportTimeoutSeconds int, queueConfig config.QueueConfig, pipeline string, queueDir string) (*otlplogs.Exporter, error) {
+	logsClient := otellogging.NewOTLPLogsClient(otlpEndpoint, time.Duration(exportTimeoutSeconds)*time.Second)
+	if queueConfig.Enabled {
+		queue, err := initQueue(queueConfig, "logs", pipeline, queueDir)
+		if err != nil {
+			return nil, err
+		}
+		logsClient = diskqueue.NewLogsClient(logsClient, queue)
 	}
-	return lp, nil
+	return otlplogs.NewExporter(context.Background(), otlplogs.WithClient(logsClient))
 }
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SaimonWoidig/cc-microsvcs/common/health"
+	"github.com/SaimonWoidig/cc-microsvcs/common/lifecycle"
+	"github.com/SaimonWoidig/cc-microsvcs/service.auth/pkg/config"
+)
+
+func TestStartTokensRejectsRegisteredClaims(t *testing.T) {
+	c := &Container{Config: &config.Config{}}
+	c.Config.Tokens.Access.Claims = []config.ClaimConfig{{Name: "tenant", Value: "acme"}, {Name: "roles", Value: "admin"}}
+	if err := c.startTokens(context.Background()); err == nil || !strings.Contains(err.Error(), `"roles"`) {
+		t.Errorf("got %v, want an error naming roles", err)
+	}
+}
+
+func TestShutdownStopsAllComponents(t *testing.T) {
+	c := &Container{lifecycle: lifecycle.New(nil), Health: health.New(health.Config{})}
+	errStop := errors.New("flush failed")
+	var stopped []string
+	hooks := []lifecycle.Hook{
+		{Name: "telemetry", Stop: func(context.Context) error {
+			stopped = append(stopped, "telemetry")
+			return errStop
+		}},
+		{Name: "store", DependsOn: []string{"telemetry"}, Stop: func(context.Context) error {
+			stopped = append(stopped, "store")
+			return nil
+		}},
+		{Name: "http.server", DependsOn: []string{"store"}, Stop: func(ctx context.Context) error {
+			stopped = append(stopped, "http.server")
+			// the server waits for the requests being handled until the shutdown deadline
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}
+	for _, h := range hooks {
+		if err := c.lifecycle.Append(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.lifecycle.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.Health.SetReady(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	err := c.Shutdown(ctx)
+	if !errors.Is(err, errStop) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the failed hook and the expired context", err)
+	}
+	if got := strings.Join(stopped, ","); got != "http.server,store,telemetry" {
+		t.Errorf("stopped %s, want all components in reverse order", got)
+	}
+	if report := c.Health.Ready(context.Background()); report.Status != health.StatusFail {
+		t.Error("container ready after shutdown")
+	}
+}
@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	otelmetrics "github.com/SaimonWoidig/cc-microsvcs/common/otel/metrics"
+)
+
+const (
+	// OutcomeKey is the attribute key holding the outcome of an operation, e.g. "success" or "failure".
+	OutcomeKey attribute.Key = "outcome"
+	// GrantTypeKey is the attribute key holding the OAuth2 grant type a token was issued for.
+	GrantTypeKey attribute.Key = "grant_type"
+	// TokenTypeKey is the attribute key holding the type of an issued token, e.g. "access" or "refresh".
+	TokenTypeKey attribute.Key = "token_type"
+	// ResultKey is the attribute key holding the result of a lookup, e.g. "hit" or "miss".
+	ResultKey attribute.Key = "result"
+)
+
+// The values of OutcomeKey.
+const (
+	OutcomeSuccess string = "success"
+	OutcomeFailure string = "failure"
+)
+
+// The values of ResultKey.
+const (
+	ResultHit  string = "hit"
+	ResultMiss string = "miss"
+)
+
+// Metrics holds the business instruments of the auth service.
+type Metrics struct {
+	// Registry is the registry the instruments are declared in. It provides the metric catalog.
+	Registry *otelmetrics.Registry
+	// Logins counts login attempts by outcome.
+	Logins *otelmetrics.Counter
+	// TokensIssued counts issued tokens by grant type and token type.
+	TokensIssued *otelmetrics.Counter
+	// RefreshTokensReused counts refresh tokens used after they were rotated, which revokes their token family.
+	RefreshTokensReused *otelmetrics.Counter
+	// Introspections counts token introspections by result, a hit for active tokens, and token type.
+	Introspections *otelmetrics.Counter
+	// IntrospectionDuration records the duration of the token introspections by result.
+	IntrospectionDuration *otelmetrics.Histogram
+	// Revocations counts revoked tokens by token type.
+	Revocations *otelmetrics.Counter
+}
+
+// NewMetrics declares the business instruments of the auth service on meter.
+func NewMetrics(meter metric.Meter) (*Metrics, error) {
+	registry := otelmetrics.NewRegistry(meter)
+
+	logins, err := registry.Counter(otelmetrics.Definition{
+		Name:          "auth.logins",
+		Unit:          "{login}",
+		Description:   "Number of login attempts.",
+		AttributeKeys: []attribute.Key{OutcomeKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tokensIssued, err := registry.Counter(otelmetrics.Definition{
+		Name:          "auth.tokens.issued",
+		Unit:          "{token}",
+		Description:   "Number of issued tokens.",
+		AttributeKeys: []attribute.Key{GrantTypeKey, TokenTypeKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokensReused, err := registry.Counter(otelmetrics.Definition{
+		Name:        "auth.refresh_tokens.reused",
+		Unit:        "{token}",
+		Description: "Number of reused refresh tokens. Each reuse revokes the token family.",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	introspections, err := registry.Counter(otelmetrics.Definition{
+		Name:          "auth.introspections",
+		Unit:          "{introspection}",
+		Description:   "Number of token introspections. A hit is an active token.",
+		AttributeKeys: []attribute.Key{ResultKey, TokenTypeKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	introspectionDuration, err := registry.Histogram(otelmetrics.Definition{
+		Name:          "auth.introspection.duration",
+		Unit:          "s",
+		Description:   "Duration of the token introspections.",
+		AttributeKeys: []attribute.Key{ResultKey},
+		Boundaries:    []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revocations, err := registry.Counter(otelmetrics.Definition{
+		Name:          "auth.revocations",
+		Unit:          "{token}",
+		Description:   "Number of revoked tokens.",
+		AttributeKeys: []attribute.Key{TokenTypeKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Metrics{
+		Registry:              registry,
+		Logins:                logins,
+		TokensIssued:          tokensIssued,
+		RefreshTokensReused:   refreshTokensReused,
+		Introspections:        introspections,
+		IntrospectionDuration: introspectionDuration,
+		Revocations:           revocations,
+	}, nil
+}
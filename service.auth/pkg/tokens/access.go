@@ -0,0 +1,259 @@
+/*
+Package tokens contains the tokens issued by the auth service: the JWT access tokens of the RFC 9068 profile,
+signed with the keys of a KeySource, and the opaque rotating refresh tokens.
+*/
+package tokens
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AccessTokenType is the media type of the access tokens set as the typ header, see RFC 9068 section 2.1.
+const AccessTokenType string = "at+jwt"
+
+// clockSkew is the tolerated difference of the clocks of the issuer and the verifier.
+const clockSkew = 30 * time.Second
+
+// Audience is the aud claim. It is serialized as a string if it has a single value, see RFC 7519 section 4.1.3.
+type Audience []string
+
+// MarshalJSON implements json.Marshaler.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// AccessClaims is a struct that represents the claims of an access token, see RFC 9068 section 2.2.
+type AccessClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	JWTID     string   `json:"jti"`
+	ClientID  string   `json:"client_id"`
+	Scope     string   `json:"scope,omitempty"`
+	// AuthTime is the time the user authenticated, kept when the token is refreshed.
+	AuthTime int64    `json:"auth_time,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	Username string   `json:"preferred_username,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	// Extra are the additional claims. They do not override the registered claims above.
+	Extra map[string]any `json:"-"`
+}
+
+// registeredClaims are the names of the claims set by the Issuer. They cannot be added as static claims.
+var registeredClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "iat": true, "jti": true, "client_id": true, "scope": true,
+	"auth_time": true, "roles": true, "preferred_username": true, "email": true,
+}
+
+// RegisteredClaim reports whether name is a claim set by the Issuer, which the static claims of AccessTokenConfig must not collide with.
+func RegisteredClaim(name string) bool {
+	return registeredClaims[name]
+}
+
+// accessClaims has the fields of AccessClaims without its JSON methods.
+type accessClaims AccessClaims
+
+// MarshalJSON implements json.Marshaler. The Extra claims are added to the claims which are not set.
+func (c AccessClaims) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(accessClaims(c))
+	if err != nil || len(c.Extra) == 0 {
+		return b, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range c.Extra {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The claims without a field are collected in Extra.
+func (c *AccessClaims) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*accessClaims)(c)); err != nil {
+		return err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	known, err := json.Marshal(accessClaims(*c))
+	if err != nil {
+		return err
+	}
+	var knownKeys map[string]json.RawMessage
+	if err := json.Unmarshal(known, &knownKeys); err != nil {
+		return err
+	}
+	for k := range knownKeys {
+		delete(m, k)
+	}
+	if len(m) > 0 {
+		c.Extra = m
+	}
+	return nil
+}
+
+// AccessTokenConfig is a struct that represents the configuration of the issued access tokens.
+type AccessTokenConfig struct {
+	// Issuer is the iss claim, the URL of the auth service.
+	Issuer string
+	// Audience is the default aud claim, the resource servers accepting the tokens.
+	Audience []string
+	// TTL is the lifetime of the tokens.
+	TTL time.Duration
+	// IDTokenTTL is the lifetime of the OpenID Connect ID tokens.
+	IDTokenTTL time.Duration
+	// Claims are the static additional claims of all tokens. Claims named like a registered claim, see RegisteredClaim, are ignored.
+	Claims map[string]any
+	// IncludeUsername and IncludeEmail add the preferred_username and email claims of the user.
+	IncludeUsername bool
+	IncludeEmail    bool
+}
+
+// AccessTokenRequest is a struct that represents the subject and grant of an access token.
+type AccessTokenRequest struct {
+	Subject  string
+	ClientID string
+	Scope    string
+	// Audience overrides the default audience if set.
+	Audience []string
+	AuthTime time.Time
+	Roles    []string
+	Username string
+	Email    string
+}
+
+// Issuer issues and verifies the access tokens.
+type Issuer struct {
+	keys   KeySource
+	config AccessTokenConfig
+}
+
+// NewIssuer returns an Issuer signing the access tokens with the keys of keys.
+func NewIssuer(keys KeySource, config AccessTokenConfig) *Issuer {
+	return &Issuer{keys: keys, config: config}
+}
+
+// IssuerURL returns the iss claim of the issued tokens.
+func (i *Issuer) IssuerURL() string {
+	return i.config.Issuer
+}
+
+// AccessTokenTTL returns the lifetime of the access tokens.
+func (i *Issuer) AccessTokenTTL() time.Duration {
+	return i.config.TTL
+}
+
+/*
+IssueAccessToken returns a signed JWT access token of the RFC 9068 profile for req.
+
+Parameters:
+  - ctx: The context of the request.
+  - req: The subject, client and scope of the token.
+
+Returns:
+  - string: The signed token.
+  - *AccessClaims: The claims of the token.
+  - error: An error if the signing key is not available or the token could not be signed.
+*/
+func (i *Issuer) IssueAccessToken(ctx context.Context, req AccessTokenRequest) (string, *AccessClaims, error) {
+	key, err := i.keys.SigningKey(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	jti, err := randomID()
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	audience := req.Audience
+	if len(audience) == 0 {
+		audience = i.config.Audience
+	}
+	claims := &AccessClaims{
+		Issuer:    i.config.Issuer,
+		Subject:   req.Subject,
+		Audience:  audience,
+		ExpiresAt: now.Add(i.config.TTL).Unix(),
+		IssuedAt:  now.Unix(),
+		JWTID:     jti,
+		ClientID:  req.ClientID,
+		Scope:     req.Scope,
+		Roles:     req.Roles,
+		Extra:     i.config.Claims,
+	}
+	if !req.AuthTime.IsZero() {
+		claims.AuthTime = req.AuthTime.Unix()
+	}
+	if i.config.IncludeUsername {
+		claims.Username = req.Username
+	}
+	if i.config.IncludeEmail {
+		claims.Email = req.Email
+	}
+	token, err := sign(key, AccessTokenType, claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+/*
+VerifyAccessToken verifies the signature, type, issuer and expiry of an access token issued by IssueAccessToken.
+The audience is not checked, as the auth service accepts the tokens of all audiences, e.g. for introspection.
+
+Parameters:
+  - ctx: The context of the request.
+  - token: The signed token.
+
+Returns:
+  - *AccessClaims: The claims of the valid token.
+  - error: ErrInvalidToken if the token is not valid.
+*/
+func (i *Issuer) VerifyAccessToken(ctx context.Context, token string) (*AccessClaims, error) {
+	var claims AccessClaims
+	lookup := func(kid string) (crypto.PublicKey, error) {
+		return i.keys.VerificationKey(ctx, kid)
+	}
+	if err := parse(token, AccessTokenType, lookup, &claims); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if claims.Issuer != i.config.Issuer || now.Add(-clockSkew).Unix() >= claims.ExpiresAt || now.Add(clockSkew).Unix() < claims.IssuedAt {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// randomID returns a random 128 bit ID encoded in base64url.
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating random ID: %w", err)
+	}
+	return b64.EncodeToString(b), nil
+}
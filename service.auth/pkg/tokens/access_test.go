@@ -0,0 +1,150 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// newTestIssuer returns an Issuer with a new ES256 key and config, with the issuer https://auth.example.com if not set.
+func newTestIssuer(t *testing.T, config AccessTokenConfig) *Issuer {
+	t.Helper()
+	signer, err := GenerateKey(AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewStaticKeySource(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Issuer == "" {
+		config.Issuer = "https://auth.example.com"
+	}
+	if config.TTL == 0 {
+		config.TTL = 5 * time.Minute
+	}
+	return NewIssuer(keys, config)
+}
+
+func TestIssueAndVerifyAccessToken(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t, AccessTokenConfig{
+		Audience:        []string{"orders"},
+		Claims:          map[string]any{"tenant": "cc", "sub": "overridden"},
+		IncludeUsername: true,
+	})
+	authTime := time.Now().Add(-time.Hour)
+	token, issued, err := issuer.IssueAccessToken(ctx, AccessTokenRequest{
+		Subject:  "alice-id",
+		ClientID: "console",
+		Scope:    "orders:read",
+		AuthTime: authTime,
+		Roles:    []string{"reader"},
+		Username: "alice",
+		Email:    "alice@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := issuer.VerifyAccessToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "alice-id" || claims.ClientID != "console" || claims.Scope != "orders:read" || claims.JWTID == "" || claims.JWTID != issued.JWTID {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "orders" {
+		t.Errorf("aud = %v, want the default audience", claims.Audience)
+	}
+	if claims.ExpiresAt-claims.IssuedAt != 300 || claims.AuthTime != authTime.Unix() {
+		t.Errorf("exp %d, iat %d, auth_time %d", claims.ExpiresAt, claims.IssuedAt, claims.AuthTime)
+	}
+	if claims.Username != "alice" || claims.Email != "" {
+		t.Errorf("preferred_username %q, email %q: want only the username", claims.Username, claims.Email)
+	}
+	if claims.Extra["tenant"] != "cc" {
+		t.Errorf("static claim missing: %v", claims.Extra)
+	}
+
+	token, _, err = issuer.IssueAccessToken(ctx, AccessTokenRequest{Subject: "gateway", Audience: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err = issuer.VerifyAccessToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if len(claims.Audience) != 2 || claims.AuthTime != 0 {
+		t.Errorf("aud %v, auth_time %d: want the requested audience and no auth_time", claims.Audience, claims.AuthTime)
+	}
+}
+
+func TestVerifyAccessTokenRejects(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t, AccessTokenConfig{})
+	expired := newTestIssuer(t, AccessTokenConfig{TTL: -time.Minute})
+	other := newTestIssuer(t, AccessTokenConfig{Issuer: "https://other.example.com"})
+
+	expiredToken, _, err := expired.IssueAccessToken(ctx, AccessTokenRequest{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expired.VerifyAccessToken(ctx, expiredToken); err != ErrInvalidToken {
+		t.Errorf("expired token: got %v, want ErrInvalidToken", err)
+	}
+	otherToken, _, err := other.IssueAccessToken(ctx, AccessTokenRequest{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.VerifyAccessToken(ctx, otherToken); err != ErrInvalidToken {
+		t.Errorf("token of another issuer: got %v, want ErrInvalidToken", err)
+	}
+	key, err := issuer.keys.SigningKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a correctly signed token of another type, e.g. an ID token, is not an access token
+	idToken, err := sign(key, typJWT, AccessClaims{Issuer: "https://auth.example.com", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.VerifyAccessToken(ctx, idToken); err != ErrInvalidToken {
+		t.Errorf("token of another type: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	for _, tc := range []struct {
+		aud  Audience
+		want string
+	}{
+		{Audience{"a"}, `"a"`},
+		{Audience{"a", "b"}, `["a","b"]`},
+	} {
+		b, err := json.Marshal(tc.aud)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("Marshal(%v) = %s, want %s", tc.aud, b, tc.want)
+		}
+		var got Audience
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.aud) || got[0] != "a" {
+			t.Errorf("Unmarshal(%s) = %v, want %v", b, got, tc.aud)
+		}
+	}
+}
+
+func TestRegisteredClaim(t *testing.T) {
+	for _, name := range []string{"iss", "sub", "aud", "exp", "iat", "jti", "client_id", "scope", "auth_time", "roles", "preferred_username", "email"} {
+		if !RegisteredClaim(name) {
+			t.Errorf("%s is not registered", name)
+		}
+	}
+	if RegisteredClaim("tenant") {
+		t.Error("tenant is registered")
+	}
+}
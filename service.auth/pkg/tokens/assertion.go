@@ -0,0 +1,89 @@
+package tokens
+
+import (
+	"crypto"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ClientAssertionType is the client_assertion_type of the private_key_jwt client authentication, see RFC 7523 section 2.2.
+const ClientAssertionType string = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// maxAssertionLifetime is the maximum time between the iat and exp claims of a client assertion.
+const maxAssertionLifetime = 5 * time.Minute
+
+// AssertionClaims is a struct that represents the claims of a client assertion, see RFC 7523 section 3.
+type AssertionClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	JWTID     string   `json:"jti"`
+}
+
+/*
+VerifyClientAssertion verifies a client assertion of the private_key_jwt client authentication signed with one of the keys of jwks.
+The iss and sub claims have to be the client ID and aud has to contain one of audiences. The assertion has to have a jti
+and may be valid for at most five minutes, so the jti can be remembered until the expiry to reject replays.
+
+Parameters:
+  - assertion: The client_assertion of the request.
+  - clientID: The ID of the client.
+  - jwks: The registered public keys of the client.
+  - audiences: The accepted aud values, e.g. the issuer and the URL of the token endpoint.
+
+Returns:
+  - *AssertionClaims: The claims of the valid assertion.
+  - error: ErrInvalidToken if the assertion is not valid.
+*/
+func VerifyClientAssertion(assertion string, clientID string, jwks JWKSet, audiences []string) (*AssertionClaims, error) {
+	lookup := func(kid string) (crypto.PublicKey, error) {
+		for _, jwk := range jwks.Keys {
+			// a key ID can be omitted if the client registered a single key
+			if jwk.Kid == kid || (kid == "" && len(jwks.Keys) == 1) {
+				return jwk.PublicKey()
+			}
+		}
+		return nil, ErrUnknownKey
+	}
+	var claims AssertionClaims
+	if err := parse(assertion, typJWT, lookup, &claims); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != clientID || claims.Subject != clientID || claims.JWTID == "":
+		return nil, ErrInvalidToken
+	case !slices.ContainsFunc(claims.Audience, func(aud string) bool { return slices.Contains(audiences, aud) }):
+		return nil, ErrInvalidToken
+	case now.Add(-clockSkew).Unix() >= claims.ExpiresAt || claims.ExpiresAt > now.Add(maxAssertionLifetime+clockSkew).Unix():
+		return nil, ErrInvalidToken
+	case claims.NotBefore > now.Add(clockSkew).Unix() || claims.IssuedAt > now.Add(clockSkew).Unix():
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// AssertionIssuer returns the unverified iss claim of assertion, which names the client if the request has no client_id.
+// The issuer is only used to look up the keys the assertion is then verified with.
+func AssertionIssuer(assertion string) string {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Issuer
+}
@@ -0,0 +1,79 @@
+package tokens
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testAssertion returns the claims of a valid assertion of the client gateway for the audience https://auth.example.com.
+func testAssertion() AssertionClaims {
+	now := time.Now()
+	return AssertionClaims{
+		Issuer:    "gateway",
+		Subject:   "gateway",
+		Audience:  Audience{"https://auth.example.com"},
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		IssuedAt:  now.Unix(),
+		JWTID:     "assertion-1",
+	}
+}
+
+func TestVerifyClientAssertion(t *testing.T) {
+	key := newTestKey(t, AlgES256)
+	jwk, err := NewJWK(key.Signer.Public(), key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := JWKSet{Keys: []JWK{jwk}}
+	audiences := []string{"https://auth.example.com", "https://auth.example.com/v1/token"}
+
+	assertion, err := sign(key, typJWT, testAssertion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := VerifyClientAssertion(assertion, "gateway", jwks, audiences)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.JWTID != "assertion-1" {
+		t.Errorf("jti = %q, want assertion-1", claims.JWTID)
+	}
+	if got := AssertionIssuer(assertion); got != "gateway" {
+		t.Errorf("AssertionIssuer = %q, want gateway", got)
+	}
+	if got := AssertionIssuer("not a jwt"); got != "" {
+		t.Errorf("AssertionIssuer of an invalid assertion = %q, want empty", got)
+	}
+
+	now := time.Now()
+	for name, modify := range map[string]func(*AssertionClaims){
+		"other issuer":   func(c *AssertionClaims) { c.Issuer = "other" },
+		"other subject":  func(c *AssertionClaims) { c.Subject = "other" },
+		"no jti":         func(c *AssertionClaims) { c.JWTID = "" },
+		"other audience": func(c *AssertionClaims) { c.Audience = Audience{"https://other.example.com"} },
+		"expired":        func(c *AssertionClaims) { c.ExpiresAt = now.Add(-time.Minute).Unix() },
+		"long-lived":     func(c *AssertionClaims) { c.ExpiresAt = now.Add(time.Hour).Unix() },
+		"not yet valid":  func(c *AssertionClaims) { c.NotBefore = now.Add(time.Hour).Unix() },
+		"issued later":   func(c *AssertionClaims) { c.IssuedAt = now.Add(time.Hour).Unix() },
+	} {
+		claims := testAssertion()
+		modify(&claims)
+		assertion, err := sign(key, typJWT, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := VerifyClientAssertion(assertion, "gateway", jwks, audiences); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	// an assertion signed with an unregistered key is rejected
+	other, err := sign(newTestKey(t, AlgES256), typJWT, testAssertion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyClientAssertion(other, "gateway", jwks, audiences); err == nil {
+		t.Error("assertion of an unregistered key accepted")
+	}
+}
@@ -0,0 +1,132 @@
+package tokens
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"slices"
+	"time"
+)
+
+// IDClaims is a struct that represents the claims of an OpenID Connect ID token, see OpenID Connect Core 1.0 section 2.
+type IDClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	AuthTime  int64    `json:"auth_time,omitempty"`
+	Nonce     string   `json:"nonce,omitempty"`
+	// AuthorizedParty is the client the token was issued to.
+	AuthorizedParty string `json:"azp,omitempty"`
+	// AccessTokenHash binds the token to the access token issued with it.
+	AccessTokenHash string `json:"at_hash,omitempty"`
+	// SessionID is the ID of the login session, see OpenID Connect Front-Channel Logout 1.0 section 3.
+	SessionID string `json:"sid,omitempty"`
+	// Username, Name and Email are the claims of the profile and email scopes.
+	Username string `json:"preferred_username,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// IDTokenRequest is a struct that represents the user and client of an ID token.
+type IDTokenRequest struct {
+	Subject  string
+	ClientID string
+	Nonce    string
+	AuthTime time.Time
+	// AccessToken is the access token issued with the ID token, which is hashed into the at_hash claim.
+	AccessToken string
+	SessionID   string
+	Username    string
+	Email       string
+}
+
+/*
+IssueIDToken returns a signed OpenID Connect ID token for req. The audience is the client.
+
+Parameters:
+  - ctx: The context of the request.
+  - req: The user, client and session of the token. Empty claims are omitted.
+
+Returns:
+  - string: The signed token.
+  - *IDClaims: The claims of the token.
+  - error: An error if the signing key is not available or the token could not be signed.
+*/
+func (i *Issuer) IssueIDToken(ctx context.Context, req IDTokenRequest) (string, *IDClaims, error) {
+	key, err := i.keys.SigningKey(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	claims := &IDClaims{
+		Issuer:          i.config.Issuer,
+		Subject:         req.Subject,
+		Audience:        Audience{req.ClientID},
+		ExpiresAt:       now.Add(i.config.IDTokenTTL).Unix(),
+		IssuedAt:        now.Unix(),
+		Nonce:           req.Nonce,
+		AuthorizedParty: req.ClientID,
+		SessionID:       req.SessionID,
+		Username:        req.Username,
+		Name:            req.Username,
+		Email:           req.Email,
+	}
+	if !req.AuthTime.IsZero() {
+		claims.AuthTime = req.AuthTime.Unix()
+	}
+	if req.AccessToken != "" {
+		claims.AccessTokenHash = tokenHash(key.Algorithm, req.AccessToken)
+	}
+	token, err := sign(key, "JWT", claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+/*
+VerifyIDTokenHint verifies the signature and issuer of an ID token passed back by a client, e.g. the id_token_hint of a logout.
+Expired tokens are accepted, as the hint only identifies the user and client, see OpenID Connect RP-Initiated Logout 1.0 section 2.
+
+Parameters:
+  - ctx: The context of the request.
+  - token: The signed token.
+
+Returns:
+  - *IDClaims: The claims of the token.
+  - error: ErrInvalidToken if the token is not valid.
+*/
+func (i *Issuer) VerifyIDTokenHint(ctx context.Context, token string) (*IDClaims, error) {
+	var claims IDClaims
+	lookup := func(kid string) (crypto.PublicKey, error) {
+		return i.keys.VerificationKey(ctx, kid)
+	}
+	if err := parse(token, typJWT, lookup, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != i.config.Issuer || len(claims.Audience) == 0 || slices.Contains(claims.Audience, "") {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// tokenHash returns the at_hash of token: the left half of its hash with the hash function of alg, see OpenID Connect Core 1.0 section 3.1.3.6.
+// Ed25519 uses SHA-512, as EdDSA itself does.
+func tokenHash(alg, token string) string {
+	var sum []byte
+	switch alg {
+	case AlgES384:
+		s := sha512.Sum384([]byte(token))
+		sum = s[:]
+	case AlgES512, AlgEdDSA:
+		s := sha512.Sum512([]byte(token))
+		sum = s[:]
+	default:
+		s := sha256.Sum256([]byte(token))
+		sum = s[:]
+	}
+	return b64.EncodeToString(sum[:len(sum)/2])
+}
@@ -0,0 +1,116 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssueIDToken(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t, AccessTokenConfig{IDTokenTTL: 10 * time.Minute})
+	authTime := time.Now().Add(-time.Hour)
+	token, issued, err := issuer.IssueIDToken(ctx, IDTokenRequest{
+		Subject:     "alice-id",
+		ClientID:    "console",
+		Nonce:       "nonce",
+		AuthTime:    authTime,
+		AccessToken: "access-token",
+		SessionID:   "session",
+		Username:    "alice",
+		Email:       "alice@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := issuer.VerifyIDTokenHint(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.IssuedAt != issued.IssuedAt || claims.AccessTokenHash != issued.AccessTokenHash {
+		t.Errorf("got claims %+v, want %+v", claims, issued)
+	}
+	if claims.Subject != "alice-id" || claims.AuthorizedParty != "console" || claims.Nonce != "nonce" || claims.SessionID != "session" ||
+		claims.Username != "alice" || claims.Name != "alice" || claims.Email != "alice@example.com" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "console" {
+		t.Errorf("aud = %v, want the client", claims.Audience)
+	}
+	if claims.ExpiresAt-claims.IssuedAt != 600 || claims.AuthTime != authTime.Unix() {
+		t.Errorf("exp, iat, auth_time = %d, %d, %d", claims.ExpiresAt, claims.IssuedAt, claims.AuthTime)
+	}
+	if claims.AccessTokenHash != tokenHash(AlgES256, "access-token") {
+		t.Errorf("at_hash = %q", claims.AccessTokenHash)
+	}
+}
+
+func TestIssueIDTokenOmitsEmptyClaims(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t, AccessTokenConfig{IDTokenTTL: time.Minute})
+	token, _, err := issuer.IssueIDToken(ctx, IDTokenRequest{Subject: "alice-id", ClientID: "console"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := issuer.VerifyIDTokenHint(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.AuthTime != 0 || claims.Nonce != "" || claims.AccessTokenHash != "" || claims.Username != "" || claims.Email != "" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestVerifyIDTokenHint(t *testing.T) {
+	ctx := context.Background()
+	// expired tokens are accepted as hints
+	issuer := newTestIssuer(t, AccessTokenConfig{IDTokenTTL: -time.Minute})
+	token, _, err := issuer.IssueIDToken(ctx, IDTokenRequest{Subject: "alice-id", ClientID: "console"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.VerifyIDTokenHint(ctx, token); err != nil {
+		t.Errorf("expired hint rejected: %v", err)
+	}
+
+	other := newTestIssuer(t, AccessTokenConfig{IDTokenTTL: time.Minute})
+	accessToken, _, err := issuer.IssueAccessToken(ctx, AccessTokenRequest{Subject: "alice-id", ClientID: "console"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"other issuer": func() string {
+			token, _, err := other.IssueIDToken(ctx, IDTokenRequest{Subject: "alice-id", ClientID: "console"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}(),
+		"access token": accessToken,
+		"truncated":    token[:len(token)-2],
+		"empty":        "",
+	} {
+		if _, err := issuer.VerifyIDTokenHint(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestTokenHash(t *testing.T) {
+	tests := []struct {
+		alg  string
+		want string
+	}{
+		{alg: AlgES256, want: "Pxa-1wifRlPl7yG_0oJNfw"},
+		{alg: AlgRS256, want: "Pxa-1wifRlPl7yG_0oJNfw"},
+		{alg: AlgES384, want: "fPnQAsvCEwe-sRB6O4tsdPgfI-EaOvsY"},
+		{alg: AlgES512, want: "Rb10lsqrobcpd02MQ_878ctvfDDbaRese_Z50nFw5Jw"},
+		{alg: AlgEdDSA, want: "Rb10lsqrobcpd02MQ_878ctvfDDbaRese_Z50nFw5Jw"},
+	}
+	for _, tt := range tests {
+		if got := tokenHash(tt.alg, "access-token"); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.alg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+)
+
+func TestJWKRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgEdDSA, AlgES256, AlgES384, AlgES512, AlgRS256} {
+		t.Run(alg, func(t *testing.T) {
+			key := newTestKey(t, alg)
+			jwk, err := NewJWK(key.Signer.Public(), key.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jwk.Kid != key.ID || jwk.Alg != alg || jwk.Use != "sig" {
+				t.Errorf("unexpected JWK %+v", jwk)
+			}
+			b, err := json.Marshal(JWKSet{Keys: []JWK{jwk}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var set JWKSet
+			if err := json.Unmarshal(b, &set); err != nil {
+				t.Fatal(err)
+			}
+			pub, err := set.Keys[0].PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// a token signed with the private key verifies with the decoded public key
+			token, err := sign(key, AccessTokenType, testClaims{Subject: "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var claims testClaims
+			if err := parse(token, AccessTokenType, lookupPublicKey(key.ID, pub), &claims); err != nil {
+				t.Errorf("token does not verify with the JWK: %v", err)
+			}
+		})
+	}
+}
+
+func TestJWKPublicKeyRejectsInvalidKeys(t *testing.T) {
+	ec := newTestKey(t, AlgES256)
+	ecJWK, err := NewJWK(ec.Signer.Public(), ec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := ecJWK
+	offCurve.Y = offCurve.X
+	smallRSA := JWK{Kty: "RSA", N: b64.EncodeToString(make([]byte, 128)), E: "AQAB"}
+	smallRSA.N = "1" + smallRSA.N[1:]
+
+	for name, jwk := range map[string]JWK{
+		"unknown type":    {Kty: "oct"},
+		"unknown curve":   {Kty: "EC", Crv: "P-192", X: ecJWK.X, Y: ecJWK.Y},
+		"point off curve": offCurve,
+		"short OKP":       {Kty: "OKP", Crv: "Ed25519", X: b64.EncodeToString(make([]byte, ed25519.PublicKeySize-1))},
+		"X25519":          {Kty: "OKP", Crv: "X25519", X: b64.EncodeToString(make([]byte, ed25519.PublicKeySize))},
+		"small RSA":       smallRSA,
+		"even exponent":   {Kty: "RSA", N: b64.EncodeToString(make([]byte, 256)), E: "Ag"},
+	} {
+		if _, err := jwk.PublicKey(); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+)
+
+// The JWS algorithms of the signing keys, see RFC 7518 and RFC 8037.
+const (
+	AlgEdDSA string = "EdDSA"
+	AlgES256 string = "ES256"
+	AlgES384 string = "ES384"
+	AlgES512 string = "ES512"
+	AlgRS256 string = "RS256"
+)
+
+// typJWT is the typ of plain JWTs, e.g. client assertions.
+const typJWT string = "jwt"
+
+// ErrInvalidToken is returned if a token is malformed, its signature is invalid or its claims are not valid.
+var ErrInvalidToken = errors.New("invalid token")
+
+// header is the JOSE header of a JWS.
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// b64 is the unpadded base64url encoding of the JWS parts.
+var b64 = base64.RawURLEncoding
+
+// Algorithm returns the JWS algorithm of the public key, or an error if the key type is not supported.
+func Algorithm(pub crypto.PublicKey) (string, error) {
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		return AlgEdDSA, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return AlgES256, nil
+		case 384:
+			return AlgES384, nil
+		case 521:
+			return AlgES512, nil
+		}
+		return "", fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+	case *rsa.PublicKey:
+		return AlgRS256, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// sign returns the JWS compact serialization of claims signed with key and typ as the media type of the header.
+func sign(key *Key, typ string, claims any) (string, error) {
+	h, err := json.Marshal(header{Alg: key.Algorithm, Typ: typ, Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := b64.EncodeToString(h) + "." + b64.EncodeToString(payload)
+	sig, err := signInput(key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + b64.EncodeToString(sig), nil
+}
+
+// signInput returns the JWS signature of the signing input.
+func signInput(key *Key, input []byte) ([]byte, error) {
+	if key.Algorithm == AlgEdDSA {
+		return key.Signer.Sign(rand.Reader, input, crypto.Hash(0))
+	}
+	h, hashFunc := algHash(key.Algorithm)
+	if h == nil {
+		return nil, fmt.Errorf("unsupported algorithm %q", key.Algorithm)
+	}
+	h.Write(input)
+	digest := h.Sum(nil)
+	sig, err := key.Signer.Sign(rand.Reader, digest, hashFunc)
+	if err != nil {
+		return nil, err
+	}
+	if pub, ok := key.Signer.Public().(*ecdsa.PublicKey); ok {
+		// crypto.Signer returns ASN.1 DER, JWS uses the fixed size concatenation of R and S
+		return ecdsaRaw(sig, pub)
+	}
+	return sig, nil
+}
+
+// parse verifies the signature of the JWS compact serialization token with the key returned by lookup,
+// checks the media type of its header and decodes its payload into claims.
+func parse(token string, typ string, lookup func(kid string) (crypto.PublicKey, error), claims any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidToken
+	}
+	rawHeader, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	var h header
+	if err := json.Unmarshal(rawHeader, &h); err != nil {
+		return ErrInvalidToken
+	}
+	// media types are case insensitive and may omit the "application/" prefix, see RFC 7515 section 4.1.9
+	// the typ of plain JWTs is optional, see RFC 7519 section 5.1
+	if t := strings.TrimPrefix(strings.ToLower(h.Typ), "application/"); t != typ && (typ != typJWT || t != "") {
+		return ErrInvalidToken
+	}
+	pub, err := lookup(h.Kid)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	// the algorithm of the header has to match the key, so a key can not be used with another algorithm
+	if alg, err := Algorithm(pub); err != nil || alg != h.Alg {
+		return ErrInvalidToken
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if !verifyInput(pub, h.Alg, []byte(parts[0]+"."+parts[1]), sig) {
+		return ErrInvalidToken
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// verifyInput reports whether sig is a valid signature of input with pub.
+func verifyInput(pub crypto.PublicKey, alg string, input, sig []byte) bool {
+	if k, ok := pub.(ed25519.PublicKey); ok {
+		return ed25519.Verify(k, input, sig)
+	}
+	h, hashFunc := algHash(alg)
+	if h == nil {
+		return false
+	}
+	h.Write(input)
+	digest := h.Sum(nil)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, hashFunc, digest, sig) == nil
+	default:
+		return false
+	}
+}
+
+// algHash returns a new hash of the algorithm alg and its crypto.Hash.
+func algHash(alg string) (hash.Hash, crypto.Hash) {
+	switch alg {
+	case AlgES256, AlgRS256:
+		return sha256.New(), crypto.SHA256
+	case AlgES384:
+		return sha512.New384(), crypto.SHA384
+	case AlgES512:
+		return sha512.New(), crypto.SHA512
+	default:
+		return nil, 0
+	}
+}
+
+// ecdsaRaw converts an ASN.1 DER ECDSA signature to the concatenation of R and S padded to the size of the curve.
+func ecdsaRaw(der []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
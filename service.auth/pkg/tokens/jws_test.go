@@ -0,0 +1,136 @@
+package tokens
+
+import (
+	"crypto"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testClaims are the claims of the signed test tokens.
+type testClaims struct {
+	Subject string `json:"sub"`
+}
+
+// newTestKey returns a new Key of the algorithm alg.
+func newTestKey(t *testing.T, alg string) *Key {
+	t.Helper()
+	signer, err := GenerateKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewKey(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// lookupKey returns a lookup of parse knowing only key.
+func lookupKey(key *Key) func(kid string) (crypto.PublicKey, error) {
+	return lookupPublicKey(key.ID, key.Signer.Public())
+}
+
+// lookupPublicKey returns a lookup of parse knowing only pub with the key ID kid.
+func lookupPublicKey(kid string, pub crypto.PublicKey) func(kid string) (crypto.PublicKey, error) {
+	return func(k string) (crypto.PublicKey, error) {
+		if k != kid {
+			return nil, ErrUnknownKey
+		}
+		return pub, nil
+	}
+}
+
+func TestSignAndParse(t *testing.T) {
+	for _, alg := range []string{AlgEdDSA, AlgES256, AlgES384, AlgES512, AlgRS256} {
+		t.Run(alg, func(t *testing.T) {
+			key := newTestKey(t, alg)
+			if key.Algorithm != alg {
+				t.Fatalf("key algorithm %q, want %q", key.Algorithm, alg)
+			}
+			token, err := sign(key, AccessTokenType, testClaims{Subject: "alice"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var claims testClaims
+			if err := parse(token, AccessTokenType, lookupKey(key), &claims); err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "alice" {
+				t.Errorf("sub = %q, want alice", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidTokens(t *testing.T) {
+	key := newTestKey(t, AlgES256)
+	other := newTestKey(t, AlgES256)
+	token, err := sign(key, AccessTokenType, testClaims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	forged, err := json.Marshal(testClaims{Subject: "mallory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt, err := sign(key, typJWT, testClaims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header of an EdDSA token with the kid of the ES256 key
+	edKey := newTestKey(t, AlgEdDSA)
+	edKey.ID = key.ID
+	confused, err := sign(edKey, AccessTokenType, testClaims{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		token  string
+		lookup func(kid string) (crypto.PublicKey, error)
+	}{
+		"malformed":          {"abc", lookupKey(key)},
+		"forged payload":     {parts[0] + "." + b64.EncodeToString(forged) + "." + parts[2], lookupKey(key)},
+		"missing signature":  {parts[0] + "." + parts[1] + ".", lookupKey(key)},
+		"unknown key":        {token, lookupKey(other)},
+		"other type":         {jwt, lookupKey(key)},
+		"algorithm mismatch": {confused, lookupKey(key)},
+	} {
+		var claims testClaims
+		if err := parse(tc.token, AccessTokenType, tc.lookup, &claims); err != ErrInvalidToken {
+			t.Errorf("%s: got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestParseMediaType(t *testing.T) {
+	key := newTestKey(t, AlgEdDSA)
+	for typ, want := range map[string]bool{
+		"at+jwt":             true,
+		"AT+JWT":             true,
+		"application/at+jwt": true,
+		"":                   false,
+		"jwt":                false,
+	} {
+		token, err := sign(key, typ, testClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var claims testClaims
+		if err := parse(token, AccessTokenType, lookupKey(key), &claims); (err == nil) != want {
+			t.Errorf("typ %q: got %v, want accepted %v", typ, err, want)
+		}
+	}
+
+	// the typ of plain JWTs is optional
+	token, err := sign(key, "", testClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims testClaims
+	if err := parse(token, typJWT, lookupKey(key), &claims); err != nil {
+		t.Errorf("JWT without typ: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package tokens
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownKey is returned by KeySource.VerificationKey if no key with the key ID exists.
+var ErrUnknownKey = errors.New("unknown key")
+
+// Key is a struct that represents a key tokens are signed with.
+type Key struct {
+	// ID is the key ID set as the kid header of the signed tokens.
+	ID string
+	// Algorithm is the JWS algorithm of the key, e.g. AlgEdDSA.
+	Algorithm string
+	Signer    crypto.Signer
+}
+
+// KeySource is an interface that represents the keys tokens are signed and verified with.
+type KeySource interface {
+	// SigningKey returns the key new tokens are signed with.
+	SigningKey(ctx context.Context) (*Key, error)
+	// VerificationKey returns the public key with the key ID kid, or ErrUnknownKey.
+	VerificationKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeySource is an implementation of KeySource with a single key.
+type StaticKeySource struct {
+	key *Key
+}
+
+// interface guard
+var _ KeySource = (*StaticKeySource)(nil)
+
+// NewStaticKeySource returns a KeySource signing and verifying the tokens with signer.
+func NewStaticKeySource(signer crypto.Signer) (*StaticKeySource, error) {
+	key, err := NewKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeySource{key: key}, nil
+}
+
+// SigningKey implements KeySource.
+func (s *StaticKeySource) SigningKey(_ context.Context) (*Key, error) {
+	return s.key, nil
+}
+
+// VerificationKey implements KeySource.
+func (s *StaticKeySource) VerificationKey(_ context.Context, kid string) (crypto.PublicKey, error) {
+	if kid != s.key.ID {
+		return nil, ErrUnknownKey
+	}
+	return s.key.Signer.Public(), nil
+}
+
+// NewKey returns the Key of signer with the algorithm of its public key and its thumbprint as the ID.
+func NewKey(signer crypto.Signer) (*Key, error) {
+	alg, err := Algorithm(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	kid, err := Thumbprint(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ID: kid, Algorithm: alg, Signer: signer}, nil
+}
+
+// GenerateKey returns a new private key for the JWS algorithm alg.
+func GenerateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case AlgEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	case AlgES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgES384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case AlgES512:
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case AlgRS256:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
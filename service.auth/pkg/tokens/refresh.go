@@ -0,0 +1,283 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// refreshTokenBytes is the length of the random refresh tokens.
+const refreshTokenBytes = 32
+
+var (
+	// ErrTokenNotFound is returned by a RefreshStore if no token with the ID exists.
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrTokenReused is returned if a refresh token is used after it was rotated. The token family is revoked.
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshToken is a struct that represents the stored state of a refresh token. The token itself is not stored.
+type RefreshToken struct {
+	// ID is the SHA-256 hash of the token in hex.
+	ID string
+	// FamilyID is the ID of the token family. All tokens rotated from the same login share it.
+	FamilyID string
+	Subject  string
+	ClientID string
+	Scope    string
+	// AuthTime is the time the user authenticated with the login starting the family.
+	AuthTime  time.Time
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// FamilyExpiresAt is the time after which no token of the family can be rotated, regardless of ExpiresAt.
+	FamilyExpiresAt time.Time
+	// Used is set once the token was rotated.
+	Used bool
+	// Revoked is set if the family was revoked.
+	Revoked bool
+}
+
+// expired reports whether the token can not be used at t.
+func (t *RefreshToken) expired(at time.Time) bool {
+	return !at.Before(t.ExpiresAt) || !at.Before(t.FamilyExpiresAt)
+}
+
+// RefreshStore is an interface that represents the storage of the refresh tokens.
+type RefreshStore interface {
+	// Create stores a new token.
+	Create(ctx context.Context, token *RefreshToken) error
+	// Get returns the token with id, or ErrTokenNotFound.
+	Get(ctx context.Context, id string) (*RefreshToken, error)
+	// MarkUsed sets Used on the token with id. It returns ErrTokenReused if the token was already used or revoked,
+	// so concurrent rotations of the same token can not both succeed.
+	MarkUsed(ctx context.Context, id string) error
+	// RevokeFamily sets Revoked on all tokens of the family.
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// MemoryRefreshStore is an implementation of RefreshStore keeping the tokens in memory. Expired tokens are removed periodically.
+type MemoryRefreshStore struct {
+	mu        sync.Mutex
+	tokens    map[string]*RefreshToken
+	lastSweep time.Time
+}
+
+// interface guard
+var _ RefreshStore = (*MemoryRefreshStore)(nil)
+
+// sweepInterval is the interval expired tokens are removed from a MemoryRefreshStore in.
+const sweepInterval = time.Minute
+
+// NewMemoryRefreshStore returns an empty MemoryRefreshStore.
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{tokens: make(map[string]*RefreshToken), lastSweep: time.Now()}
+}
+
+// Create implements RefreshStore.
+func (s *MemoryRefreshStore) Create(_ context.Context, token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	c := *token
+	s.tokens[token.ID] = &c
+	return nil
+}
+
+// Get implements RefreshStore.
+func (s *MemoryRefreshStore) Get(_ context.Context, id string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	c := *t
+	return &c, nil
+}
+
+// MarkUsed implements RefreshStore.
+func (s *MemoryRefreshStore) MarkUsed(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return ErrTokenNotFound
+	}
+	if t.Used || t.Revoked {
+		return ErrTokenReused
+	}
+	t.Used = true
+	return nil
+}
+
+// RevokeFamily implements RefreshStore.
+func (s *MemoryRefreshStore) RevokeFamily(_ context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.FamilyID == familyID {
+			t.Revoked = true
+		}
+	}
+	return nil
+}
+
+// sweep removes the expired tokens if the sweep interval passed. The mutex has to be held.
+// Used tokens are kept until they expire, so their reuse is still detected.
+func (s *MemoryRefreshStore) sweep() {
+	now := time.Now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for id, t := range s.tokens {
+		if t.expired(now) {
+			delete(s.tokens, id)
+		}
+	}
+}
+
+// RefreshTokenConfig is a struct that represents the configuration of the refresh tokens.
+type RefreshTokenConfig struct {
+	// TTL is the lifetime of a token. A rotated token gets a new lifetime.
+	TTL time.Duration
+	// FamilyTTL is the absolute lifetime of a token family, after which the user has to log in again.
+	FamilyTTL time.Duration
+}
+
+// RefreshGrant is a struct that represents the subject and client a refresh token is issued to.
+type RefreshGrant struct {
+	Subject  string
+	ClientID string
+	Scope    string
+	AuthTime time.Time
+}
+
+// RefreshTokens issues and rotates the opaque refresh tokens.
+type RefreshTokens struct {
+	store  RefreshStore
+	config RefreshTokenConfig
+}
+
+// NewRefreshTokens returns the RefreshTokens stored in store.
+func NewRefreshTokens(store RefreshStore, config RefreshTokenConfig) *RefreshTokens {
+	return &RefreshTokens{store: store, config: config}
+}
+
+// TTL returns the lifetime of a refresh token.
+func (r *RefreshTokens) TTL() time.Duration {
+	return r.config.TTL
+}
+
+// Issue returns a refresh token of a new token family for grant.
+func (r *RefreshTokens) Issue(ctx context.Context, grant RefreshGrant) (string, error) {
+	familyID, err := randomID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return r.issue(ctx, &RefreshToken{
+		FamilyID:        familyID,
+		Subject:         grant.Subject,
+		ClientID:        grant.ClientID,
+		Scope:           grant.Scope,
+		AuthTime:        grant.AuthTime,
+		FamilyExpiresAt: now.Add(r.config.FamilyTTL),
+	})
+}
+
+/*
+Rotate exchanges a refresh token for a new token of the same family. Each token can be rotated once.
+If a rotated token is presented again, it was likely stolen: the whole family is revoked, so neither the attacker
+nor the user can use it anymore, and ErrTokenReused is returned.
+
+Parameters:
+  - ctx: The context of the request.
+  - token: The presented refresh token.
+
+Returns:
+  - string: The new refresh token.
+  - *RefreshToken: The state of the presented token, holding the subject and client of the grant.
+  - error: ErrInvalidToken if the token is unknown or expired, ErrTokenReused if it was already rotated or revoked.
+*/
+func (r *RefreshTokens) Rotate(ctx context.Context, token string) (string, *RefreshToken, error) {
+	old, err := r.store.Get(ctx, hashToken(token))
+	if errors.Is(err, ErrTokenNotFound) {
+		return "", nil, ErrInvalidToken
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if old.expired(time.Now()) {
+		return "", nil, ErrInvalidToken
+	}
+	if err := r.store.MarkUsed(ctx, old.ID); err != nil {
+		if errors.Is(err, ErrTokenReused) {
+			return "", old, errors.Join(err, r.store.RevokeFamily(ctx, old.FamilyID))
+		}
+		return "", nil, err
+	}
+	next := *old
+	next.Used = false
+	newToken, err := r.issue(ctx, &next)
+	if err != nil {
+		return "", nil, err
+	}
+	return newToken, old, nil
+}
+
+// Lookup returns the state of the refresh token if it is active: known, not expired, not rotated and not revoked.
+// Otherwise, ErrInvalidToken is returned.
+func (r *RefreshTokens) Lookup(ctx context.Context, token string) (*RefreshToken, error) {
+	t, err := r.store.Get(ctx, hashToken(token))
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Used || t.Revoked || t.expired(time.Now()) {
+		return nil, ErrInvalidToken
+	}
+	return t, nil
+}
+
+// Revoke revokes the family of the refresh token. Unknown tokens are ignored.
+func (r *RefreshTokens) Revoke(ctx context.Context, token string) error {
+	t, err := r.store.Get(ctx, hashToken(token))
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.store.RevokeFamily(ctx, t.FamilyID)
+}
+
+// issue generates a new token, sets the ID and lifetime of t and stores it.
+func (r *RefreshTokens) issue(ctx context.Context, t *RefreshToken) (string, error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating refresh token: %w", err)
+	}
+	token := b64.EncodeToString(b)
+	now := time.Now()
+	t.ID = hashToken(token)
+	t.CreatedAt = now
+	t.ExpiresAt = now.Add(r.config.TTL)
+	if err := r.store.Create(ctx, t); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// hashToken returns the ID of a refresh token, the SHA-256 hash in hex. The token has enough entropy to not need a salt.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestRefreshTokens returns RefreshTokens in memory with config, with a TTL of an hour and a family TTL of a day if not set.
+func newTestRefreshTokens(config RefreshTokenConfig) *RefreshTokens {
+	if config.TTL == 0 {
+		config.TTL = time.Hour
+	}
+	if config.FamilyTTL == 0 {
+		config.FamilyTTL = 24 * time.Hour
+	}
+	return NewRefreshTokens(NewMemoryRefreshStore(), config)
+}
+
+// testGrant is the grant of the issued test tokens.
+var testGrant = RefreshGrant{Subject: "alice", ClientID: "console", Scope: "openid"}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRefreshTokens(RefreshTokenConfig{})
+	first, err := r.Issue(ctx, testGrant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := r.Lookup(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Subject != "alice" || state.ClientID != "console" || state.Scope != "openid" || state.ID == first {
+		t.Errorf("unexpected state %+v", state)
+	}
+
+	second, old, err := r.Rotate(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || old.FamilyID != state.FamilyID {
+		t.Errorf("rotated to %q in family %q", second, old.FamilyID)
+	}
+	if _, err := r.Lookup(ctx, first); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("rotated token still active: %v", err)
+	}
+	next, err := r.Lookup(ctx, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.FamilyID != state.FamilyID || !next.FamilyExpiresAt.Equal(state.FamilyExpiresAt) {
+		t.Errorf("rotation started a new family: %+v", next)
+	}
+
+	// reusing the rotated token revokes the family
+	if _, _, err := r.Rotate(ctx, first); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("reuse: got %v, want ErrTokenReused", err)
+	}
+	if _, err := r.Lookup(ctx, second); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of the revoked family still active: %v", err)
+	}
+	if _, _, err := r.Rotate(ctx, second); !errors.Is(err, ErrTokenReused) {
+		t.Errorf("rotation of a revoked token: got %v, want ErrTokenReused", err)
+	}
+	if _, _, err := r.Rotate(ctx, "unknown"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown token: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	for name, config := range map[string]RefreshTokenConfig{
+		"token":  {TTL: -time.Second},
+		"family": {FamilyTTL: -time.Second},
+	} {
+		r := newTestRefreshTokens(config)
+		token, err := r.Issue(ctx, testGrant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Lookup(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s expired: Lookup got %v, want ErrInvalidToken", name, err)
+		}
+		if _, _, err := r.Rotate(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s expired: Rotate got %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestRefreshTokenRevoke(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRefreshTokens(RefreshTokenConfig{})
+	first, err := r.Issue(ctx, testGrant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := r.Rotate(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := r.Issue(ctx, testGrant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// revoking any token of a family revokes the whole family
+	if err := r.Revoke(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Lookup(ctx, second); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of the revoked family still active: %v", err)
+	}
+	if _, err := r.Lookup(ctx, other); err != nil {
+		t.Errorf("token of another family revoked: %v", err)
+	}
+	if err := r.Revoke(ctx, "unknown"); err != nil {
+		t.Errorf("revoking an unknown token: %v", err)
+	}
+}
+
+func TestRefreshTokenConcurrentRotation(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRefreshTokens(RefreshTokenConfig{})
+	token, err := r.Issue(ctx, testGrant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := r.Rotate(ctx, token); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Errorf("%d concurrent rotations succeeded, want 1", succeeded)
+	}
+}
@@ -0,0 +1,129 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminPath is the path of the user management API relative to the versioned API group.
+const AdminPath string = "/admin/users"
+
+// maxListLimit is the maximum page size of the list endpoint.
+const maxListLimit int = 1000
+
+// handlers serves the user management API.
+type handlers struct {
+	svc *Service
+}
+
+/*
+RegisterAdminRoutes registers the user management API of svc on g. The group has to be restricted to administrators.
+
+The following endpoints are registered:
+  - GET /: Lists the users, paginated with the offset and limit query parameters.
+  - POST /: Creates a user from a CreateInput.
+  - GET /:id: Returns a user.
+  - PATCH /:id: Updates a user with an UpdateInput.
+  - DELETE /:id: Deletes a user.
+
+Example usage:
+
+	users.RegisterAdminRoutes(api.Group(users.AdminPath, adminAuth), svc)
+*/
+func RegisterAdminRoutes(g *echo.Group, svc *Service) {
+	h := &handlers{svc: svc}
+	g.GET("", h.list)
+	g.POST("", h.create)
+	g.GET("/:id", h.get)
+	g.PATCH("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+}
+
+func (h *handlers) list(c echo.Context) error {
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		return err
+	}
+	limit, err := queryInt(c, "limit", 100)
+	if err != nil {
+		return err
+	}
+	users, err := h.svc.List(c.Request().Context(), ListOptions{Offset: offset, Limit: min(limit, maxListLimit)})
+	if err != nil {
+		return httpError(err)
+	}
+	if users == nil {
+		users = []*User{}
+	}
+	return c.JSON(http.StatusOK, users)
+}
+
+func (h *handlers) create(c echo.Context) error {
+	var input CreateInput
+	if err := c.Bind(&input); err != nil {
+		return err
+	}
+	u, err := h.svc.Create(c.Request().Context(), input)
+	if err != nil {
+		return httpError(err)
+	}
+	return c.JSON(http.StatusCreated, u)
+}
+
+func (h *handlers) get(c echo.Context) error {
+	u, err := h.svc.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return httpError(err)
+	}
+	return c.JSON(http.StatusOK, u)
+}
+
+func (h *handlers) update(c echo.Context) error {
+	var input UpdateInput
+	if err := c.Bind(&input); err != nil {
+		return err
+	}
+	u, err := h.svc.Update(c.Request().Context(), c.Param("id"), input)
+	if err != nil {
+		return httpError(err)
+	}
+	return c.JSON(http.StatusOK, u)
+}
+
+func (h *handlers) delete(c echo.Context) error {
+	if err := h.svc.Delete(c.Request().Context(), c.Param("id")); err != nil {
+		return httpError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// queryInt returns the non-negative integer query parameter name, or def if it is not set.
+func queryInt(c echo.Context, name string, def int) (int, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid "+name)
+	}
+	return n, nil
+}
+
+// httpError maps the errors of the Service to HTTP errors. Other errors are returned unchanged and result in a 500.
+func httpError(err error) error {
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return echo.NewHTTPError(http.StatusBadRequest, validationErr.Error())
+	case errors.Is(err, ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrAlreadyExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return err
+	}
+}
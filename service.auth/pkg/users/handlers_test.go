@@ -0,0 +1,70 @@
+package users
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestAPI returns an Echo server with the user management API of svc under /admin/users.
+func newTestAPI(svc *Service) *echo.Echo {
+	e := echo.New()
+	RegisterAdminRoutes(e.Group(AdminPath), svc)
+	return e
+}
+
+// do sends a request with the JSON body to e and returns the recorded response.
+func do(e *echo.Echo, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminRoutes(t *testing.T) {
+	e := newTestAPI(newTestService(t, NewMemoryRepository(), testParams))
+
+	rec := do(e, http.MethodPost, AdminPath, `{"username":"alice","password":"correct horse","roles":["reader"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "argon2id") || strings.Contains(rec.Body.String(), "correct horse") {
+		t.Errorf("create response leaks the password: %s", rec.Body)
+	}
+	var created User
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name, method, target, body string
+		want                       int
+	}{
+		{"duplicate", http.MethodPost, AdminPath, `{"username":"alice","password":"correct horse"}`, http.StatusConflict},
+		{"invalid", http.MethodPost, AdminPath, `{"username":"bob","password":"short"}`, http.StatusBadRequest},
+		{"get", http.MethodGet, AdminPath + "/" + created.ID, "", http.StatusOK},
+		{"get missing", http.MethodGet, AdminPath + "/missing", "", http.StatusNotFound},
+		{"list", http.MethodGet, AdminPath + "?limit=10", "", http.StatusOK},
+		{"invalid limit", http.MethodGet, AdminPath + "?limit=-1", "", http.StatusBadRequest},
+		{"update", http.MethodPatch, AdminPath + "/" + created.ID, `{"email":"alice@example.com"}`, http.StatusOK},
+		{"update missing", http.MethodPatch, AdminPath + "/missing", `{"email":"alice@example.com"}`, http.StatusNotFound},
+		{"delete", http.MethodDelete, AdminPath + "/" + created.ID, "", http.StatusNoContent},
+		{"delete again", http.MethodDelete, AdminPath + "/" + created.ID, "", http.StatusNotFound},
+	} {
+		if rec := do(e, tc.method, tc.target, tc.body); rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d: %s", tc.name, rec.Code, tc.want, rec.Body)
+		}
+	}
+
+	rec = do(e, http.MethodGet, AdminPath, "")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("list of no users: got %d %s, want an empty array", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,144 @@
+package users
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MemoryRepository is an implementation of Repository keeping the users in memory. It is meant for tests and development.
+type MemoryRepository struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	// ids maps the usernames to the IDs of the users.
+	ids map[string]string
+}
+
+// interface guard
+var _ Repository = (*MemoryRepository)(nil)
+
+// NewMemoryRepository returns an empty MemoryRepository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users: make(map[string]*User),
+		ids:   make(map[string]string),
+	}
+}
+
+// Create implements Repository.
+func (r *MemoryRepository) Create(_ context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.ids[user.Username]; ok {
+		return ErrAlreadyExists
+	}
+	if _, ok := r.users[user.ID]; ok {
+		return ErrAlreadyExists
+	}
+	r.users[user.ID] = clone(user)
+	r.ids[user.Username] = user.ID
+	return nil
+}
+
+// Get implements Repository.
+func (r *MemoryRepository) Get(_ context.Context, id string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(u), nil
+}
+
+// GetByUsername implements Repository.
+func (r *MemoryRepository) GetByUsername(_ context.Context, username string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.ids[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(r.users[id]), nil
+}
+
+// List implements Repository.
+func (r *MemoryRepository) List(_ context.Context, opts ListOptions) ([]*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	usernames := make([]string, 0, len(r.ids))
+	for username := range r.ids {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	usernames = page(usernames, opts)
+	users := make([]*User, 0, len(usernames))
+	for _, username := range usernames {
+		users = append(users, clone(r.users[r.ids[username]]))
+	}
+	return users, nil
+}
+
+// Update implements Repository.
+func (r *MemoryRepository) Update(_ context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if old.Username != user.Username {
+		if _, ok := r.ids[user.Username]; ok {
+			return ErrAlreadyExists
+		}
+		delete(r.ids, old.Username)
+		r.ids[user.Username] = user.ID
+	}
+	r.users[user.ID] = clone(user)
+	return nil
+}
+
+// UpdatePasswordHash implements Repository.
+func (r *MemoryRepository) UpdatePasswordHash(_ context.Context, id, oldHash, newHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.PasswordHash != oldHash {
+		return ErrNotFound
+	}
+	u.PasswordHash = newHash
+	return nil
+}
+
+// Delete implements Repository.
+func (r *MemoryRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.ids, u.Username)
+	delete(r.users, id)
+	return nil
+}
+
+// page returns the items of s selected by opts.
+func page[T any](s []T, opts ListOptions) []T {
+	if opts.Offset >= len(s) {
+		return nil
+	}
+	s = s[max(opts.Offset, 0):]
+	if opts.Limit > 0 && opts.Limit < len(s) {
+		s = s[:opts.Limit]
+	}
+	return s
+}
+
+// clone returns a copy of u, so the stored users can not be modified by the callers.
+func clone(u *User) *User {
+	c := *u
+	c.Roles = slices.Clone(u.Roles)
+	return &c
+}
@@ -0,0 +1,145 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The defaults of the unset argon2id parameters, the second recommended option of RFC 9106 with less parallelism.
+const (
+	DefaultArgon2Memory      uint32 = 64 * 1024
+	DefaultArgon2Iterations  uint32 = 3
+	DefaultArgon2Parallelism uint8  = 2
+	DefaultArgon2SaltLength  uint32 = 16
+	DefaultArgon2KeyLength   uint32 = 32
+)
+
+// errInvalidHash is returned if a stored hash is not an argon2id hash in the PHC string format.
+var errInvalidHash = errors.New("invalid argon2id hash")
+
+// Argon2Params is a struct that represents the parameters of the argon2id password hashing.
+type Argon2Params struct {
+	// Memory is the memory used by a hash in KiB. If zero, DefaultArgon2Memory is used.
+	Memory uint32
+	// Iterations is the number of passes over the memory. If zero, DefaultArgon2Iterations is used.
+	Iterations uint32
+	// Parallelism is the number of threads used by a hash. If zero, DefaultArgon2Parallelism is used.
+	Parallelism uint8
+	// SaltLength is the length of the random salt in bytes. If zero, DefaultArgon2SaltLength is used.
+	SaltLength uint32
+	// KeyLength is the length of the hash in bytes. If zero, DefaultArgon2KeyLength is used.
+	KeyLength uint32
+}
+
+// withDefaults returns the params with the defaults applied to the unset fields.
+func (p Argon2Params) withDefaults() Argon2Params {
+	if p.Memory == 0 {
+		p.Memory = DefaultArgon2Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = DefaultArgon2Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = DefaultArgon2Parallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = DefaultArgon2SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = DefaultArgon2KeyLength
+	}
+	return p
+}
+
+// Hasher hashes and verifies passwords with argon2id.
+type Hasher struct {
+	params Argon2Params
+}
+
+// NewHasher returns a Hasher hashing the passwords with params.
+func NewHasher(params Argon2Params) *Hasher {
+	return &Hasher{params: params.withDefaults()}
+}
+
+/*
+Hash returns the argon2id hash of password with a random salt in the PHC string format,
+e.g. "$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>" with the salt and hash encoded in unpadded base64.
+
+Parameters:
+  - password: The password to hash.
+
+Returns:
+  - string: The encoded hash.
+  - error: An error if the salt could not be generated.
+*/
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+/*
+Verify reports whether password matches the encoded hash. The hash is computed with the parameters stored in it,
+so hashes created with older parameters can still be verified.
+
+Parameters:
+  - password: The password to verify.
+  - encoded: The hash created by Hash.
+
+Returns:
+  - bool: Whether the password matches.
+  - bool: Whether the hash was created with other parameters than the current ones and should be replaced by a new hash.
+  - error: An error if the hash could not be decoded.
+*/
+func (h *Hasher) Verify(password, encoded string) (match bool, rehash bool, err error) {
+	params, salt, key, err := decodeHash(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	got := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return false, false, nil
+	}
+	return true, params != h.params, nil
+}
+
+// decodeHash returns the parameters, salt and key of an argon2id hash in the PHC string format.
+func decodeHash(encoded string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, errInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, errInvalidHash
+	}
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, errInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, errInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, errInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,68 @@
+package users
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHasherHashAndVerify(t *testing.T) {
+	h := NewHasher(testParams)
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected hash format %q", hash)
+	}
+	other, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == other {
+		t.Error("hashes of the same password are equal, the salt is not random")
+	}
+
+	match, rehash, err := h.Verify("correct horse", hash)
+	if err != nil || !match || rehash {
+		t.Errorf("Verify(correct) = %v, %v, %v, want true, false, nil", match, rehash, err)
+	}
+	match, _, err = h.Verify("wrong horse", hash)
+	if err != nil || match {
+		t.Errorf("Verify(wrong) = %v, %v, want false, nil", match, err)
+	}
+}
+
+func TestHasherVerifyRequestsRehash(t *testing.T) {
+	hash, err := NewHasher(testParams).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, params := range map[string]Argon2Params{
+		"memory":      {Memory: 128, Iterations: 1, Parallelism: 1},
+		"iterations":  {Memory: 64, Iterations: 2, Parallelism: 1},
+		"parallelism": {Memory: 64, Iterations: 1, Parallelism: 2},
+		"key length":  {Memory: 64, Iterations: 1, Parallelism: 1, KeyLength: 64},
+	} {
+		match, rehash, err := NewHasher(params).Verify("correct horse", hash)
+		if err != nil || !match || !rehash {
+			t.Errorf("%s: Verify = %v, %v, %v, want true, true, nil", name, match, rehash, err)
+		}
+	}
+}
+
+func TestHasherVerifyInvalidHash(t *testing.T) {
+	h := NewHasher(testParams)
+	for _, hash := range []string{
+		"",
+		"plain",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$",
+	} {
+		if _, _, err := h.Verify("password", hash); err == nil {
+			t.Errorf("Verify(%q): got no error", hash)
+		}
+	}
+}
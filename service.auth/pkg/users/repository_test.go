@@ -0,0 +1,136 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRepository checks the contract of Repository on the empty repo.
+func testRepository(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	newUser := func(id, username string) *User {
+		return &User{ID: id, Username: username, PasswordHash: "hash-" + id, Roles: []string{"reader"}, CreatedAt: now, UpdatedAt: now}
+	}
+
+	for _, u := range []*User{newUser("1", "carol"), newUser("2", "alice"), newUser("3", "bob")} {
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Create(ctx, newUser("4", "alice")); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Create duplicate username: got %v, want ErrAlreadyExists", err)
+	}
+
+	u, err := repo.Get(ctx, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username != "alice" || u.PasswordHash != "hash-2" || len(u.Roles) != 1 || u.Roles[0] != "reader" || !u.CreatedAt.Equal(now) {
+		t.Errorf("Get returned %+v", u)
+	}
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing: got %v, want ErrNotFound", err)
+	}
+	if u, err := repo.GetByUsername(ctx, "bob"); err != nil || u.ID != "3" {
+		t.Errorf("GetByUsername(bob) = %v, %v", u, err)
+	}
+	if _, err := repo.GetByUsername(ctx, "dave"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByUsername missing: got %v, want ErrNotFound", err)
+	}
+
+	list, err := repo.List(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := usernames(list); got != "alice,bob,carol" {
+		t.Errorf("List = %s, want alice,bob,carol", got)
+	}
+	list, err = repo.List(ctx, ListOptions{Offset: 1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := usernames(list); got != "bob" {
+		t.Errorf("List page = %s, want bob", got)
+	}
+
+	u.Username = "alicia"
+	u.Roles = nil
+	if err := repo.Update(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByUsername(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("old username still found after rename: %v", err)
+	}
+	if got, err := repo.GetByUsername(ctx, "alicia"); err != nil || got.ID != "2" || len(got.Roles) != 0 {
+		t.Errorf("GetByUsername(alicia) = %+v, %v", got, err)
+	}
+	u.Username = "bob"
+	if err := repo.Update(ctx, u); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Update to taken username: got %v, want ErrAlreadyExists", err)
+	}
+	if err := repo.Update(ctx, newUser("missing", "dave")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update missing: got %v, want ErrNotFound", err)
+	}
+
+	if err := repo.UpdatePasswordHash(ctx, "3", "stale", "new"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdatePasswordHash with stale hash: got %v, want ErrNotFound", err)
+	}
+	if err := repo.UpdatePasswordHash(ctx, "3", "hash-3", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.Get(ctx, "3"); err != nil || got.PasswordHash != "new" || got.Username != "bob" {
+		t.Errorf("after UpdatePasswordHash: %+v, %v", got, err)
+	}
+
+	if err := repo.Delete(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, "1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete twice: got %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetByUsername(ctx, "carol"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted user still found: %v", err)
+	}
+	if err := repo.Create(ctx, newUser("5", "carol")); err != nil {
+		t.Errorf("username of a deleted user not reusable: %v", err)
+	}
+}
+
+// usernames returns the usernames of users as a comma separated list.
+func usernames(users []*User) string {
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.Username
+	}
+	return strings.Join(names, ",")
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, NewMemoryRepository())
+}
+
+func TestMemoryRepositoryReturnsCopies(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	u := &User{ID: "1", Username: "alice", Roles: []string{"reader"}}
+	if err := repo.Create(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	u.Roles[0] = "writer"
+	got, err := repo.Get(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Roles[0] = "writer"
+	again, err := repo.Get(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Roles[0] != "reader" {
+		t.Errorf("stored roles modified through a caller: %v", again.Roles)
+	}
+}
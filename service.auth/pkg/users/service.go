@@ -0,0 +1,234 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MinPasswordLength is the minimum length of the passwords in bytes.
+const MinPasswordLength int = 8
+
+// ValidationError is returned if the fields of a created or updated user are invalid.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// CreateInput is a struct that represents the fields of a new user.
+type CreateInput struct {
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+	Disabled bool     `json:"disabled"`
+}
+
+// UpdateInput is a struct that represents the changed fields of a user. Nil fields are left unchanged.
+type UpdateInput struct {
+	Email    *string   `json:"email"`
+	Password *string   `json:"password"`
+	Roles    *[]string `json:"roles"`
+	Disabled *bool     `json:"disabled"`
+}
+
+// Service manages the users and authenticates them with their passwords.
+type Service struct {
+	repo   Repository
+	hasher *Hasher
+	logger *slog.Logger
+	// dummyHash is verified for unknown usernames, so their logins take as long as the logins of existing users.
+	dummyHash string
+}
+
+/*
+NewService creates the Service of the users stored in repo.
+
+Parameters:
+  - repo: The repository the users are stored in.
+  - hasher: The hasher of the passwords. Its parameters are the current ones, older hashes are replaced on login.
+  - logger: The logger of the failed rehashes.
+
+Returns:
+  - *Service: The created service.
+  - error: An error if the dummy hash could not be created.
+
+Example usage:
+
+	svc, err := users.NewService(users.NewMemoryRepository(), users.NewHasher(users.Argon2Params{}), logger)
+*/
+func NewService(repo Repository, hasher *Hasher, logger *slog.Logger) (*Service, error) {
+	dummyHash, err := hasher.Hash(uuid.NewString())
+	if err != nil {
+		return nil, err
+	}
+	return &Service{repo: repo, hasher: hasher, logger: logger, dummyHash: dummyHash}, nil
+}
+
+// Create validates input, hashes the password and stores the new user.
+func (s *Service) Create(ctx context.Context, input CreateInput) (*User, error) {
+	input.Username = strings.TrimSpace(input.Username)
+	if input.Username == "" {
+		return nil, &ValidationError{Field: "username", Reason: "must not be empty"}
+	}
+	if err := validatePassword(input.Password); err != nil {
+		return nil, err
+	}
+	roles, err := normalizeRoles(input.Roles)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := s.hasher.Hash(input.Password)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	u := &User{
+		ID:           uuid.NewString(),
+		Username:     input.Username,
+		Email:        strings.TrimSpace(input.Email),
+		PasswordHash: hash,
+		Roles:        roles,
+		Disabled:     input.Disabled,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.repo.Create(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Get returns the user with id.
+func (s *Service) Get(ctx context.Context, id string) (*User, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// List returns a page of the users ordered by username.
+func (s *Service) List(ctx context.Context, opts ListOptions) ([]*User, error) {
+	return s.repo.List(ctx, opts)
+}
+
+// Update applies the set fields of input to the user with id.
+func (s *Service) Update(ctx context.Context, id string, input UpdateInput) (*User, error) {
+	u, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Email != nil {
+		u.Email = strings.TrimSpace(*input.Email)
+	}
+	if input.Roles != nil {
+		if u.Roles, err = normalizeRoles(*input.Roles); err != nil {
+			return nil, err
+		}
+	}
+	if input.Disabled != nil {
+		u.Disabled = *input.Disabled
+	}
+	if input.Password != nil {
+		if err := validatePassword(*input.Password); err != nil {
+			return nil, err
+		}
+		if u.PasswordHash, err = s.hasher.Hash(*input.Password); err != nil {
+			return nil, err
+		}
+	}
+	u.UpdatedAt = time.Now().UTC()
+	if err := s.repo.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Delete removes the user with id.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	return s.repo.Delete(ctx, id)
+}
+
+/*
+Authenticate returns the user with username if password matches its hash and the user is not disabled.
+If the hash was created with other argon2id parameters than the current ones, it is replaced by a hash with the current parameters.
+A failed rehash is logged, but does not fail the login.
+
+Parameters:
+  - ctx: The context of the login.
+  - username: The username of the user.
+  - password: The password of the user.
+
+Returns:
+  - *User: The authenticated user.
+  - error: ErrInvalidCredentials if the user does not exist, the password is wrong or the user is disabled, or an error of the repository.
+*/
+func (s *Service) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	u, err := s.repo.GetByUsername(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		_, _, _ = s.hasher.Verify(password, s.dummyHash)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	match, rehash, err := s.hasher.Verify(password, u.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+	if !match || u.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+	if rehash {
+		s.rehash(ctx, u, password)
+	}
+	return u, nil
+}
+
+// rehash replaces the password hash of u by a hash with the current parameters.
+// Only the hash is written, and only if it was not changed since u was read, so concurrent updates of the user are not overwritten.
+func (s *Service) rehash(ctx context.Context, u *User, password string) {
+	hash, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repo.UpdatePasswordHash(ctx, u.ID, u.PasswordHash, hash)
+	}
+	if err != nil {
+		s.logger.Warn("rehashing password failed", "user_id", u.ID, "error", err.Error())
+		return
+	}
+	u.PasswordHash = hash
+	s.logger.Info("password rehashed with current parameters", "user_id", u.ID)
+}
+
+// validatePassword returns a ValidationError if password is too short.
+func validatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return &ValidationError{Field: "password", Reason: fmt.Sprintf("must be at least %d characters long", MinPasswordLength)}
+	}
+	return nil
+}
+
+// normalizeRoles returns the trimmed distinct roles, or a ValidationError if a role is empty or contains a comma.
+func normalizeRoles(roles []string) ([]string, error) {
+	normalized := make([]string, 0, len(roles))
+	seen := make(map[string]struct{}, len(roles))
+	for _, r := range roles {
+		r = strings.TrimSpace(r)
+		if r == "" || strings.Contains(r, ",") {
+			return nil, &ValidationError{Field: "roles", Reason: fmt.Sprintf("invalid role %q", r)}
+		}
+		if _, ok := seen[r]; ok {
+			continue
+		}
+		seen[r] = struct{}{}
+		normalized = append(normalized, r)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,173 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// testParams are cheap argon2id parameters, so the tests do not spend time hashing.
+var testParams = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+// newTestService returns a Service of repo hashing with params.
+func newTestService(t *testing.T, repo Repository, params Argon2Params) *Service {
+	t.Helper()
+	svc, err := NewService(repo, NewHasher(params), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+// stringPtr returns a pointer to s.
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestServiceCreateValidates(t *testing.T) {
+	svc := newTestService(t, NewMemoryRepository(), testParams)
+	ctx := context.Background()
+
+	for name, input := range map[string]CreateInput{
+		"empty username": {Username: "  ", Password: "long enough"},
+		"short password": {Username: "alice", Password: "short"},
+		"empty role":     {Username: "alice", Password: "long enough", Roles: []string{" "}},
+		"comma in role":  {Username: "alice", Password: "long enough", Roles: []string{"a,b"}},
+	} {
+		var verr *ValidationError
+		if _, err := svc.Create(ctx, input); !errors.As(err, &verr) {
+			t.Errorf("%s: got %v, want a ValidationError", name, err)
+		}
+	}
+
+	u, err := svc.Create(ctx, CreateInput{Username: " alice ", Password: "long enough", Roles: []string{"b", " a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username != "alice" {
+		t.Errorf("got username %q, want it trimmed", u.Username)
+	}
+	if len(u.Roles) != 2 || u.Roles[0] != "b" || u.Roles[1] != "a" {
+		t.Errorf("got roles %v, want [b a]", u.Roles)
+	}
+	if u.PasswordHash == "long enough" || u.PasswordHash == "" {
+		t.Errorf("password stored unhashed: %q", u.PasswordHash)
+	}
+	if _, err := svc.Create(ctx, CreateInput{Username: "alice", Password: "long enough"}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("duplicate username: got %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestServiceAuthenticate(t *testing.T) {
+	svc := newTestService(t, NewMemoryRepository(), testParams)
+	ctx := context.Background()
+
+	u, err := svc.Create(ctx, CreateInput{Username: "alice", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.Authenticate(ctx, "alice", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != u.ID {
+		t.Errorf("authenticated %q, want %q", got.ID, u.ID)
+	}
+
+	for name, login := range map[string][2]string{
+		"wrong password": {"alice", "wrong horse"},
+		"unknown user":   {"bob", "correct horse"},
+	} {
+		if _, err := svc.Authenticate(ctx, login[0], login[1]); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: got %v, want ErrInvalidCredentials", name, err)
+		}
+	}
+
+	disabled := true
+	if _, err := svc.Update(ctx, u.ID, UpdateInput{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Authenticate(ctx, "alice", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("disabled user: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestServiceAuthenticateRehashes(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	old := newTestService(t, repo, testParams)
+	u, err := old.Create(ctx, CreateInput{Username: "alice", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := newTestService(t, repo, Argon2Params{Memory: 128, Iterations: 1, Parallelism: 1})
+	if _, err := svc.Authenticate(ctx, "alice", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _, _, err := decodeHash(stored.PasswordHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Memory != 128 {
+		t.Errorf("hash not replaced: memory %d, want 128", params.Memory)
+	}
+	if !stored.UpdatedAt.Equal(u.UpdatedAt) {
+		t.Errorf("rehash changed UpdatedAt from %v to %v", u.UpdatedAt, stored.UpdatedAt)
+	}
+}
+
+func TestServiceRehashKeepsConcurrentUpdates(t *testing.T) {
+	repo := NewMemoryRepository()
+	ctx := context.Background()
+	svc := newTestService(t, repo, testParams)
+	u, err := svc.Create(ctx, CreateInput{Username: "alice", Email: "old@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an admin changes the email and password between the read and the rehash of a login
+	stale, err := repo.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Update(ctx, u.ID, UpdateInput{Email: stringPtr("new@example.com"), Password: stringPtr("battery staple")}); err != nil {
+		t.Fatal(err)
+	}
+	svc.rehash(ctx, stale, "correct horse")
+
+	stored, err := repo.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Email != "new@example.com" {
+		t.Errorf("rehash overwrote the email: %q", stored.Email)
+	}
+	if _, err := svc.Authenticate(ctx, "alice", "battery staple"); err != nil {
+		t.Errorf("rehash overwrote the new password: %v", err)
+	}
+
+	// without a concurrent change, only the hash is replaced
+	fresh, err := repo.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldHash := fresh.PasswordHash
+	svc.rehash(ctx, fresh, "battery staple")
+	stored, err = repo.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.PasswordHash == oldHash {
+		t.Error("password hash not replaced")
+	}
+	if stored.Email != "new@example.com" || !stored.UpdatedAt.Equal(fresh.UpdatedAt) {
+		t.Errorf("rehash changed other fields: %+v", stored)
+	}
+}
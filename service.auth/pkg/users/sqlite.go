@@ -0,0 +1,219 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLiteDriver is the name of the database/sql driver SQLite databases are opened with.
+// The driver is registered by building with the sqlite tag, see sqlite_driver.go.
+const SQLiteDriver string = "sqlite3"
+
+// sqliteSchema creates the users table if it does not exist.
+const sqliteSchema string = `
+CREATE TABLE IF NOT EXISTS users (
+	id            TEXT PRIMARY KEY,
+	username      TEXT NOT NULL UNIQUE,
+	email         TEXT NOT NULL DEFAULT '',
+	password_hash TEXT NOT NULL,
+	roles         TEXT NOT NULL DEFAULT '',
+	disabled      INTEGER NOT NULL DEFAULT 0,
+	created_at    INTEGER NOT NULL,
+	updated_at    INTEGER NOT NULL
+)`
+
+// sqliteColumns are the selected columns in the order scanned by scanUser.
+const sqliteColumns string = "id, username, email, password_hash, roles, disabled, created_at, updated_at"
+
+// SQLiteRepository is an implementation of Repository storing the users in a SQLite database. It is meant for small deployments.
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+// interface guard
+var _ Repository = (*SQLiteRepository)(nil)
+
+/*
+NewSQLiteRepository opens the SQLite database at path and creates the users table if it does not exist.
+
+Parameters:
+  - ctx: The context of the schema creation.
+  - path: The path of the database file, or a data source name understood by the driver.
+
+Returns:
+  - *SQLiteRepository: The created repository, closed with Close.
+  - error: An error if the database could not be opened or migrated, e.g. if the binary was built without the sqlite tag.
+
+Example usage:
+
+	repo, err := users.NewSQLiteRepository(ctx, "/var/lib/auth-service/users.db")
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+*/
+func NewSQLiteRepository(ctx context.Context, path string) (*SQLiteRepository, error) {
+	db, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, serializing the connections avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("creating users table: %w", err)
+	}
+	return &SQLiteRepository{db: db}, nil
+}
+
+// Close closes the database.
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
+// PingContext checks the connection to the database. It implements health.Pinger.
+func (r *SQLiteRepository) PingContext(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Create implements Repository.
+func (r *SQLiteRepository) Create(ctx context.Context, user *User) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO users ("+sqliteColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.Username, user.Email, user.PasswordHash, joinRoles(user.Roles), user.Disabled,
+		user.CreatedAt.UnixNano(), user.UpdatedAt.UnixNano(),
+	)
+	return uniqueError(err)
+}
+
+// Get implements Repository.
+func (r *SQLiteRepository) Get(ctx context.Context, id string) (*User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+sqliteColumns+" FROM users WHERE id = ?", id))
+}
+
+// GetByUsername implements Repository.
+func (r *SQLiteRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+sqliteColumns+" FROM users WHERE username = ?", username))
+}
+
+// List implements Repository.
+func (r *SQLiteRepository) List(ctx context.Context, opts ListOptions) ([]*User, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		// a negative limit means no limit in SQLite
+		limit = -1
+	}
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+sqliteColumns+" FROM users ORDER BY username LIMIT ? OFFSET ?",
+		limit, max(opts.Offset, 0),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []*User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// Update implements Repository.
+func (r *SQLiteRepository) Update(ctx context.Context, user *User) error {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE users SET username = ?, email = ?, password_hash = ?, roles = ?, disabled = ?, updated_at = ? WHERE id = ?",
+		user.Username, user.Email, user.PasswordHash, joinRoles(user.Roles), user.Disabled, user.UpdatedAt.UnixNano(), user.ID,
+	)
+	if err != nil {
+		return uniqueError(err)
+	}
+	return affected(res)
+}
+
+// UpdatePasswordHash implements Repository.
+func (r *SQLiteRepository) UpdatePasswordHash(ctx context.Context, id, oldHash, newHash string) error {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE users SET password_hash = ? WHERE id = ? AND password_hash = ?",
+		newHash, id, oldHash,
+	)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// Delete implements Repository.
+func (r *SQLiteRepository) Delete(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser scans a row of sqliteColumns into a user.
+func scanUser(s scanner) (*User, error) {
+	var (
+		u                    User
+		roles                string
+		createdAt, updatedAt int64
+	)
+	err := s.Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &roles, &u.Disabled, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.Roles = splitRoles(roles)
+	u.CreatedAt = time.Unix(0, createdAt).UTC()
+	u.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return &u, nil
+}
+
+// affected returns ErrNotFound if res did not affect any row.
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// uniqueError returns ErrAlreadyExists if err is a violation of a unique constraint.
+// The SQLite drivers do not share an error type, but all report the message of SQLite.
+func uniqueError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// joinRoles returns the roles as a comma separated list.
+func joinRoles(roles []string) string {
+	return strings.Join(roles, ",")
+}
+
+// splitRoles returns the roles of a comma separated list.
+func splitRoles(roles string) []string {
+	if roles == "" {
+		return []string{}
+	}
+	return strings.Split(roles, ",")
+}
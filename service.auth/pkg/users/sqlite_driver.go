@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package users
+
+// The SQLite driver, registered as SQLiteDriver. It is only linked into binaries built with the sqlite tag,
+// so the default build does not depend on cgo: CGO_ENABLED=1 go build -tags sqlite .
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,35 @@
+//go:build sqlite
+
+package users
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteRepository(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.db")
+	repo, err := NewSQLiteRepository(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRepository(t, repo)
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the users survive reopening the database
+	repo, err = NewSQLiteRepository(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if err := repo.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByUsername(ctx, "alicia"); err != nil {
+		t.Errorf("user lost after reopening: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Package users contains the users of the auth service: the User model, the Repository they are stored in,
+the argon2id password hashing and the Service implementing the user management and password authentication.
+*/
+package users
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned if a user does not exist.
+	ErrNotFound = errors.New("user not found")
+	// ErrAlreadyExists is returned if a user with the same username already exists.
+	ErrAlreadyExists = errors.New("user already exists")
+	// ErrInvalidCredentials is returned if the username or password of a login is wrong or the user is disabled.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// The names of the Repository implementations.
+const (
+	StoreMemory string = "memory"
+	StoreSQLite string = "sqlite"
+)
+
+// User is a struct that represents a user of the auth service.
+type User struct {
+	// ID is the immutable unique ID of the user.
+	ID string `json:"id"`
+	// Username is the unique name the user logs in with.
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
+	// PasswordHash is the argon2id hash of the password in the PHC string format. It is never serialized.
+	PasswordHash string `json:"-"`
+	// Roles are issued in the roles claim of the tokens of the user. They are not interpreted by the auth service,
+	// the users are managed with the admin API, which is authorized by its own token.
+	Roles []string `json:"roles"`
+	// Disabled users can not log in.
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ListOptions is a struct that represents the page of users returned by Repository.List.
+type ListOptions struct {
+	Offset int
+	// Limit is the maximum number of returned users. If zero, all users are returned.
+	Limit int
+}
+
+// Repository is an interface that represents the storage of the users.
+// Implementations return ErrNotFound for missing users and ErrAlreadyExists for duplicate usernames.
+type Repository interface {
+	// Create stores a new user.
+	Create(ctx context.Context, user *User) error
+	// Get returns the user with id.
+	Get(ctx context.Context, id string) (*User, error)
+	// GetByUsername returns the user with username.
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	// List returns a page of the users ordered by username.
+	List(ctx context.Context, opts ListOptions) ([]*User, error)
+	// Update replaces the stored user with the same ID.
+	Update(ctx context.Context, user *User) error
+	// UpdatePasswordHash replaces the password hash of the user with id if it still is oldHash, leaving all other fields unchanged.
+	// It returns ErrNotFound if the user does not exist or its password hash was changed meanwhile.
+	UpdatePasswordHash(ctx context.Context, id, oldHash, newHash string) error
+	// Delete removes the user with id.
+	Delete(ctx context.Context, id string) error
+}